@@ -1,74 +1,38 @@
-package controller
+package controller_test
 
 import (
+	"bookstore/controller"
 	"bookstore/dao"
 	"bookstore/model"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strings"
 	"testing"
 )
 
-// ensureProjectRootWD 尝试将当前工作目录切换到项目根目录（包含 views 目录）
-func ensureProjectRootWD(t *testing.T) func() {
-	origWd, err := os.Getwd()
-	if err != nil {
-		t.Fatalf("获取当前工作目录失败: %v", err)
-	}
-	// 如果当前目录已经包含 views，则不需要切换
-	if fi, err := os.Stat(filepath.Join(origWd, "views")); err == nil && fi.IsDir() {
-		return func() { os.Chdir(origWd) }
-	}
-	// 尝试父目录
-	parent := filepath.Dir(origWd)
-	if fi, err := os.Stat(filepath.Join(parent, "views")); err == nil && fi.IsDir() {
-		if err := os.Chdir(parent); err != nil {
-			t.Fatalf("切换到项目根目录失败: %v", err)
-		}
-		return func() { os.Chdir(origWd) }
-	}
-	// 尝试再向上一级（防护）
-	grand := filepath.Dir(parent)
-	if fi, err := os.Stat(filepath.Join(grand, "views")); err == nil && fi.IsDir() {
-		if err := os.Chdir(grand); err != nil {
-			t.Fatalf("切换到项目根目录失败: %v", err)
-		}
-		return func() { os.Chdir(origWd) }
-	}
-	// 如果未找到 views，则仍然返回恢复函数，但记录警告
-	t.Log("未能在父目录中找到 views 目录；保持当前工作目录不变")
-	return func() { os.Chdir(origWd) }
-}
-
 // TestBookControllerOperations 测试图书控制器操作
 func TestBookControllerOperations(t *testing.T) {
-	// 切换到项目根目录以确保模板路径可用
-	restore := ensureProjectRootWD(t)
-	defer restore()
-
 	// 测试获取分页图书
 	t.Run("测试获取分页图书", func(t *testing.T) {
 		// 先添加一些测试图书
 		testBooks := []*model.Book{
-			{Title: "控制器测试图书1", Author: "控制器测试作者1", Price: 10.00, Sales: 0, Stock: 10, ImgPath: "/static/img/ctrl1.jpg"},
-			{Title: "控制器测试图书2", Author: "控制器测试作者2", Price: 20.00, Sales: 0, Stock: 10, ImgPath: "/static/img/ctrl2.jpg"},
-			{Title: "控制器测试图书3", Author: "控制器测试作者3", Price: 30.00, Sales: 0, Stock: 10, ImgPath: "/static/img/ctrl3.jpg"},
-			{Title: "控制器测试图书4", Author: "控制器测试作者4", Price: 40.00, Sales: 0, Stock: 10, ImgPath: "/static/img/ctrl4.jpg"},
+			{Title: "控制器测试图书1", Author: "控制器测试作者1", Price: model.Cents(1000), Sales: 0, Stock: 10, ImgPath: "/static/img/ctrl1.jpg"},
+			{Title: "控制器测试图书2", Author: "控制器测试作者2", Price: model.Cents(2000), Sales: 0, Stock: 10, ImgPath: "/static/img/ctrl2.jpg"},
+			{Title: "控制器测试图书3", Author: "控制器测试作者3", Price: model.Cents(3000), Sales: 0, Stock: 10, ImgPath: "/static/img/ctrl3.jpg"},
+			{Title: "控制器测试图书4", Author: "控制器测试作者4", Price: model.Cents(4000), Sales: 0, Stock: 10, ImgPath: "/static/img/ctrl4.jpg"},
 		}
 
 		var addedBookIDs []int
 		for _, book := range testBooks {
-			err := dao.AddBook(book)
+			err := dao.AddBook(t.Context(), book)
 			if err != nil {
 				t.Errorf("添加测试图书失败: %v", err)
 			}
 
 			// 获取刚添加的图书ID
-			books, err := dao.GetBooks()
+			books, err := dao.GetBooks(t.Context())
 			if err != nil {
 				t.Errorf("获取图书列表失败: %v", err)
 			}
@@ -95,7 +59,7 @@ func TestBookControllerOperations(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		GetPageBooks(rr, req)
+		handle(controller.GetPageBooks, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -113,20 +77,20 @@ func TestBookControllerOperations(t *testing.T) {
 	t.Run("测试获取带价格筛选的图书", func(t *testing.T) {
 		// 先添加一些不同价格的测试图书
 		testBooks := []*model.Book{
-			{Title: "低价控制器测试图书", Author: "低价控制器测试作者", Price: 10.00, Sales: 0, Stock: 10, ImgPath: "/static/img/low_ctrl.jpg"},
-			{Title: "中价控制器测试图书", Author: "中价控制器测试作者", Price: 50.00, Sales: 0, Stock: 10, ImgPath: "/static/img/mid_ctrl.jpg"},
-			{Title: "高价控制器测试图书", Author: "高价控制器测试作者", Price: 100.00, Sales: 0, Stock: 10, ImgPath: "/static/img/high_ctrl.jpg"},
+			{Title: "低价控制器测试图书", Author: "低价控制器测试作者", Price: model.Cents(1000), Sales: 0, Stock: 10, ImgPath: "/static/img/low_ctrl.jpg"},
+			{Title: "中价控制器测试图书", Author: "中价控制器测试作者", Price: model.Cents(5000), Sales: 0, Stock: 10, ImgPath: "/static/img/mid_ctrl.jpg"},
+			{Title: "高价控制器测试图书", Author: "高价控制器测试作者", Price: model.Cents(10000), Sales: 0, Stock: 10, ImgPath: "/static/img/high_ctrl.jpg"},
 		}
 
 		var addedBookIDs []int
 		for _, book := range testBooks {
-			err := dao.AddBook(book)
+			err := dao.AddBook(t.Context(), book)
 			if err != nil {
 				t.Errorf("添加测试图书失败: %v", err)
 			}
 
 			// 获取刚添加的图书ID
-			books, err := dao.GetBooks()
+			books, err := dao.GetBooks(t.Context())
 			if err != nil {
 				t.Errorf("获取图书列表失败: %v", err)
 			}
@@ -153,7 +117,7 @@ func TestBookControllerOperations(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		GetPageBooksByPrice(rr, req)
+		handle(controller.GetPageBooksByPrice, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -173,19 +137,19 @@ func TestBookControllerOperations(t *testing.T) {
 		testBook := &model.Book{
 			Title:   "删除控制器测试图书",
 			Author:  "删除控制器测试作者",
-			Price:   99.99,
+			Price:   model.Cents(9999),
 			Sales:   0,
 			Stock:   10,
 			ImgPath: "/static/img/delete_ctrl.jpg",
 		}
 
-		err := dao.AddBook(testBook)
+		err := dao.AddBook(t.Context(), testBook)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取刚添加的图书ID
-		books, err := dao.GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -209,7 +173,7 @@ func TestBookControllerOperations(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		DeleteBook(rr, req)
+		handle(controller.DeleteBook, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -217,7 +181,7 @@ func TestBookControllerOperations(t *testing.T) {
 		}
 
 		// 验证图书是否已被删除
-		deletedBook, err := dao.GetBookByID(testBookID)
+		deletedBook, err := dao.GetBookByID(t.Context(), testBookID)
 		if err != nil {
 			t.Errorf("查询已删除的图书时发生错误: %v", err)
 		}
@@ -233,19 +197,19 @@ func TestBookControllerOperations(t *testing.T) {
 		testBook := &model.Book{
 			Title:   "更新页面测试图书",
 			Author:  "更新页面测试作者",
-			Price:   88.88,
+			Price:   model.Cents(8888),
 			Sales:   0,
 			Stock:   10,
 			ImgPath: "/static/img/update_page.jpg",
 		}
 
-		err := dao.AddBook(testBook)
+		err := dao.AddBook(t.Context(), testBook)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取刚添加的图书ID
-		books, err := dao.GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -274,7 +238,7 @@ func TestBookControllerOperations(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		ToUpdateBookPage(rr, req)
+		handle(controller.ToUpdateBookPage, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -294,7 +258,7 @@ func TestBookControllerOperations(t *testing.T) {
 		}
 
 		rr2 := httptest.NewRecorder()
-		ToUpdateBookPage(rr2, req2)
+		handle(controller.ToUpdateBookPage, rr2, req2)
 
 		// 检查响应状态码
 		if rr2.Code != http.StatusOK {
@@ -321,7 +285,7 @@ func TestBookControllerOperations(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		UpdateOrAddBook(rr, req)
+		handle(controller.UpdateOrAddBook, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -329,7 +293,7 @@ func TestBookControllerOperations(t *testing.T) {
 		}
 
 		// 验证图书是否成功添加
-		books, err := dao.GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -363,7 +327,7 @@ func TestBookControllerOperations(t *testing.T) {
 		req2.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr2 := httptest.NewRecorder()
 
-		UpdateOrAddBook(rr2, req2)
+		handle(controller.UpdateOrAddBook, rr2, req2)
 
 		// 检查响应状态码
 		if rr2.Code != http.StatusOK {
@@ -371,7 +335,7 @@ func TestBookControllerOperations(t *testing.T) {
 		}
 
 		// 验证图书是否成功更新
-		updatedBook, err := dao.GetBookByID(fmt.Sprintf("%d", addedBookID))
+		updatedBook, err := dao.GetBookByID(t.Context(), fmt.Sprintf("%d", addedBookID))
 		if err != nil {
 			t.Errorf("查询更新后的图书失败: %v", err)
 		}
@@ -384,8 +348,8 @@ func TestBookControllerOperations(t *testing.T) {
 			t.Errorf("图书作者未正确更新，期望: 更新后的HTTP测试作者, 实际: %s", updatedBook.Author)
 		}
 
-		if updatedBook.Price != 88.88 {
-			t.Errorf("图书价格未正确更新，期望: 88.88, 实际: %.2f", updatedBook.Price)
+		if updatedBook.Price != model.Cents(8888) {
+			t.Errorf("图书价格未正确更新，期望: 88.88, 实际: %s", updatedBook.Price)
 		}
 
 		// 清理测试图书
@@ -395,10 +359,6 @@ func TestBookControllerOperations(t *testing.T) {
 
 // TestBookControllerDataValidation 测试图书控制器数据验证
 func TestBookControllerDataValidation(t *testing.T) {
-	// 切换到项目根目录以确保模板路径可用
-	restore := ensureProjectRootWD(t)
-	defer restore()
-
 	tests := []struct {
 		name        string
 		formData    url.Values
@@ -428,7 +388,7 @@ func TestBookControllerDataValidation(t *testing.T) {
 				"sales":  {"0"},
 				"stock":  {"10"},
 			},
-			expectError: false, // 这里假设系统允许空标题，实际项目中可能需要验证
+			expectError: true, // 书名不能为空
 			description: "测试空标题图书",
 		},
 		{
@@ -467,7 +427,7 @@ func TestBookControllerDataValidation(t *testing.T) {
 				"sales":  {"0"},
 				"stock":  {"10"},
 			},
-			expectError: false, // 这里假设系统允许负价格，实际项目中可能需要验证
+			expectError: true, // 价格不能小于0
 			description: "测试负价格图书",
 		},
 		{
@@ -493,7 +453,7 @@ func TestBookControllerDataValidation(t *testing.T) {
 				"sales":  {"0"},
 				"stock":  {"-10"},
 			},
-			expectError: false, // 这里假设系统允许负库存，实际项目中可能需要验证
+			expectError: true, // 库存不能小于0
 			description: "测试负库存图书",
 		},
 	}
@@ -508,7 +468,7 @@ func TestBookControllerDataValidation(t *testing.T) {
 			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 			rr := httptest.NewRecorder()
 
-			UpdateOrAddBook(rr, req)
+			handle(controller.UpdateOrAddBook, rr, req)
 
 			if tt.expectError {
 				if rr.Code == http.StatusOK {
@@ -519,7 +479,7 @@ func TestBookControllerDataValidation(t *testing.T) {
 					t.Errorf("不期望返回错误，但返回了错误: %d, %s", rr.Code, tt.description)
 				} else {
 					// 验证图书是否成功添加
-					books, err := dao.GetBooks()
+					books, err := dao.GetBooks(t.Context())
 					if err != nil {
 						t.Errorf("获取图书列表失败: %v", err)
 					}
@@ -545,10 +505,6 @@ func TestBookControllerDataValidation(t *testing.T) {
 
 // TestBookControllerConcurrentOperations 测试图书控制器并发操作
 func TestBookControllerConcurrentOperations(t *testing.T) {
-	// 切换到项目根目录以确保模板路径可用
-	restore := ensureProjectRootWD(t)
-	defer restore()
-
 	// 测试并发添加图书
 	t.Run("测试并发添加图书", func(t *testing.T) {
 		done := make(chan bool, 10)
@@ -561,7 +517,7 @@ func TestBookControllerConcurrentOperations(t *testing.T) {
 				formData.Set("bookId", "0")
 				formData.Set("title", fmt.Sprintf("并发控制器测试图书%d", index))
 				formData.Set("author", fmt.Sprintf("并发控制器测试作者%d", index))
-				formData.Set("price", fmt.Sprintf("%.2f", float64(index*10)))
+				formData.Set("price", fmt.Sprintf("%d", index*10))
 				formData.Set("sales", "0")
 				formData.Set("stock", "10")
 
@@ -574,7 +530,7 @@ func TestBookControllerConcurrentOperations(t *testing.T) {
 				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 				rr := httptest.NewRecorder()
 
-				UpdateOrAddBook(rr, req)
+				handle(controller.UpdateOrAddBook, rr, req)
 
 				if rr.Code != http.StatusOK {
 					t.Errorf("并发添加图书失败，状态码: %d", rr.Code)
@@ -588,7 +544,7 @@ func TestBookControllerConcurrentOperations(t *testing.T) {
 		}
 
 		// 清理测试图书
-		books, err := dao.GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -609,19 +565,19 @@ func TestBookControllerConcurrentOperations(t *testing.T) {
 		testBook := &model.Book{
 			Title:   "并发查询控制器测试图书",
 			Author:  "并发查询控制器测试作者",
-			Price:   99.99,
+			Price:   model.Cents(9999),
 			Sales:   0,
 			Stock:   10,
 			ImgPath: "/static/img/concurrent_query_ctrl.jpg",
 		}
 
-		err := dao.AddBook(testBook)
+		err := dao.AddBook(t.Context(), testBook)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取测试图书ID
-		books, err := dao.GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -651,7 +607,7 @@ func TestBookControllerConcurrentOperations(t *testing.T) {
 				}
 
 				rr := httptest.NewRecorder()
-				GetPageBooks(rr, req)
+				handle(controller.GetPageBooks, rr, req)
 
 				if rr.Code != http.StatusOK {
 					t.Errorf("并发查询图书失败，状态码: %d", rr.Code)
@@ -675,7 +631,7 @@ func BenchmarkGetPageBooks(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		req, _ := http.NewRequest("GET", "/getPageBooks?pageNo=1", nil)
 		rr := httptest.NewRecorder()
-		GetPageBooks(rr, req)
+		handle(controller.GetPageBooks, rr, req)
 	}
 }
 
@@ -684,16 +640,12 @@ func BenchmarkGetPageBooksByPrice(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		req, _ := http.NewRequest("GET", "/getPageBooksByPrice?pageNo=1&min=10&max=100", nil)
 		rr := httptest.NewRecorder()
-		GetPageBooksByPrice(rr, req)
+		handle(controller.GetPageBooksByPrice, rr, req)
 	}
 }
 
 // TestBookControllerEdgeCases 测试图书控制器边界情况
 func TestBookControllerEdgeCases(t *testing.T) {
-	// 切换到项目根目录以确保模板路径可用
-	restore := ensureProjectRootWD(t)
-	defer restore()
-
 	// 测试无效的页码参数
 	t.Run("测试无效页码参数", func(t *testing.T) {
 		// 测试负数页码
@@ -703,7 +655,7 @@ func TestBookControllerEdgeCases(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		GetPageBooks(rr, req)
+		handle(controller.GetPageBooks, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -720,7 +672,7 @@ func TestBookControllerEdgeCases(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		GetPageBooksByPrice(rr, req)
+		handle(controller.GetPageBooksByPrice, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -736,7 +688,7 @@ func TestBookControllerEdgeCases(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		DeleteBook(rr, req)
+		handle(controller.DeleteBook, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -752,7 +704,7 @@ func TestBookControllerEdgeCases(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		DeleteBook(rr, req)
+		handle(controller.DeleteBook, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -768,11 +720,11 @@ func TestBookControllerEdgeCases(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		ToUpdateBookPage(rr, req)
+		handle(controller.ToUpdateBookPage, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 图书不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -794,11 +746,11 @@ func TestBookControllerEdgeCases(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		UpdateOrAddBook(rr, req)
+		handle(controller.UpdateOrAddBook, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 价格、销量和库存不是数字或者书名为空时返回400
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码400，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -814,38 +766,11 @@ func TestBookControllerEdgeCases(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		UpdateOrAddBook(rr, req)
-
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
-		}
-	})
-}
-
-// TestBookControllerTemplateFunctions 测试图书控制器模板相关函数
-func TestBookControllerTemplateFunctions(t *testing.T) {
-	// 测试findViewsDir函数
-	t.Run("测试findViewsDir函数", func(t *testing.T) {
-		viewsDir := findViewsDir()
-		if viewsDir == "" {
-			t.Error("findViewsDir应该返回非空字符串")
-		}
-	})
-
-	// 测试parseTemplateFiles函数
-	t.Run("测试parseTemplateFiles函数", func(t *testing.T) {
-		// 测试不存在的模板文件 - 使用defer recover来捕获panic
-		defer func() {
-			if r := recover(); r != nil {
-				// 预期的panic，测试通过
-				t.Logf("预期的panic被捕获: %v", r)
-			}
-		}()
+		handle(controller.UpdateOrAddBook, rr, req)
 
-		tmpl := parseTemplateFiles("nonexistent.html")
-		if tmpl == nil {
-			t.Error("parseTemplateFiles应该返回非nil模板")
+		// 价格、销量和库存不是数字或者书名为空时返回400
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码400，实际得到: %d", rr.Code)
 		}
 	})
 }
@@ -1,6 +1,8 @@
-package controller
+package controller_test
 
 import (
+	"bookstore/config"
+	"bookstore/controller"
 	"bookstore/dao"
 	"bookstore/model"
 	"bookstore/utils"
@@ -24,7 +26,7 @@ func TestCartControllerOperations(t *testing.T) {
 	cleanupTestUser(t, testUserID)
 
 	// 创建测试用户
-	err := dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+	err := dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 	if err != nil {
 		// 如果用户已存在，尝试删除后重新创建
 		if strings.Contains(err.Error(), "Duplicate entry") {
@@ -35,7 +37,7 @@ func TestCartControllerOperations(t *testing.T) {
 			sqlStr := "DELETE FROM users WHERE username = ?"
 			utils.Db.Exec(sqlStr, testUserName)
 			// 重新创建
-			err = dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+			err = dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 			if err != nil {
 				t.Errorf("重新创建测试用户失败: %v", err)
 			}
@@ -45,7 +47,7 @@ func TestCartControllerOperations(t *testing.T) {
 	}
 
 	// 获取实际创建的用户ID
-	user, err := dao.CheckUserName(testUserName)
+	user, err := dao.CheckUserName(t.Context(), testUserName)
 	if err != nil {
 		t.Errorf("获取测试用户失败: %v", err)
 	}
@@ -57,20 +59,20 @@ func TestCartControllerOperations(t *testing.T) {
 	}()
 
 	testBooks := []*model.Book{
-		{Title: "控制器购物车测试图书1", Author: "控制器购物车测试作者1", Price: 10.00, Sales: 0, Stock: 100, ImgPath: "/static/img/ctrl_cart1.jpg"},
-		{Title: "控制器购物车测试图书2", Author: "控制器购物车测试作者2", Price: 20.00, Sales: 0, Stock: 100, ImgPath: "/static/img/ctrl_cart2.jpg"},
-		{Title: "控制器购物车测试图书3", Author: "控制器购物车测试作者3", Price: 30.00, Sales: 0, Stock: 100, ImgPath: "/static/img/ctrl_cart3.jpg"},
+		{Title: "控制器购物车测试图书1", Author: "控制器购物车测试作者1", Price: model.Cents(1000), Sales: 0, Stock: 100, ImgPath: "/static/img/ctrl_cart1.jpg"},
+		{Title: "控制器购物车测试图书2", Author: "控制器购物车测试作者2", Price: model.Cents(2000), Sales: 0, Stock: 100, ImgPath: "/static/img/ctrl_cart2.jpg"},
+		{Title: "控制器购物车测试图书3", Author: "控制器购物车测试作者3", Price: model.Cents(3000), Sales: 0, Stock: 100, ImgPath: "/static/img/ctrl_cart3.jpg"},
 	}
 
 	var addedBookIDs []int
 	for _, book := range testBooks {
-		err := dao.AddBook(book)
+		err := dao.AddBook(t.Context(), book)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取刚添加的图书ID
-		books, err := dao.GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -98,13 +100,9 @@ func TestCartControllerOperations(t *testing.T) {
 
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -127,7 +125,7 @@ func TestCartControllerOperations(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		AddBook2Cart(rr, req)
+		handle(controller.AddBook2Cart, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -141,7 +139,7 @@ func TestCartControllerOperations(t *testing.T) {
 		}
 
 		// 验证购物车是否成功创建
-		cart, err := dao.GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -166,13 +164,9 @@ func TestCartControllerOperations(t *testing.T) {
 
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -194,7 +188,7 @@ func TestCartControllerOperations(t *testing.T) {
 		req1.AddCookie(cookie)
 
 		rr1 := httptest.NewRecorder()
-		AddBook2Cart(rr1, req1)
+		handle(controller.AddBook2Cart, rr1, req1)
 
 		// 第二次添加同一商品
 		req2, err := http.NewRequest("GET", "/addBook2Cart?bookId="+fmt.Sprintf("%d", addedBookIDs[0]), nil)
@@ -205,7 +199,7 @@ func TestCartControllerOperations(t *testing.T) {
 		req2.AddCookie(cookie)
 
 		rr2 := httptest.NewRecorder()
-		AddBook2Cart(rr2, req2)
+		handle(controller.AddBook2Cart, rr2, req2)
 
 		// 检查响应状态码
 		if rr2.Code != http.StatusOK {
@@ -213,7 +207,7 @@ func TestCartControllerOperations(t *testing.T) {
 		}
 
 		// 验证购物车中的商品数量
-		cart, err := dao.GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -235,13 +229,9 @@ func TestCartControllerOperations(t *testing.T) {
 	t.Run("测试获取购物车信息", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -264,7 +254,7 @@ func TestCartControllerOperations(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		GetCartInfo(rr, req)
+		handle(controller.GetCartInfo, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -282,13 +272,9 @@ func TestCartControllerOperations(t *testing.T) {
 	t.Run("测试更新购物项", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -310,10 +296,10 @@ func TestCartControllerOperations(t *testing.T) {
 		req1.AddCookie(cookie)
 
 		rr1 := httptest.NewRecorder()
-		AddBook2Cart(rr1, req1)
+		handle(controller.AddBook2Cart, rr1, req1)
 
 		// 获取购物车中的购物项ID
-		cart, err := dao.GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -338,7 +324,7 @@ func TestCartControllerOperations(t *testing.T) {
 		req2.AddCookie(cookie)
 
 		rr2 := httptest.NewRecorder()
-		UpdateCartItem(rr2, req2)
+		handle(controller.UpdateCartItem, rr2, req2)
 
 		// 检查响应状态码
 		if rr2.Code != http.StatusOK {
@@ -352,7 +338,7 @@ func TestCartControllerOperations(t *testing.T) {
 		}
 
 		// 验证购物项数量是否已更新
-		updatedCart, err := dao.GetCartByUserID(testUserID)
+		updatedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取更新后的购物车失败: %v", err)
 		}
@@ -366,13 +352,9 @@ func TestCartControllerOperations(t *testing.T) {
 	t.Run("测试删除购物项", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -394,10 +376,10 @@ func TestCartControllerOperations(t *testing.T) {
 		req1.AddCookie(cookie)
 
 		rr1 := httptest.NewRecorder()
-		AddBook2Cart(rr1, req1)
+		handle(controller.AddBook2Cart, rr1, req1)
 
 		// 获取购物车中的购物项ID
-		cart, err := dao.GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -417,7 +399,7 @@ func TestCartControllerOperations(t *testing.T) {
 		req2.AddCookie(cookie)
 
 		rr2 := httptest.NewRecorder()
-		DeleteCartItem(rr2, req2)
+		handle(controller.DeleteCartItem, rr2, req2)
 
 		// 检查响应状态码
 		if rr2.Code != http.StatusOK {
@@ -425,7 +407,7 @@ func TestCartControllerOperations(t *testing.T) {
 		}
 
 		// 验证购物项是否已被删除
-		updatedCart, err := dao.GetCartByUserID(testUserID)
+		updatedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取更新后的购物车失败: %v", err)
 		}
@@ -439,13 +421,9 @@ func TestCartControllerOperations(t *testing.T) {
 	t.Run("测试清空购物车", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -467,10 +445,10 @@ func TestCartControllerOperations(t *testing.T) {
 		req1.AddCookie(cookie)
 
 		rr1 := httptest.NewRecorder()
-		AddBook2Cart(rr1, req1)
+		handle(controller.AddBook2Cart, rr1, req1)
 
 		// 获取购物车ID
-		cart, err := dao.GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -490,7 +468,7 @@ func TestCartControllerOperations(t *testing.T) {
 		req2.AddCookie(cookie)
 
 		rr2 := httptest.NewRecorder()
-		DeleteCart(rr2, req2)
+		handle(controller.DeleteCart, rr2, req2)
 
 		// 检查响应状态码
 		if rr2.Code != http.StatusOK {
@@ -498,7 +476,7 @@ func TestCartControllerOperations(t *testing.T) {
 		}
 
 		// 验证购物车是否已被清空
-		emptyCart, err := dao.GetCartByUserID(testUserID)
+		emptyCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			// 购物车不存在是正常的，说明清空成功
 			if !strings.Contains(err.Error(), "no rows in result set") {
@@ -509,7 +487,7 @@ func TestCartControllerOperations(t *testing.T) {
 		}
 	})
 
-	// 测试未登录状态
+	// 测试未登录状态，图书添加到游客购物车中，游客购物车的id保存在Cookie中
 	t.Run("测试未登录状态", func(t *testing.T) {
 		// 创建请求（不添加Cookie）
 		req, err := http.NewRequest("GET", "/addBook2Cart?bookId="+fmt.Sprintf("%d", addedBookIDs[0]), nil)
@@ -518,7 +496,7 @@ func TestCartControllerOperations(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		AddBook2Cart(rr, req)
+		handle(controller.AddBook2Cart, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -527,8 +505,27 @@ func TestCartControllerOperations(t *testing.T) {
 
 		// 检查响应内容
 		body := rr.Body.String()
-		if !strings.Contains(body, "请先登录") {
-			t.Error("未登录时应该返回'请先登录'")
+		if !strings.Contains(body, "添加到了购物车") {
+			t.Error("响应内容应该包含'添加到了购物车'")
+		}
+
+		// 检查游客购物车
+		var guestCartID string
+		for _, c := range rr.Result().Cookies() {
+			if c.Name == config.Default().Cart.GuestCookieName {
+				guestCartID = c.Value
+			}
+		}
+		if guestCartID == "" {
+			t.Fatal("未登录时应该设置游客购物车的Cookie")
+		}
+		defer func() { _ = dao.DeleteCartByCartID(t.Context(), guestCartID) }()
+		guestCart, err := dao.GetCartByCartID(t.Context(), guestCartID)
+		if err != nil {
+			t.Fatalf("获取游客购物车失败: %v", err)
+		}
+		if guestCart.UserID != 0 || len(guestCart.CartItems) != 1 {
+			t.Errorf("游客购物车不正确: %+v", guestCart)
 		}
 	})
 }
@@ -540,13 +537,13 @@ func TestCartControllerDataValidation(t *testing.T) {
 	testUserPassword := "testpassword887"
 
 	// 创建测试用户
-	err := dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+	err := dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 	if err != nil {
 		t.Errorf("创建测试用户失败: %v", err)
 	}
 
 	// 获取实际创建的用户ID
-	user, err := dao.CheckUserName(testUserName)
+	user, err := dao.CheckUserName(t.Context(), testUserName)
 	if err != nil {
 		t.Errorf("获取测试用户失败: %v", err)
 	}
@@ -557,7 +554,8 @@ func TestCartControllerDataValidation(t *testing.T) {
 		cleanupTestUser(t, testUserID)
 	}()
 
-	testBookID := 1
+	testBookID := addTestBook(t)
+	defer cleanupTestBook(t, testBookID)
 
 	tests := []struct {
 		name        string
@@ -574,19 +572,19 @@ func TestCartControllerDataValidation(t *testing.T) {
 		{
 			name:        "空图书ID",
 			bookID:      "",
-			expectError: false, // 这里假设系统允许空图书ID，实际项目中可能需要验证
+			expectError: true, // 图书不存在时返回404
 			description: "测试空图书ID",
 		},
 		{
 			name:        "无效图书ID",
 			bookID:      "999999",
-			expectError: false, // 这里假设系统允许无效图书ID，实际项目中可能需要验证
+			expectError: true, // 图书不存在时返回404
 			description: "测试无效图书ID",
 		},
 		{
 			name:        "负图书ID",
 			bookID:      "-1",
-			expectError: false, // 这里假设系统允许负图书ID，实际项目中可能需要验证
+			expectError: true, // 图书不存在时返回404
 			description: "测试负图书ID",
 		},
 	}
@@ -595,13 +593,9 @@ func TestCartControllerDataValidation(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// 创建Session
 			sessionID := utils.CreateUUID()
-			session := &model.Session{
-				SessionID: sessionID,
-				UserName:  "testuser",
-				UserID:    testUserID,
-			}
+			session := newTestSession(sessionID, testUserID, "testuser")
 
-			err := dao.AddSession(session)
+			err := dao.AddSession(t.Context(), session)
 			if err != nil {
 				t.Errorf("添加Session失败: %v", err)
 			}
@@ -624,7 +618,7 @@ func TestCartControllerDataValidation(t *testing.T) {
 			req.AddCookie(cookie)
 
 			rr := httptest.NewRecorder()
-			AddBook2Cart(rr, req)
+			handle(controller.AddBook2Cart, rr, req)
 
 			if tt.expectError {
 				if rr.Code == http.StatusOK {
@@ -649,7 +643,7 @@ func TestCartControllerConcurrentOperations(t *testing.T) {
 	cleanupTestUser(t, 886)
 
 	// 创建测试用户
-	err := dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+	err := dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 	if err != nil {
 		// 如果用户已存在，尝试删除后重新创建
 		if strings.Contains(err.Error(), "Duplicate entry") {
@@ -660,7 +654,7 @@ func TestCartControllerConcurrentOperations(t *testing.T) {
 			sqlStr := "DELETE FROM users WHERE username = ?"
 			utils.Db.Exec(sqlStr, testUserName)
 			// 重新创建
-			err = dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+			err = dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 			if err != nil {
 				// 如果还是失败，跳过这个测试
 				t.Skipf("无法创建测试用户，跳过测试: %v", err)
@@ -671,7 +665,7 @@ func TestCartControllerConcurrentOperations(t *testing.T) {
 	}
 
 	// 获取实际创建的用户ID
-	user, err := dao.CheckUserName(testUserName)
+	user, err := dao.CheckUserName(t.Context(), testUserName)
 	if err != nil {
 		t.Errorf("获取测试用户失败: %v", err)
 	}
@@ -682,19 +676,16 @@ func TestCartControllerConcurrentOperations(t *testing.T) {
 		cleanupTestUser(t, testUserID)
 	}()
 
-	testBookID := 1
+	testBookID := addTestBook(t)
+	defer cleanupTestBook(t, testBookID)
 
 	// 测试并发添加商品到购物车
 	t.Run("测试并发添加商品到购物车", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -722,7 +713,7 @@ func TestCartControllerConcurrentOperations(t *testing.T) {
 				req.AddCookie(cookie)
 
 				rr := httptest.NewRecorder()
-				AddBook2Cart(rr, req)
+				handle(controller.AddBook2Cart, rr, req)
 
 				if rr.Code != http.StatusOK {
 					t.Errorf("并发添加商品失败，状态码: %d", rr.Code)
@@ -736,7 +727,7 @@ func TestCartControllerConcurrentOperations(t *testing.T) {
 		}
 
 		// 验证购物车状态
-		cart, err := dao.GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -750,13 +741,9 @@ func TestCartControllerConcurrentOperations(t *testing.T) {
 	t.Run("测试并发更新购物项", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -778,10 +765,10 @@ func TestCartControllerConcurrentOperations(t *testing.T) {
 		req1.AddCookie(cookie)
 
 		rr1 := httptest.NewRecorder()
-		AddBook2Cart(rr1, req1)
+		handle(controller.AddBook2Cart, rr1, req1)
 
 		// 获取购物车中的购物项ID
-		cart, err := dao.GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -812,7 +799,7 @@ func TestCartControllerConcurrentOperations(t *testing.T) {
 				req.AddCookie(cookie)
 
 				rr := httptest.NewRecorder()
-				UpdateCartItem(rr, req)
+				handle(controller.UpdateCartItem, rr, req)
 
 				if rr.Code != http.StatusOK {
 					t.Errorf("并发更新购物项失败，状态码: %d", rr.Code)
@@ -826,7 +813,7 @@ func TestCartControllerConcurrentOperations(t *testing.T) {
 		}
 
 		// 验证购物车状态
-		updatedCart, err := dao.GetCartByUserID(testUserID)
+		updatedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -844,13 +831,13 @@ func BenchmarkAddBook2Cart(b *testing.B) {
 	testUserPassword := "testpassword885"
 
 	// 创建测试用户
-	err := dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+	err := dao.SaveUser(b.Context(), testUserName, testUserPassword, testUserEmail)
 	if err != nil {
 		b.Errorf("创建测试用户失败: %v", err)
 	}
 
 	// 获取实际创建的用户ID
-	user, err := dao.CheckUserName(testUserName)
+	user, err := dao.CheckUserName(b.Context(), testUserName)
 	if err != nil {
 		b.Errorf("获取测试用户失败: %v", err)
 	}
@@ -858,21 +845,18 @@ func BenchmarkAddBook2Cart(b *testing.B) {
 
 	// 准备测试数据
 	sessionID := utils.CreateUUID()
-	session := &model.Session{
-		SessionID: sessionID,
-		UserName:  testUserName,
-		UserID:    testUserID,
-	}
+	session := newTestSession(sessionID, testUserID, testUserName)
 
-	dao.AddSession(session)
+	dao.AddSession(b.Context(), session)
 
 	defer func() {
-		cleanupTestSession(nil, sessionID)
-		cleanupTestCart(nil, testUserID)
-		cleanupTestUser(nil, testUserID)
+		cleanupTestSession(b, sessionID)
+		cleanupTestCart(b, testUserID)
+		cleanupTestUser(b, testUserID)
 	}()
 
-	testBookID := 1
+	testBookID := addTestBook(b)
+	defer cleanupTestBook(b, testBookID)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -883,7 +867,7 @@ func BenchmarkAddBook2Cart(b *testing.B) {
 		}
 		req.AddCookie(cookie)
 		rr := httptest.NewRecorder()
-		AddBook2Cart(rr, req)
+		handle(controller.AddBook2Cart, rr, req)
 	}
 }
 
@@ -894,13 +878,13 @@ func TestCartControllerEdgeCases(t *testing.T) {
 	testUserPassword := "testpassword883"
 
 	// 创建测试用户
-	err := dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+	err := dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 	if err != nil {
 		t.Errorf("创建测试用户失败: %v", err)
 	}
 
 	// 获取实际创建的用户ID
-	user, err := dao.CheckUserName(testUserName)
+	user, err := dao.CheckUserName(t.Context(), testUserName)
 	if err != nil {
 		t.Errorf("获取测试用户失败: %v", err)
 	}
@@ -915,13 +899,9 @@ func TestCartControllerEdgeCases(t *testing.T) {
 	t.Run("测试无效图书ID添加到购物车", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUserName,
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, testUserName)
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -943,11 +923,11 @@ func TestCartControllerEdgeCases(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		AddBook2Cart(rr, req)
+		handle(controller.AddBook2Cart, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 图书、购物项或者购物车不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -955,13 +935,9 @@ func TestCartControllerEdgeCases(t *testing.T) {
 	t.Run("测试空图书ID添加到购物车", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUserName,
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, testUserName)
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -983,11 +959,11 @@ func TestCartControllerEdgeCases(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		AddBook2Cart(rr, req)
+		handle(controller.AddBook2Cart, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 图书、购物项或者购物车不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -995,13 +971,9 @@ func TestCartControllerEdgeCases(t *testing.T) {
 	t.Run("测试无效购物项ID更新", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUserName,
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, testUserName)
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -1028,11 +1000,11 @@ func TestCartControllerEdgeCases(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		UpdateCartItem(rr, req)
+		handle(controller.UpdateCartItem, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 图书、购物项或者购物车不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -1040,13 +1012,9 @@ func TestCartControllerEdgeCases(t *testing.T) {
 	t.Run("测试无效购物项ID删除", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUserName,
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, testUserName)
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -1068,11 +1036,11 @@ func TestCartControllerEdgeCases(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		DeleteCartItem(rr, req)
+		handle(controller.DeleteCartItem, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 图书、购物项或者购物车不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -1080,13 +1048,9 @@ func TestCartControllerEdgeCases(t *testing.T) {
 	t.Run("测试无效购物车ID清空", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUserName,
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, testUserName)
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -1108,11 +1072,11 @@ func TestCartControllerEdgeCases(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		DeleteCart(rr, req)
+		handle(controller.DeleteCart, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 图书、购物项或者购物车不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -1120,13 +1084,9 @@ func TestCartControllerEdgeCases(t *testing.T) {
 	t.Run("测试空购物车ID清空", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUserName,
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, testUserName)
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -1148,11 +1108,11 @@ func TestCartControllerEdgeCases(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		DeleteCart(rr, req)
+		handle(controller.DeleteCart, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 图书、购物项或者购物车不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 }
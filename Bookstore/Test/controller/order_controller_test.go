@@ -1,6 +1,7 @@
-package controller
+package controller_test
 
 import (
+	"bookstore/controller"
 	"bookstore/dao"
 	"bookstore/model"
 	"bookstore/utils"
@@ -9,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestOrderControllerFlow 测试订单控制器流程
@@ -19,21 +21,20 @@ func TestOrderControllerFlow(t *testing.T) {
 	testUserPassword := "testpassword666"
 
 	// 先清理可能存在的测试用户
-	cleanupTestUser(t, 666)
+	cleanupTestUser(t, testUserName)
 
 	// 创建测试用户
-	err := dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+	err := dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 	if err != nil {
 		// 如果用户已存在，尝试删除后重新创建
 		if strings.Contains(err.Error(), "Duplicate entry") {
 			// 先清理相关数据
-			cleanupTestCart(t, 666)
 			cleanupTestSession(t, "")
 			// 删除用户
 			sqlStr := "DELETE FROM users WHERE username = ?"
 			utils.Db.Exec(sqlStr, testUserName)
 			// 重新创建
-			err = dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+			err = dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 			if err != nil {
 				t.Errorf("重新创建测试用户失败: %v", err)
 			}
@@ -43,7 +44,7 @@ func TestOrderControllerFlow(t *testing.T) {
 	}
 
 	// 获取实际创建的用户ID
-	user, err := dao.CheckUserName(testUserName)
+	user, err := dao.CheckUserName(t.Context(), testUserName)
 	if err != nil {
 		t.Errorf("获取测试用户失败: %v", err)
 	}
@@ -55,19 +56,19 @@ func TestOrderControllerFlow(t *testing.T) {
 	}()
 
 	testBooks := []*model.Book{
-		{Title: "控制器订单测试图书1", Author: "控制器订单测试作者1", Price: 10.00, Sales: 0, Stock: 100, ImgPath: "/static/img/ctrl_order1.jpg"},
-		{Title: "控制器订单测试图书2", Author: "控制器订单测试作者2", Price: 20.00, Sales: 0, Stock: 100, ImgPath: "/static/img/ctrl_order2.jpg"},
+		{Title: "控制器订单测试图书1", Author: "控制器订单测试作者1", Price: model.Cents(1000), Sales: 0, Stock: 100, ImgPath: "/static/img/ctrl_order1.jpg"},
+		{Title: "控制器订单测试图书2", Author: "控制器订单测试作者2", Price: model.Cents(2000), Sales: 0, Stock: 100, ImgPath: "/static/img/ctrl_order2.jpg"},
 	}
 
 	var addedBookIDs []int
 	for _, book := range testBooks {
-		err := dao.AddBook(book)
+		err := dao.AddBook(t.Context(), book)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取刚添加的图书ID
-		books, err := dao.GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -92,13 +93,9 @@ func TestOrderControllerFlow(t *testing.T) {
 	t.Run("测试创建订单", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -120,7 +117,7 @@ func TestOrderControllerFlow(t *testing.T) {
 		req1.AddCookie(cookie)
 
 		rr1 := httptest.NewRecorder()
-		AddBook2Cart(rr1, req1)
+		handle(controller.AddBook2Cart, rr1, req1)
 
 		// 添加第二个商品到购物车
 		req2, err := http.NewRequest("GET", "/addBook2Cart?bookId="+fmt.Sprintf("%d", addedBookIDs[1]), nil)
@@ -131,10 +128,10 @@ func TestOrderControllerFlow(t *testing.T) {
 		req2.AddCookie(cookie)
 
 		rr2 := httptest.NewRecorder()
-		AddBook2Cart(rr2, req2)
+		handle(controller.AddBook2Cart, rr2, req2)
 
 		// 验证购物车是否创建成功
-		cart, err := dao.GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -148,7 +145,7 @@ func TestOrderControllerFlow(t *testing.T) {
 		}
 
 		// 测试结账（创建订单）
-		req3, err := http.NewRequest("GET", "/checkout", nil)
+		req3, err := newCheckoutRequest()
 		if err != nil {
 			t.Fatalf("创建请求失败: %v", err)
 		}
@@ -156,7 +153,7 @@ func TestOrderControllerFlow(t *testing.T) {
 		req3.AddCookie(cookie)
 
 		rr3 := httptest.NewRecorder()
-		Checkout(rr3, req3)
+		handle(controller.Checkout, rr3, req3)
 
 		// 检查响应状态码
 		if rr3.Code != http.StatusOK {
@@ -170,13 +167,13 @@ func TestOrderControllerFlow(t *testing.T) {
 		}
 
 		// 验证订单是否成功创建
-		orders, err := dao.GetMyOrders(testUserID)
+		orders, err := dao.GetMyOrders(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取我的订单失败: %v", err)
 		}
 
 		if len(orders) == 0 {
-			t.Error("订单应该存在")
+			t.Fatal("订单应该存在")
 		}
 
 		// 验证订单信息
@@ -185,12 +182,12 @@ func TestOrderControllerFlow(t *testing.T) {
 			t.Errorf("订单用户ID不匹配，期望: %d, 实际: %d", testUserID, order.UserID)
 		}
 
-		if order.State != 0 {
-			t.Errorf("订单状态不匹配，期望: 0, 实际: %d", order.State)
+		if order.State != model.OrderStatePaid {
+			t.Errorf("订单状态不匹配，期望: %d, 实际: %d", model.OrderStatePaid, order.State)
 		}
 
 		// 验证购物车是否被清空
-		emptyCart, err := dao.GetCartByUserID(testUserID)
+		emptyCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			// 购物车不存在是正常的，说明清空成功
 			if !strings.Contains(err.Error(), "no rows in result set") {
@@ -201,7 +198,7 @@ func TestOrderControllerFlow(t *testing.T) {
 		}
 
 		// 验证库存扣减
-		updatedBook, err := dao.GetBookByID(fmt.Sprintf("%d", addedBookIDs[0]))
+		updatedBook, err := dao.GetBookByID(t.Context(), fmt.Sprintf("%d", addedBookIDs[0]))
 		if err != nil {
 			t.Errorf("获取更新后的图书失败: %v", err)
 		}
@@ -220,12 +217,12 @@ func TestOrderControllerFlow(t *testing.T) {
 			order := &model.Order{
 				OrderID:     orderID,
 				TotalCount:  int64(i + 1),
-				TotalAmount: float64((i + 1) * 10),
+				TotalAmount: model.Cents(int64(i+1) * 1000),
 				State:       int64(i % 3), // 0, 1, 2
 				UserID:      int64(testUserID),
 			}
 
-			err := dao.AddOrder(order)
+			err := dao.AddOrder(t.Context(), order)
 			if err != nil {
 				t.Errorf("添加订单失败: %v", err)
 			}
@@ -237,8 +234,11 @@ func TestOrderControllerFlow(t *testing.T) {
 			t.Fatalf("创建请求失败: %v", err)
 		}
 
+		// 只有店员和管理员可以查看所有订单
+		req.AddCookie(loginTestAdmin(t))
+
 		rr := httptest.NewRecorder()
-		GetOrders(rr, req)
+		handle(controller.GetOrders, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -256,13 +256,9 @@ func TestOrderControllerFlow(t *testing.T) {
 	t.Run("测试获取我的订单", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -284,7 +280,7 @@ func TestOrderControllerFlow(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		GetMyOrders(rr, req)
+		handle(controller.GetMyOrders, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -305,12 +301,12 @@ func TestOrderControllerFlow(t *testing.T) {
 		order := &model.Order{
 			OrderID:     orderID,
 			TotalCount:  1,
-			TotalAmount: 10.00,
+			TotalAmount: model.Cents(1000),
 			State:       0,
 			UserID:      int64(testUserID),
 		}
 
-		err := dao.AddOrder(order)
+		err := dao.AddOrder(t.Context(), order)
 		if err != nil {
 			t.Errorf("添加订单失败: %v", err)
 		}
@@ -318,15 +314,15 @@ func TestOrderControllerFlow(t *testing.T) {
 		// 创建订单项
 		orderItem := &model.OrderItem{
 			Count:   1,
-			Amount:  10.00,
+			Amount:  model.Cents(1000),
 			Title:   "测试图书",
 			Author:  "测试作者",
-			Price:   10.00,
+			Price:   model.Cents(1000),
 			ImgPath: "/static/img/test.jpg",
 			OrderID: orderID,
 		}
 
-		err = dao.AddOrderItem(orderItem)
+		err = dao.AddOrderItem(t.Context(), orderItem)
 		if err != nil {
 			t.Errorf("添加订单项失败: %v", err)
 		}
@@ -337,8 +333,11 @@ func TestOrderControllerFlow(t *testing.T) {
 			t.Fatalf("创建请求失败: %v", err)
 		}
 
+		// 管理员可以查看所有用户的订单
+		req.AddCookie(loginTestAdmin(t))
+
 		rr := httptest.NewRecorder()
-		GetOrderInfo(rr, req)
+		handle(controller.GetOrderInfo, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -359,24 +358,25 @@ func TestOrderControllerFlow(t *testing.T) {
 		order := &model.Order{
 			OrderID:     orderID,
 			TotalCount:  1,
-			TotalAmount: 10.00,
-			State:       0, // 未发货
+			TotalAmount: model.Cents(1000),
+			State:       model.OrderStatePaid, // 已付款，等待发货
 			UserID:      int64(testUserID),
 		}
 
-		err := dao.AddOrder(order)
+		err := dao.AddOrder(t.Context(), order)
 		if err != nil {
 			t.Errorf("添加订单失败: %v", err)
 		}
 
-		// 测试发货
+		// 测试发货，只有店员和管理员可以发货
 		req, err := http.NewRequest("GET", "/sendOrder?orderId="+orderID, nil)
 		if err != nil {
 			t.Fatalf("创建请求失败: %v", err)
 		}
+		req.AddCookie(loginTestAdmin(t))
 
 		rr := httptest.NewRecorder()
-		SendOrder(rr, req)
+		handle(controller.SendOrder, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -384,7 +384,7 @@ func TestOrderControllerFlow(t *testing.T) {
 		}
 
 		// 验证订单状态是否已更新
-		orders, err := dao.GetOrders()
+		orders, err := dao.GetOrders(t.Context())
 		if err != nil {
 			t.Errorf("获取订单列表失败: %v", err)
 		}
@@ -398,11 +398,11 @@ func TestOrderControllerFlow(t *testing.T) {
 		}
 
 		if foundOrder == nil {
-			t.Error("订单应该存在")
+			t.Fatal("订单应该存在")
 		}
 
-		if foundOrder.State != 1 {
-			t.Errorf("订单状态不匹配，期望: 1, 实际: %d", foundOrder.State)
+		if foundOrder.State != model.OrderStateShipped {
+			t.Errorf("订单状态不匹配，期望: %d, 实际: %d", model.OrderStateShipped, foundOrder.State)
 		}
 	})
 
@@ -413,25 +413,21 @@ func TestOrderControllerFlow(t *testing.T) {
 		order := &model.Order{
 			OrderID:     orderID,
 			TotalCount:  1,
-			TotalAmount: 10.00,
-			State:       1, // 已发货
+			TotalAmount: model.Cents(1000),
+			State:       model.OrderStateShipped, // 已发货
 			UserID:      int64(testUserID),
 		}
 
-		err := dao.AddOrder(order)
+		err := dao.AddOrder(t.Context(), order)
 		if err != nil {
 			t.Errorf("添加订单失败: %v", err)
 		}
 
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err = dao.AddSession(session)
+		err = dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -454,7 +450,7 @@ func TestOrderControllerFlow(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		TakeOrder(rr, req)
+		handle(controller.TakeOrder, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -462,7 +458,7 @@ func TestOrderControllerFlow(t *testing.T) {
 		}
 
 		// 验证订单状态是否已更新
-		orders, err := dao.GetOrders()
+		orders, err := dao.GetOrders(t.Context())
 		if err != nil {
 			t.Errorf("获取订单列表失败: %v", err)
 		}
@@ -476,11 +472,11 @@ func TestOrderControllerFlow(t *testing.T) {
 		}
 
 		if foundOrder == nil {
-			t.Error("订单应该存在")
+			t.Fatal("订单应该存在")
 		}
 
-		if foundOrder.State != 2 {
-			t.Errorf("订单状态不匹配，期望: 2, 实际: %d", foundOrder.State)
+		if foundOrder.State != model.OrderStateCompleted {
+			t.Errorf("订单状态不匹配，期望: %d, 实际: %d", model.OrderStateCompleted, foundOrder.State)
 		}
 	})
 }
@@ -492,21 +488,20 @@ func TestOrderControllerDataValidation(t *testing.T) {
 	testUserPassword := "testpassword665"
 
 	// 先清理可能存在的测试用户
-	cleanupTestUser(t, 665)
+	cleanupTestUser(t, testUserName)
 
 	// 创建测试用户
-	err := dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+	err := dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 	if err != nil {
 		// 如果用户已存在，尝试删除后重新创建
 		if strings.Contains(err.Error(), "Duplicate entry") {
 			// 先清理相关数据
-			cleanupTestCart(t, 665)
 			cleanupTestSession(t, "")
 			// 删除用户
 			sqlStr := "DELETE FROM users WHERE username = ?"
 			utils.Db.Exec(sqlStr, testUserName)
 			// 重新创建
-			err = dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+			err = dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 			if err != nil {
 				t.Errorf("重新创建测试用户失败: %v", err)
 			}
@@ -516,7 +511,7 @@ func TestOrderControllerDataValidation(t *testing.T) {
 	}
 
 	// 获取实际创建的用户ID
-	user, err := dao.CheckUserName(testUserName)
+	user, err := dao.CheckUserName(t.Context(), testUserName)
 	if err != nil {
 		t.Errorf("获取测试用户失败: %v", err)
 	}
@@ -527,6 +522,19 @@ func TestOrderControllerDataValidation(t *testing.T) {
 		cleanupTestUser(t, testUserID)
 	}()
 
+	// 正常的订单已经付款，可以发货和收货
+	err = dao.AddOrder(t.Context(), &model.Order{
+		OrderID:     "test_order_id",
+		CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+		TotalCount:  1,
+		TotalAmount: model.Cents(1000),
+		State:       model.OrderStatePaid,
+		UserID:      int64(testUserID),
+	})
+	if err != nil {
+		t.Fatalf("添加订单失败: %v", err)
+	}
+
 	tests := []struct {
 		name        string
 		orderID     string
@@ -542,13 +550,13 @@ func TestOrderControllerDataValidation(t *testing.T) {
 		{
 			name:        "空订单ID",
 			orderID:     "",
-			expectError: false, // 这里假设系统允许空订单ID，实际项目中可能需要验证
+			expectError: true, // 订单不存在时返回404
 			description: "测试空订单ID",
 		},
 		{
 			name:        "无效订单ID",
 			orderID:     "invalid_order_id",
-			expectError: false, // 这里假设系统允许无效订单ID，实际项目中可能需要验证
+			expectError: true, // 订单不存在时返回404
 			description: "测试无效订单ID",
 		},
 	}
@@ -557,13 +565,9 @@ func TestOrderControllerDataValidation(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// 创建Session
 			sessionID := utils.CreateUUID()
-			session := &model.Session{
-				SessionID: sessionID,
-				UserName:  "testuser",
-				UserID:    testUserID,
-			}
+			session := newTestSession(sessionID, testUserID, "testuser")
 
-			err := dao.AddSession(session)
+			err := dao.AddSession(t.Context(), session)
 			if err != nil {
 				t.Errorf("添加Session失败: %v", err)
 			}
@@ -572,14 +576,15 @@ func TestOrderControllerDataValidation(t *testing.T) {
 				cleanupTestSession(t, sessionID)
 			}()
 
-			// 测试发货
+			// 测试发货，只有店员和管理员可以发货
 			req1, err := http.NewRequest("GET", "/sendOrder?orderId="+tt.orderID, nil)
 			if err != nil {
 				t.Fatalf("创建请求失败: %v", err)
 			}
+			req1.AddCookie(loginTestAdmin(t))
 
 			rr1 := httptest.NewRecorder()
-			SendOrder(rr1, req1)
+			handle(controller.SendOrder, rr1, req1)
 
 			if tt.expectError {
 				if rr1.Code == http.StatusOK {
@@ -605,7 +610,7 @@ func TestOrderControllerDataValidation(t *testing.T) {
 			req2.AddCookie(cookie)
 
 			rr2 := httptest.NewRecorder()
-			TakeOrder(rr2, req2)
+			handle(controller.TakeOrder, rr2, req2)
 
 			if tt.expectError {
 				if rr2.Code == http.StatusOK {
@@ -627,21 +632,20 @@ func TestOrderControllerConcurrentOperations(t *testing.T) {
 	testUserPassword := "testpassword664"
 
 	// 先清理可能存在的测试用户
-	cleanupTestUser(t, 664)
+	cleanupTestUser(t, testUserName)
 
 	// 创建测试用户
-	err := dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+	err := dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 	if err != nil {
 		// 如果用户已存在，尝试删除后重新创建
 		if strings.Contains(err.Error(), "Duplicate entry") {
 			// 先清理相关数据
-			cleanupTestCart(t, 664)
 			cleanupTestSession(t, "")
 			// 删除用户
 			sqlStr := "DELETE FROM users WHERE username = ?"
 			utils.Db.Exec(sqlStr, testUserName)
 			// 重新创建
-			err = dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+			err = dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 			if err != nil {
 				// 如果还是失败，跳过这个测试
 				t.Skipf("无法创建测试用户，跳过测试: %v", err)
@@ -652,7 +656,7 @@ func TestOrderControllerConcurrentOperations(t *testing.T) {
 	}
 
 	// 获取实际创建的用户ID
-	user, err := dao.CheckUserName(testUserName)
+	user, err := dao.CheckUserName(t.Context(), testUserName)
 	if err != nil {
 		t.Errorf("获取测试用户失败: %v", err)
 	}
@@ -663,17 +667,16 @@ func TestOrderControllerConcurrentOperations(t *testing.T) {
 		cleanupTestUser(t, testUserID)
 	}()
 
+	testBookID := addTestBook(t)
+	defer cleanupTestBook(t, testBookID)
+
 	// 测试并发创建订单
 	t.Run("测试并发创建订单", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -689,7 +692,7 @@ func TestOrderControllerConcurrentOperations(t *testing.T) {
 				defer func() { done <- true }()
 
 				// 先添加商品到购物车
-				req1, err := http.NewRequest("GET", "/addBook2Cart?bookId=1", nil)
+				req1, err := http.NewRequest("GET", "/addBook2Cart?bookId="+fmt.Sprintf("%d", testBookID), nil)
 				if err != nil {
 					t.Errorf("创建请求失败: %v", err)
 					return
@@ -702,10 +705,10 @@ func TestOrderControllerConcurrentOperations(t *testing.T) {
 				req1.AddCookie(cookie)
 
 				rr1 := httptest.NewRecorder()
-				AddBook2Cart(rr1, req1)
+				handle(controller.AddBook2Cart, rr1, req1)
 
 				// 然后结账
-				req2, err := http.NewRequest("GET", "/checkout", nil)
+				req2, err := newCheckoutRequest()
 				if err != nil {
 					t.Errorf("创建请求失败: %v", err)
 					return
@@ -714,7 +717,7 @@ func TestOrderControllerConcurrentOperations(t *testing.T) {
 				req2.AddCookie(cookie)
 
 				rr2 := httptest.NewRecorder()
-				Checkout(rr2, req2)
+				handle(controller.Checkout, rr2, req2)
 
 				if rr2.Code != http.StatusOK {
 					t.Errorf("并发创建订单失败，状态码: %d", rr2.Code)
@@ -728,44 +731,43 @@ func TestOrderControllerConcurrentOperations(t *testing.T) {
 		}
 
 		// 验证订单创建
-		orders, err := dao.GetMyOrders(testUserID)
+		orders, err := dao.GetMyOrders(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取我的订单失败: %v", err)
 		}
 
-		if len(orders) < 10 {
-			t.Errorf("订单数量不匹配，期望: >=10, 实际: %d", len(orders))
+		// 结账时可能把其他请求加入的图书一起结算，所以订单数量不确定，但10本图书都要结算并且只能结算一次
+		totalCount := int64(0)
+		for _, order := range orders {
+			totalCount += order.TotalCount
+		}
+		if len(orders) == 0 || len(orders) > 10 || totalCount != 10 {
+			t.Errorf("订单不匹配，期望: 1到10个订单共10本图书, 实际: %d个订单共%d本图书", len(orders), totalCount)
 		}
 	})
 
-	// 测试并发更新订单状态
+	// 测试并发更新订单状态，同一个订单只能发货和收货一次，重复的请求返回409
 	t.Run("测试并发更新订单状态", func(t *testing.T) {
-		// 先创建一个订单
+		// 先创建一个已付款的订单
 		orderID := utils.CreateUUID()
 		order := &model.Order{
 			OrderID:     orderID,
 			TotalCount:  1,
-			TotalAmount: 10.00,
-			State:       0,
+			TotalAmount: model.Cents(1000),
+			State:       model.OrderStatePaid,
 			UserID:      int64(testUserID),
 		}
 
-		err := dao.AddOrder(order)
+		err := dao.AddOrder(t.Context(), order)
 		if err != nil {
 			t.Errorf("添加订单失败: %v", err)
 		}
 
-		done := make(chan bool, 10)
-
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  "testuser",
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, "testuser")
 
-		err = dao.AddSession(session)
+		err = dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -774,54 +776,50 @@ func TestOrderControllerConcurrentOperations(t *testing.T) {
 			cleanupTestSession(t, sessionID)
 		}()
 
-		for i := 0; i < 10; i++ {
-			go func(index int) {
-				defer func() { done <- true }()
-
-				// 测试发货
-				req1, err := http.NewRequest("GET", "/sendOrder?orderId="+orderID, nil)
-				if err != nil {
-					t.Errorf("创建请求失败: %v", err)
-					return
-				}
-
-				rr1 := httptest.NewRecorder()
-				SendOrder(rr1, req1)
-
-				if rr1.Code != http.StatusOK {
-					t.Errorf("并发发货失败，状态码: %d", rr1.Code)
-				}
-
-				// 测试收货
-				req2, err := http.NewRequest("GET", "/takeOrder?orderId="+orderID, nil)
-				if err != nil {
-					t.Errorf("创建请求失败: %v", err)
-					return
-				}
-
-				// 添加Cookie
-				cookie := &http.Cookie{
-					Name:  "user",
-					Value: sessionID,
+		// 添加Cookie
+		cookie := &http.Cookie{
+			Name:  "user",
+			Value: sessionID,
+		}
+		adminCookie := loginTestAdmin(t)
+
+		// concurrently 并发发送10次请求，返回成功的次数
+		concurrently := func(h func(http.ResponseWriter, *http.Request) error, target string, cookie *http.Cookie) int {
+			codes := make(chan int, 10)
+			for i := 0; i < 10; i++ {
+				go func() {
+					req := httptest.NewRequest("GET", target, nil)
+					req.AddCookie(cookie)
+					rr := httptest.NewRecorder()
+					handle(h, rr, req)
+					codes <- rr.Code
+				}()
+			}
+			var ok int
+			for i := 0; i < 10; i++ {
+				switch code := <-codes; code {
+				case http.StatusOK:
+					ok++
+				case http.StatusConflict:
+				default:
+					t.Errorf("并发更新订单状态失败，状态码: %d", code)
 				}
-				req2.AddCookie(cookie)
-
-				rr2 := httptest.NewRecorder()
-				TakeOrder(rr2, req2)
+			}
+			return ok
+		}
 
-				if rr2.Code != http.StatusOK {
-					t.Errorf("并发收货失败，状态码: %d", rr2.Code)
-				}
-			}(i)
+		// 测试发货
+		if ok := concurrently(controller.SendOrder, "/sendOrder?orderId="+orderID, adminCookie); ok != 1 {
+			t.Errorf("并发发货成功的次数不对，期望: 1, 实际: %d", ok)
 		}
 
-		// 等待所有goroutine完成
-		for i := 0; i < 10; i++ {
-			<-done
+		// 测试收货
+		if ok := concurrently(controller.TakeOrder, "/takeOrder?orderId="+orderID, cookie); ok != 1 {
+			t.Errorf("并发收货成功的次数不对，期望: 1, 实际: %d", ok)
 		}
 
 		// 验证订单状态
-		orders, err := dao.GetOrders()
+		orders, err := dao.GetOrders(t.Context())
 		if err != nil {
 			t.Errorf("获取订单列表失败: %v", err)
 		}
@@ -847,21 +845,20 @@ func BenchmarkCheckout(b *testing.B) {
 	testUserPassword := "testpassword663"
 
 	// 先清理可能存在的测试用户
-	cleanupTestUser(nil, 663)
+	cleanupTestUser(b, testUserName)
 
 	// 创建测试用户
-	err := dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+	err := dao.SaveUser(b.Context(), testUserName, testUserPassword, testUserEmail)
 	if err != nil {
 		// 如果用户已存在，尝试删除后重新创建
 		if strings.Contains(err.Error(), "Duplicate entry") {
 			// 先清理相关数据
-			cleanupTestCart(nil, 663)
-			cleanupTestSession(nil, "")
+			cleanupTestSession(b, "")
 			// 删除用户
 			sqlStr := "DELETE FROM users WHERE username = ?"
 			utils.Db.Exec(sqlStr, testUserName)
 			// 重新创建
-			err = dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+			err = dao.SaveUser(b.Context(), testUserName, testUserPassword, testUserEmail)
 			if err != nil {
 				b.Errorf("重新创建测试用户失败: %v", err)
 			}
@@ -871,7 +868,7 @@ func BenchmarkCheckout(b *testing.B) {
 	}
 
 	// 获取实际创建的用户ID
-	user, err := dao.CheckUserName(testUserName)
+	user, err := dao.CheckUserName(b.Context(), testUserName)
 	if err != nil {
 		b.Errorf("获取测试用户失败: %v", err)
 	}
@@ -879,44 +876,44 @@ func BenchmarkCheckout(b *testing.B) {
 
 	// 准备测试数据
 	sessionID := utils.CreateUUID()
-	session := &model.Session{
-		SessionID: sessionID,
-		UserName:  testUserName,
-		UserID:    testUserID,
-	}
+	session := newTestSession(sessionID, testUserID, testUserName)
 
-	dao.AddSession(session)
+	dao.AddSession(b.Context(), session)
 
 	// 添加商品到购物车
-	req1, _ := http.NewRequest("GET", "/addBook2Cart?bookId=1", nil)
+	testBookID := addTestBook(b)
+	req1, _ := http.NewRequest("GET", "/addBook2Cart?bookId="+fmt.Sprintf("%d", testBookID), nil)
 	cookie := &http.Cookie{
 		Name:  "user",
 		Value: sessionID,
 	}
 	req1.AddCookie(cookie)
 	rr1 := httptest.NewRecorder()
-	AddBook2Cart(rr1, req1)
+	handle(controller.AddBook2Cart, rr1, req1)
 
 	defer func() {
-		cleanupTestSession(nil, sessionID)
-		cleanupTestOrder(nil, testUserID)
+		cleanupTestSession(b, sessionID)
+		cleanupTestUser(b, testUserID)
+		cleanupTestBook(b, testBookID)
 	}()
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		req, _ := http.NewRequest("GET", "/checkout", nil)
+		req, _ := newCheckoutRequest()
 		req.AddCookie(cookie)
 		rr := httptest.NewRecorder()
-		Checkout(rr, req)
+		handle(controller.Checkout, rr, req)
 	}
 }
 
 func BenchmarkGetOrders(b *testing.B) {
+	adminCookie := loginTestAdmin(b)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		req, _ := http.NewRequest("GET", "/getOrders", nil)
+		req.AddCookie(adminCookie)
 		rr := httptest.NewRecorder()
-		GetOrders(rr, req)
+		handle(controller.GetOrders, rr, req)
 	}
 }
 
@@ -927,21 +924,20 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 	testUserPassword := "testpassword661"
 
 	// 先清理可能存在的测试用户
-	cleanupTestUser(t, 661)
+	cleanupTestUser(t, testUserName)
 
 	// 创建测试用户
-	err := dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+	err := dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 	if err != nil {
 		// 如果用户已存在，尝试删除后重新创建
 		if strings.Contains(err.Error(), "Duplicate entry") {
 			// 先清理相关数据
-			cleanupTestCart(t, 661)
 			cleanupTestSession(t, "")
 			// 删除用户
 			sqlStr := "DELETE FROM users WHERE username = ?"
 			utils.Db.Exec(sqlStr, testUserName)
 			// 重新创建
-			err = dao.SaveUser(testUserName, testUserPassword, testUserEmail)
+			err = dao.SaveUser(t.Context(), testUserName, testUserPassword, testUserEmail)
 			if err != nil {
 				t.Errorf("重新创建测试用户失败: %v", err)
 			}
@@ -951,7 +947,7 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 	}
 
 	// 获取实际创建的用户ID
-	user, err := dao.CheckUserName(testUserName)
+	user, err := dao.CheckUserName(t.Context(), testUserName)
 	if err != nil {
 		t.Errorf("获取测试用户失败: %v", err)
 	}
@@ -966,13 +962,9 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 	t.Run("测试空购物车结账", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUserName,
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, testUserName)
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -989,7 +981,7 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 			}
 		}()
 
-		req, err := http.NewRequest("GET", "/checkout", nil)
+		req, err := newCheckoutRequest()
 		if err != nil {
 			t.Fatalf("创建请求失败: %v", err)
 		}
@@ -1001,7 +993,7 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		Checkout(rr, req)
+		handle(controller.Checkout, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -1016,12 +1008,14 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 			t.Fatalf("创建请求失败: %v", err)
 		}
 
+		req.AddCookie(loginTestAdmin(t))
+
 		rr := httptest.NewRecorder()
-		GetOrderInfo(rr, req)
+		handle(controller.GetOrderInfo, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 订单不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -1032,12 +1026,14 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 			t.Fatalf("创建请求失败: %v", err)
 		}
 
+		req.AddCookie(loginTestAdmin(t))
+
 		rr := httptest.NewRecorder()
-		GetOrderInfo(rr, req)
+		handle(controller.GetOrderInfo, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 订单不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -1048,12 +1044,14 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 			t.Fatalf("创建请求失败: %v", err)
 		}
 
+		req.AddCookie(loginTestAdmin(t))
+
 		rr := httptest.NewRecorder()
-		SendOrder(rr, req)
+		handle(controller.SendOrder, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 订单不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -1064,12 +1062,14 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 			t.Fatalf("创建请求失败: %v", err)
 		}
 
+		req.AddCookie(loginTestAdmin(t))
+
 		rr := httptest.NewRecorder()
-		SendOrder(rr, req)
+		handle(controller.SendOrder, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 订单不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -1077,13 +1077,9 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 	t.Run("测试无效订单ID收货", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUserName,
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, testUserName)
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -1104,11 +1100,11 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		TakeOrder(rr, req)
+		handle(controller.TakeOrder, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 订单不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -1116,13 +1112,9 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 	t.Run("测试空订单ID收货", func(t *testing.T) {
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUserName,
-			UserID:    testUserID,
-		}
+		session := newTestSession(sessionID, testUserID, testUserName)
 
-		err := dao.AddSession(session)
+		err := dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -1143,11 +1135,11 @@ func TestOrderControllerEdgeCases(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		TakeOrder(rr, req)
+		handle(controller.TakeOrder, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 订单不存在时返回404
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("期望状态码404，实际得到: %d", rr.Code)
 		}
 	})
 }
@@ -0,0 +1,216 @@
+package controller_test
+
+import (
+	"bookstore/config"
+	"bookstore/controller"
+	"bookstore/dao"
+	"bookstore/model"
+	"bookstore/utils"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain 测试之前使用内存中的SQLite数据库初始化dao和处理器，没有配置在线支付，下单后直接是已付款的状态
+func TestMain(m *testing.M) {
+	cfg := config.Default()
+	cfg.Database.Driver = utils.DriverSQLite
+	if err := dao.Setup(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "初始化数据库失败：", err)
+		os.Exit(1)
+	}
+	if err := controller.Configure(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "初始化处理器失败：", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	dao.Close()
+	os.Exit(code)
+}
+
+// handle 调用处理器，和线上一样经过controller.LoadSession在请求的context中保存Session，
+// 处理器返回的错误由controller.HandlerFunc转换为响应
+func handle(h func(http.ResponseWriter, *http.Request) error, w http.ResponseWriter, r *http.Request) {
+	controller.LoadSession(controller.HandlerFunc(h)).ServeHTTP(w, r)
+}
+
+// newTestSession 为用户创建一个还在有效期内的Session
+func newTestSession(sessionID string, userID int, username string) *model.Session {
+	return dao.NewSession(sessionID, &model.User{ID: userID, Username: username}, time.Now())
+}
+
+// cleanupTestBook 清理测试图书
+func cleanupTestBook(t testing.TB, bookID interface{}) {
+	var idStr string
+	switch v := bookID.(type) {
+	case int:
+		idStr = fmt.Sprintf("%d", v)
+	case string:
+		idStr = v
+	default:
+		t.Logf("不支持的bookID类型: %T", bookID)
+		return
+	}
+
+	// 先删除引用该图书的购物项，避免外键约束
+	_, _ = utils.Db.ExecContext(t.Context(), "DELETE FROM cart_items WHERE book_id = ?", idStr)
+	if err := dao.DeleteBook(t.Context(), idStr); err != nil {
+		t.Logf("清理测试图书失败: %v", err)
+	}
+}
+
+// cleanupTestSession 清理测试Session，sessionID为空时清理所有测试用户的Session
+func cleanupTestSession(t testing.TB, sessionID string) {
+	var err error
+	if sessionID == "" {
+		_, err = utils.Db.ExecContext(t.Context(), "DELETE FROM sessions WHERE username LIKE '%test%'")
+	} else {
+		err = dao.DeleteSession(t.Context(), sessionID)
+	}
+	if err != nil {
+		t.Logf("清理测试Session失败: %v", err)
+	}
+}
+
+// cleanupTestUser 清理测试用户以及属于他的会话、购物车、订单和收货地址 (支持用户名和用户ID)
+func cleanupTestUser(t testing.TB, userIdentifier interface{}) {
+	var userID int
+	switch v := userIdentifier.(type) {
+	case int:
+		userID = v
+	case string:
+		// 通过用户名获取用户ID
+		user, err := dao.CheckUserName(t.Context(), v)
+		if err != nil {
+			t.Logf("获取测试用户失败: %v", err)
+			return
+		}
+		userID = user.ID
+	default:
+		t.Logf("不支持的用户标识符类型: %T", userIdentifier)
+		return
+	}
+	if userID == 0 {
+		return
+	}
+
+	ctx := t.Context()
+	// 先清理购物车、Session和订单
+	cleanupTestCart(t, userID)
+	if err := dao.DeleteSessionsByUserID(ctx, userID); err != nil {
+		t.Logf("清理测试Session失败: %v", err)
+	}
+	cleanupTestOrder(t, userID)
+	_, _ = utils.Db.ExecContext(ctx, "DELETE FROM order_history WHERE actor_id = ?", userID)
+	_, _ = utils.Db.ExecContext(ctx, "DELETE FROM addresses WHERE user_id = ?", userID)
+
+	// 删除用户
+	if _, err := utils.Db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", userID); err != nil {
+		t.Logf("清理测试用户失败: %v", err)
+	}
+}
+
+// cleanupTestCart 清理测试购物车，DeleteCartByCartID会一起删除购物项
+func cleanupTestCart(t testing.TB, userID int) {
+	// 获取用户的购物车，没有购物车时不需要清理
+	cart, err := dao.GetCartByUserID(t.Context(), userID)
+	if err != nil {
+		return
+	}
+	if err := dao.DeleteCartByCartID(t.Context(), cart.CartID); err != nil {
+		t.Logf("删除购物车失败: %v", err)
+	}
+}
+
+// cleanupTestOrder 清理测试订单
+func cleanupTestOrder(t testing.TB, userID int) {
+	// 获取用户的订单
+	orders, err := dao.GetMyOrders(t.Context(), userID)
+	if err != nil {
+		t.Logf("获取订单失败: %v", err)
+		return
+	}
+
+	// 删除所有订单，先删除引用订单的订单项、订单历史和支付记录
+	for _, order := range orders {
+		for _, sqlStr := range []string{
+			"DELETE FROM order_items WHERE order_id = ?",
+			"DELETE FROM order_history WHERE order_id = ?",
+			"DELETE FROM payments WHERE order_id = ?",
+			"DELETE FROM orders WHERE id = ?",
+		} {
+			if _, err := utils.Db.ExecContext(t.Context(), sqlStr, order.OrderID); err != nil {
+				t.Logf("删除订单失败(%s): %v", sqlStr, err)
+			}
+		}
+	}
+}
+
+// setupTestUser 创建测试用户
+func setupTestUser(t testing.TB, username, password, email string) {
+	// 先清理可能存在的用户
+	cleanupTestUser(t, username)
+
+	if err := dao.SaveUser(t.Context(), username, password, email); err != nil {
+		t.Fatalf("创建测试用户失败: %v", err)
+	}
+}
+
+// addTestBook 添加一本库存充足的测试图书，返回图书的id
+func addTestBook(t testing.TB) int {
+	book := &model.Book{
+		Title:   fmt.Sprintf("控制器测试图书%d", time.Now().UnixNano()),
+		Author:  "控制器测试作者",
+		Price:   model.Cents(1000),
+		Stock:   1000,
+		ImgPath: "/static/img/default.jpg",
+	}
+	if err := dao.AddBook(t.Context(), book); err != nil {
+		t.Fatalf("添加测试图书失败: %v", err)
+	}
+	books, err := dao.GetBooks(t.Context())
+	if err != nil {
+		t.Fatalf("获取图书列表失败: %v", err)
+	}
+	for _, b := range books {
+		if b.Title == book.Title {
+			return b.ID
+		}
+	}
+	t.Fatalf("未找到添加的测试图书: %s", book.Title)
+	return 0
+}
+
+// testShipping 结账时在确认订单页面填写的收货地址
+var testShipping = url.Values{"recipient": {"张三"}, "phone": {"138-0000-0000"}, "address": {"北京市海淀区中关村大街1号"}}
+
+// newCheckoutRequest 创建确认订单后结账的请求，请求中带有收货地址
+func newCheckoutRequest() (*http.Request, error) {
+	req, err := http.NewRequest("POST", "/checkout", strings.NewReader(testShipping.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// loginTestAdmin 创建测试用的管理员并登录，返回带有Session id的Cookie
+func loginTestAdmin(t testing.TB) *http.Cookie {
+	ctx := t.Context()
+	if _, err := dao.SaveAdmin(ctx, "testadmin", "testadmin123", "testadmin@example.com"); err != nil {
+		t.Fatalf("创建测试管理员失败: %v", err)
+	}
+	admin, err := dao.CheckUserName(ctx, "testadmin")
+	if err != nil {
+		t.Fatalf("获取测试管理员失败: %v", err)
+	}
+	sessionID := utils.CreateUUID()
+	if err := dao.AddSession(ctx, dao.NewSession(sessionID, admin, time.Now())); err != nil {
+		t.Fatalf("添加管理员的Session失败: %v", err)
+	}
+	return &http.Cookie{Name: config.Default().Session.CookieName, Value: sessionID}
+}
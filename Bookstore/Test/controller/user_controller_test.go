@@ -1,8 +1,8 @@
-package controller
+package controller_test
 
 import (
+	"bookstore/controller"
 	"bookstore/dao"
-	"bookstore/model"
 	"bookstore/utils"
 	"net/http"
 	"net/http/httptest"
@@ -37,7 +37,7 @@ func TestLoginFlowHTTP(t *testing.T) {
 		rr := httptest.NewRecorder()
 
 		// 调用GetPageBooksByPrice处理器
-		GetPageBooksByPrice(rr, req)
+		handle(controller.GetPageBooksByPrice, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -70,7 +70,7 @@ func TestLoginFlowHTTP(t *testing.T) {
 		rr := httptest.NewRecorder()
 
 		// 调用Login处理器
-		Login(rr, req)
+		handle(controller.Login, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -96,7 +96,7 @@ func TestLoginFlowHTTP(t *testing.T) {
 			}
 
 			// 验证Session是否存在于数据库中
-			session, err := dao.GetSession(userCookie.Value)
+			session, err := dao.GetSession(t.Context(), userCookie.Value)
 			if err != nil {
 				t.Errorf("获取Session失败: %v", err)
 			}
@@ -127,7 +127,7 @@ func TestLoginFlowHTTP(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		Login(rr, req)
+		handle(controller.Login, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -152,21 +152,17 @@ func TestLoginFlowHTTP(t *testing.T) {
 	// 测试注销功能
 	t.Run("测试注销功能", func(t *testing.T) {
 		// 获取实际创建的用户ID
-		user, err := dao.CheckUserName(testUsername)
+		user, err := dao.CheckUserName(t.Context(), testUsername)
 		if err != nil {
 			t.Errorf("获取测试用户失败: %v", err)
 		}
 
 		// 首先创建一个有效的Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUsername,
-			UserID:    user.ID,
-		}
+		session := newTestSession(sessionID, user.ID, testUsername)
 
 		// 添加Session到数据库
-		err = dao.AddSession(session)
+		err = dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
@@ -187,7 +183,7 @@ func TestLoginFlowHTTP(t *testing.T) {
 		rr := httptest.NewRecorder()
 
 		// 调用Logout处理器
-		Logout(rr, req)
+		handle(controller.Logout, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -211,7 +207,7 @@ func TestLoginFlowHTTP(t *testing.T) {
 		}
 
 		// 验证Session是否已被删除
-		deletedSession, err := dao.GetSession(sessionID)
+		deletedSession, err := dao.GetSession(t.Context(), sessionID)
 		if err != nil {
 			t.Errorf("获取已删除的Session时发生错误: %v", err)
 		}
@@ -235,7 +231,7 @@ func TestLoginFlowHTTP(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		Login(rr, req)
+		handle(controller.Login, rr, req)
 
 		// 获取第一次登录的Cookie
 		cookies := rr.Result().Cookies()
@@ -262,7 +258,7 @@ func TestLoginFlowHTTP(t *testing.T) {
 
 		rr2 := httptest.NewRecorder()
 
-		Login(rr2, req2)
+		handle(controller.Login, rr2, req2)
 
 		// 检查第二次登录的响应
 		if rr2.Code != http.StatusOK {
@@ -288,7 +284,7 @@ func TestLoginFlowHTTP(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		Login(rr, req)
+		handle(controller.Login, rr, req)
 
 		// 检查响应内容是否包含错误信息
 		body := rr.Body.String()
@@ -311,7 +307,7 @@ func TestLoginFlowHTTP(t *testing.T) {
 		req2.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr2 := httptest.NewRecorder()
 
-		Login(rr2, req2)
+		handle(controller.Login, rr2, req2)
 
 		// 检查响应内容是否包含错误信息
 		body2 := rr2.Body.String()
@@ -337,27 +333,23 @@ func TestSessionManagement(t *testing.T) {
 	// 测试Session创建和验证
 	t.Run("测试Session创建和验证", func(t *testing.T) {
 		// 获取实际创建的用户ID
-		user, err := dao.CheckUserName(testUsername)
+		user, err := dao.CheckUserName(t.Context(), testUsername)
 		if err != nil {
 			t.Errorf("获取测试用户失败: %v", err)
 		}
 
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUsername,
-			UserID:    user.ID,
-		}
+		session := newTestSession(sessionID, user.ID, testUsername)
 
 		// 添加Session到数据库
-		err = dao.AddSession(session)
+		err = dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
 
 		// 验证Session存在
-		retrievedSession, err := dao.GetSession(sessionID)
+		retrievedSession, err := dao.GetSession(t.Context(), sessionID)
 		if err != nil {
 			t.Errorf("获取Session失败: %v", err)
 		}
@@ -399,33 +391,29 @@ func TestSessionManagement(t *testing.T) {
 	// 测试Session删除
 	t.Run("测试Session删除", func(t *testing.T) {
 		// 获取实际创建的用户ID
-		user, err := dao.CheckUserName(testUsername)
+		user, err := dao.CheckUserName(t.Context(), testUsername)
 		if err != nil {
 			t.Errorf("获取测试用户失败: %v", err)
 		}
 
 		// 创建Session
 		sessionID := utils.CreateUUID()
-		session := &model.Session{
-			SessionID: sessionID,
-			UserName:  testUsername,
-			UserID:    user.ID,
-		}
+		session := newTestSession(sessionID, user.ID, testUsername)
 
 		// 添加Session到数据库
-		err = dao.AddSession(session)
+		err = dao.AddSession(t.Context(), session)
 		if err != nil {
 			t.Errorf("添加Session失败: %v", err)
 		}
 
 		// 删除Session
-		err = dao.DeleteSession(sessionID)
+		err = dao.DeleteSession(t.Context(), sessionID)
 		if err != nil {
 			t.Errorf("删除Session失败: %v", err)
 		}
 
 		// 验证Session已被删除
-		deletedSession, err := dao.GetSession(sessionID)
+		deletedSession, err := dao.GetSession(t.Context(), sessionID)
 		if err != nil {
 			t.Errorf("获取已删除的Session时发生错误: %v", err)
 		}
@@ -452,7 +440,7 @@ func TestUserControllerEdgeCases(t *testing.T) {
 		req.AddCookie(cookie)
 
 		rr := httptest.NewRecorder()
-		Logout(rr, req)
+		handle(controller.Logout, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -468,7 +456,7 @@ func TestUserControllerEdgeCases(t *testing.T) {
 		}
 
 		rr := httptest.NewRecorder()
-		Logout(rr, req)
+		handle(controller.Logout, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -491,11 +479,11 @@ func TestUserControllerEdgeCases(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		Regist(rr, req)
+		handle(controller.Regist, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 用户名和密码不能为空
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码400，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -514,11 +502,11 @@ func TestUserControllerEdgeCases(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		Regist(rr, req)
+		handle(controller.Regist, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 用户名和密码不能为空
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码400，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -537,7 +525,7 @@ func TestUserControllerEdgeCases(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		Regist(rr, req)
+		handle(controller.Regist, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -557,11 +545,11 @@ func TestUserControllerEdgeCases(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		Regist(rr, req)
+		handle(controller.Regist, rr, req)
 
-		// 检查响应状态码
-		if rr.Code != http.StatusOK {
-			t.Errorf("期望状态码200，实际得到: %d", rr.Code)
+		// 用户名和密码不能为空
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("期望状态码400，实际得到: %d", rr.Code)
 		}
 	})
 
@@ -578,7 +566,7 @@ func TestUserControllerEdgeCases(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		CheckUserName(rr, req)
+		handle(controller.CheckUserName, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -605,7 +593,7 @@ func TestUserControllerEdgeCases(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		CheckUserName(rr, req)
+		handle(controller.CheckUserName, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -631,7 +619,7 @@ func TestUserControllerEdgeCases(t *testing.T) {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
-		CheckUserName(rr, req)
+		handle(controller.CheckUserName, rr, req)
 
 		// 检查响应状态码
 		if rr.Code != http.StatusOK {
@@ -1,6 +1,7 @@
-package dao
+package dao_test
 
 import (
+	"bookstore/dao"
 	"bookstore/model"
 	"fmt"
 	"testing"
@@ -14,21 +15,21 @@ func TestBookOperations(t *testing.T) {
 		testBook := &model.Book{
 			Title:   "测试图书",
 			Author:  "测试作者",
-			Price:   99.99,
+			Price:   model.Cents(9999),
 			Sales:   0,
 			Stock:   100,
 			ImgPath: "/static/img/test.jpg",
 		}
 
 		// 添加图书到数据库
-		err := AddBook(testBook)
+		err := dao.AddBook(t.Context(), testBook)
 		if err != nil {
 			t.Errorf("添加图书失败: %v", err)
 		}
 
 		// 验证图书是否成功添加
 		// 通过查询所有图书来验证
-		books, err := GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -40,7 +41,7 @@ func TestBookOperations(t *testing.T) {
 				found = true
 				// 验证图书信息
 				if book.Price != testBook.Price {
-					t.Errorf("价格不匹配，期望: %.2f, 实际: %.2f", testBook.Price, book.Price)
+					t.Errorf("价格不匹配，期望: %s, 实际: %s", testBook.Price, book.Price)
 				}
 				if book.Stock != testBook.Stock {
 					t.Errorf("库存不匹配，期望: %d, 实际: %d", testBook.Stock, book.Stock)
@@ -70,19 +71,19 @@ func TestBookOperations(t *testing.T) {
 		testBook := &model.Book{
 			Title:   "查询测试图书",
 			Author:  "查询测试作者",
-			Price:   88.88,
+			Price:   model.Cents(8888),
 			Sales:   10,
 			Stock:   50,
 			ImgPath: "/static/img/query_test.jpg",
 		}
 
-		err := AddBook(testBook)
+		err := dao.AddBook(t.Context(), testBook)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取所有图书
-		books, err := GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -108,7 +109,7 @@ func TestBookOperations(t *testing.T) {
 				t.Errorf("作者不匹配，期望: %s, 实际: %s", testBook.Author, foundBook.Author)
 			}
 			if foundBook.Price != testBook.Price {
-				t.Errorf("价格不匹配，期望: %.2f, 实际: %.2f", testBook.Price, foundBook.Price)
+				t.Errorf("价格不匹配，期望: %s, 实际: %s", testBook.Price, foundBook.Price)
 			}
 			if foundBook.Sales != testBook.Sales {
 				t.Errorf("销量不匹配，期望: %d, 实际: %d", testBook.Sales, foundBook.Sales)
@@ -127,19 +128,19 @@ func TestBookOperations(t *testing.T) {
 		testBook := &model.Book{
 			Title:   "ID查询测试图书",
 			Author:  "ID查询测试作者",
-			Price:   77.77,
+			Price:   model.Cents(7777),
 			Sales:   5,
 			Stock:   25,
 			ImgPath: "/static/img/id_query_test.jpg",
 		}
 
-		err := AddBook(testBook)
+		err := dao.AddBook(t.Context(), testBook)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取刚添加的图书ID
-		books, err := GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -157,7 +158,7 @@ func TestBookOperations(t *testing.T) {
 		}
 
 		// 根据ID查询图书
-		retrievedBook, err := GetBookByID(testBookID)
+		retrievedBook, err := dao.GetBookByID(t.Context(), testBookID)
 		if err != nil {
 			t.Errorf("根据ID查询图书失败: %v", err)
 		}
@@ -173,7 +174,7 @@ func TestBookOperations(t *testing.T) {
 				t.Errorf("作者不匹配，期望: %s, 实际: %s", testBook.Author, retrievedBook.Author)
 			}
 			if retrievedBook.Price != testBook.Price {
-				t.Errorf("价格不匹配，期望: %.2f, 实际: %.2f", testBook.Price, retrievedBook.Price)
+				t.Errorf("价格不匹配，期望: %s, 实际: %s", testBook.Price, retrievedBook.Price)
 			}
 			// 清理测试图书
 			cleanupTestBook(t, retrievedBook.ID)
@@ -186,19 +187,19 @@ func TestBookOperations(t *testing.T) {
 		testBook := &model.Book{
 			Title:   "更新测试图书",
 			Author:  "更新测试作者",
-			Price:   66.66,
+			Price:   model.Cents(6666),
 			Sales:   0,
 			Stock:   30,
 			ImgPath: "/static/img/update_test.jpg",
 		}
 
-		err := AddBook(testBook)
+		err := dao.AddBook(t.Context(), testBook)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取刚添加的图书ID
-		books, err := GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -220,19 +221,19 @@ func TestBookOperations(t *testing.T) {
 			ID:      testBookID,
 			Title:   "更新后的图书标题",
 			Author:  "更新后的作者",
-			Price:   88.88,
+			Price:   model.Cents(8888),
 			Sales:   10,
 			Stock:   20,
 			ImgPath: "/static/img/updated_test.jpg",
 		}
 
-		err = UpdateBook(updatedBook)
+		err = dao.UpdateBook(t.Context(), updatedBook)
 		if err != nil {
 			t.Errorf("更新图书失败: %v", err)
 		}
 
 		// 验证更新结果
-		retrievedBook, err := GetBookByID(fmt.Sprintf("%d", testBookID))
+		retrievedBook, err := dao.GetBookByID(t.Context(), fmt.Sprintf("%d", testBookID))
 		if err != nil {
 			t.Errorf("查询更新后的图书失败: %v", err)
 		}
@@ -244,7 +245,7 @@ func TestBookOperations(t *testing.T) {
 			t.Errorf("更新后作者不匹配，期望: %s, 实际: %s", updatedBook.Author, retrievedBook.Author)
 		}
 		if retrievedBook.Price != updatedBook.Price {
-			t.Errorf("更新后价格不匹配，期望: %.2f, 实际: %.2f", updatedBook.Price, retrievedBook.Price)
+			t.Errorf("更新后价格不匹配，期望: %s, 实际: %s", updatedBook.Price, retrievedBook.Price)
 		}
 		if retrievedBook.Sales != updatedBook.Sales {
 			t.Errorf("更新后销量不匹配，期望: %d, 实际: %d", updatedBook.Sales, retrievedBook.Sales)
@@ -263,19 +264,19 @@ func TestBookOperations(t *testing.T) {
 		testBook := &model.Book{
 			Title:   "删除测试图书",
 			Author:  "删除测试作者",
-			Price:   55.55,
+			Price:   model.Cents(5555),
 			Sales:   0,
 			Stock:   40,
 			ImgPath: "/static/img/delete_test.jpg",
 		}
 
-		err := AddBook(testBook)
+		err := dao.AddBook(t.Context(), testBook)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取刚添加的图书ID
-		books, err := GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -293,13 +294,13 @@ func TestBookOperations(t *testing.T) {
 		}
 
 		// 删除图书
-		err = DeleteBook(testBookID)
+		err = dao.DeleteBook(t.Context(), testBookID)
 		if err != nil {
 			t.Errorf("删除图书失败: %v", err)
 		}
 
 		// 验证图书是否已被删除
-		deletedBook, err := GetBookByID(testBookID)
+		deletedBook, err := dao.GetBookByID(t.Context(), testBookID)
 		if err != nil {
 			t.Errorf("查询已删除的图书时发生错误: %v", err)
 		}
@@ -313,22 +314,22 @@ func TestBookOperations(t *testing.T) {
 	t.Run("测试分页功能", func(t *testing.T) {
 		// 先添加多本测试图书
 		testBooks := []*model.Book{
-			{Title: "分页测试图书1", Author: "分页测试作者1", Price: 10.00, Sales: 0, Stock: 10, ImgPath: "/static/img/page1.jpg"},
-			{Title: "分页测试图书2", Author: "分页测试作者2", Price: 20.00, Sales: 0, Stock: 10, ImgPath: "/static/img/page2.jpg"},
-			{Title: "分页测试图书3", Author: "分页测试作者3", Price: 30.00, Sales: 0, Stock: 10, ImgPath: "/static/img/page3.jpg"},
-			{Title: "分页测试图书4", Author: "分页测试作者4", Price: 40.00, Sales: 0, Stock: 10, ImgPath: "/static/img/page4.jpg"},
-			{Title: "分页测试图书5", Author: "分页测试作者5", Price: 50.00, Sales: 0, Stock: 10, ImgPath: "/static/img/page5.jpg"},
+			{Title: "分页测试图书1", Author: "分页测试作者1", Price: model.Cents(1000), Sales: 0, Stock: 10, ImgPath: "/static/img/page1.jpg"},
+			{Title: "分页测试图书2", Author: "分页测试作者2", Price: model.Cents(2000), Sales: 0, Stock: 10, ImgPath: "/static/img/page2.jpg"},
+			{Title: "分页测试图书3", Author: "分页测试作者3", Price: model.Cents(3000), Sales: 0, Stock: 10, ImgPath: "/static/img/page3.jpg"},
+			{Title: "分页测试图书4", Author: "分页测试作者4", Price: model.Cents(4000), Sales: 0, Stock: 10, ImgPath: "/static/img/page4.jpg"},
+			{Title: "分页测试图书5", Author: "分页测试作者5", Price: model.Cents(5000), Sales: 0, Stock: 10, ImgPath: "/static/img/page5.jpg"},
 		}
 
 		var addedBookIDs []int
 		for _, book := range testBooks {
-			err := AddBook(book)
+			err := dao.AddBook(t.Context(), book)
 			if err != nil {
 				t.Errorf("添加测试图书失败: %v", err)
 			}
 
 			// 获取刚添加的图书ID
-			books, err := GetBooks()
+			books, err := dao.GetBooks(t.Context())
 			if err != nil {
 				t.Errorf("获取图书列表失败: %v", err)
 			}
@@ -342,7 +343,7 @@ func TestBookOperations(t *testing.T) {
 		}
 
 		// 测试第一页
-		page, err := GetPageBooks("1")
+		page, err := dao.GetPageBooks(t.Context(), "1")
 		if err != nil {
 			t.Errorf("获取第一页图书失败: %v", err)
 		}
@@ -360,7 +361,7 @@ func TestBookOperations(t *testing.T) {
 		}
 
 		// 测试第二页
-		page2, err := GetPageBooks("2")
+		page2, err := dao.GetPageBooks(t.Context(), "2")
 		if err != nil {
 			t.Errorf("获取第二页图书失败: %v", err)
 		}
@@ -379,20 +380,20 @@ func TestBookOperations(t *testing.T) {
 	t.Run("测试价格筛选", func(t *testing.T) {
 		// 先添加不同价格的测试图书
 		testBooks := []*model.Book{
-			{Title: "低价图书", Author: "低价作者", Price: 10.00, Sales: 0, Stock: 10, ImgPath: "/static/img/low.jpg"},
-			{Title: "中价图书", Author: "中价作者", Price: 50.00, Sales: 0, Stock: 10, ImgPath: "/static/img/mid.jpg"},
-			{Title: "高价图书", Author: "高价作者", Price: 100.00, Sales: 0, Stock: 10, ImgPath: "/static/img/high.jpg"},
+			{Title: "低价图书", Author: "低价作者", Price: model.Cents(1000), Sales: 0, Stock: 10, ImgPath: "/static/img/low.jpg"},
+			{Title: "中价图书", Author: "中价作者", Price: model.Cents(5000), Sales: 0, Stock: 10, ImgPath: "/static/img/mid.jpg"},
+			{Title: "高价图书", Author: "高价作者", Price: model.Cents(10000), Sales: 0, Stock: 10, ImgPath: "/static/img/high.jpg"},
 		}
 
 		var addedBookIDs []int
 		for _, book := range testBooks {
-			err := AddBook(book)
+			err := dao.AddBook(t.Context(), book)
 			if err != nil {
 				t.Errorf("添加测试图书失败: %v", err)
 			}
 
 			// 获取刚添加的图书ID
-			books, err := GetBooks()
+			books, err := dao.GetBooks(t.Context())
 			if err != nil {
 				t.Errorf("获取图书列表失败: %v", err)
 			}
@@ -406,28 +407,28 @@ func TestBookOperations(t *testing.T) {
 		}
 
 		// 测试价格范围筛选 (20-80)
-		page, err := GetPageBooksByPrice("1", "20", "80")
+		page, err := dao.GetPageBooksByPrice(t.Context(), "1", "20", "80")
 		if err != nil {
 			t.Errorf("按价格筛选图书失败: %v", err)
 		}
 
 		// 验证筛选结果
 		for _, book := range page.Books {
-			if book.Price < 20 || book.Price > 80 {
-				t.Errorf("图书价格超出筛选范围，价格: %.2f, 范围: 20-80", book.Price)
+			if book.Price.Cmp(model.Cents(2000)) < 0 || book.Price.Cmp(model.Cents(8000)) > 0 {
+				t.Errorf("图书价格超出筛选范围，价格: %s, 范围: 20-80", book.Price)
 			}
 		}
 
 		// 测试价格范围筛选 (0-30)
-		page2, err := GetPageBooksByPrice("1", "0", "30")
+		page2, err := dao.GetPageBooksByPrice(t.Context(), "1", "0", "30")
 		if err != nil {
 			t.Errorf("按价格筛选图书失败: %v", err)
 		}
 
 		// 验证筛选结果
 		for _, book := range page2.Books {
-			if book.Price < 0 || book.Price > 30 {
-				t.Errorf("图书价格超出筛选范围，价格: %.2f, 范围: 0-30", book.Price)
+			if book.Price.Cmp(model.Cents(0)) < 0 || book.Price.Cmp(model.Cents(3000)) > 0 {
+				t.Errorf("图书价格超出筛选范围，价格: %s, 范围: 0-30", book.Price)
 			}
 		}
 
@@ -451,7 +452,7 @@ func TestBookDataValidation(t *testing.T) {
 			book: &model.Book{
 				Title:   "正常图书",
 				Author:  "正常作者",
-				Price:   99.99,
+				Price:   model.Cents(9999),
 				Sales:   10,
 				Stock:   100,
 				ImgPath: "/static/img/normal.jpg",
@@ -464,7 +465,7 @@ func TestBookDataValidation(t *testing.T) {
 			book: &model.Book{
 				Title:   "零价格图书",
 				Author:  "零价格作者",
-				Price:   0.00,
+				Price:   model.Cents(0),
 				Sales:   0,
 				Stock:   10,
 				ImgPath: "/static/img/free.jpg",
@@ -477,7 +478,7 @@ func TestBookDataValidation(t *testing.T) {
 			book: &model.Book{
 				Title:   "负价格图书",
 				Author:  "负价格作者",
-				Price:   -10.00,
+				Price:   model.Cents(-1000),
 				Sales:   0,
 				Stock:   10,
 				ImgPath: "/static/img/negative.jpg",
@@ -490,7 +491,7 @@ func TestBookDataValidation(t *testing.T) {
 			book: &model.Book{
 				Title:   "",
 				Author:  "空标题作者",
-				Price:   50.00,
+				Price:   model.Cents(5000),
 				Sales:   0,
 				Stock:   10,
 				ImgPath: "/static/img/empty_title.jpg",
@@ -503,7 +504,7 @@ func TestBookDataValidation(t *testing.T) {
 			book: &model.Book{
 				Title:   "空作者图书",
 				Author:  "",
-				Price:   50.00,
+				Price:   model.Cents(5000),
 				Sales:   0,
 				Stock:   10,
 				ImgPath: "/static/img/empty_author.jpg",
@@ -516,7 +517,7 @@ func TestBookDataValidation(t *testing.T) {
 			book: &model.Book{
 				Title:   "高价格图书",
 				Author:  "高价格作者",
-				Price:   99999.99,
+				Price:   model.Cents(9999999),
 				Sales:   0,
 				Stock:   1,
 				ImgPath: "/static/img/expensive.jpg",
@@ -528,7 +529,7 @@ func TestBookDataValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := AddBook(tt.book)
+			err := dao.AddBook(t.Context(), tt.book)
 
 			if tt.expectError {
 				if err == nil {
@@ -539,7 +540,7 @@ func TestBookDataValidation(t *testing.T) {
 					t.Errorf("不期望返回错误，但返回了错误: %v, %s", err, tt.description)
 				} else {
 					// 验证图书是否成功添加
-					books, err := GetBooks()
+					books, err := dao.GetBooks(t.Context())
 					if err != nil {
 						t.Errorf("获取图书列表失败: %v", err)
 					}
@@ -576,13 +577,13 @@ func TestBookConcurrentOperations(t *testing.T) {
 				book := &model.Book{
 					Title:   fmt.Sprintf("并发测试图书%d", index),
 					Author:  fmt.Sprintf("并发测试作者%d", index),
-					Price:   float64(index * 10),
+					Price:   model.Cents(int64(index) * 1000),
 					Sales:   0,
 					Stock:   10,
 					ImgPath: fmt.Sprintf("/static/img/concurrent%d.jpg", index),
 				}
 
-				err := AddBook(book)
+				err := dao.AddBook(t.Context(), book)
 				if err != nil {
 					t.Errorf("并发添加图书失败: %v", err)
 				}
@@ -595,7 +596,7 @@ func TestBookConcurrentOperations(t *testing.T) {
 		}
 
 		// 清理测试图书
-		books, err := GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -616,19 +617,19 @@ func TestBookConcurrentOperations(t *testing.T) {
 		testBook := &model.Book{
 			Title:   "并发查询测试图书",
 			Author:  "并发查询测试作者",
-			Price:   99.99,
+			Price:   model.Cents(9999),
 			Sales:   0,
 			Stock:   10,
 			ImgPath: "/static/img/concurrent_query.jpg",
 		}
 
-		err := AddBook(testBook)
+		err := dao.AddBook(t.Context(), testBook)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取测试图书ID
-		books, err := GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -651,7 +652,7 @@ func TestBookConcurrentOperations(t *testing.T) {
 			go func(index int) {
 				defer func() { done <- true }()
 
-				book, err := GetBookByID(testBookID)
+				book, err := dao.GetBookByID(t.Context(), testBookID)
 				if err != nil {
 					t.Errorf("并发查询图书失败: %v", err)
 				}
@@ -678,26 +679,26 @@ func BenchmarkAddBook(b *testing.B) {
 		book := &model.Book{
 			Title:   fmt.Sprintf("性能测试图书%d", i),
 			Author:  fmt.Sprintf("性能测试作者%d", i),
-			Price:   float64(i),
+			Price:   model.Cents(int64(i)),
 			Sales:   0,
 			Stock:   10,
 			ImgPath: fmt.Sprintf("/static/img/benchmark%d.jpg", i),
 		}
-		AddBook(book)
+		dao.AddBook(b.Context(), book)
 	}
 }
 
 func BenchmarkGetBooks(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		GetBooks()
+		dao.GetBooks(b.Context())
 	}
 }
 
 func BenchmarkGetPageBooks(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		GetPageBooks("1")
+		dao.GetPageBooks(b.Context(), "1")
 	}
 }
 
@@ -1,8 +1,11 @@
-package dao
+package dao_test
 
 import (
+	"bookstore/dao"
 	"bookstore/model"
 	"bookstore/utils"
+	"database/sql"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -16,20 +19,20 @@ func TestCartOperations(t *testing.T) {
 	// ensureTestUser 已被替换为 createTestUser
 
 	testBooks := []*model.Book{
-		{Title: "购物车测试图书1", Author: "购物车测试作者1", Price: 10.00, Sales: 0, Stock: 100, ImgPath: "/static/img/cart1.jpg"},
-		{Title: "购物车测试图书2", Author: "购物车测试作者2", Price: 20.00, Sales: 0, Stock: 100, ImgPath: "/static/img/cart2.jpg"},
-		{Title: "购物车测试图书3", Author: "购物车测试作者3", Price: 30.00, Sales: 0, Stock: 100, ImgPath: "/static/img/cart3.jpg"},
+		{Title: "购物车测试图书1", Author: "购物车测试作者1", Price: model.Cents(1000), Sales: 0, Stock: 100, ImgPath: "/static/img/cart1.jpg"},
+		{Title: "购物车测试图书2", Author: "购物车测试作者2", Price: model.Cents(2000), Sales: 0, Stock: 100, ImgPath: "/static/img/cart2.jpg"},
+		{Title: "购物车测试图书3", Author: "购物车测试作者3", Price: model.Cents(3000), Sales: 0, Stock: 100, ImgPath: "/static/img/cart3.jpg"},
 	}
 
 	var addedBookIDs []int
 	for _, book := range testBooks {
-		err := AddBook(book)
+		err := dao.AddBook(t.Context(), book)
 		if err != nil {
 			t.Fatalf("添加测试图书失败: %v", err)
 		}
 
 		// 获取刚添加的图书ID
-		books, err := GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Fatalf("获取图书列表失败: %v", err)
 		}
@@ -63,20 +66,20 @@ func TestCartOperations(t *testing.T) {
 
 		// 创建购物项
 		cartItem := &model.CartItem{
-			Book:   &model.Book{ID: addedBookIDs[0], Price: 10.00},
+			Book:   &model.Book{ID: addedBookIDs[0], Price: model.Cents(1000)},
 			Count:  2,
 			CartID: cartID,
 		}
 		cart.CartItems = []*model.CartItem{cartItem}
 
 		// 添加购物车到数据库
-		err := AddCart(cart)
+		err := dao.AddCart(t.Context(), cart)
 		if err != nil {
 			t.Fatalf("添加购物车失败: %v", err)
 		}
 
 		// 验证购物车是否成功创建
-		retrievedCart, err := GetCartByUserID(testUserID)
+		retrievedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Fatalf("获取购物车失败: %v", err)
 		}
@@ -102,9 +105,9 @@ func TestCartOperations(t *testing.T) {
 		}
 
 		// 验证总价计算
-		expectedTotalAmount := 2 * 10.00
+		expectedTotalAmount := model.Cents(1000).Mul(2)
 		if retrievedCart.GetTotalAmount() != expectedTotalAmount {
-			t.Errorf("总金额不匹配，期望: %.2f, 实际: %.2f", expectedTotalAmount, retrievedCart.GetTotalAmount())
+			t.Errorf("总金额不匹配，期望: %s, 实际: %s", expectedTotalAmount, retrievedCart.GetTotalAmount())
 		}
 
 		if retrievedCart.GetTotalCount() != 2 {
@@ -115,7 +118,7 @@ func TestCartOperations(t *testing.T) {
 	// 测试重复添加同一商品
 	t.Run("测试重复添加同一商品", func(t *testing.T) {
 		// 获取现有购物车
-		cart, err := GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -126,13 +129,13 @@ func TestCartOperations(t *testing.T) {
 
 		// 添加同一商品到购物车
 		cartItem := &model.CartItem{
-			Book:   &model.Book{ID: addedBookIDs[0], Price: 10.00},
+			Book:   &model.Book{ID: addedBookIDs[0], Price: model.Cents(1000)},
 			Count:  1,
 			CartID: cart.CartID,
 		}
 
 		// 检查是否已存在该商品的购物项
-		existingCartItem, err := GetCartItemByBookIDAndCartID(fmt.Sprintf("%d", addedBookIDs[0]), cart.CartID)
+		existingCartItem, err := dao.GetCartItemByBookIDAndCartID(t.Context(), fmt.Sprintf("%d", addedBookIDs[0]), cart.CartID)
 		if err != nil {
 			t.Errorf("查询购物项失败: %v", err)
 		}
@@ -140,27 +143,27 @@ func TestCartOperations(t *testing.T) {
 		if existingCartItem != nil {
 			// 更新现有购物项的数量
 			existingCartItem.Count += 1
-			err = UpdateBookCount(existingCartItem)
+			err = dao.UpdateBookCount(t.Context(), existingCartItem)
 			if err != nil {
 				t.Errorf("更新购物项失败: %v", err)
 			}
 		} else {
 			// 创建新的购物项
 			cart.CartItems = append(cart.CartItems, cartItem)
-			err = AddCartItem(cartItem)
+			err = dao.AddCartItem(t.Context(), cartItem)
 			if err != nil {
 				t.Errorf("添加购物项失败: %v", err)
 			}
 		}
 
 		// 更新购物车
-		err = UpdateCart(cart)
+		err = dao.UpdateCart(t.Context(), cart)
 		if err != nil {
 			t.Errorf("更新购物车失败: %v", err)
 		}
 
 		// 验证购物车更新
-		updatedCart, err := GetCartByUserID(testUserID)
+		updatedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取更新后的购物车失败: %v", err)
 		}
@@ -169,16 +172,16 @@ func TestCartOperations(t *testing.T) {
 			t.Errorf("总数量不匹配，期望: 3, 实际: %d", updatedCart.GetTotalCount())
 		}
 
-		expectedTotalAmount := 3 * 10.00
+		expectedTotalAmount := model.Cents(1000).Mul(3)
 		if updatedCart.GetTotalAmount() != expectedTotalAmount {
-			t.Errorf("总金额不匹配，期望: %.2f, 实际: %.2f", expectedTotalAmount, updatedCart.GetTotalAmount())
+			t.Errorf("总金额不匹配，期望: %s, 实际: %s", expectedTotalAmount, updatedCart.GetTotalAmount())
 		}
 	})
 
 	// 测试更新商品数量
 	t.Run("测试更新商品数量", func(t *testing.T) {
 		// 获取现有购物车
-		cart, err := GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -190,19 +193,19 @@ func TestCartOperations(t *testing.T) {
 		// 更新第一个购物项的数量
 		if len(cart.CartItems) > 0 {
 			cart.CartItems[0].Count = 5
-			err = UpdateBookCount(cart.CartItems[0])
+			err = dao.UpdateBookCount(t.Context(), cart.CartItems[0])
 			if err != nil {
 				t.Errorf("更新购物项数量失败: %v", err)
 			}
 
 			// 更新购物车
-			err = UpdateCart(cart)
+			err = dao.UpdateCart(t.Context(), cart)
 			if err != nil {
 				t.Errorf("更新购物车失败: %v", err)
 			}
 
 			// 验证更新结果
-			updatedCart, err := GetCartByUserID(testUserID)
+			updatedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 			if err != nil {
 				t.Errorf("获取更新后的购物车失败: %v", err)
 			}
@@ -211,9 +214,9 @@ func TestCartOperations(t *testing.T) {
 				t.Errorf("购物项数量不匹配，期望: 5, 实际: %d", updatedCart.CartItems[0].Count)
 			}
 
-			expectedTotalAmount := 5 * 10.00
+			expectedTotalAmount := model.Cents(1000).Mul(5)
 			if updatedCart.GetTotalAmount() != expectedTotalAmount {
-				t.Errorf("总金额不匹配，期望: %.2f, 实际: %.2f", expectedTotalAmount, updatedCart.GetTotalAmount())
+				t.Errorf("总金额不匹配，期望: %s, 实际: %s", expectedTotalAmount, updatedCart.GetTotalAmount())
 			}
 		}
 	})
@@ -221,7 +224,7 @@ func TestCartOperations(t *testing.T) {
 	// 测试删除购物项
 	t.Run("测试删除购物项", func(t *testing.T) {
 		// 获取现有购物车
-		cart, err := GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -236,7 +239,7 @@ func TestCartOperations(t *testing.T) {
 
 		// 删除第一个购物项
 		cartItemID := cart.CartItems[0].CartItemID
-		err = DeleteCartItemByID(fmt.Sprintf("%d", cartItemID))
+		err = dao.DeleteCartItemByID(t.Context(), fmt.Sprintf("%d", cartItemID))
 		if err != nil {
 			t.Errorf("删除购物项失败: %v", err)
 		}
@@ -245,13 +248,13 @@ func TestCartOperations(t *testing.T) {
 		cart.CartItems = cart.CartItems[1:]
 
 		// 更新购物车
-		err = UpdateCart(cart)
+		err = dao.UpdateCart(t.Context(), cart)
 		if err != nil {
 			t.Errorf("更新购物车失败: %v", err)
 		}
 
 		// 验证删除结果
-		updatedCart, err := GetCartByUserID(testUserID)
+		updatedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取更新后的购物车失败: %v", err)
 		}
@@ -264,15 +267,15 @@ func TestCartOperations(t *testing.T) {
 			t.Errorf("总数量不匹配，期望: 0, 实际: %d", updatedCart.GetTotalCount())
 		}
 
-		if updatedCart.GetTotalAmount() != 0 {
-			t.Errorf("总金额不匹配，期望: 0.00, 实际: %.2f", updatedCart.GetTotalAmount())
+		if !updatedCart.GetTotalAmount().IsZero() {
+			t.Errorf("总金额不匹配，期望: 0.00, 实际: %s", updatedCart.GetTotalAmount())
 		}
 	})
 
 	// 测试清空购物车
 	t.Run("测试清空购物车", func(t *testing.T) {
 		// 先添加一些购物项
-		cart, err := GetCartByUserID(testUserID)
+		cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -284,25 +287,25 @@ func TestCartOperations(t *testing.T) {
 		// 添加多个购物项
 		for i, bookID := range addedBookIDs {
 			cartItem := &model.CartItem{
-				Book:   &model.Book{ID: bookID, Price: float64((i + 1) * 10)},
+				Book:   &model.Book{ID: bookID, Price: model.Cents(int64(i+1) * 1000)},
 				Count:  int64(i + 1),
 				CartID: cart.CartID,
 			}
 			cart.CartItems = append(cart.CartItems, cartItem)
-			err = AddCartItem(cartItem)
+			err = dao.AddCartItem(t.Context(), cartItem)
 			if err != nil {
 				t.Errorf("添加购物项失败: %v", err)
 			}
 		}
 
 		// 更新购物车
-		err = UpdateCart(cart)
+		err = dao.UpdateCart(t.Context(), cart)
 		if err != nil {
 			t.Errorf("更新购物车失败: %v", err)
 		}
 
 		// 验证购物车有内容
-		updatedCart, err := GetCartByUserID(testUserID)
+		updatedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -312,19 +315,15 @@ func TestCartOperations(t *testing.T) {
 		}
 
 		// 清空购物车
-		err = DeleteCartByCartID(cart.CartID)
+		err = dao.DeleteCartByCartID(t.Context(), cart.CartID)
 		if err != nil {
 			t.Errorf("清空购物车失败: %v", err)
 		}
 
-		// 验证购物车已被清空
-		emptyCart, err := GetCartByUserID(testUserID)
-		if err != nil {
-			t.Errorf("获取清空后的购物车失败: %v", err)
-		}
-
-		if emptyCart != nil {
-			t.Error("购物车应该已被清空")
+		// 验证购物车已被清空，用户没有购物车时返回sql.ErrNoRows
+		emptyCart, err := dao.GetCartByUserID(t.Context(), testUserID)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("获取清空后的购物车应该返回sql.ErrNoRows，实际: %v, %+v", err, emptyCart)
 		}
 	})
 
@@ -339,31 +338,31 @@ func TestCartOperations(t *testing.T) {
 
 		// 添加多个不同价格的购物项
 		cartItems := []*model.CartItem{
-			{Book: &model.Book{ID: addedBookIDs[0], Price: 10.00}, Count: 2, CartID: cartID},
-			{Book: &model.Book{ID: addedBookIDs[1], Price: 20.00}, Count: 1, CartID: cartID},
-			{Book: &model.Book{ID: addedBookIDs[2], Price: 30.00}, Count: 3, CartID: cartID},
+			{Book: &model.Book{ID: addedBookIDs[0], Price: model.Cents(1000)}, Count: 2, CartID: cartID},
+			{Book: &model.Book{ID: addedBookIDs[1], Price: model.Cents(2000)}, Count: 1, CartID: cartID},
+			{Book: &model.Book{ID: addedBookIDs[2], Price: model.Cents(3000)}, Count: 3, CartID: cartID},
 		}
 
 		cart.CartItems = cartItems
 
 		// 添加购物车到数据库
-		err := AddCart(cart)
+		err := dao.AddCart(t.Context(), cart)
 		if err != nil {
 			t.Errorf("添加购物车失败: %v", err)
 		}
 
 		// 验证总价计算
-		retrievedCart, err := GetCartByUserID(testUserID)
+		retrievedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
 
 		// 计算期望的总价
-		expectedTotalAmount := (2 * 10.00) + (1 * 20.00) + (3 * 30.00) // 20 + 20 + 90 = 130
-		expectedTotalCount := int64(2 + 1 + 3)                         // 6
+		expectedTotalAmount := model.Cents(13000) // 20 + 20 + 90 = 130
+		expectedTotalCount := int64(2 + 1 + 3)    // 6
 
 		if retrievedCart.GetTotalAmount() != expectedTotalAmount {
-			t.Errorf("总金额不匹配，期望: %.2f, 实际: %.2f", expectedTotalAmount, retrievedCart.GetTotalAmount())
+			t.Errorf("总金额不匹配，期望: %s, 实际: %s", expectedTotalAmount, retrievedCart.GetTotalAmount())
 		}
 
 		if retrievedCart.GetTotalCount() != expectedTotalCount {
@@ -372,9 +371,9 @@ func TestCartOperations(t *testing.T) {
 
 		// 验证每个购物项的小计
 		for i, cartItem := range retrievedCart.CartItems {
-			expectedAmount := float64(cartItem.Count) * cartItem.Book.Price
+			expectedAmount := cartItem.Book.Price.Mul(cartItem.Count)
 			if cartItem.GetAmount() != expectedAmount {
-				t.Errorf("购物项%d小计不匹配，期望: %.2f, 实际: %.2f", i, expectedAmount, cartItem.GetAmount())
+				t.Errorf("购物项%d小计不匹配，期望: %s, 实际: %s", i, expectedAmount, cartItem.GetAmount())
 			}
 		}
 	})
@@ -383,11 +382,12 @@ func TestCartOperations(t *testing.T) {
 // TestCartDataValidation 测试购物车数据验证
 func TestCartDataValidation(t *testing.T) {
 	testUserID := createTestUser(t)
-	testBookID := 1
+	testBookID := createTestBook(t)
 
 	defer func() {
 		cleanupTestCart(t, testUserID)
 		cleanupTestUserByID(t, testUserID)
+		cleanupTestBook(t, testBookID)
 	}()
 
 	tests := []struct {
@@ -399,7 +399,7 @@ func TestCartDataValidation(t *testing.T) {
 		{
 			name: "正常购物项",
 			cartItem: &model.CartItem{
-				Book:   &model.Book{ID: testBookID, Price: 10.00},
+				Book:   &model.Book{ID: testBookID, Price: model.Cents(1000)},
 				Count:  1,
 				CartID: "test_cart_id",
 			},
@@ -409,7 +409,7 @@ func TestCartDataValidation(t *testing.T) {
 		{
 			name: "零数量购物项",
 			cartItem: &model.CartItem{
-				Book:   &model.Book{ID: testBookID, Price: 10.00},
+				Book:   &model.Book{ID: testBookID, Price: model.Cents(1000)},
 				Count:  0,
 				CartID: "test_cart_id",
 			},
@@ -419,7 +419,7 @@ func TestCartDataValidation(t *testing.T) {
 		{
 			name: "负数量购物项",
 			cartItem: &model.CartItem{
-				Book:   &model.Book{ID: testBookID, Price: 10.00},
+				Book:   &model.Book{ID: testBookID, Price: model.Cents(1000)},
 				Count:  -1,
 				CartID: "test_cart_id",
 			},
@@ -429,7 +429,7 @@ func TestCartDataValidation(t *testing.T) {
 		{
 			name: "大数量购物项",
 			cartItem: &model.CartItem{
-				Book:   &model.Book{ID: testBookID, Price: 10.00},
+				Book:   &model.Book{ID: testBookID, Price: model.Cents(1000)},
 				Count:  999999,
 				CartID: "test_cart_id",
 			},
@@ -439,7 +439,7 @@ func TestCartDataValidation(t *testing.T) {
 		{
 			name: "零价格图书购物项",
 			cartItem: &model.CartItem{
-				Book:   &model.Book{ID: testBookID, Price: 0.00},
+				Book:   &model.Book{ID: testBookID, Price: model.Cents(0)},
 				Count:  1,
 				CartID: "test_cart_id",
 			},
@@ -449,7 +449,7 @@ func TestCartDataValidation(t *testing.T) {
 		{
 			name: "负价格图书购物项",
 			cartItem: &model.CartItem{
-				Book:   &model.Book{ID: testBookID, Price: -10.00},
+				Book:   &model.Book{ID: testBookID, Price: model.Cents(-1000)},
 				Count:  1,
 				CartID: "test_cart_id",
 			},
@@ -472,7 +472,7 @@ func TestCartDataValidation(t *testing.T) {
 			cart.CartItems = []*model.CartItem{tt.cartItem}
 
 			// 添加购物车
-			err := AddCart(cart)
+			err := dao.AddCart(t.Context(), cart)
 
 			if tt.expectError {
 				if err == nil {
@@ -483,7 +483,7 @@ func TestCartDataValidation(t *testing.T) {
 					t.Errorf("不期望返回错误，但返回了错误: %v, %s", err, tt.description)
 				} else {
 					// 验证购物车是否成功创建
-					retrievedCart, err := GetCartByUserID(testUserID)
+					retrievedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 					if err != nil {
 						t.Errorf("获取购物车失败: %v", err)
 					}
@@ -503,11 +503,12 @@ func TestCartDataValidation(t *testing.T) {
 // TestCartConcurrentOperations 测试购物车并发操作
 func TestCartConcurrentOperations(t *testing.T) {
 	testUserID := createTestUser(t)
-	testBookID := 1
+	testBookID := createTestBook(t)
 
 	defer func() {
 		cleanupTestCart(t, testUserID)
 		cleanupTestUserByID(t, testUserID)
+		cleanupTestBook(t, testBookID)
 	}()
 
 	// 测试并发添加购物项
@@ -519,7 +520,7 @@ func TestCartConcurrentOperations(t *testing.T) {
 			UserID: testUserID,
 		}
 
-		err := AddCart(cart)
+		err := dao.AddCart(t.Context(), cart)
 		if err != nil {
 			t.Errorf("创建购物车失败: %v", err)
 		}
@@ -531,12 +532,12 @@ func TestCartConcurrentOperations(t *testing.T) {
 				defer func() { done <- true }()
 
 				cartItem := &model.CartItem{
-					Book:   &model.Book{ID: testBookID, Price: float64(index * 10)},
+					Book:   &model.Book{ID: testBookID, Price: model.Cents(int64(index) * 1000)},
 					Count:  int64(index + 1),
 					CartID: cartID,
 				}
 
-				err := AddCartItem(cartItem)
+				err := dao.AddCartItem(t.Context(), cartItem)
 				if err != nil {
 					t.Errorf("并发添加购物项失败: %v", err)
 				}
@@ -549,7 +550,7 @@ func TestCartConcurrentOperations(t *testing.T) {
 		}
 
 		// 验证购物车状态
-		retrievedCart, err := GetCartByUserID(testUserID)
+		retrievedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -573,13 +574,13 @@ func TestCartConcurrentOperations(t *testing.T) {
 
 		// 添加初始购物项
 		cartItem := &model.CartItem{
-			Book:   &model.Book{ID: testBookID, Price: 10.00},
+			Book:   &model.Book{ID: testBookID, Price: model.Cents(1000)},
 			Count:  1,
 			CartID: cartID,
 		}
 		cart.CartItems = []*model.CartItem{cartItem}
 
-		err := AddCart(cart)
+		err := dao.AddCart(t.Context(), cart)
 		if err != nil {
 			t.Errorf("创建购物车失败: %v", err)
 		}
@@ -591,7 +592,7 @@ func TestCartConcurrentOperations(t *testing.T) {
 				defer func() { done <- true }()
 
 				// 获取购物车
-				cart, err := GetCartByUserID(testUserID)
+				cart, err := dao.GetCartByUserID(t.Context(), testUserID)
 				if err != nil {
 					t.Errorf("获取购物车失败: %v", err)
 					return
@@ -600,14 +601,14 @@ func TestCartConcurrentOperations(t *testing.T) {
 				if cart != nil && len(cart.CartItems) > 0 {
 					// 更新购物项数量
 					cart.CartItems[0].Count += 1
-					err = UpdateBookCount(cart.CartItems[0])
+					err = dao.UpdateBookCount(t.Context(), cart.CartItems[0])
 					if err != nil {
 						t.Errorf("更新购物项失败: %v", err)
 						return
 					}
 
 					// 更新购物车
-					err = UpdateCart(cart)
+					err = dao.UpdateCart(t.Context(), cart)
 					if err != nil {
 						t.Errorf("更新购物车失败: %v", err)
 					}
@@ -621,7 +622,7 @@ func TestCartConcurrentOperations(t *testing.T) {
 		}
 
 		// 验证购物车状态
-		retrievedCart, err := GetCartByUserID(testUserID)
+		retrievedCart, err := dao.GetCartByUserID(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取购物车失败: %v", err)
 		}
@@ -637,12 +638,13 @@ func TestCartConcurrentOperations(t *testing.T) {
 
 // BenchmarkCartOperations 性能测试
 func BenchmarkAddCart(b *testing.B) {
-	testUserID := createTestUserNoT()
-	testBookID := 1
+	testUserID := createTestUser(b)
+	testBookID := createTestBook(b)
 
 	defer func() {
-		cleanupTestCart(nil, testUserID)
-		cleanupTestUserByID(nil, testUserID)
+		cleanupTestCart(b, testUserID)
+		cleanupTestUserByID(b, testUserID)
+		cleanupTestBook(b, testBookID)
 	}()
 
 	b.ResetTimer()
@@ -654,19 +656,19 @@ func BenchmarkAddCart(b *testing.B) {
 		}
 
 		cartItem := &model.CartItem{
-			Book:   &model.Book{ID: testBookID, Price: 10.00},
+			Book:   &model.Book{ID: testBookID, Price: model.Cents(1000)},
 			Count:  1,
 			CartID: cartID,
 		}
 		cart.CartItems = []*model.CartItem{cartItem}
 
-		AddCart(cart)
+		dao.AddCart(b.Context(), cart)
 	}
 }
 
 func BenchmarkGetCartByUserID(b *testing.B) {
-	testUserID := createTestUserNoT()
-	testBookID := 1
+	testUserID := createTestUser(b)
+	testBookID := createTestBook(b)
 
 	// 准备测试数据
 	cartID := utils.CreateUUID()
@@ -676,74 +678,76 @@ func BenchmarkGetCartByUserID(b *testing.B) {
 	}
 
 	cartItem := &model.CartItem{
-		Book:   &model.Book{ID: testBookID, Price: 10.00},
+		Book:   &model.Book{ID: testBookID, Price: model.Cents(1000)},
 		Count:  1,
 		CartID: cartID,
 	}
 	cart.CartItems = []*model.CartItem{cartItem}
 
-	AddCart(cart)
+	dao.AddCart(b.Context(), cart)
 
 	defer func() {
-		cleanupTestCart(nil, testUserID)
-		cleanupTestUserByID(nil, testUserID)
+		cleanupTestCart(b, testUserID)
+		cleanupTestUserByID(b, testUserID)
+		cleanupTestBook(b, testBookID)
 	}()
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		GetCartByUserID(testUserID)
+		dao.GetCartByUserID(b.Context(), testUserID)
 	}
 }
 
-// cleanupTestCart 清理测试购物车
-func cleanupTestCart(t *testing.T, userID int) {
-	// 获取用户的购物车
-	cart, err := GetCartByUserID(userID)
-	if err != nil {
-		if t != nil {
-			t.Logf("获取购物车失败: %v", err)
+// cleanupTestCart 清理测试购物车，基准测试会为同一个用户添加多个购物车
+func cleanupTestCart(tb testing.TB, userID int) {
+	for {
+		// 获取用户的购物车，没有购物车时清理完成
+		cart, err := dao.GetCartByUserID(tb.Context(), userID)
+		if err != nil {
+			return
 		}
-		return
-	}
-
-	if cart != nil {
 		// 删除购物车
-		err = DeleteCartByCartID(cart.CartID)
-		if err != nil {
-			if t != nil {
-				t.Logf("删除购物车失败: %v", err)
-			}
+		if err := dao.DeleteCartByCartID(tb.Context(), cart.CartID); err != nil {
+			tb.Logf("删除购物车失败: %v", err)
+			return
 		}
 	}
 }
 
-// createTestUser 创建一个临时测试用户并返回其ID
-func createTestUser(t *testing.T) int {
+// createTestUser 注册一个临时测试用户并返回其ID
+func createTestUser(tb testing.TB) int {
 	username := fmt.Sprintf("test_user_%d", time.Now().UnixNano())
-	email := fmt.Sprintf("%s@example.com", username)
-	res, err := utils.Db.Exec("INSERT INTO users (username, password, email) VALUES (?, ?, ?)", username, "password", email)
-	if err != nil {
-		t.Fatalf("createTestUser 插入用户失败: %v", err)
+	if err := dao.SaveUser(tb.Context(), username, "password", username+"@example.com"); err != nil {
+		tb.Fatalf("createTestUser 保存用户失败: %v", err)
 	}
-	id64, err := res.LastInsertId()
+	user, err := dao.CheckUserName(tb.Context(), username)
 	if err != nil {
-		t.Fatalf("createTestUser 获取插入ID失败: %v", err)
+		tb.Fatalf("createTestUser 查询用户失败: %v", err)
 	}
-	return int(id64)
+	return user.ID
 }
 
-// createTestUserNoT 为基准测试创建用户（不使用 *testing.T）
-func createTestUserNoT() int {
-	username := fmt.Sprintf("bench_user_%d", time.Now().UnixNano())
-	email := fmt.Sprintf("%s@example.com", username)
-	res, err := utils.Db.Exec("INSERT INTO users (username, password, email) VALUES (?, ?, ?)", username, "password", email)
-	if err != nil {
-		// 如果插入失败，回退到默认存在的用户 id=1
-		return 1
+// createTestBook 添加一本库存充足的测试图书并返回其ID
+func createTestBook(tb testing.TB) int {
+	book := &model.Book{
+		Title:   fmt.Sprintf("测试图书%d", time.Now().UnixNano()),
+		Author:  "测试作者",
+		Price:   model.Cents(1000),
+		Stock:   1000000,
+		ImgPath: "/static/img/test.jpg",
 	}
-	id64, err := res.LastInsertId()
+	if err := dao.AddBook(tb.Context(), book); err != nil {
+		tb.Fatalf("createTestBook 添加图书失败: %v", err)
+	}
+	books, err := dao.GetBooks(tb.Context())
 	if err != nil {
-		return 1
+		tb.Fatalf("createTestBook 获取图书列表失败: %v", err)
+	}
+	for _, b := range books {
+		if b.Title == book.Title {
+			return b.ID
+		}
 	}
-	return int(id64)
+	tb.Fatalf("createTestBook 未找到添加的图书: %s", book.Title)
+	return 0
 }
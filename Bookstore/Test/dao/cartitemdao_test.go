@@ -1,6 +1,7 @@
-package dao
+package dao_test
 
 import (
+	"bookstore/dao"
 	"bookstore/model"
 	"bookstore/utils"
 	"fmt"
@@ -14,13 +15,13 @@ func TestCartItemDAO(t *testing.T) {
 	defer cleanupTestUserByID(t, testUserID)
 
 	// 添加测试图书
-	book := &model.Book{Title: "CartItemTestBook", Author: "Tester", Price: 9.99, Sales: 0, Stock: 10, ImgPath: "/static/img/test.jpg"}
-	err := AddBook(book)
+	book := &model.Book{Title: "CartItemTestBook", Author: "Tester", Price: model.Cents(999), Sales: 0, Stock: 10, ImgPath: "/static/img/test.jpg"}
+	err := dao.AddBook(t.Context(), book)
 	if err != nil {
 		t.Fatalf("AddBook failed: %v", err)
 	}
 	// 查找刚添加的图书ID
-	books, err := GetBooks()
+	books, err := dao.GetBooks(t.Context())
 	if err != nil {
 		t.Fatalf("GetBooks failed: %v", err)
 	}
@@ -40,22 +41,22 @@ func TestCartItemDAO(t *testing.T) {
 	// 创建购物车
 	cartID := utils.CreateUUID()
 	cart := &model.Cart{CartID: cartID, UserID: testUserID}
-	err = AddCart(cart)
+	err = dao.AddCart(t.Context(), cart)
 	if err != nil {
 		t.Fatalf("AddCart failed: %v", err)
 	}
-	// defer DeleteCartByCartID(cartID) // wrap to explicitly ignore returned error
-	defer func() { _ = DeleteCartByCartID(cartID) }()
+	// defer dao.DeleteCartByCartID(t.Context(), cartID) // wrap to explicitly ignore returned error
+	defer func() { _ = dao.DeleteCartByCartID(t.Context(), cartID) }()
 
 	// 添加购物项
-	ci := &model.CartItem{Book: &model.Book{ID: bookID, Price: 9.99}, Count: 2, CartID: cartID}
-	err = AddCartItem(ci)
+	ci := &model.CartItem{Book: &model.Book{ID: bookID, Price: model.Cents(999)}, Count: 2, CartID: cartID}
+	err = dao.AddCartItem(t.Context(), ci)
 	if err != nil {
 		t.Fatalf("AddCartItem failed: %v", err)
 	}
 
 	// 获取购物项
-	got, err := GetCartItemByBookIDAndCartID(fmt.Sprintf("%d", bookID), cartID)
+	got, err := dao.GetCartItemByBookIDAndCartID(t.Context(), fmt.Sprintf("%d", bookID), cartID)
 	if err != nil {
 		t.Fatalf("GetCartItemByBookIDAndCartID failed: %v", err)
 	}
@@ -71,12 +72,12 @@ func TestCartItemDAO(t *testing.T) {
 
 	// 更新购物项数量
 	got.Count = 5
-	err = UpdateBookCount(got)
+	err = dao.UpdateBookCount(t.Context(), got)
 	if err != nil {
 		t.Fatalf("UpdateBookCount failed: %v", err)
 	}
 	// 重新查询验证
-	got2, err := GetCartItemByBookIDAndCartID(fmt.Sprintf("%d", bookID), cartID)
+	got2, err := dao.GetCartItemByBookIDAndCartID(t.Context(), fmt.Sprintf("%d", bookID), cartID)
 	if err != nil {
 		t.Fatalf("requery failed: %v", err)
 	}
@@ -85,7 +86,7 @@ func TestCartItemDAO(t *testing.T) {
 	}
 
 	// 获取购物车所有购物项
-	items, err := GetCartItemsByCartID(cartID)
+	items, err := dao.GetCartItemsByCartID(t.Context(), cartID)
 	if err != nil {
 		t.Fatalf("GetCartItemsByCartID failed: %v", err)
 	}
@@ -94,29 +95,29 @@ func TestCartItemDAO(t *testing.T) {
 	}
 
 	// 删除单个购物项
-	err = DeleteCartItemByID(fmt.Sprintf("%d", got2.CartItemID))
+	err = dao.DeleteCartItemByID(t.Context(), fmt.Sprintf("%d", got2.CartItemID))
 	if err != nil {
 		t.Fatalf("DeleteCartItemByID failed: %v", err)
 	}
 
 	// 确认已删除
-	_, err = GetCartItemByBookIDAndCartID(fmt.Sprintf("%d", bookID), cartID)
+	_, err = dao.GetCartItemByBookIDAndCartID(t.Context(), fmt.Sprintf("%d", bookID), cartID)
 	if err == nil {
 		t.Fatalf("expected error after deleting cart item, got nil")
 	}
 
 	// 添加多个购物项并测试按cart清除
-	ci1 := &model.CartItem{Book: &model.Book{ID: bookID, Price: 9.99}, Count: 1, CartID: cartID}
-	ci2 := &model.CartItem{Book: &model.Book{ID: bookID, Price: 9.99}, Count: 3, CartID: cartID}
-	_ = AddCartItem(ci1)
-	_ = AddCartItem(ci2)
+	ci1 := &model.CartItem{Book: &model.Book{ID: bookID, Price: model.Cents(999)}, Count: 1, CartID: cartID}
+	ci2 := &model.CartItem{Book: &model.Book{ID: bookID, Price: model.Cents(999)}, Count: 3, CartID: cartID}
+	_ = dao.AddCartItem(t.Context(), ci1)
+	_ = dao.AddCartItem(t.Context(), ci2)
 
-	err = DeleteCartItemsByCartID(cartID)
+	err = dao.DeleteCartItemsByCartID(t.Context(), cartID)
 	if err != nil {
 		t.Fatalf("DeleteCartItemsByCartID failed: %v", err)
 	}
 	// 确认全部删除
-	itemsAfter, err := GetCartItemsByCartID(cartID)
+	itemsAfter, err := dao.GetCartItemsByCartID(t.Context(), cartID)
 	if err != nil {
 		// if table empty it may return nil,nil or nil,sql.ErrNoRows depending; just ensure length 0
 		itemsAfter = []*model.CartItem{}
@@ -1,8 +1,10 @@
-package dao
+package dao_test
 
 import (
+	"bookstore/dao"
 	"bookstore/model"
 	"bookstore/utils"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -11,26 +13,23 @@ import (
 // TestOrderFlow 测试订单流程
 func TestOrderFlow(t *testing.T) {
 	// 准备测试数据
-	testUserID := 777
-
-	// 确保测试环境满足：测试用户存在、orders 表包含 create_time 列（有些环境缺失）
-	ensureTestSetup(t, testUserID)
+	testUserID := createTestUser(t)
 
 	testBooks := []*model.Book{
-		{Title: "订单测试图书1", Author: "订单测试作者1", Price: 10.00, Sales: 0, Stock: 100, ImgPath: "/static/img/order1.jpg"},
-		{Title: "订单测试图书2", Author: "订单测试作者2", Price: 20.00, Sales: 0, Stock: 100, ImgPath: "/static/img/order2.jpg"},
-		{Title: "订单测试图书3", Author: "订单测试作者3", Price: 30.00, Sales: 0, Stock: 100, ImgPath: "/static/img/order3.jpg"},
+		{Title: "订单测试图书1", Author: "订单测试作者1", Price: model.Cents(1000), Sales: 0, Stock: 100, ImgPath: "/static/img/order1.jpg"},
+		{Title: "订单测试图书2", Author: "订单测试作者2", Price: model.Cents(2000), Sales: 0, Stock: 100, ImgPath: "/static/img/order2.jpg"},
+		{Title: "订单测试图书3", Author: "订单测试作者3", Price: model.Cents(3000), Sales: 0, Stock: 100, ImgPath: "/static/img/order3.jpg"},
 	}
 
 	var addedBookIDs []int
 	for _, book := range testBooks {
-		err := AddBook(book)
+		err := dao.AddBook(t.Context(), book)
 		if err != nil {
 			t.Errorf("添加测试图书失败: %v", err)
 		}
 
 		// 获取刚添加的图书ID
-		books, err := GetBooks()
+		books, err := dao.GetBooks(t.Context())
 		if err != nil {
 			t.Errorf("获取图书列表失败: %v", err)
 		}
@@ -63,13 +62,13 @@ func TestOrderFlow(t *testing.T) {
 
 		// 创建购物项
 		cartItems := []*model.CartItem{
-			{Book: &model.Book{ID: addedBookIDs[0], Price: 10.00}, Count: 2, CartID: cartID},
-			{Book: &model.Book{ID: addedBookIDs[1], Price: 20.00}, Count: 1, CartID: cartID},
+			{Book: &model.Book{ID: addedBookIDs[0], Price: model.Cents(1000)}, Count: 2, CartID: cartID},
+			{Book: &model.Book{ID: addedBookIDs[1], Price: model.Cents(2000)}, Count: 1, CartID: cartID},
 		}
 		cart.CartItems = cartItems
 
 		// 添加购物车到数据库
-		err := AddCart(cart)
+		err := dao.AddCart(t.Context(), cart)
 		if err != nil {
 			t.Errorf("添加购物车失败: %v", err)
 		}
@@ -87,13 +86,13 @@ func TestOrderFlow(t *testing.T) {
 		}
 
 		// 添加订单到数据库
-		err = AddOrder(order)
+		err = dao.AddOrder(t.Context(), order)
 		if err != nil {
 			t.Errorf("添加订单失败: %v", err)
 		}
 
 		// 验证订单是否成功创建
-		orders, err := GetOrders()
+		orders, err := dao.GetOrders(t.Context())
 		if err != nil {
 			t.Errorf("获取订单列表失败: %v", err)
 		}
@@ -120,9 +119,9 @@ func TestOrderFlow(t *testing.T) {
 		}
 
 		// 验证订单总价计算
-		expectedTotalAmount := (2 * 10.00) + (1 * 20.00) // 40.00
+		expectedTotalAmount := model.Cents(4000) // 40.00
 		if foundOrder.TotalAmount != expectedTotalAmount {
-			t.Errorf("订单总金额不匹配，期望: %.2f, 实际: %.2f", expectedTotalAmount, foundOrder.TotalAmount)
+			t.Errorf("订单总金额不匹配，期望: %s, 实际: %s", expectedTotalAmount, foundOrder.TotalAmount)
 		}
 
 		expectedTotalCount := int64(2 + 1) // 3
@@ -142,13 +141,13 @@ func TestOrderFlow(t *testing.T) {
 				OrderID: orderID,
 			}
 
-			err = AddOrderItem(orderItem)
+			err = dao.AddOrderItem(t.Context(), orderItem)
 			if err != nil {
 				t.Errorf("添加订单项失败: %v", err)
 			}
 
 			// 验证订单项
-			orderItems, err := GetOrderItemsByOrderID(orderID)
+			orderItems, err := dao.GetOrderItemsByOrderID(t.Context(), orderID)
 			if err != nil {
 				t.Errorf("获取订单项失败: %v", err)
 			}
@@ -167,9 +166,9 @@ func TestOrderFlow(t *testing.T) {
 				t.Errorf("订单项数量不匹配，期望: %d, 实际: %d", cartItem.Count, lastOrderItem.Count)
 			}
 
-			expectedAmount := float64(cartItem.Count) * cartItem.Book.Price
+			expectedAmount := cartItem.Book.Price.Mul(cartItem.Count)
 			if lastOrderItem.Amount != expectedAmount {
-				t.Errorf("订单项金额不匹配，期望: %.2f, 实际: %.2f", expectedAmount, lastOrderItem.Amount)
+				t.Errorf("订单项金额不匹配，期望: %s, 实际: %s", expectedAmount, lastOrderItem.Amount)
 			}
 		}
 	})
@@ -183,24 +182,24 @@ func TestOrderFlow(t *testing.T) {
 			OrderID:     orderID,
 			CreateTime:  timeStr,
 			TotalCount:  1,
-			TotalAmount: 10.00,
-			State:       0, // 未发货
+			TotalAmount: model.Cents(1000),
+			State:       model.OrderStatePaid, // 已付款，等待发货
 			UserID:      int64(testUserID),
 		}
 
-		err := AddOrder(order)
+		err := dao.AddOrder(t.Context(), order)
 		if err != nil {
 			t.Errorf("添加订单失败: %v", err)
 		}
 
-		// 测试发货（状态从0更新为1）
-		err = UpdateOrderState(orderID, 1)
+		// 测试发货
+		err = dao.TransitionOrder(t.Context(), orderID, model.OrderStateShipped, testUserID)
 		if err != nil {
 			t.Errorf("更新订单状态失败: %v", err)
 		}
 
 		// 验证订单状态
-		orders, err := GetOrders()
+		orders, err := dao.GetOrders(t.Context())
 		if err != nil {
 			t.Errorf("获取订单列表失败: %v", err)
 		}
@@ -218,18 +217,18 @@ func TestOrderFlow(t *testing.T) {
 			return
 		}
 
-		if foundOrder.State != 1 {
-			t.Errorf("订单状态不匹配，期望: 1, 实际: %d", foundOrder.State)
+		if foundOrder.State != model.OrderStateShipped {
+			t.Errorf("订单状态不匹配，期望: %d, 实际: %d", model.OrderStateShipped, foundOrder.State)
 		}
 
-		// 测试收货（状态从1更新为2）
-		err = UpdateOrderState(orderID, 2)
+		// 测试收货
+		err = dao.TransitionOrder(t.Context(), orderID, model.OrderStateCompleted, testUserID)
 		if err != nil {
 			t.Errorf("更新订单状态失败: %v", err)
 		}
 
 		// 验证订单状态
-		orders, err = GetOrders()
+		orders, err = dao.GetOrders(t.Context())
 		if err != nil {
 			t.Errorf("获取订单列表失败: %v", err)
 		}
@@ -247,15 +246,15 @@ func TestOrderFlow(t *testing.T) {
 			return
 		}
 
-		if foundOrder.State != 2 {
-			t.Errorf("订单状态不匹配，期望: 2, 实际: %d", foundOrder.State)
+		if foundOrder.State != model.OrderStateCompleted {
+			t.Errorf("订单状态不匹配，期望: %d, 实际: %d", model.OrderStateCompleted, foundOrder.State)
 		}
 	})
 
 	// 测试库存扣减
 	t.Run("测试库存扣减", func(t *testing.T) {
 		// 获取测试图书的初始库存
-		initialBook, err := GetBookByID(fmt.Sprintf("%d", addedBookIDs[0]))
+		initialBook, err := dao.GetBookByID(t.Context(), fmt.Sprintf("%d", addedBookIDs[0]))
 		if err != nil {
 			t.Errorf("获取图书失败: %v", err)
 		}
@@ -272,14 +271,14 @@ func TestOrderFlow(t *testing.T) {
 
 		// 创建购物项
 		cartItem := &model.CartItem{
-			Book:   &model.Book{ID: addedBookIDs[0], Price: 10.00},
+			Book:   &model.Book{ID: addedBookIDs[0], Price: model.Cents(1000)},
 			Count:  3,
 			CartID: cartID,
 		}
 		cart.CartItems = []*model.CartItem{cartItem}
 
 		// 添加购物车到数据库
-		err = AddCart(cart)
+		err = dao.AddCart(t.Context(), cart)
 		if err != nil {
 			t.Errorf("添加购物车失败: %v", err)
 		}
@@ -297,14 +296,14 @@ func TestOrderFlow(t *testing.T) {
 		}
 
 		// 添加订单到数据库
-		err = AddOrder(order)
+		err = dao.AddOrder(t.Context(), order)
 		if err != nil {
 			t.Errorf("添加订单失败: %v", err)
 		}
 
 		// 模拟库存扣减和销量更新
 		// 从数据库中读取完整的图书信息再更新，避免使用只包含ID/Price的局部对象导致字段丢失
-		dbBook, err := GetBookByID(fmt.Sprintf("%d", cartItem.Book.ID))
+		dbBook, err := dao.GetBookByID(t.Context(), fmt.Sprintf("%d", cartItem.Book.ID))
 		if err != nil {
 			t.Errorf("获取数据库中图书失败: %v", err)
 		} else {
@@ -312,14 +311,14 @@ func TestOrderFlow(t *testing.T) {
 			dbBook.Stock = dbBook.Stock - int(cartItem.Count)
 
 			// 更新图书信息
-			err = UpdateBook(dbBook)
+			err = dao.UpdateBook(t.Context(), dbBook)
 			if err != nil {
 				t.Errorf("更新图书信息失败: %v", err)
 			}
 		}
 
 		// 验证库存扣减
-		updatedBook, err := GetBookByID(fmt.Sprintf("%d", addedBookIDs[0]))
+		updatedBook, err := dao.GetBookByID(t.Context(), fmt.Sprintf("%d", addedBookIDs[0]))
 		if err != nil {
 			t.Errorf("获取更新后的图书失败: %v", err)
 		}
@@ -338,7 +337,7 @@ func TestOrderFlow(t *testing.T) {
 	// 测试销量更新
 	t.Run("测试销量更新", func(t *testing.T) {
 		// 获取测试图书的初始销量
-		initialBook, err := GetBookByID(fmt.Sprintf("%d", addedBookIDs[1]))
+		initialBook, err := dao.GetBookByID(t.Context(), fmt.Sprintf("%d", addedBookIDs[1]))
 		if err != nil {
 			t.Errorf("获取图书失败: %v", err)
 		}
@@ -354,14 +353,14 @@ func TestOrderFlow(t *testing.T) {
 
 		// 创建购物项
 		cartItem := &model.CartItem{
-			Book:   &model.Book{ID: addedBookIDs[1], Price: 20.00},
+			Book:   &model.Book{ID: addedBookIDs[1], Price: model.Cents(2000)},
 			Count:  2,
 			CartID: cartID,
 		}
 		cart.CartItems = []*model.CartItem{cartItem}
 
 		// 添加购物车到数据库
-		err = AddCart(cart)
+		err = dao.AddCart(t.Context(), cart)
 		if err != nil {
 			t.Errorf("添加购物车失败: %v", err)
 		}
@@ -379,7 +378,7 @@ func TestOrderFlow(t *testing.T) {
 		}
 
 		// 添加订单到数据库
-		err = AddOrder(order)
+		err = dao.AddOrder(t.Context(), order)
 		if err != nil {
 			t.Errorf("添加订单失败: %v", err)
 		}
@@ -389,13 +388,13 @@ func TestOrderFlow(t *testing.T) {
 		book.Sales = book.Sales + int(cartItem.Count)
 
 		// 更新图书信息
-		err = UpdateBook(book)
+		err = dao.UpdateBook(t.Context(), book)
 		if err != nil {
 			t.Errorf("更新图书信息失败: %v", err)
 		}
 
 		// 验证销量更新
-		updatedBook, err := GetBookByID(fmt.Sprintf("%d", addedBookIDs[1]))
+		updatedBook, err := dao.GetBookByID(t.Context(), fmt.Sprintf("%d", addedBookIDs[1]))
 		if err != nil {
 			t.Errorf("获取更新后的图书失败: %v", err)
 		}
@@ -417,12 +416,12 @@ func TestOrderFlow(t *testing.T) {
 				OrderID:     orderID,
 				CreateTime:  timeStr,
 				TotalCount:  int64(i + 1),
-				TotalAmount: float64((i + 1) * 10),
+				TotalAmount: model.Cents(int64(i+1) * 1000),
 				State:       int64(i % 3), // 0, 1, 2
 				UserID:      int64(testUserID),
 			}
 
-			err := AddOrder(order)
+			err := dao.AddOrder(t.Context(), order)
 			if err != nil {
 				t.Errorf("添加订单失败: %v", err)
 			}
@@ -431,7 +430,7 @@ func TestOrderFlow(t *testing.T) {
 		}
 
 		// 测试获取所有订单
-		allOrders, err := GetOrders()
+		allOrders, err := dao.GetOrders(t.Context())
 		if err != nil {
 			t.Errorf("获取所有订单失败: %v", err)
 		}
@@ -458,7 +457,7 @@ func TestOrderFlow(t *testing.T) {
 		}
 
 		// 测试获取我的订单
-		myOrders, err := GetMyOrders(testUserID)
+		myOrders, err := dao.GetMyOrders(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取我的订单失败: %v", err)
 		}
@@ -476,7 +475,7 @@ func TestOrderFlow(t *testing.T) {
 	})
 }
 
-func cleanupTestBook(t *testing.T, bookID interface{}) {
+func cleanupTestBook(tb testing.TB, bookID interface{}) {
 	var idStr string
 	switch v := bookID.(type) {
 	case int:
@@ -484,25 +483,21 @@ func cleanupTestBook(t *testing.T, bookID interface{}) {
 	case string:
 		idStr = v
 	default:
-		t.Logf("不支持的bookID类型: %T", bookID)
+		tb.Logf("不支持的bookID类型: %T", bookID)
 		return
 	}
 
-	// 先删除与该图书关联的购物项（cart_items）和订单项（order_items），避免外键约束
-	_, _ = utils.Db.Exec("DELETE FROM cart_items WHERE book_id = ?", idStr)
-	_, _ = utils.Db.Exec("DELETE FROM order_items WHERE title IN (SELECT title FROM books WHERE id = ?)", idStr)
+	// 先删除与该图书关联的购物项（cart_items），避免外键约束，订单项只保存图书的快照
+	_, _ = utils.Db.ExecContext(tb.Context(), "DELETE FROM cart_items WHERE book_id = ?", idStr)
 
-	sqlStr := "DELETE FROM books WHERE id = ?"
-	_, err := utils.Db.Exec(sqlStr, idStr)
-	if err != nil {
-		t.Logf("清理测试图书失败: %v", err)
+	if err := dao.DeleteBook(tb.Context(), idStr); err != nil {
+		tb.Logf("清理测试图书失败: %v", err)
 	}
 }
 
 // TestOrderDataValidation 测试订单数据验证
 func TestOrderDataValidation(t *testing.T) {
-	testUserID := 776
-	ensureTestSetup(t, testUserID)
+	testUserID := createTestUser(t)
 
 	defer func() {
 		cleanupTestOrder(t, testUserID)
@@ -521,7 +516,7 @@ func TestOrderDataValidation(t *testing.T) {
 				OrderID:     utils.CreateUUID(),
 				CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
 				TotalCount:  1,
-				TotalAmount: 10.00,
+				TotalAmount: model.Cents(1000),
 				State:       0,
 				UserID:      int64(testUserID),
 			},
@@ -534,7 +529,7 @@ func TestOrderDataValidation(t *testing.T) {
 				OrderID:     utils.CreateUUID(),
 				CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
 				TotalCount:  1,
-				TotalAmount: 0.00,
+				TotalAmount: model.Cents(0),
 				State:       0,
 				UserID:      int64(testUserID),
 			},
@@ -547,7 +542,7 @@ func TestOrderDataValidation(t *testing.T) {
 				OrderID:     utils.CreateUUID(),
 				CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
 				TotalCount:  1,
-				TotalAmount: -10.00,
+				TotalAmount: model.Cents(-1000),
 				State:       0,
 				UserID:      int64(testUserID),
 			},
@@ -560,7 +555,7 @@ func TestOrderDataValidation(t *testing.T) {
 				OrderID:     utils.CreateUUID(),
 				CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
 				TotalCount:  0,
-				TotalAmount: 10.00,
+				TotalAmount: model.Cents(1000),
 				State:       0,
 				UserID:      int64(testUserID),
 			},
@@ -573,7 +568,7 @@ func TestOrderDataValidation(t *testing.T) {
 				OrderID:     utils.CreateUUID(),
 				CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
 				TotalCount:  1,
-				TotalAmount: 999999.99,
+				TotalAmount: model.Cents(99999999),
 				State:       0,
 				UserID:      int64(testUserID),
 			},
@@ -586,7 +581,7 @@ func TestOrderDataValidation(t *testing.T) {
 				OrderID:     utils.CreateUUID(),
 				CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
 				TotalCount:  1,
-				TotalAmount: 10.00,
+				TotalAmount: model.Cents(1000),
 				State:       2, // 已完成
 				UserID:      int64(testUserID),
 			},
@@ -597,7 +592,7 @@ func TestOrderDataValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := AddOrder(tt.order)
+			err := dao.AddOrder(t.Context(), tt.order)
 
 			if tt.expectError {
 				if err == nil {
@@ -608,7 +603,7 @@ func TestOrderDataValidation(t *testing.T) {
 					t.Errorf("不期望返回错误，但返回了错误: %v, %s", err, tt.description)
 				} else {
 					// 验证订单是否成功创建
-					orders, err := GetOrders()
+					orders, err := dao.GetOrders(t.Context())
 					if err != nil {
 						t.Errorf("获取订单列表失败: %v", err)
 					}
@@ -632,8 +627,7 @@ func TestOrderDataValidation(t *testing.T) {
 
 // TestOrderConcurrentOperations 测试订单并发操作
 func TestOrderConcurrentOperations(t *testing.T) {
-	testUserID := 775
-	ensureTestSetup(t, testUserID)
+	testUserID := createTestUser(t)
 
 	defer func() {
 		cleanupTestOrder(t, testUserID)
@@ -654,12 +648,12 @@ func TestOrderConcurrentOperations(t *testing.T) {
 					OrderID:     orderID,
 					CreateTime:  timeStr,
 					TotalCount:  int64(index + 1),
-					TotalAmount: float64((index + 1) * 10),
+					TotalAmount: model.Cents(int64(index+1) * 1000),
 					State:       0,
 					UserID:      int64(testUserID),
 				}
 
-				err := AddOrder(order)
+				err := dao.AddOrder(t.Context(), order)
 				if err != nil {
 					t.Errorf("并发创建订单失败: %v", err)
 				}
@@ -672,7 +666,7 @@ func TestOrderConcurrentOperations(t *testing.T) {
 		}
 
 		// 验证订单创建
-		orders, err := GetMyOrders(testUserID)
+		orders, err := dao.GetMyOrders(t.Context(), testUserID)
 		if err != nil {
 			t.Errorf("获取我的订单失败: %v", err)
 		}
@@ -691,38 +685,43 @@ func TestOrderConcurrentOperations(t *testing.T) {
 			OrderID:     orderID,
 			CreateTime:  timeStr,
 			TotalCount:  1,
-			TotalAmount: 10.00,
-			State:       0,
+			TotalAmount: model.Cents(1000),
+			State:       model.OrderStatePaid,
 			UserID:      int64(testUserID),
 		}
 
-		err := AddOrder(order)
+		err := dao.AddOrder(t.Context(), order)
 		if err != nil {
 			t.Errorf("创建订单失败: %v", err)
 		}
 
-		done := make(chan bool, 10)
+		// 同时发货，只有一次能成功，其余的都是非法的状态变更
+		errs := make(chan error, 10)
 
 		for i := 0; i < 10; i++ {
-			go func(index int) {
-				defer func() { done <- true }()
-
-				// 更新订单状态
-				state := int64(index % 3) // 0, 1, 2
-				err := UpdateOrderState(orderID, state)
-				if err != nil {
-					t.Errorf("并发更新订单状态失败: %v", err)
-				}
-			}(i)
+			go func() {
+				errs <- dao.TransitionOrder(t.Context(), orderID, model.OrderStateShipped, testUserID)
+			}()
 		}
 
 		// 等待所有goroutine完成
+		var shipped int
 		for i := 0; i < 10; i++ {
-			<-done
+			err := <-errs
+			var stateErr *dao.IllegalTransitionError
+			switch {
+			case err == nil:
+				shipped++
+			case !errors.As(err, &stateErr):
+				t.Errorf("并发更新订单状态失败: %v", err)
+			}
+		}
+		if shipped != 1 {
+			t.Errorf("并发发货成功的次数不对，期望: 1, 实际: %d", shipped)
 		}
 
 		// 验证订单状态
-		orders, err := GetOrders()
+		orders, err := dao.GetOrders(t.Context())
 		if err != nil {
 			t.Errorf("获取订单列表失败: %v", err)
 		}
@@ -739,17 +738,19 @@ func TestOrderConcurrentOperations(t *testing.T) {
 			t.Error("订单应该存在")
 			return
 		}
+
+		if foundOrder.State != model.OrderStateShipped {
+			t.Errorf("订单状态不匹配，期望: %d, 实际: %d", model.OrderStateShipped, foundOrder.State)
+		}
 	})
 }
 
 // BenchmarkAddOrder 性能测试
 func BenchmarkAddOrder(b *testing.B) {
-	testUserID := 774
-	ensureTestSetup(nil, testUserID)
+	testUserID := createTestUser(b)
 
 	defer func() {
-		cleanupTestOrder(nil, testUserID)
-		cleanupTestUserByID(nil, testUserID)
+		cleanupTestUserByID(b, testUserID)
 	}()
 
 	b.ResetTimer()
@@ -760,17 +761,16 @@ func BenchmarkAddOrder(b *testing.B) {
 			OrderID:     orderID,
 			CreateTime:  timeStr,
 			TotalCount:  int64(i + 1),
-			TotalAmount: float64((i + 1) * 10),
+			TotalAmount: model.Cents(int64(i+1) * 1000),
 			State:       0,
 			UserID:      int64(testUserID),
 		}
-		AddOrder(order)
+		dao.AddOrder(b.Context(), order)
 	}
 }
 
 func BenchmarkGetMyOrders(b *testing.B) {
-	testUserID := 773
-	ensureTestSetup(nil, testUserID)
+	testUserID := createTestUser(b)
 
 	// 准备测试数据
 	orderID := utils.CreateUUID()
@@ -779,155 +779,65 @@ func BenchmarkGetMyOrders(b *testing.B) {
 		OrderID:     orderID,
 		CreateTime:  timeStr,
 		TotalCount:  1,
-		TotalAmount: 10.00,
+		TotalAmount: model.Cents(1000),
 		State:       0,
 		UserID:      int64(testUserID),
 	}
 
-	AddOrder(order)
+	dao.AddOrder(b.Context(), order)
 
 	defer func() {
-		cleanupTestOrder(nil, testUserID)
-		cleanupTestUserByID(nil, testUserID)
+		cleanupTestUserByID(b, testUserID)
 	}()
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		GetMyOrders(testUserID)
+		dao.GetMyOrders(b.Context(), testUserID)
 	}
 }
 
 // cleanup and helpers
 // cleanupTestOrder 清理测试订单
-func cleanupTestOrder(t *testing.T, userID int) {
+func cleanupTestOrder(tb testing.TB, userID int) {
 	// 获取用户的订单
-	orders, err := GetMyOrders(userID)
+	orders, err := dao.GetMyOrders(tb.Context(), userID)
 	if err != nil {
-		if t != nil {
-			t.Logf("获取订单失败: %v", err)
-		}
+		tb.Logf("获取订单失败: %v", err)
 		return
 	}
-
-	// 删除所有订单
+	// 删除所有订单以及引用订单的订单项、订单历史和支付记录
 	for _, order := range orders {
-		// 删除订单项
-		sqlStr := "DELETE FROM order_items WHERE order_id = ?"
-		_, err := utils.Db.Exec(sqlStr, order.OrderID)
-		if err != nil {
-			if t != nil {
-				t.Logf("删除订单项失败: %v", err)
-			}
-		}
-
-		// 删除订单
-		sqlStr = "DELETE FROM orders WHERE id = ?"
-		_, err = utils.Db.Exec(sqlStr, order.OrderID)
-		if err != nil {
-			if t != nil {
-				t.Logf("删除订单失败: %v", err)
-			}
-		}
+		deleteTestOrder(tb, order.OrderID)
 	}
 }
 
-// ensureTestSetup 确保测试用户存在并修复缺失的 create_time 列（t 可以为 nil）
-func ensureTestSetup(t *testing.T, userID int) {
-	// 确保用户存在
-	err := ensureTestUser(t, userID)
-	if err != nil {
-		if t != nil {
-			t.Fatalf("ensureTestUser 失败: %v", err)
-		}
-		return
-	}
-
-	// 确保 orders.create_time 列存在（某些测试 DB 可能缺失）
-	var cnt int
-	row := utils.Db.QueryRow("SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS WHERE table_schema = DATABASE() AND table_name = 'orders' AND column_name = 'create_time'")
-	err = row.Scan(&cnt)
-	if err != nil {
-		if t != nil {
-			t.Logf("检查 create_time 列时出错: %v", err)
+// deleteTestOrder 删除一个订单，先删除引用它的数据以满足外键约束
+func deleteTestOrder(tb testing.TB, orderID string) {
+	for _, sqlStr := range []string{
+		"DELETE FROM order_items WHERE order_id = ?",
+		"DELETE FROM order_history WHERE order_id = ?",
+		"DELETE FROM payments WHERE order_id = ?",
+		"DELETE FROM orders WHERE id = ?",
+	} {
+		if _, err := utils.Db.ExecContext(tb.Context(), sqlStr, orderID); err != nil {
+			tb.Logf("删除订单失败(%s): %v", sqlStr, err)
 		}
-		return
 	}
-	if cnt == 0 {
-		// 列不存在，尝试添加（兼容大多数 MySQL 版本）
-		_, err := utils.Db.Exec("ALTER TABLE orders ADD COLUMN create_time DATETIME NULL DEFAULT NULL")
-		if err != nil {
-			if t != nil {
-				t.Logf("尝试添加 create_time 列失败: %v", err)
-			}
-			// 不致命：如果不能添加，后续 Insert 会报错，测试会按原样失败以提示手动修复
-		}
-	}
-}
-
-// ensureTestUser 创建测试用户（尽量兼容不同 users 表结构），返回 error 而不直接 Fatal，以便在 benchmark 中使用 nil t
-func ensureTestUser(t *testing.T, userID int) error {
-	// 尝试常见列集合
-	sqls := []string{
-		"INSERT IGNORE INTO users (id, username, password, email) VALUES (?, ?, ?, ?)",
-		"INSERT IGNORE INTO users (id, username, password) VALUES (?, ?, ?)",
-		"INSERT IGNORE INTO users (id, username) VALUES (?, ?)",
-	}
-	var lastErr error
-	for _, s := range sqls {
-		var err error
-		switch s {
-		case sqls[0]:
-			_, err = utils.Db.Exec(s, userID, fmt.Sprintf("test_user_%d", userID), "password", fmt.Sprintf("test_%d@example.com", userID))
-		case sqls[1]:
-			_, err = utils.Db.Exec(s, userID, fmt.Sprintf("test_user_%d", userID), "password")
-		case sqls[2]:
-			_, err = utils.Db.Exec(s, userID, fmt.Sprintf("test_user_%d", userID))
-		}
-		if err == nil {
-			return nil
-		}
-		lastErr = err
-		if t != nil {
-			t.Logf("ensureTestUser 尝试失败(%s): %v", s, err)
-		}
-	}
-	// 如果所有尝试都失败，返回最后一个错误
-	return fmt.Errorf("无法插入测试用户(尝试多种列组合)，请在测试 DB 中手动创建用户 id=%d: 最后错误: %v", userID, lastErr)
 }
 
-// cleanupTestUserByID 删除测试用户，t 可以为 nil
-func cleanupTestUserByID(t *testing.T, userID int) {
+// cleanupTestUserByID 删除测试用户以及属于他的会话、购物车、订单和收货地址
+func cleanupTestUserByID(tb testing.TB, userID int) {
+	ctx := tb.Context()
 	// 删除 session
-	_, _ = utils.Db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
-	// 删除购物项（先找到 carts）
-	rows, err := utils.Db.Query("SELECT id FROM carts WHERE user_id = ?", userID)
-	if err == nil {
-		var cartID string
-		for rows.Next() {
-			rows.Scan(&cartID)
-			_, _ = utils.Db.Exec("DELETE FROM cart_items WHERE cart_id = ?", cartID)
-		}
-		rows.Close()
-	}
-	// 删除购物车
-	_, _ = utils.Db.Exec("DELETE FROM carts WHERE user_id = ?", userID)
-	// 删除该用户的订单项和订单
-	orderRows, err := utils.Db.Query("SELECT id FROM orders WHERE user_id = ?", userID)
-	if err == nil {
-		var orderID string
-		for orderRows.Next() {
-			orderRows.Scan(&orderID)
-			_, _ = utils.Db.Exec("DELETE FROM order_items WHERE order_id = ?", orderID)
-		}
-		orderRows.Close()
-	}
-	_, _ = utils.Db.Exec("DELETE FROM orders WHERE user_id = ?", userID)
-
+	_, _ = utils.Db.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = ?", userID)
+	// 删除购物车，DeleteCartByCartID 会一起删除购物项
+	cleanupTestCart(tb, userID)
+	// 删除该用户的订单，操作过的订单历史也要删除
+	cleanupTestOrder(tb, userID)
+	_, _ = utils.Db.ExecContext(ctx, "DELETE FROM order_history WHERE actor_id = ?", userID)
+	_, _ = utils.Db.ExecContext(ctx, "DELETE FROM addresses WHERE user_id = ?", userID)
 	// 最后删除用户
-	_, err = utils.Db.Exec("DELETE FROM users WHERE id = ?", userID)
-	if err != nil {
-		if t != nil {
-			t.Logf("cleanupTestUserByID 删除用户失败: %v", err)
-		}
+	if _, err := utils.Db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", userID); err != nil {
+		tb.Logf("cleanupTestUserByID 删除用户失败: %v", err)
 	}
 }
@@ -0,0 +1,460 @@
+package dao_test
+
+import (
+	"bookstore/config"
+	"bookstore/dao"
+	"bookstore/utils"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestMain 测试之前使用内存中的SQLite数据库初始化dao，所有的表都由迁移脚本创建
+func TestMain(m *testing.M) {
+	cfg := config.Default()
+	cfg.Database.Driver = utils.DriverSQLite
+	if err := dao.Setup(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "初始化数据库失败：", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	dao.Close()
+	os.Exit(code)
+}
+
+// TestSaveUser 测试用户注册功能
+func TestSaveUser(t *testing.T) {
+	// 测试正常注册
+	t.Run("测试正常注册", func(t *testing.T) {
+		// 清理可能存在的测试数据
+		cleanupTestUser(t, "testuser1")
+
+		// 测试正常注册
+		err := dao.SaveUser(t.Context(), "testuser1", "password123", "testuser1@example.com")
+		if err != nil {
+			t.Errorf("正常注册失败: %v", err)
+		}
+
+		// 验证用户是否成功创建
+		user, err := dao.CheckUserName(t.Context(), "testuser1")
+		if err != nil {
+			t.Errorf("查询用户失败: %v", err)
+		}
+		if user.ID == 0 {
+			t.Error("用户未成功创建")
+		}
+		if user.Username != "testuser1" {
+			t.Errorf("用户名不匹配，期望: testuser1, 实际: %s", user.Username)
+		}
+		if user.Email != "testuser1@example.com" {
+			t.Errorf("邮箱不匹配，期望: testuser1@example.com, 实际: %s", user.Email)
+		}
+
+		// 清理测试数据
+		cleanupTestUser(t, "testuser1")
+	})
+
+	// 测试用户名重复
+	t.Run("测试用户名重复", func(t *testing.T) {
+		// 先创建一个用户
+		err := dao.SaveUser(t.Context(), "duplicateuser", "password123", "duplicateuser@example.com")
+		if err != nil {
+			t.Errorf("创建初始用户失败: %v", err)
+		}
+
+		// 尝试用相同用户名创建用户
+		err = dao.SaveUser(t.Context(), "duplicateuser", "password456", "duplicateuser2@example.com")
+		if err == nil {
+			t.Error("应该返回用户名重复错误，但没有返回错误")
+		}
+
+		// 清理测试数据
+		cleanupTestUser(t, "duplicateuser")
+	})
+
+	// 测试邮箱重复
+	t.Run("测试邮箱重复", func(t *testing.T) {
+		// 使用时间戳确保唯一性
+		timestamp := time.Now().UnixNano()
+		username1 := fmt.Sprintf("user1_%d", timestamp)
+		username2 := fmt.Sprintf("user2_%d", timestamp)
+		email := fmt.Sprintf("duplicateemail_%d@example.com", timestamp)
+
+		// 先创建一个用户
+		err := dao.SaveUser(t.Context(), username1, "password123", email)
+		if err != nil {
+			t.Errorf("创建初始用户失败: %v", err)
+		}
+
+		// 尝试用相同邮箱创建用户
+		err = dao.SaveUser(t.Context(), username2, "password456", email)
+		if err == nil {
+			t.Error("应该返回邮箱重复错误，但没有返回错误")
+		}
+
+		// 清理测试数据
+		cleanupTestUser(t, username1)
+		cleanupTestUser(t, username2)
+	})
+}
+
+// TestCheckUserNameAndPassword 测试用户登录验证功能
+func TestCheckUserNameAndPassword(t *testing.T) {
+	// 准备测试数据
+	setupTestUser(t, "logintest", "password123", "logintest@example.com")
+	defer cleanupTestUser(t, "logintest")
+
+	// 测试正确用户名密码
+	t.Run("测试正确用户名密码", func(t *testing.T) {
+		user, err := dao.CheckUserNameAndPassword(t.Context(), "logintest", "password123")
+		if err != nil {
+			t.Errorf("查询用户失败: %v", err)
+		}
+		if user.ID == 0 {
+			t.Error("应该找到用户，但用户ID为0")
+		}
+		if user.Username != "logintest" {
+			t.Errorf("用户名不匹配，期望: logintest, 实际: %s", user.Username)
+		}
+		if ok, _ := utils.CheckPassword(user.Password, "password123"); !ok {
+			t.Errorf("保存的密码哈希与密码不匹配: %s", user.Password)
+		}
+	})
+
+	// 测试错误密码
+	t.Run("测试错误密码", func(t *testing.T) {
+		user, _ := dao.CheckUserNameAndPassword(t.Context(), "logintest", "wrongpassword")
+		// DAO may return empty user when not found; assert not found
+		if user != nil && user.ID != 0 && user.Username != "" {
+			t.Error("错误密码不应该找到用户，但找到了用户")
+		}
+	})
+
+	// 测试不存在的用户名
+	t.Run("测试不存在的用户名", func(t *testing.T) {
+		user, _ := dao.CheckUserNameAndPassword(t.Context(), "nonexistentuser", "password123")
+		if user != nil && user.ID != 0 && user.Username != "" {
+			t.Error("不存在的用户名不应该找到用户，但找到了用户")
+		}
+	})
+
+	// 测试空用户名
+	t.Run("测试空用户名", func(t *testing.T) {
+		user, _ := dao.CheckUserNameAndPassword(t.Context(), "", "password123")
+		if user != nil && user.ID != 0 && user.Username != "" {
+			t.Error("空用户名不应该找到用户，但找到了用户")
+		}
+	})
+
+	// 测试空密码
+	t.Run("测试空密码", func(t *testing.T) {
+		user, _ := dao.CheckUserNameAndPassword(t.Context(), "logintest", "")
+		if user != nil && user.ID != 0 && user.Username != "" {
+			t.Error("空密码不应该找到用户，但找到了用户")
+		}
+	})
+}
+
+// setupTestUser 创建测试用户
+func setupTestUser(t *testing.T, username, password, email string) {
+	err := dao.SaveUser(t.Context(), username, password, email)
+	if err != nil {
+		t.Fatalf("创建测试用户失败: %v", err)
+	}
+}
+
+// TestLoginFlow 测试登录流程
+func TestLoginFlow(t *testing.T) {
+	// 准备测试数据
+	testUsername := "logintestuser"
+	testPassword := "password123"
+	testEmail := "logintestuser@example.com"
+
+	// 创建测试用户
+	setupTestUser(t, testUsername, testPassword, testEmail)
+	testUser, err := dao.CheckUserName(t.Context(), testUsername)
+	if err != nil {
+		t.Fatalf("查询测试用户失败: %v", err)
+	}
+	defer func() {
+		cleanupTestUser(t, testUsername)
+		cleanupTestSession(t, testUsername)
+	}()
+
+	// 测试未登录状态访问
+	t.Run("测试未登录状态访问", func(t *testing.T) {
+		// 创建一个模拟的HTTP请求，不包含Cookie
+		req, err := http.NewRequest("GET", "/main", nil)
+		if err != nil {
+			t.Fatalf("创建请求失败: %v", err)
+		}
+
+		// 测试IsLogin函数
+		isLoggedIn, session := dao.IsLogin(req)
+		if isLoggedIn {
+			t.Error("未登录状态应该返回false，但返回了true")
+		}
+		if session != nil {
+			t.Error("未登录状态session应该为nil，但不为nil")
+		}
+	})
+
+	// 测试登录成功
+	t.Run("测试登录成功", func(t *testing.T) {
+		// 测试用户名密码验证
+		user, err := dao.CheckUserNameAndPassword(t.Context(), testUsername, testPassword)
+		if err != nil {
+			t.Errorf("验证用户失败: %v", err)
+		}
+		if user.ID == 0 {
+			t.Error("应该找到用户，但用户ID为0")
+		}
+		if user.Username != testUsername {
+			t.Errorf("用户名不匹配，期望: %s, 实际: %s", testUsername, user.Username)
+		}
+
+		// 测试Session创建
+		sessionID := utils.CreateUUID()
+		session := dao.NewSession(sessionID, user, time.Now())
+
+		// 添加Session到数据库
+		err = dao.AddSession(t.Context(), session)
+		if err != nil {
+			t.Errorf("添加Session失败: %v", err)
+		}
+
+		// 验证Session是否成功创建
+		retrievedSession, err := dao.GetSession(t.Context(), sessionID)
+		if err != nil {
+			t.Errorf("获取Session失败: %v", err)
+		}
+		if retrievedSession.SessionID != sessionID {
+			t.Errorf("Session ID不匹配，期望: %s, 实际: %s", sessionID, retrievedSession.SessionID)
+		}
+		if retrievedSession.UserName != testUsername {
+			t.Errorf("用户名不匹配，期望: %s, 实际: %s", testUsername, retrievedSession.UserName)
+		}
+
+		// 测试带Cookie的登录状态检查
+		req, err := http.NewRequest("GET", "/main", nil)
+		if err != nil {
+			t.Fatalf("创建请求失败: %v", err)
+		}
+
+		// 添加Cookie到请求
+		cookie := &http.Cookie{
+			Name:  "user",
+			Value: sessionID,
+		}
+		req.AddCookie(cookie)
+
+		// 测试IsLogin函数
+		isLoggedIn, session := dao.IsLogin(req)
+		if !isLoggedIn {
+			t.Error("已登录状态应该返回true，但返回了false")
+		}
+		if session == nil {
+			t.Error("已登录状态session不应该为nil")
+		}
+		if session.UserName != testUsername {
+			t.Errorf("Session用户名不匹配，期望: %s, 实际: %s", testUsername, session.UserName)
+		}
+
+		// 清理测试Session
+		cleanupTestSession(t, sessionID)
+	})
+
+	// 测试登录失败
+	t.Run("测试登录失败", func(t *testing.T) {
+		// 测试错误密码
+		user, _ := dao.CheckUserNameAndPassword(t.Context(), testUsername, "wrongpassword")
+		// DAO returns empty user when not found; assert no user found
+		if user.ID != 0 {
+			t.Error("错误密码不应该找到用户，但找到了用户")
+		}
+
+		// 测试不存在的用户名
+		user, _ = dao.CheckUserNameAndPassword(t.Context(), "nonexistentuser", testPassword)
+		if user.ID != 0 {
+			t.Error("不存在的用户名不应该找到用户，但找到了用户")
+		}
+
+		// 测试空用户名
+		user, _ = dao.CheckUserNameAndPassword(t.Context(), "", testPassword)
+		if user.ID != 0 {
+			t.Error("空用户名不应该找到用户，但找到了用户")
+		}
+
+		// 测试空密码
+		user, _ = dao.CheckUserNameAndPassword(t.Context(), testUsername, "")
+		if user.ID != 0 {
+			t.Error("空密码不应该找到用户，但找到了用户")
+		}
+	})
+
+	// 测试注销功能
+	t.Run("测试注销功能", func(t *testing.T) {
+		// 创建Session
+		sessionID := utils.CreateUUID()
+		session := dao.NewSession(sessionID, testUser, time.Now())
+
+		// 添加Session到数据库
+		err := dao.AddSession(t.Context(), session)
+		if err != nil {
+			t.Errorf("添加Session失败: %v", err)
+		}
+
+		// 验证Session存在
+		retrievedSession, err := dao.GetSession(t.Context(), sessionID)
+		if err != nil {
+			t.Errorf("获取Session失败: %v", err)
+		}
+		if retrievedSession == nil {
+			t.Error("Session应该存在，但为nil")
+		}
+
+		// 测试注销功能 - 删除Session
+		err = dao.DeleteSession(t.Context(), sessionID)
+		if err != nil {
+			t.Errorf("删除Session失败: %v", err)
+		}
+
+		// 验证Session已被删除
+		deletedSession, err := dao.GetSession(t.Context(), sessionID)
+		if err != nil {
+			t.Errorf("获取已删除的Session时发生错误: %v", err)
+		}
+		if deletedSession != nil && deletedSession.UserID > 0 {
+			t.Error("Session应该已被删除，但仍然存在")
+		}
+
+		// 测试注销后的登录状态检查
+		req, err := http.NewRequest("GET", "/main", nil)
+		if err != nil {
+			t.Fatalf("创建请求失败: %v", err)
+		}
+
+		// 添加已删除的Cookie到请求
+		cookie := &http.Cookie{
+			Name:  "user",
+			Value: sessionID,
+		}
+		req.AddCookie(cookie)
+
+		// 测试IsLogin函数
+		isLoggedIn, session := dao.IsLogin(req)
+		if isLoggedIn {
+			t.Error("注销后应该返回false，但返回了true")
+		}
+	})
+
+	// 测试Session过期处理
+	t.Run("测试Session过期处理", func(t *testing.T) {
+		// 创建Session
+		sessionID := utils.CreateUUID()
+		session := dao.NewSession(sessionID, testUser, time.Now())
+
+		// 添加Session到数据库
+		err := dao.AddSession(t.Context(), session)
+		if err != nil {
+			t.Errorf("添加Session失败: %v", err)
+		}
+
+		// 立即删除Session（模拟过期）
+		err = dao.DeleteSession(t.Context(), sessionID)
+		if err != nil {
+			t.Errorf("删除Session失败: %v", err)
+		}
+
+		// 测试带过期Cookie的请求
+		req, err := http.NewRequest("GET", "/main", nil)
+		if err != nil {
+			t.Fatalf("创建请求失败: %v", err)
+		}
+
+		// 添加过期的Cookie
+		cookie := &http.Cookie{
+			Name:  "user",
+			Value: sessionID,
+		}
+		req.AddCookie(cookie)
+
+		// 测试IsLogin函数
+		isLoggedIn, session := dao.IsLogin(req)
+		if isLoggedIn {
+			t.Error("过期Session应该返回false，但返回了true")
+		}
+		if session != nil {
+			t.Error("过期Session应该返回nil，但不为nil")
+		}
+	})
+
+	// 测试并发登录
+	t.Run("测试并发登录", func(t *testing.T) {
+		// 这里可以添加并发测试逻辑
+		// 使用goroutine同时进行登录操作
+		// 验证系统在并发情况下的稳定性
+		done := make(chan bool, 10)
+
+		for i := 0; i < 10; i++ {
+			go func(index int) {
+				defer func() { done <- true }()
+
+				// 测试用户名密码验证
+				user, err := dao.CheckUserNameAndPassword(t.Context(), testUsername, testPassword)
+				if err != nil {
+					t.Errorf("并发测试中验证用户失败: %v", err)
+					return
+				}
+				if user.ID == 0 {
+					t.Errorf("并发测试中应该找到用户，但用户ID为0")
+					return
+				}
+
+				// 创建Session
+				sessionID := utils.CreateUUID()
+				session := dao.NewSession(sessionID, user, time.Now())
+
+				// 添加Session
+				err = dao.AddSession(t.Context(), session)
+				if err != nil {
+					t.Errorf("并发测试中添加Session失败: %v", err)
+					return
+				}
+
+				// 立即清理Session
+				cleanupTestSession(t, sessionID)
+			}(i)
+		}
+
+		// 等待所有goroutine完成
+		for i := 0; i < 10; i++ {
+			<-done
+		}
+	})
+}
+
+// cleanupTestUser 清理测试用户
+func cleanupTestUser(t *testing.T, username string) {
+	// 先获取用户ID，用户不存在时不需要清理
+	user, err := dao.CheckUserName(t.Context(), username)
+	if err != nil {
+		t.Logf("获取用户ID失败: %v", err)
+		return
+	}
+	if user.ID == 0 {
+		return
+	}
+	cleanupTestUserByID(t, user.ID)
+}
+
+// cleanupTestSession 清理测试Session
+func cleanupTestSession(t *testing.T, sessionID string) {
+	sqlStr := "DELETE FROM sessions WHERE session_id = ?"
+	_, err := utils.Db.ExecContext(t.Context(), sqlStr, sessionID)
+	if err != nil {
+		t.Logf("清理测试Session失败: %v", err)
+	}
+}
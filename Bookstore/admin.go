@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bookstore/config"
+	"bookstore/dao"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// createAdminUsage create-admin命令的用法
+const createAdminUsage = `用法: bookstore [-config 配置文件] [-db mysql|sqlite] [-dsn 连接信息] create-admin 用户名 [邮箱]
+  用户已经存在时将其设置为管理员并重新设置密码，不存在时创建，创建时需要邮箱
+  密码从环境变量 ` + config.EnvPrefix + `ADMIN_PASSWORD 读取，没有设置时从标准输入读取一行`
+
+// minAdminPasswordLen 管理员密码的最短长度
+const minAdminPasswordLen = 8
+
+// runCreateAdmin 执行create-admin命令，创建第一个管理员或找回管理员的密码
+func runCreateAdmin(args []string, stdin io.Reader) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(createAdminUsage)
+	}
+	username, email := args[0], ""
+	if len(args) == 2 {
+		email = args[1]
+	}
+	password, ok := os.LookupEnv(config.EnvPrefix + "ADMIN_PASSWORD")
+	if !ok {
+		fmt.Print("请输入管理员的密码：")
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("读取密码失败: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if len(password) < minAdminPasswordLen {
+		return fmt.Errorf("管理员的密码至少需要%d个字符", minAdminPasswordLen)
+	}
+	created, err := dao.SaveAdmin(context.Background(), username, password, email)
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Printf("已创建管理员%s\n", username)
+	} else {
+		fmt.Printf("已将%s设置为管理员并重新设置了密码\n", username)
+	}
+	return nil
+}
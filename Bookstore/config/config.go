@@ -0,0 +1,286 @@
+package config
+
+import (
+	"bookstore/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+)
+
+// EnvPrefix 环境变量的前缀，环境变量的名称见各字段的env标签
+const EnvPrefix = "BOOKSTORE_"
+
+// Config 程序的所有配置，依次由默认值、配置文件和环境变量决定，后者覆盖前者
+type Config struct {
+	Database DatabaseConfig `json:"database"`
+	Server   ServerConfig   `json:"server"`
+	Views    ViewsConfig    `json:"views"`
+	Books    BooksConfig    `json:"books"`
+	Session  SessionConfig  `json:"session"`
+	Cart     CartConfig     `json:"cart"`
+	Payment  PaymentConfig  `json:"payment"`
+	Features FeaturesConfig `json:"features"`
+	Log      LogConfig      `json:"log"`
+}
+
+// DatabaseConfig 数据库的配置
+type DatabaseConfig struct {
+	Driver          string   `json:"driver" env:"DB_DRIVER"`                     //mysql 或 sqlite
+	DSN             string   `json:"dsn" env:"DB_DSN"`                           //连接信息，为空时使用所选驱动的默认值
+	MaxOpenConns    int      `json:"maxOpenConns" env:"DB_MAX_OPEN_CONNS"`       //最大连接数，0表示不限制，SQLite固定为1
+	MaxIdleConns    int      `json:"maxIdleConns" env:"DB_MAX_IDLE_CONNS"`       //最大空闲连接数
+	ConnMaxLifetime Duration `json:"connMaxLifetime" env:"DB_CONN_MAX_LIFETIME"` //连接的最长使用时间，0表示不限制
+}
+
+// ServerConfig HTTP服务的配置
+type ServerConfig struct {
+	Addr              string   `json:"addr" env:"ADDR"`                             //监听的地址
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout" env:"READ_HEADER_TIMEOUT"` //读取请求头的超时时间
+	ReadTimeout       Duration `json:"readTimeout" env:"READ_TIMEOUT"`              //读取整个请求的超时时间
+	WriteTimeout      Duration `json:"writeTimeout" env:"WRITE_TIMEOUT"`            //从读完请求头到写完响应的超时时间
+	IdleTimeout       Duration `json:"idleTimeout" env:"IDLE_TIMEOUT"`              //保持连接时等待下一个请求的超时时间
+	ShutdownTimeout   Duration `json:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT"`      //收到退出信号后等待正在处理的请求完成的最长时间
+}
+
+// ViewsConfig 模板和静态资源的配置
+type ViewsConfig struct {
+	TemplateRoot string `json:"templateRoot" env:"TEMPLATE_ROOT"` //模板的根目录，其中有index.html、layouts、partials和pages目录，为空时使用编译进程序中的模板
+	StaticRoot   string `json:"staticRoot" env:"STATIC_ROOT"`     //css、js和图片等静态资源的目录，为空时使用编译进程序中的静态资源
+	Reload       bool   `json:"reload" env:"TEMPLATE_RELOAD"`     //开发模式，模板文件修改后自动重新解析，不需要重启
+}
+
+// BooksConfig 图书查询的配置
+type BooksConfig struct {
+	PageSize int64 `json:"pageSize" env:"PAGE_SIZE"` //每页显示的图书数量
+}
+
+// SessionConfig Session和保存Session的id的Cookie的配置
+type SessionConfig struct {
+	CookieName     string   `json:"cookieName" env:"COOKIE_NAME"`
+	CookieSecure   bool     `json:"cookieSecure" env:"COOKIE_SECURE"`           //是否只通过HTTPS发送Cookie，使用HTTPS部署时应设置为true
+	CookieSameSite string   `json:"cookieSameSite" env:"COOKIE_SAMESITE"`       //lax、strict 或 none
+	IdleTimeout    Duration `json:"idleTimeout" env:"SESSION_IDLE_TIMEOUT"`     //空闲超时时间，超过该时间没有访问即过期
+	MaxLifetime    Duration `json:"maxLifetime" env:"SESSION_MAX_LIFETIME"`     //最长有效期，从登录时开始计算，不会因访问而顺延
+	SweepInterval  Duration `json:"sweepInterval" env:"SESSION_SWEEP_INTERVAL"` //清理过期Session的间隔
+}
+
+// CartConfig 游客购物车的配置，没有登录时添加的图书保存在游客购物车中，登录后合并到用户的购物车
+type CartConfig struct {
+	GuestCookieName  string   `json:"guestCookieName" env:"GUEST_CART_COOKIE_NAME"`   //保存游客购物车的id的Cookie
+	GuestTTL         Duration `json:"guestTTL" env:"GUEST_CART_TTL"`                  //游客购物车超过该时间没有修改即被清理
+	SweepInterval    Duration `json:"sweepInterval" env:"GUEST_CART_SWEEP_INTERVAL"`  //清理游客购物车的间隔
+	ReservationTTL   Duration `json:"reservationTTL" env:"CART_RESERVATION_TTL"`      //添加或修改购物项后为其保留库存的时间，0表示不保留
+	MaxReservedCount int64    `json:"maxReservedCount" env:"CART_MAX_RESERVED_COUNT"` //一个购物车中最多保留库存的图书数量，0表示不限制
+}
+
+// MinWebhookSecretLen 验证网关回调签名的密钥的最小长度
+const MinWebhookSecretLen = 16
+
+// PaymentConfig 在线支付的配置
+type PaymentConfig struct {
+	Provider      string `json:"provider" env:"PAYMENT_PROVIDER"`            //支付网关，为空时不使用在线支付，目前只有mock，即在本地运行的模拟网关
+	AllowMock     bool   `json:"allowMock" env:"PAYMENT_ALLOW_MOCK"`         //允许使用模拟网关，模拟网关的付款页面中任何人都可以自己完成支付，只能在开发和测试时打开
+	WebhookSecret string `json:"webhookSecret" env:"PAYMENT_WEBHOOK_SECRET"` //验证网关回调签名的密钥，使用网关时必须配置，重启后仍然相同
+}
+
+// Enabled 是否使用在线支付，没有配置支付网关时订单创建后直接为已付款
+func (p PaymentConfig) Enabled() bool {
+	return p.Provider != ""
+}
+
+// FeaturesConfig 可以单独关闭的功能
+type FeaturesConfig struct {
+	Registration bool `json:"registration" env:"FEATURE_REGISTRATION"` //允许新用户注册
+	API          bool `json:"api" env:"FEATURE_API"`                   //提供/api/v1下的JSON接口
+}
+
+// LogConfig 日志的配置
+type LogConfig struct {
+	Level string `json:"level" env:"LOG_LEVEL"` //debug、info、warn 或 error，debug时会记录执行的每一条sql语句
+}
+
+// Default 获取默认配置
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{
+			Driver:          utils.DriverMySQL,
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration{time.Hour},
+		},
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: Duration{5 * time.Second},
+			ReadTimeout:       Duration{15 * time.Second},
+			WriteTimeout:      Duration{30 * time.Second},
+			IdleTimeout:       Duration{time.Minute},
+			ShutdownTimeout:   Duration{30 * time.Second},
+		},
+		Books: BooksConfig{
+			PageSize: 4,
+		},
+		Session: SessionConfig{
+			CookieName:     "user",
+			CookieSameSite: "lax",
+			IdleTimeout:    Duration{2 * time.Hour},
+			MaxLifetime:    Duration{7 * 24 * time.Hour},
+			SweepInterval:  Duration{10 * time.Minute},
+		},
+		Cart: CartConfig{
+			GuestCookieName:  "guest_cart",
+			GuestTTL:         Duration{7 * 24 * time.Hour},
+			SweepInterval:    Duration{time.Hour},
+			ReservationTTL:   Duration{15 * time.Minute},
+			MaxReservedCount: 20,
+		},
+		Features: FeaturesConfig{
+			Registration: true,
+			API:          true,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+	}
+}
+
+// Load 在默认配置的基础上读取配置文件和环境变量，并检查配置是否正确，path为空时不读取配置文件
+func Load(path string) (*Config, error) {
+	return load(path, os.LookupEnv)
+}
+
+// Read 与Load相同但不检查配置，用于之后还要用命令行参数覆盖的情况，覆盖后再调用Validate
+func Read(path string) (*Config, error) {
+	return read(path, os.LookupEnv)
+}
+
+// load 读取配置并检查，lookupEnv用于获取环境变量
+func load(path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg, err := read(path, lookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	cfg.SetDefaultDSN()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// read 读取配置文件和环境变量，lookupEnv用于获取环境变量
+func read(path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(cfg).Elem(), lookupEnv); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile 读取JSON格式的配置文件，文件中没有的配置保持原来的值，不认识的配置视为错误
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("读取配置文件%s失败: %w", path, err)
+	}
+	return nil
+}
+
+// SetDefaultDSN 没有配置连接信息时SQLite使用内存数据库，MySQL的连接信息包含密码，没有默认值
+func (c *Config) SetDefaultDSN() {
+	if c.Database.DSN == "" && c.Database.Driver == utils.DriverSQLite {
+		c.Database.DSN = utils.DefaultSQLiteDSN
+	}
+}
+
+// Validate 检查配置是否正确，返回所有不正确的配置
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	db := c.Database
+	check(db.Driver == utils.DriverMySQL || db.Driver == utils.DriverSQLite, "database.driver只能是mysql或sqlite，实际为%q", db.Driver)
+	check(db.Driver != utils.DriverMySQL || db.DSN != "", "使用mysql时必须配置database.dsn（或-dsn参数、BOOKSTORE_DB_DSN环境变量）")
+	check(db.MaxOpenConns >= 0, "database.maxOpenConns不能小于0")
+	check(db.MaxIdleConns >= 0, "database.maxIdleConns不能小于0")
+	check(db.MaxOpenConns == 0 || db.MaxIdleConns <= db.MaxOpenConns, "database.maxIdleConns不能大于maxOpenConns")
+	check(db.ConnMaxLifetime.Duration >= 0, "database.connMaxLifetime不能小于0")
+	srv := c.Server
+	check(srv.Addr != "", "server.addr不能为空")
+	check(srv.ReadHeaderTimeout.Duration > 0, "server.readHeaderTimeout必须大于0")
+	check(srv.ReadTimeout.Duration >= srv.ReadHeaderTimeout.Duration, "server.readTimeout不能小于readHeaderTimeout")
+	check(srv.WriteTimeout.Duration > 0, "server.writeTimeout必须大于0")
+	check(srv.IdleTimeout.Duration > 0, "server.idleTimeout必须大于0")
+	check(srv.ShutdownTimeout.Duration > 0, "server.shutdownTimeout必须大于0")
+	check(c.Views.TemplateRoot == "" || isDir(c.Views.TemplateRoot), "views.templateRoot不是一个目录: %q", c.Views.TemplateRoot)
+	check(c.Views.StaticRoot == "" || isDir(c.Views.StaticRoot), "views.staticRoot不是一个目录: %q", c.Views.StaticRoot)
+	check(c.Books.PageSize >= 1 && c.Books.PageSize <= 100, "books.pageSize必须在1到100之间，实际为%d", c.Books.PageSize)
+	s := c.Session
+	check(s.CookieName != "", "session.cookieName不能为空")
+	_, ok := sameSiteModes[s.CookieSameSite]
+	check(ok, "session.cookieSameSite只能是lax、strict或none，实际为%q", s.CookieSameSite)
+	check(s.CookieSameSite != "none" || s.CookieSecure, "session.cookieSameSite为none时cookieSecure必须为true")
+	check(s.IdleTimeout.Duration > 0, "session.idleTimeout必须大于0")
+	check(s.MaxLifetime.Duration >= s.IdleTimeout.Duration, "session.maxLifetime不能小于idleTimeout")
+	check(s.SweepInterval.Duration > 0, "session.sweepInterval必须大于0")
+	cart := c.Cart
+	check(cart.GuestCookieName != "" && cart.GuestCookieName != s.CookieName, "cart.guestCookieName不能为空，且不能与session.cookieName相同")
+	check(cart.GuestTTL.Duration > 0, "cart.guestTTL必须大于0")
+	check(cart.SweepInterval.Duration > 0, "cart.sweepInterval必须大于0")
+	check(cart.ReservationTTL.Duration >= 0, "cart.reservationTTL不能小于0")
+	check(cart.MaxReservedCount >= 0, "cart.maxReservedCount不能小于0")
+	pay := c.Payment
+	check(pay.Provider == "" || pay.Provider == "mock", "payment.provider只能为空或mock，实际为%q", pay.Provider)
+	check(pay.Provider != "mock" || pay.AllowMock, "模拟网关中任何人都可以自己完成支付，只能用于开发和测试，使用时需要将payment.allowMock设置为true")
+	check(!pay.Enabled() || len(pay.WebhookSecret) >= MinWebhookSecretLen, "payment.webhookSecret至少需要%d个字符", MinWebhookSecretLen)
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level只能是debug、info、warn或error，实际为%q", c.Log.Level)
+	return errors.Join(errs...)
+}
+
+// isDir 判断路径是否是一个目录
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// sameSiteModes Cookie的SameSite属性
+var sameSiteModes = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+// SameSite 获取Cookie的SameSite属性
+func (s *SessionConfig) SameSite() http.SameSite {
+	if mode, ok := sameSiteModes[s.CookieSameSite]; ok {
+		return mode
+	}
+	return http.SameSiteLaxMode
+}
+
+// SlogLevel 获取日志的级别，配置不正确时使用info
+func (l *LogConfig) SlogLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig 在临时目录中创建模板和静态资源目录，并写入配置文件
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, sub := range []string{"views", "static"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content = strings.ReplaceAll(content, "$DIR", filepath.ToSlash(dir))
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// envOf 用map模拟环境变量
+func envOf(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `{
+		"database": {"driver": "sqlite", "maxOpenConns": 10},
+		"server": {"addr": ":9090"},
+		"views": {"templateRoot": "$DIR/views", "staticRoot": "$DIR/static"},
+		"session": {"idleTimeout": "30m"}
+	}`)
+	cfg, err := load(path, envOf(map[string]string{
+		"BOOKSTORE_PAGE_SIZE":            "8",
+		"BOOKSTORE_COOKIE_SECURE":        "true",
+		"BOOKSTORE_FEATURE_REGISTRATION": "false",
+		"BOOKSTORE_DB_CONN_MAX_LIFETIME": "5m",
+		"BOOKSTORE_LOG_LEVEL":            "debug",
+		"BOOKSTORE_GUEST_CART_TTL":       "48h",
+		"BOOKSTORE_CART_RESERVATION_TTL": "0s",
+	}))
+	if err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	//配置文件覆盖默认值
+	if cfg.Database.Driver != "sqlite" || cfg.Database.MaxOpenConns != 10 || cfg.Server.Addr != ":9090" {
+		t.Errorf("配置文件中的值没有生效: %+v %+v", cfg.Database, cfg.Server)
+	}
+	if cfg.Session.IdleTimeout.Duration != 30*time.Minute {
+		t.Errorf("idleTimeout应为30m，实际为%v", cfg.Session.IdleTimeout)
+	}
+	//配置文件中没有的保持默认值
+	if cfg.Database.MaxIdleConns != 5 || cfg.Session.CookieName != "user" || !cfg.Features.API {
+		t.Errorf("没有配置的值应保持默认值: %+v", cfg)
+	}
+	//没有配置连接信息时使用驱动的默认值
+	if cfg.Database.DSN != ":memory:" {
+		t.Errorf("SQLite默认使用内存数据库，实际为%q", cfg.Database.DSN)
+	}
+	//环境变量覆盖配置文件
+	if cfg.Books.PageSize != 8 || !cfg.Session.CookieSecure || cfg.Features.Registration || cfg.Database.ConnMaxLifetime.Duration != 5*time.Minute {
+		t.Errorf("环境变量没有生效: %+v", cfg)
+	}
+	if cfg.Cart.GuestTTL.Duration != 48*time.Hour || cfg.Cart.GuestCookieName != "guest_cart" || cfg.Cart.ReservationTTL.Duration != 0 {
+		t.Errorf("游客购物车的配置不正确: %+v", cfg.Cart)
+	}
+	if cfg.Log.SlogLevel() != slog.LevelDebug {
+		t.Errorf("日志级别应为debug，实际为%v", cfg.Log.SlogLevel())
+	}
+	//默认不使用在线支付，不需要配置回调的密钥
+	if cfg.Payment.Enabled() {
+		t.Errorf("默认不应使用在线支付，实际的网关为%q", cfg.Payment.Provider)
+	}
+}
+
+// TestExampleConfig 示例配置文件可以直接使用
+func TestExampleConfig(t *testing.T) {
+	cfg, err := load("../config.example.json", envOf(nil))
+	if err != nil {
+		t.Fatalf("示例配置文件不正确: %v", err)
+	}
+	if cfg.Database.Driver != "mysql" || cfg.Payment.Enabled() {
+		t.Errorf("示例配置应使用mysql且不使用在线支付: %+v %+v", cfg.Database, cfg.Payment)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	valid := `{"views": {"templateRoot": "$DIR/views", "staticRoot": "$DIR/static"}}`
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		want    []string
+	}{
+		{"不认识的配置", `{"server": {"adr": ":1"}}`, nil, []string{"adr"}},
+		{"时间间隔格式不正确", `{"session": {"idleTimeout": 30}}`, nil, []string{"时间间隔"}},
+		{"环境变量格式不正确", valid, map[string]string{"BOOKSTORE_PAGE_SIZE": "abc"}, []string{"BOOKSTORE_PAGE_SIZE"}},
+		{"目录不存在", `{"views": {"templateRoot": "$DIR/nothing", "staticRoot": "$DIR/static"}}`, nil, []string{"views.templateRoot"}},
+		{"返回所有不正确的配置", valid, map[string]string{
+			"BOOKSTORE_DB_DRIVER":         "oracle",
+			"BOOKSTORE_PAGE_SIZE":         "0",
+			"BOOKSTORE_COOKIE_SAMESITE":   "none",
+			"BOOKSTORE_DB_MAX_IDLE_CONNS": "50",
+			"BOOKSTORE_LOG_LEVEL":         "verbose",
+		}, []string{"database.driver", "books.pageSize", "cookieSecure", "maxIdleConns", "log.level"}},
+		{"MySQL没有连接信息", valid, nil, []string{"database.dsn"}},
+		{"不支持的支付网关", `{"payment": {"provider": "alipay"}}`, nil, []string{"payment.provider"}},
+		{"没有允许使用模拟网关", `{"payment": {"provider": "mock", "webhookSecret": "0123456789abcdef"}}`, nil, []string{"payment.allowMock"}},
+		{"回调的密钥太短", `{"payment": {"provider": "mock", "allowMock": true, "webhookSecret": "short"}}`, nil, []string{"payment.webhookSecret"}},
+		{"使用网关时没有配置回调的密钥", `{"payment": {"provider": "mock", "allowMock": true}}`, nil, []string{"payment.webhookSecret"}},
+		{"游客购物车的Cookie与Session的Cookie同名", `{"session": {"cookieName": "user"}, "cart": {"guestCookieName": "user"}}`, nil, []string{"cart.guestCookieName"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(writeConfig(t, tt.content), envOf(tt.env))
+			if err == nil {
+				t.Fatal("应返回错误")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("错误信息应包含%q，实际为%v", want, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Duration 以"2h"、"30m"这样的字符串配置的时间间隔
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON 从字符串解析时间间隔
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("时间间隔应为\"2h\"、\"30m\"这样的字符串: %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalJSON 将时间间隔保存为字符串
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// durationType Duration的类型，环境变量需要按字符串解析
+var durationType = reflect.TypeOf(Duration{})
+
+// applyEnv 用环境变量覆盖带有env标签的字段，环境变量的名称为EnvPrefix加上env标签
+func applyEnv(v reflect.Value, lookupEnv func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		name, ok := t.Field(i).Tag.Lookup("env")
+		if !ok {
+			//没有env标签的结构体是一组配置
+			if field.Kind() == reflect.Struct && field.Type() != durationType {
+				if err := applyEnv(field, lookupEnv); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		name = EnvPrefix + name
+		value, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("环境变量%s的值%q不正确: %w", name, value, err)
+		}
+	}
+	return nil
+}
+
+// setField 将字符串转换为字段的类型并设置
+func setField(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(Duration{d}))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("不支持的配置类型%s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxAddresses 每个用户最多可以保存的收货地址的数量
+const maxAddresses = 20
+
+// validateAddress 去掉收货地址首尾的空白并检查：收货人、联系电话和详细地址都不能为空，
+// 长度不能超过表中字段的长度，联系电话只能包含数字、空格、+和-
+func validateAddress(address *model.Address) error {
+	address.Recipient = strings.TrimSpace(address.Recipient)
+	address.Phone = strings.TrimSpace(address.Phone)
+	address.Detail = strings.TrimSpace(address.Detail)
+	if address.Recipient == "" || address.Phone == "" || address.Detail == "" {
+		return badRequest("收货人、联系电话和详细地址都不能为空！")
+	}
+	if utf8.RuneCountInString(address.Recipient) > 50 || utf8.RuneCountInString(address.Detail) > 200 {
+		return badRequest("收货人不能超过50个字，详细地址不能超过200个字！")
+	}
+	if len(address.Phone) < 5 || len(address.Phone) > 20 {
+		return badRequest("联系电话的格式不正确！")
+	}
+	for _, c := range address.Phone {
+		if !(c >= '0' && c <= '9' || c == ' ' || c == '+' || c == '-') {
+			return badRequest("联系电话的格式不正确！")
+		}
+	}
+	return nil
+}
+
+// getOwnAddress 获取当前用户的收货地址，地址不存在或属于其他用户时都返回404，
+// 店员和管理员也不能使用其他用户的地址
+func getOwnAddress(ctx context.Context, session *model.Session, addressID string) (*model.Address, error) {
+	address, err := dao.GetAddressByID(ctx, addressID)
+	if err != nil {
+		return nil, orNotFound(err, "收货地址不存在！")
+	}
+	if address.UserID != session.UserID {
+		return nil, notFound("收货地址不存在！")
+	}
+	return address, nil
+}
+
+// saveAddress 检查收货地址并保存到当前用户的地址簿中，id为0时添加，否则修改
+func saveAddress(ctx context.Context, session *model.Session, address *model.Address) error {
+	if err := validateAddress(address); err != nil {
+		return err
+	}
+	address.UserID = session.UserID
+	if address.ID != 0 {
+		return dao.UpdateAddress(ctx, address)
+	}
+	addresses, err := dao.GetAddressesByUserID(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+	if len(addresses) >= maxAddresses {
+		return conflict(fmt.Sprintf("最多只能保存%d个收货地址，请先删除不用的地址！", maxAddresses))
+	}
+	return dao.AddAddress(ctx, address)
+}
+
+// shippingAddress 确定结账时的收货地址：选择了地址簿中的地址时使用该地址，
+// 否则使用填写的新地址，save为true时同时保存到地址簿中
+func shippingAddress(ctx context.Context, session *model.Session, addressID string, address *model.Address, save bool) (*model.Address, error) {
+	if addressID != "" {
+		return getOwnAddress(ctx, session, addressID)
+	}
+	if save {
+		return address, saveAddress(ctx, session, address)
+	}
+	return address, validateAddress(address)
+}
+
+// addressFromForm 获取表单中填写的收货地址
+func addressFromForm(r *http.Request) *model.Address {
+	return &model.Address{
+		Recipient: r.PostFormValue("recipient"),
+		Phone:     r.PostFormValue("phone"),
+		Detail:    r.PostFormValue("address"),
+	}
+}
+
+// renderAddresses 显示当前用户的地址簿，editing不为nil时在表单中编辑该地址
+func renderAddresses(w http.ResponseWriter, r *http.Request, session *model.Session, editing *model.Address) error {
+	addresses, err := dao.GetAddressesByUserID(r.Context(), session.UserID)
+	if err != nil {
+		return err
+	}
+	session.Addresses = addresses
+	session.Address = editing
+	return render(w, "pages/user/address.html", session)
+}
+
+// GetAddresses 收货地址页面，地址栏中带有addressId时编辑该地址
+func GetAddresses(w http.ResponseWriter, r *http.Request) error {
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	var editing *model.Address
+	if addressID := r.URL.Query().Get("addressId"); addressID != "" {
+		if editing, err = getOwnAddress(r.Context(), session, addressID); err != nil {
+			return err
+		}
+	}
+	return renderAddresses(w, r, session, editing)
+}
+
+// UpdateOrAddAddress 添加或修改收货地址，没有addressId时添加
+func UpdateOrAddAddress(w http.ResponseWriter, r *http.Request) error {
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	address := addressFromForm(r)
+	if addressID := r.PostFormValue("addressId"); addressID != "" {
+		old, err := getOwnAddress(r.Context(), session, addressID)
+		if err != nil {
+			return err
+		}
+		address.ID = old.ID
+	}
+	if err := saveAddress(r.Context(), session, address); err != nil {
+		return err
+	}
+	return renderAddresses(w, r, session, nil)
+}
+
+// DeleteAddress 删除收货地址，已有订单中的收货地址不受影响
+func DeleteAddress(w http.ResponseWriter, r *http.Request) error {
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	address, err := getOwnAddress(r.Context(), session, r.PostFormValue("addressId"))
+	if err != nil {
+		return err
+	}
+	if err := dao.DeleteAddressByID(r.Context(), strconv.FormatInt(address.ID, 10)); err != nil {
+		return err
+	}
+	return renderAddresses(w, r, session, nil)
+}
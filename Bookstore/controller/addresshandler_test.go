@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testAddress 结账时在页面中填写的收货地址
+var testAddress = url.Values{"recipient": {"张三"}, "phone": {"138-0000-0000"}, "address": {"北京市海淀区中关村大街1号"}}
+
+// testAPIAddress 通过API结账时填写的收货地址
+var testAPIAddress = map[string]string{"recipient": "李四", "phone": "+86 139 0000 0000", "address": "上海市浦东新区世纪大道2号"}
+
+// userAddresses 获取用户的地址簿
+func userAddresses(t *testing.T, username string) (int, []*model.Address) {
+	t.Helper()
+	user, err := dao.CheckUserName(t.Context(), username)
+	if err != nil || user.ID == 0 {
+		t.Fatalf("获取用户%s失败: %v", username, err)
+	}
+	addresses, err := dao.GetAddressesByUserID(t.Context(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID, addresses
+}
+
+func TestAddressBook(t *testing.T) {
+	owner := loginAs(t, "addressowner", model.RoleCustomer)
+	other := loginAs(t, "addressother", model.RoleCustomer)
+	save := HandlerFunc(UpdateOrAddAddress)
+
+	if w := serve(save, "POST", "/updateOrAddAddress", testAddress, owner); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "中关村大街1号") {
+		t.Fatalf("添加收货地址失败，状态码为%d", w.Code)
+	}
+	_, addresses := userAddresses(t, "addressowner")
+	if len(addresses) != 1 || addresses[0].Recipient != "张三" {
+		t.Fatalf("地址簿中应有1个地址，实际为%+v", addresses)
+	}
+	addressID := strconv.FormatInt(addresses[0].ID, 10)
+
+	for _, form := range []url.Values{
+		{"recipient": {""}, "phone": {"13800000000"}, "address": {"某地"}},
+		{"recipient": {"张三"}, "phone": {"电话号码"}, "address": {"某地"}},
+		{"recipient": {"张三"}, "phone": {"13800000000"}, "address": {strings.Repeat("长", 201)}},
+	} {
+		if w := serve(save, "POST", "/updateOrAddAddress", form, owner); w.Code != http.StatusBadRequest {
+			t.Errorf("收货地址%v不正确，应返回400，实际为%d", form, w.Code)
+		}
+	}
+
+	t.Run("不能查看或修改别人的地址", func(t *testing.T) {
+		if w := serve(HandlerFunc(GetAddresses), "GET", "/getAddresses?addressId="+addressID, nil, other); w.Code != http.StatusNotFound {
+			t.Errorf("编辑别人的地址应返回404，实际为%d", w.Code)
+		}
+		form := url.Values{"addressId": {addressID}, "recipient": {"王五"}, "phone": {"13700000000"}, "address": {"别处"}}
+		if w := serve(save, "POST", "/updateOrAddAddress", form, other); w.Code != http.StatusNotFound {
+			t.Errorf("修改别人的地址应返回404，实际为%d", w.Code)
+		}
+		if w := serve(HandlerFunc(DeleteAddress), "POST", "/deleteAddress", url.Values{"addressId": {addressID}}, other); w.Code != http.StatusNotFound {
+			t.Errorf("删除别人的地址应返回404，实际为%d", w.Code)
+		}
+		if _, addresses := userAddresses(t, "addressowner"); len(addresses) != 1 || addresses[0].Recipient != "张三" {
+			t.Errorf("别人不能修改地址: %+v", addresses)
+		}
+	})
+
+	t.Run("修改和删除自己的地址", func(t *testing.T) {
+		if w := serve(HandlerFunc(GetAddresses), "GET", "/getAddresses?addressId="+addressID, nil, owner); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="addressId" value="`+addressID+`"`) {
+			t.Errorf("编辑地址的表单中应有地址的id，状态码为%d", w.Code)
+		}
+		form := url.Values{"addressId": {addressID}, "recipient": {"张三"}, "phone": {"13800000000"}, "address": {"北京市朝阳区"}}
+		if w := serve(save, "POST", "/updateOrAddAddress", form, owner); w.Code != http.StatusOK {
+			t.Fatalf("修改地址失败，状态码为%d", w.Code)
+		}
+		if _, addresses := userAddresses(t, "addressowner"); len(addresses) != 1 || addresses[0].Detail != "北京市朝阳区" {
+			t.Errorf("地址应被修改: %+v", addresses)
+		}
+		if w := serve(HandlerFunc(DeleteAddress), "POST", "/deleteAddress", url.Values{"addressId": {addressID}}, owner); w.Code != http.StatusOK {
+			t.Fatalf("删除地址失败，状态码为%d", w.Code)
+		}
+		if _, addresses := userAddresses(t, "addressowner"); len(addresses) != 0 {
+			t.Errorf("地址应被删除: %+v", addresses)
+		}
+	})
+}
+
+func TestCheckoutAddress(t *testing.T) {
+	buyer := loginAs(t, "shipbuyer", model.RoleCustomer)
+	book := addStockBook(t, "需要收货地址的图书", 10)
+	addToCart := func() {
+		serve(HandlerFunc(AddBook2Cart), "POST", "/addBook2Cart", url.Values{"bookId": {strconv.Itoa(book.ID)}}, buyer)
+	}
+	addToCart()
+	userID, _ := userAddresses(t, "shipbuyer")
+	saved := &model.Address{UserID: userID, Recipient: "赵六", Phone: "13600000000", Detail: "广州市天河区"}
+	if err := dao.AddAddress(t.Context(), saved); err != nil {
+		t.Fatal(err)
+	}
+	addressID := strconv.FormatInt(saved.ID, 10)
+
+	if w := serve(HandlerFunc(ToCheckout), "GET", "/toCheckout", nil, buyer); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "广州市天河区") {
+		t.Errorf("确认订单页面中应有地址簿中的地址，状态码为%d", w.Code)
+	}
+
+	t.Run("没有收货地址时不能结账", func(t *testing.T) {
+		w := serve(HandlerFunc(Checkout), "POST", "/checkout", url.Values{"recipient": {"赵六"}}, buyer)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "不能为空") || !strings.Contains(w.Body.String(), `value="赵六"`) {
+			t.Errorf("应回到确认订单页面并提示，保留填写的内容，状态码为%d", w.Code)
+		}
+		other := loginAs(t, "shipother", model.RoleCustomer)
+		serve(HandlerFunc(AddBook2Cart), "POST", "/addBook2Cart", url.Values{"bookId": {strconv.Itoa(book.ID)}}, other)
+		if w := serve(HandlerFunc(Checkout), "POST", "/checkout", url.Values{"addressId": {addressID}}, other); w.Code != http.StatusNotFound {
+			t.Errorf("使用别人的地址结账应返回404，实际为%d", w.Code)
+		}
+		if orders, _ := dao.GetMyOrders(t.Context(), userID); len(orders) != 0 {
+			t.Errorf("不应生成订单: %+v", orders)
+		}
+	})
+
+	t.Run("修改地址不影响已有的订单", func(t *testing.T) {
+		if w := serve(HandlerFunc(Checkout), "POST", "/checkout", url.Values{"addressId": {addressID}}, buyer); w.Code != http.StatusOK {
+			t.Fatalf("结账失败，状态码为%d", w.Code)
+		}
+		saved.Detail = "深圳市南山区"
+		if err := dao.UpdateAddress(t.Context(), saved); err != nil {
+			t.Fatal(err)
+		}
+		orders, err := dao.GetMyOrders(t.Context(), userID)
+		if err != nil {
+			t.Fatalf("获取订单失败: %v", err)
+		}
+		if len(orders) != 1 || orders[0].ShipRecipient != "赵六" || orders[0].ShipPhone != "13600000000" || orders[0].ShipAddress != "广州市天河区" {
+			t.Fatalf("订单中应保存下单时的地址: %+v", orders)
+		}
+		staff := loginAs(t, "shipstaff", model.RoleStaff)
+		if w := serve(HandlerFunc(GetOrders), "GET", "/getOrders", nil, staff); !strings.Contains(w.Body.String(), "广州市天河区") {
+			t.Errorf("订单管理页面中应显示订单的收货地址")
+		}
+	})
+
+	t.Run("填写新地址并保存到地址簿", func(t *testing.T) {
+		addToCart()
+		form := url.Values{"saveAddress": {"on"}}
+		for k, v := range testAddress {
+			form[k] = v
+		}
+		if w := serve(HandlerFunc(Checkout), "POST", "/checkout", form, buyer); w.Code != http.StatusOK {
+			t.Fatalf("结账失败，状态码为%d", w.Code)
+		}
+		if _, addresses := userAddresses(t, "shipbuyer"); len(addresses) != 2 || addresses[1].Recipient != "张三" {
+			t.Errorf("新地址应保存到地址簿中: %+v", addresses)
+		}
+	})
+}
+
+func TestAPIAddresses(t *testing.T) {
+	c := apiLogin(t, "apiaddress")
+	var address model.Address
+	if status, apiErr := c.do("POST", "/api/v1/addresses", testAPIAddress, &address); status != http.StatusCreated || address.ID == 0 {
+		t.Fatalf("添加地址应返回201，实际为%d %+v", status, apiErr)
+	}
+	if status, _ := c.do("POST", "/api/v1/addresses", map[string]string{"recipient": "李四"}, nil); status != http.StatusBadRequest {
+		t.Errorf("地址不完整时应返回400，实际为%d", status)
+	}
+	addressURL := "/api/v1/addresses/" + strconv.FormatInt(address.ID, 10)
+	other := apiLogin(t, "apiaddressother")
+	if status, _ := other.do("PUT", addressURL, testAPIAddress, nil); status != http.StatusNotFound {
+		t.Errorf("修改别人的地址应返回404，实际为%d", status)
+	}
+	update := map[string]string{"recipient": "李四", "phone": "13900000000", "address": "杭州市西湖区"}
+	if status, _ := c.do("PUT", addressURL, update, &address); status != http.StatusOK || address.Detail != "杭州市西湖区" {
+		t.Errorf("修改地址失败，状态码为%d，地址为%+v", status, address)
+	}
+
+	book := addStockBook(t, "API收货地址的图书", 5)
+	c.do("POST", "/api/v1/cart/items", map[string]int{"bookId": book.ID, "count": 1}, nil)
+	other.do("POST", "/api/v1/cart/items", map[string]int{"bookId": book.ID, "count": 1}, nil)
+	if status, _ := other.do("POST", "/api/v1/checkout", map[string]int64{"addressId": address.ID}, nil); status != http.StatusNotFound {
+		t.Errorf("使用别人的地址结账应返回404，实际为%d", status)
+	}
+	if status, apiErr := c.do("POST", "/api/v1/checkout", map[string]string{}, nil); status != http.StatusBadRequest {
+		t.Errorf("没有收货地址时结账应返回400，实际为%d %+v", status, apiErr)
+	}
+	var order apiOrderDetail
+	if status, apiErr := c.do("POST", "/api/v1/checkout", map[string]int64{"addressId": address.ID}, &order); status != http.StatusCreated {
+		t.Fatalf("结账应返回201，实际为%d %+v", status, apiErr)
+	}
+	var addresses []*model.Address
+	if status, _ := c.do("DELETE", addressURL, nil, &addresses); status != http.StatusOK || len(addresses) != 0 {
+		t.Errorf("删除地址失败，状态码为%d，地址为%+v", status, addresses)
+	}
+	if status, _ := c.do("GET", "/api/v1/orders/"+order.OrderID, nil, &order); status != http.StatusOK || order.ShipAddress != "杭州市西湖区" {
+		t.Errorf("删除地址后订单中仍应有收货地址: %+v", order.Order)
+	}
+}
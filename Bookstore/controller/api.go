@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API错误码，客户端根据错误码而不是错误信息判断错误类型
+const (
+	apiCodeBadRequest   = "bad_request"
+	apiCodeUnauthorized = "unauthorized"
+	apiCodeForbidden    = "forbidden"
+	apiCodeNotFound     = "not_found"
+	apiCodeConflict     = "conflict"
+	apiCodeOutOfStock   = "out_of_stock"
+	apiCodeInternal     = "internal_error"
+)
+
+// apiResponse API统一的响应格式，成功时只有data，失败时只有error
+type apiResponse struct {
+	Data  interface{} `json:"data,omitempty"`
+	Error *apiError   `json:"error,omitempty"`
+}
+
+// apiError API统一的错误格式
+type apiError struct {
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"`
+}
+
+// writeJSON 以JSON格式响应数据
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiResponse{Data: data})
+}
+
+// writeAPIError 以JSON格式响应错误信息
+func writeAPIError(w http.ResponseWriter, status int, code string, message string, details ...string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiResponse{Error: &apiError{Code: code, Message: message, Details: details}})
+}
+
+// apiHandlerFunc 返回错误的API处理器，错误统一由ServeHTTP记录日志并按JSON格式响应
+type apiHandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP 实现http.Handler接口
+func (fn apiHandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := fn(w, r); err != nil {
+		he := toHTTPError(err)
+		logError(r, he, err)
+		writeAPIError(w, he.Status, he.Code, he.Message, he.Details...)
+	}
+}
+
+// decodeJSON 解析请求体中的JSON，失败时返回400错误
+func decodeJSON(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return badRequest("请求的JSON格式不正确！")
+	}
+	return nil
+}
+
+// apiSession 获取API请求对应的Session，优先使用Authorization头中的Bearer令牌，
+// 其次使用与网页相同的Cookie，没有登录时返回nil
+func apiSession(r *http.Request) *model.Session {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return dao.ValidateSession(r.Context(), strings.TrimPrefix(auth, "Bearer "), time.Now())
+	}
+	_, session := isLogin(r)
+	return session
+}
+
+// apiAuth 包装需要登录的API，没有登录时响应401
+func apiAuth(next func(w http.ResponseWriter, r *http.Request, session *model.Session) error) apiHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		session := apiSession(r)
+		if session == nil {
+			return unauthorized()
+		}
+		return next(w, r, session)
+	}
+}
+
+// NewAPIHandler 创建/api/v1下所有JSON接口的处理器
+func NewAPIHandler() http.Handler {
+	mux := http.NewServeMux()
+	//图书
+	mux.Handle("GET /api/v1/books", apiHandlerFunc(APIGetBooks))
+	mux.Handle("GET /api/v1/books/{id}", apiHandlerFunc(APIGetBook))
+	//购物车
+	mux.Handle("GET /api/v1/cart", apiAuth(APIGetCart))
+	mux.Handle("DELETE /api/v1/cart", apiAuth(APIClearCart))
+	mux.Handle("POST /api/v1/cart/items", apiAuth(APIAddCartItem))
+	mux.Handle("PUT /api/v1/cart/items/{id}", apiAuth(APIUpdateCartItem))
+	mux.Handle("DELETE /api/v1/cart/items/{id}", apiAuth(APIDeleteCartItem))
+	//结账和订单
+	mux.Handle("POST /api/v1/checkout", apiAuth(APICheckout))
+	mux.Handle("GET /api/v1/orders", apiAuth(APIGetMyOrders))
+	mux.Handle("GET /api/v1/orders/{id}", apiAuth(APIGetOrder))
+	mux.Handle("POST /api/v1/orders/{id}/receive", apiAuth(APITakeOrder))
+	mux.Handle("POST /api/v1/orders/{id}/cancel", apiAuth(APICancelOrder))
+	//没有配置支付网关时不提供支付的接口
+	if paymentsEnabled() {
+		mux.Handle("POST /api/v1/orders/{id}/pay", apiAuth(APIPayOrder))
+	}
+	//收货地址
+	mux.Handle("GET /api/v1/addresses", apiAuth(APIGetAddresses))
+	mux.Handle("POST /api/v1/addresses", apiAuth(APIAddAddress))
+	mux.Handle("PUT /api/v1/addresses/{id}", apiAuth(APIUpdateAddress))
+	mux.Handle("DELETE /api/v1/addresses/{id}", apiAuth(APIDeleteAddress))
+	//用户
+	mux.Handle("POST /api/v1/auth/register", apiHandlerFunc(APIRegist))
+	mux.Handle("POST /api/v1/auth/login", apiHandlerFunc(APILogin))
+	mux.Handle("POST /api/v1/auth/logout", apiAuth(APILogout))
+	mux.Handle("GET /api/v1/auth/me", apiAuth(APIGetMe))
+	//其他的路径都按JSON格式返回404
+	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, apiCodeNotFound, "接口不存在！")
+	})
+	return mux
+}
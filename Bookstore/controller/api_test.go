@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// apiClient 使用令牌调用API的测试客户端
+type apiClient struct {
+	t       *testing.T
+	handler http.Handler
+	token   string
+}
+
+// do 发送请求并将响应中的data解析到out中，返回状态码和错误信息
+func (c *apiClient) do(method string, target string, body interface{}, out interface{}) (int, *apiError) {
+	c.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	r.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		r.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		c.t.Fatalf("%s %s 的Content-Type应为JSON，实际为%q", method, target, ct)
+	}
+	var resp struct {
+		Data  json.RawMessage `json:"data"`
+		Error *apiError       `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		c.t.Fatalf("%s %s 的响应不是合法的JSON: %v", method, target, err)
+	}
+	if resp.Error == nil && out != nil {
+		json.Unmarshal(resp.Data, out)
+	}
+	return w.Code, resp.Error
+}
+
+// apiLogin 注册用户并通过API登录，返回带有令牌的客户端
+func apiLogin(t *testing.T, username string) *apiClient {
+	t.Helper()
+	c := &apiClient{t: t, handler: NewAPIHandler()}
+	body := map[string]string{"username": username, "password": "secret", "email": username + "@example.com"}
+	if status, apiErr := c.do("POST", "/api/v1/auth/register", body, nil); status != http.StatusCreated {
+		t.Fatalf("注册失败，状态码为%d: %+v", status, apiErr)
+	}
+	var login apiLoginResponse
+	if status, apiErr := c.do("POST", "/api/v1/auth/login", body, &login); status != http.StatusOK {
+		t.Fatalf("登录失败，状态码为%d: %+v", status, apiErr)
+	}
+	c.token = login.Token
+	return c
+}
+
+func TestAPIBooks(t *testing.T) {
+	c := &apiClient{t: t, handler: NewAPIHandler()}
+	var page model.Page
+	if status, _ := c.do("GET", "/api/v1/books?pageNo=1", nil, &page); status != http.StatusOK {
+		t.Fatalf("获取图书状态码应为200，实际为%d", status)
+	}
+	if page.PageNo != 1 || len(page.Books) == 0 {
+		t.Errorf("应返回第一页的图书，实际为%+v", page)
+	}
+	if status, _ := c.do("GET", "/api/v1/books?min=0&max=30", nil, &page); status != http.StatusOK {
+		t.Fatalf("按价格查询图书状态码应为200，实际为%d", status)
+	}
+	for _, b := range page.Books {
+		if b.Price.Cmp(model.Cents(3000)) > 0 {
+			t.Errorf("图书《%s》的价格%v超出了查询范围", b.Title, b.Price)
+		}
+	}
+	if status, _ := c.do("GET", "/api/v1/books?keyword=Go&sort=price_desc", nil, &page); status != http.StatusOK || len(page.Books) == 0 {
+		t.Fatalf("按关键字查询图书失败，状态码为%d，结果为%+v", status, page)
+	}
+	for i, b := range page.Books {
+		if i > 0 && b.Price.Cmp(page.Books[i-1].Price) > 0 {
+			t.Errorf("图书应按价格从高到低排序，实际为%+v", page.Books)
+		}
+	}
+	for _, query := range []string{"sort=random", "min=abc", "min=50&max=10"} {
+		if status, apiErr := c.do("GET", "/api/v1/books?"+query, nil, nil); status != http.StatusBadRequest || apiErr.Code != apiCodeBadRequest {
+			t.Errorf("查询条件%s不正确时应返回400，实际为%d %+v", query, status, apiErr)
+		}
+	}
+	if status, apiErr := c.do("GET", "/api/v1/books?pageNo=abc", nil, nil); status != http.StatusBadRequest || apiErr.Code != apiCodeBadRequest {
+		t.Errorf("页码不正确时应返回400，实际为%d %+v", status, apiErr)
+	}
+	var book model.Book
+	if status, _ := c.do("GET", "/api/v1/books/1", nil, &book); status != http.StatusOK || book.ID != 1 {
+		t.Errorf("获取图书失败，状态码为%d，图书为%+v", status, book)
+	}
+	if status, apiErr := c.do("GET", "/api/v1/books/99999", nil, nil); status != http.StatusNotFound || apiErr.Code != apiCodeNotFound {
+		t.Errorf("图书不存在时应返回404，实际为%d %+v", status, apiErr)
+	}
+	if status, apiErr := c.do("GET", "/api/v1/nothing", nil, nil); status != http.StatusNotFound || apiErr == nil {
+		t.Errorf("不存在的接口应返回JSON格式的404，实际为%d %+v", status, apiErr)
+	}
+}
+
+func TestAPIAuth(t *testing.T) {
+	anonymous := &apiClient{t: t, handler: NewAPIHandler()}
+	if status, apiErr := anonymous.do("GET", "/api/v1/cart", nil, nil); status != http.StatusUnauthorized || apiErr.Code != apiCodeUnauthorized {
+		t.Errorf("没有登录时应返回401，实际为%d %+v", status, apiErr)
+	}
+	body := map[string]string{"username": "user1", "password": "wrong"}
+	if status, _ := anonymous.do("POST", "/api/v1/auth/login", body, nil); status != http.StatusUnauthorized {
+		t.Errorf("密码错误时应返回401，实际为%d", status)
+	}
+	c := apiLogin(t, "apiauth")
+	body = map[string]string{"username": "apiauth", "password": "secret", "email": "other@example.com"}
+	if status, apiErr := c.do("POST", "/api/v1/auth/register", body, nil); status != http.StatusConflict {
+		t.Errorf("用户名已存在时应返回409，实际为%d %+v", status, apiErr)
+	}
+	var me model.User
+	if status, _ := c.do("GET", "/api/v1/auth/me", nil, &me); status != http.StatusOK || me.Username != "apiauth" || me.Password != "" {
+		t.Errorf("获取当前用户失败，状态码为%d，用户为%+v", status, me)
+	}
+	if status, _ := c.do("POST", "/api/v1/auth/logout", nil, nil); status != http.StatusOK {
+		t.Errorf("注销状态码应为200，实际为%d", status)
+	}
+	if status, _ := c.do("GET", "/api/v1/auth/me", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("注销后令牌应失效，实际状态码为%d", status)
+	}
+}
+
+func TestAPICartAndCheckout(t *testing.T) {
+	c := apiLogin(t, "apicart")
+	var cart model.Cart
+	if status, _ := c.do("GET", "/api/v1/cart", nil, &cart); status != http.StatusOK || len(cart.CartItems) != 0 {
+		t.Fatalf("新用户的购物车应为空，状态码为%d，购物车为%+v", status, cart)
+	}
+	if status, apiErr := c.do("POST", "/api/v1/cart/items", map[string]int{"bookId": 99999}, nil); status != http.StatusNotFound {
+		t.Errorf("添加不存在的图书应返回404，实际为%d %+v", status, apiErr)
+	}
+	if status, apiErr := c.do("POST", "/api/v1/cart/items", map[string]int{"bookId": 1, "count": 0}, nil); status != http.StatusBadRequest {
+		t.Errorf("数量不正确时应返回400，实际为%d %+v", status, apiErr)
+	}
+	c.do("POST", "/api/v1/cart/items", map[string]int{"bookId": 1}, nil)
+	c.do("POST", "/api/v1/cart/items", map[string]int{"bookId": 2, "count": 2}, nil)
+	if status, _ := c.do("POST", "/api/v1/cart/items", map[string]int{"bookId": 1}, &cart); status != http.StatusCreated {
+		t.Fatalf("添加图书到购物车状态码应为201，实际为%d", status)
+	}
+	if len(cart.CartItems) != 2 || cart.TotalCount != 4 {
+		t.Fatalf("购物车中应有2个购物项共4本图书，实际为%+v", cart)
+	}
+	itemURL := fmt.Sprintf("/api/v1/cart/items/%d", cart.CartItems[0].CartItemID)
+	if status, _ := c.do("PUT", itemURL, map[string]int{"count": 3}, &cart); status != http.StatusOK || cart.TotalCount != 5 {
+		t.Errorf("更新购物项失败，状态码为%d，购物车为%+v", status, cart)
+	}
+	//其他用户不能操作该购物项
+	other := apiLogin(t, "apicartother")
+	if status, _ := other.do("DELETE", itemURL, nil, nil); status != http.StatusNotFound {
+		t.Errorf("删除别人的购物项应返回404，实际为%d", status)
+	}
+	if status, _ := c.do("DELETE", itemURL, nil, &cart); status != http.StatusOK || len(cart.CartItems) != 1 {
+		t.Errorf("删除购物项失败，状态码为%d，购物车为%+v", status, cart)
+	}
+
+	var order apiOrderDetail
+	if status, apiErr := c.do("POST", "/api/v1/checkout", testAPIAddress, &order); status != http.StatusCreated {
+		t.Fatalf("结账状态码应为201，实际为%d %+v", status, apiErr)
+	}
+	if order.Order == nil || order.TotalCount != 2 || len(order.Items) != 1 {
+		t.Fatalf("订单应包含1个订单项共2本图书，实际为%+v", order)
+	}
+	if order.ShipRecipient != testAPIAddress["recipient"] || order.ShipAddress != testAPIAddress["address"] {
+		t.Errorf("订单中应有收货地址，实际为%+v", order.Order)
+	}
+	if status, _ := c.do("GET", "/api/v1/cart", nil, &cart); status != http.StatusOK || len(cart.CartItems) != 0 {
+		t.Errorf("结账后购物车应为空，实际为%+v", cart)
+	}
+	if status, apiErr := c.do("POST", "/api/v1/checkout", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("购物车为空时结账应返回400，实际为%d %+v", status, apiErr)
+	}
+
+	var orders []*model.Order
+	if status, _ := c.do("GET", "/api/v1/orders", nil, &orders); status != http.StatusOK || len(orders) != 1 || len(orders[0].Items) != 1 {
+		t.Errorf("应有1个带有订单项的订单，状态码为%d，订单为%v", status, orders)
+	}
+	orderURL := "/api/v1/orders/" + order.OrderID
+	if status, apiErr := other.do("GET", orderURL, nil, nil); status != http.StatusForbidden || apiErr.Code != apiCodeForbidden {
+		t.Errorf("查看别人的订单应返回403，实际为%d %+v", status, apiErr)
+	}
+	if status, _ := c.do("GET", orderURL, nil, &order); status != http.StatusOK || len(order.Items) != 1 {
+		t.Errorf("查看订单详情失败，状态码为%d，订单为%+v", status, order)
+	}
+	if status, _ := c.do("POST", orderURL+"/receive", nil, nil); status != http.StatusConflict {
+		t.Errorf("未发货的订单确认收货应返回409，实际为%d", status)
+	}
+	var pay apiPayment
+	if status, apiErr := c.do("POST", orderURL+"/pay", nil, &pay); status != http.StatusCreated {
+		t.Fatalf("支付订单应返回201，实际为%d %+v", status, apiErr)
+	}
+	if w := completeMockPayment(t, pay.RedirectURL, true); w.Code != http.StatusNoContent {
+		t.Fatalf("处理网关的回调失败，状态码为%d %s", w.Code, w.Body.String())
+	}
+	dao.TransitionOrder(t.Context(), order.OrderID, model.OrderStateShipped, 0)
+	if status, _ := c.do("POST", orderURL+"/receive", nil, &order); status != http.StatusOK || !order.Complate() {
+		t.Errorf("确认收货失败，状态码为%d，订单为%+v", status, order)
+	}
+	//创建、付款、发货和收货各有一条记录
+	if len(order.History) != 4 || order.History[3].ActorID == 0 {
+		t.Errorf("订单应有4条状态变更记录，实际为%+v", order.History)
+	}
+	if len(order.Payments) != 1 || order.Payments[0].Status != model.PaymentStatusCaptured {
+		t.Errorf("订单应有1条已付款的支付记录，实际为%+v", order.Payments)
+	}
+}
+
+func TestAPICheckoutOutOfStock(t *testing.T) {
+	c := apiLogin(t, "apistock")
+	book := &model.Book{Title: "库存不足的图书", Author: "测试", Price: model.Cents(1000), Stock: 1, ImgPath: "/static/img/default.jpg"}
+	dao.AddBook(t.Context(), book)
+	books, _ := dao.GetBooks(t.Context())
+	for _, b := range books {
+		if b.Title == book.Title {
+			book = b
+		}
+	}
+	//超过库存的数量被调整为库存
+	var cart model.Cart
+	c.do("POST", "/api/v1/cart/items", map[string]int{"bookId": book.ID, "count": 2}, &cart)
+	if len(cart.CartItems) != 1 || cart.CartItems[0].Count != 1 || len(cart.CartItems[0].Notices) != 1 {
+		t.Fatalf("数量应调整为1本并带有提示，实际为%+v", cart.CartItems)
+	}
+	//加入购物车之后图书卖完了
+	book.Stock = 0
+	dao.UpdateBook(t.Context(), book)
+	status, apiErr := c.do("POST", "/api/v1/checkout", testAPIAddress, nil)
+	if status != http.StatusConflict || apiErr.Code != apiCodeOutOfStock || len(apiErr.Details) != 1 {
+		t.Errorf("库存不足时应返回409和每本图书的提示，实际为%d %+v", status, apiErr)
+	}
+}
+
+func TestAPIRegistDisabled(t *testing.T) {
+	conf.Features.Registration = false
+	defer func() { conf.Features.Registration = true }()
+	c := &apiClient{t: t, handler: NewAPIHandler()}
+	body := map[string]string{"username": "closeduser", "password": "123456", "email": "closeduser@example.com"}
+	if status, apiErr := c.do("POST", "/api/v1/auth/register", body, nil); status != http.StatusForbidden || apiErr.Code != apiCodeForbidden {
+		t.Errorf("关闭注册时应返回403，实际为%d %+v", status, apiErr)
+	}
+	if user, _ := dao.CheckUserName(t.Context(), "closeduser"); user.ID > 0 {
+		t.Error("关闭注册时不应保存用户")
+	}
+}
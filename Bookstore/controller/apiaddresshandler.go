@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"net/http"
+	"strconv"
+)
+
+// apiAddressRequest 添加或修改收货地址的请求
+type apiAddressRequest struct {
+	Recipient string `json:"recipient"`
+	Phone     string `json:"phone"`
+	Address   string `json:"address"`
+}
+
+// apiCheckoutRequest 结账的请求，使用地址簿中的地址时只需要addressId，
+// 否则需要填写收货地址，save为true时同时保存到地址簿中
+type apiCheckoutRequest struct {
+	AddressID int64 `json:"addressId"`
+	apiAddressRequest
+	Save bool `json:"save"`
+}
+
+// address 将请求中的收货地址转换为model.Address
+func (req *apiAddressRequest) address() *model.Address {
+	return &model.Address{Recipient: req.Recipient, Phone: req.Phone, Detail: req.Address}
+}
+
+// APIGetAddresses 获取当前用户的地址簿
+func APIGetAddresses(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	addresses, err := dao.GetAddressesByUserID(r.Context(), session.UserID)
+	if err != nil {
+		return err
+	}
+	if addresses == nil {
+		addresses = []*model.Address{}
+	}
+	writeJSON(w, http.StatusOK, addresses)
+	return nil
+}
+
+// APIAddAddress 向当前用户的地址簿中添加收货地址
+func APIAddAddress(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	var req apiAddressRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	address := req.address()
+	if err := saveAddress(r.Context(), session, address); err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusCreated, address)
+	return nil
+}
+
+// APIUpdateAddress 修改收货地址，只能修改自己的地址
+func APIUpdateAddress(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	old, err := getOwnAddress(r.Context(), session, r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	var req apiAddressRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	address := req.address()
+	address.ID = old.ID
+	if err := saveAddress(r.Context(), session, address); err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, address)
+	return nil
+}
+
+// APIDeleteAddress 删除收货地址并响应剩下的地址，已有订单中的收货地址不受影响
+func APIDeleteAddress(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	address, err := getOwnAddress(r.Context(), session, r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	if err := dao.DeleteAddressByID(r.Context(), strconv.FormatInt(address.ID, 10)); err != nil {
+		return err
+	}
+	return APIGetAddresses(w, r, session)
+}
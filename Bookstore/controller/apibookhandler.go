@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"net/http"
+)
+
+// APIGetBooks 获取带分页的图书，支持keyword、min、max、inStock和sort查询条件
+func APIGetBooks(w http.ResponseWriter, r *http.Request) error {
+	//获取查询条件，格式不正确时响应400
+	q, err := parseBookQuery(r)
+	if err != nil {
+		return err
+	}
+	page, err := dao.SearchBooks(r.Context(), q)
+	if err != nil {
+		return err
+	}
+	if page.Books == nil {
+		page.Books = []*model.Book{}
+	}
+	writeJSON(w, http.StatusOK, page)
+	return nil
+}
+
+// APIGetBook 根据图书的id获取一本图书
+func APIGetBook(w http.ResponseWriter, r *http.Request) error {
+	book, err := getBookByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, book)
+	return nil
+}
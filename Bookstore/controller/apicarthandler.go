@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// apiCartItemRequest 添加或更新购物项的请求
+type apiCartItemRequest struct {
+	BookID int   `json:"bookId"`
+	Count  int64 `json:"count"`
+}
+
+// getUserCart 获取用户的购物车，还没有购物车时返回一个空的购物车
+func getUserCart(ctx context.Context, userID int) (*model.Cart, error) {
+	cart, err := getCartByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cart == nil {
+		cart = &model.Cart{UserID: userID}
+	}
+	if cart.CartItems == nil {
+		cart.CartItems = []*model.CartItem{}
+	}
+	return cart, nil
+}
+
+// writeUserCart 查询并响应用户的购物车
+func writeUserCart(ctx context.Context, w http.ResponseWriter, status int, userID int) error {
+	cart, err := getUserCart(ctx, userID)
+	if err != nil {
+		return err
+	}
+	writeCart(w, status, cart)
+	return nil
+}
+
+// writeCart 响应已经更新过的购物车，不再重新查询，购物项中带有数量调整和价格变化的提示信息
+func writeCart(w http.ResponseWriter, status int, cart *model.Cart) {
+	if cart.CartItems == nil {
+		cart.CartItems = []*model.CartItem{}
+	}
+	writeJSON(w, status, withPriceNotices(cart))
+}
+
+// findCartItem 在用户的购物车中查找购物项，不在当前用户购物车中的购物项视为不存在
+func findCartItem(cart *model.Cart, cartItemID string) (*model.CartItem, error) {
+	iCartItemID, err := strconv.ParseInt(cartItemID, 10, 64)
+	if err == nil {
+		for _, v := range cart.CartItems {
+			if v.CartItemID == iCartItemID {
+				return v, nil
+			}
+		}
+	}
+	return nil, notFound("购物项不存在！")
+}
+
+// APIGetCart 获取当前用户的购物车
+func APIGetCart(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	return writeUserCart(r.Context(), w, http.StatusOK, session.UserID)
+}
+
+// APIAddCartItem 添加图书到购物车，购物车中已有该图书时累加数量，超过可以购买的库存时调整数量并在购物项中提示
+func APIAddCartItem(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	req := apiCartItemRequest{Count: 1}
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	if req.Count < 1 {
+		return badRequest("图书的数量必须大于0！")
+	}
+	book, err := getBookByID(r.Context(), strconv.Itoa(req.BookID))
+	if err != nil {
+		return err
+	}
+	cart, _, err := addBookToCart(r.Context(), session.UserID, book, req.Count)
+	if err != nil {
+		return err
+	}
+	writeCart(w, http.StatusCreated, cart)
+	return nil
+}
+
+// APIUpdateCartItem 修改购物项中图书的数量，超过可以购买的库存时调整数量并在购物项中提示
+func APIUpdateCartItem(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	var req apiCartItemRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	if req.Count < 1 {
+		return badRequest("图书的数量必须大于0！")
+	}
+	cart, err := getUserCart(r.Context(), session.UserID)
+	if err != nil {
+		return err
+	}
+	cartItem, err := findCartItem(cart, r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	count, notice, err := clampToStock(r.Context(), cart.CartID, cartItem.Book, req.Count)
+	if err != nil {
+		return err
+	}
+	if notice != "" {
+		cartItem.Notices = append(cartItem.Notices, notice)
+	}
+	//更新购物项的数量和金额小计，再更新购物车的总数量和总金额
+	cartItem.Count = count
+	if err := dao.UpdateBookCount(r.Context(), cartItem); err != nil {
+		return err
+	}
+	cart.Recalculate()
+	if err := dao.UpdateCart(r.Context(), cart); err != nil {
+		return err
+	}
+	writeCart(w, http.StatusOK, cart)
+	return nil
+}
+
+// APIDeleteCartItem 删除购物项
+func APIDeleteCartItem(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	cart, err := getUserCart(r.Context(), session.UserID)
+	if err != nil {
+		return err
+	}
+	cartItem, err := findCartItem(cart, r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	if err := dao.DeleteCartItemByID(r.Context(), r.PathValue("id")); err != nil {
+		return err
+	}
+	//从购物车中移除该购物项，再更新购物车中的图书的总数量和总金额
+	cart.CartItems = slices.DeleteFunc(cart.CartItems, func(v *model.CartItem) bool {
+		return v == cartItem
+	})
+	cart.Recalculate()
+	if err := dao.UpdateCart(r.Context(), cart); err != nil {
+		return err
+	}
+	writeCart(w, http.StatusOK, cart)
+	return nil
+}
+
+// APIClearCart 清空当前用户的购物车
+func APIClearCart(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	cart, err := getCartByUserID(r.Context(), session.UserID)
+	if err != nil {
+		return err
+	}
+	if cart != nil {
+		if err := dao.DeleteCartByCartID(r.Context(), cart.CartID); err != nil {
+			return err
+		}
+	}
+	writeCart(w, http.StatusOK, &model.Cart{UserID: session.UserID})
+	return nil
+}
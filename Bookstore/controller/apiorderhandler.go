@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// apiOrderDetail 订单详情，包含订单、订单项、状态的变更记录和支付记录
+type apiOrderDetail struct {
+	*model.Order
+	StateName string                `json:"stateName"`
+	Items     []*model.OrderItem    `json:"items"`
+	History   []*model.OrderHistory `json:"history"`
+	Payments  []*model.Payment      `json:"payments"`
+}
+
+// apiPayment 开始支付的响应
+type apiPayment struct {
+	RedirectURL string `json:"redirectUrl"` //用户付款的页面地址
+}
+
+// APICheckout 将当前用户的购物车结账生成订单，收货地址会复制到订单中，库存不足时响应409并逐项说明
+func APICheckout(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	cart, err := getCartByUserID(r.Context(), session.UserID)
+	if err != nil {
+		return err
+	}
+	if cart == nil || len(cart.CartItems) == 0 {
+		return badRequest("购物车是空的！")
+	}
+	var req apiCheckoutRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	var addressID string
+	if req.AddressID != 0 {
+		addressID = strconv.FormatInt(req.AddressID, 10)
+	}
+	address, err := shippingAddress(r.Context(), session, addressID, req.address(), req.Save)
+	if err != nil {
+		return err
+	}
+	order := newOrder(cart)
+	order.SetShipping(address)
+	if err := dao.Checkout(r.Context(), order, cart); err != nil {
+		if errors.Is(err, dao.ErrCartEmpty) {
+			return conflict("购物车已经结账或者是空的！")
+		}
+		return err
+	}
+	return writeAPIOrder(r.Context(), w, http.StatusCreated, order)
+}
+
+// APIGetMyOrders 获取当前用户的所有订单以及每个订单的订单项
+func APIGetMyOrders(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	orders, err := dao.GetMyOrdersWithItems(r.Context(), session.UserID)
+	if err != nil {
+		return err
+	}
+	if orders == nil {
+		orders = []*model.Order{}
+	}
+	writeJSON(w, http.StatusOK, orders)
+	return nil
+}
+
+// APIGetOrder 获取订单详情，只能查看自己的订单
+func APIGetOrder(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	order, err := getOrderByID(r.Context(), session, r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	return writeAPIOrder(r.Context(), w, http.StatusOK, order)
+}
+
+// APITakeOrder 确认收货
+func APITakeOrder(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	order, err := getOrderByID(r.Context(), session, r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	if err := changeOrderState(r.Context(), order.OrderID, model.OrderStateCompleted, session.UserID); err != nil {
+		return err
+	}
+	order.State = model.OrderStateCompleted
+	return writeAPIOrder(r.Context(), w, http.StatusOK, order)
+}
+
+// APIPayOrder 支付待付款的订单，响应网关的付款页面地址，付款的结果由网关通过回调通知，
+// 只能支付自己的订单，不是待付款的订单响应409
+func APIPayOrder(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	order, err := getOwnOrder(r.Context(), session, r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	redirectURL, err := startPayment(r.Context(), order)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusCreated, apiPayment{RedirectURL: redirectURL})
+	return nil
+}
+
+// APICancelOrder 取消还没有发货的订单并退还已经支付的款项，只有订单的所有者和管理员可以取消
+func APICancelOrder(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	order, err := dao.GetOrderByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return orNotFound(err, "订单不存在！")
+	}
+	if session.UserID != int(order.UserID) && !session.IsAdmin() {
+		return forbidden("您没有权限取消该订单！")
+	}
+	if err := cancelOrder(r.Context(), order.OrderID, session.UserID); err != nil {
+		return orNotFound(err, "订单不存在！")
+	}
+	order.State = model.OrderStateCancelled
+	return writeAPIOrder(r.Context(), w, http.StatusOK, order)
+}
+
+// writeAPIOrder 响应订单、订单项和状态的变更记录
+func writeAPIOrder(ctx context.Context, w http.ResponseWriter, status int, order *model.Order) error {
+	items, err := dao.GetOrderItemsByOrderID(ctx, order.OrderID)
+	if err != nil {
+		return err
+	}
+	if items == nil {
+		items = []*model.OrderItem{}
+	}
+	history, err := dao.GetOrderHistory(ctx, order.OrderID)
+	if err != nil {
+		return err
+	}
+	if history == nil {
+		history = []*model.OrderHistory{}
+	}
+	ps, err := dao.GetPaymentsByOrderID(ctx, order.OrderID)
+	if err != nil {
+		return err
+	}
+	if ps == nil {
+		ps = []*model.Payment{}
+	}
+	writeJSON(w, status, apiOrderDetail{Order: order, StateName: order.StateName(), Items: items, History: history, Payments: ps})
+	return nil
+}
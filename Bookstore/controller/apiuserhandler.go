@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"bookstore/utils"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiUserRequest 注册和登录的请求
+type apiUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+// apiLoginResponse 登录成功的响应，token可以放在Authorization头中代替Cookie
+type apiLoginResponse struct {
+	Token     string      `json:"token"`
+	ExpiresAt time.Time   `json:"expiresAt"`
+	User      *model.User `json:"user"`
+}
+
+// APIRegist 注册用户，关闭注册时返回403
+func APIRegist(w http.ResponseWriter, r *http.Request) error {
+	if !conf.Features.Registration {
+		return forbidden("暂停注册新用户！")
+	}
+	var req apiUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	if req.Username == "" || req.Password == "" || !strings.Contains(req.Email, "@") {
+		return badRequest("用户名、密码和邮箱不能为空！")
+	}
+	user, err := dao.CheckUserName(r.Context(), req.Username)
+	if err != nil {
+		return err
+	}
+	if user.ID > 0 {
+		return conflict("用户名已存在！")
+	}
+	if err := dao.SaveUser(r.Context(), req.Username, req.Password, req.Email); err != nil {
+		//用户名已经检查过，插入失败通常是邮箱重复
+		utils.Logger(r.Context()).Warn("注册用户失败", slog.Any("error", err))
+		return conflict("注册失败，邮箱可能已被使用！")
+	}
+	user, err = dao.CheckUserName(r.Context(), req.Username)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusCreated, user)
+	return nil
+}
+
+// APILogin 登录，成功后同时设置Cookie和返回令牌
+func APILogin(w http.ResponseWriter, r *http.Request) error {
+	var req apiUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	user, err := dao.CheckUserNameAndPassword(r.Context(), req.Username, req.Password)
+	if err != nil {
+		return err
+	}
+	if user.ID == 0 {
+		return &httpError{Status: http.StatusUnauthorized, Code: apiCodeUnauthorized, Message: "用户名或密码不正确！"}
+	}
+	//每次登录都使用新的Session
+	sess := dao.NewSession(utils.CreateUUID(), user, time.Now())
+	if err := dao.AddSession(r.Context(), sess); err != nil {
+		return err
+	}
+	http.SetCookie(w, newSessionCookie(sess.SessionID))
+	writeJSON(w, http.StatusOK, apiLoginResponse{Token: sess.SessionID, ExpiresAt: sess.ExpiresAt, User: user})
+	return nil
+}
+
+// APILogout 注销当前的Session
+func APILogout(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	if err := dao.DeleteSession(r.Context(), session.SessionID); err != nil {
+		return err
+	}
+	cookie := newSessionCookie("")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+	writeJSON(w, http.StatusOK, struct{}{})
+	return nil
+}
+
+// APIGetMe 获取当前登录的用户
+func APIGetMe(w http.ResponseWriter, r *http.Request, session *model.Session) error {
+	user, err := dao.CheckUserName(r.Context(), session.UserName)
+	if err != nil {
+		return err
+	}
+	if user.ID == 0 {
+		return notFound("用户不存在！")
+	}
+	writeJSON(w, http.StatusOK, user)
+	return nil
+}
@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// assetCacheControl 带有内容哈希的地址，内容改变时地址也会改变，浏览器可以一直缓存
+const assetCacheControl = "public, max-age=31536000, immutable"
+
+// compressibleTypes 需要压缩的文件类型，图片本身已经压缩过
+var compressibleTypes = []string{"text/", "application/javascript", "application/json", "image/svg+xml"}
+
+// hashedName 匹配带有内容哈希的文件名，如css/style.0123abcd.css
+var hashedName = regexp.MustCompile(`^(.*)\.[0-9a-f]{8}(\.[^./]+)$`)
+
+// staticFile 启动时读取到内存中的静态资源
+type staticFile struct {
+	data        []byte
+	gzip        []byte //gzip压缩后的内容，压缩后没有变小时为nil
+	brotli      []byte //构建时预先压缩的.br文件，没有时为nil
+	hash        string //内容哈希的前8位
+	contentType string
+}
+
+// staticAssets 所有的静态资源，带有内容哈希的地址和原来的地址都可以访问
+type staticAssets struct {
+	files  map[string]*staticFile
+	hashed map[string]string //带有内容哈希的文件名对应的文件名
+}
+
+// assets 处理器使用的静态资源，由Configure创建
+var assets *staticAssets
+
+// newStaticAssets 读取fsys中的所有静态资源，计算内容哈希并预先压缩。
+// 与资源同名的.br和.gz文件视为构建时预先压缩好的内容
+func newStaticAssets(fsys fs.FS) (*staticAssets, error) {
+	a := &staticAssets{files: make(map[string]*staticFile), hashed: make(map[string]string)}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if ext := path.Ext(name); ext == ".br" || ext == ".gz" {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		f := &staticFile{
+			data:        data,
+			hash:        hex.EncodeToString(sum[:])[:8],
+			contentType: mime.TypeByExtension(path.Ext(name)),
+		}
+		if f.contentType == "" {
+			f.contentType = http.DetectContentType(data)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f.brotli = precompressed(fsys, name+".br", info)
+		f.gzip = precompressed(fsys, name+".gz", info)
+		if f.gzip == nil && isCompressible(f.contentType) {
+			if f.gzip, err = gzipBytes(data); err != nil {
+				return err
+			}
+		}
+		if len(f.brotli) >= len(data) {
+			f.brotli = nil
+		}
+		if len(f.gzip) >= len(data) {
+			f.gzip = nil
+		}
+		a.files[name] = f
+		a.hashed[withHash(name, f.hash)] = name
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// precompressed 读取构建时预先压缩好的文件，没有该文件或者它比资源旧时返回nil。
+// 比资源旧说明资源修改后没有重新生成，如在覆盖目录中修改了CSS
+func precompressed(fsys fs.FS, name string, src fs.FileInfo) []byte {
+	info, err := fs.Stat(fsys, name)
+	if err != nil || info.ModTime().Before(src.ModTime()) {
+		return nil
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// isCompressible 判断该类型的文件是否需要压缩
+func isCompressible(contentType string) bool {
+	for _, t := range compressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipBytes 以最高的压缩率压缩内容
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// withHash 在文件的扩展名之前加上内容哈希，如css/style.css为css/style.0123abcd.css
+func withHash(name string, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// url 获取静态资源带有内容哈希的地址，找不到该资源时返回原来的地址
+func (a *staticAssets) url(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if f, ok := a.files[name]; ok {
+		return "/static/" + withHash(name, f.hash)
+	}
+	return "/static/" + name
+}
+
+// lookup 根据请求的文件名查找静态资源，immutable表示请求的是当前内容的哈希地址。
+// 内容已经改变的旧哈希地址也返回当前的内容，但不能长期缓存
+func (a *staticAssets) lookup(name string) (f *staticFile, immutable bool) {
+	if orig, ok := a.hashed[name]; ok {
+		return a.files[orig], true
+	}
+	if f, ok := a.files[name]; ok {
+		return f, false
+	}
+	if m := hashedName.FindStringSubmatch(name); m != nil {
+		return a.files[m[1]+m[2]], false
+	}
+	return nil, false
+}
+
+// ServeHTTP 响应静态资源，请求的路径应已去掉/static/前缀。
+// 浏览器支持时响应预先压缩的内容，没有哈希的地址每次都需要根据ETag确认是否有更新
+func (a *staticAssets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, immutable := a.lookup(strings.TrimPrefix(r.URL.Path, "/"))
+	if f == nil {
+		http.NotFound(w, r)
+		return
+	}
+	h := w.Header()
+	if immutable {
+		h.Set("Cache-Control", assetCacheControl)
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	h.Set("Content-Type", f.contentType)
+	data, encoding := f.data, ""
+	if f.gzip != nil || f.brotli != nil {
+		h.Add("Vary", "Accept-Encoding")
+		accept := r.Header.Get("Accept-Encoding")
+		switch {
+		case f.brotli != nil && acceptsEncoding(accept, "br"):
+			data, encoding = f.brotli, "br"
+		case f.gzip != nil && acceptsEncoding(accept, "gzip"):
+			data, encoding = f.gzip, "gzip"
+		}
+	}
+	etag := f.hash
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+		etag += "-" + encoding
+	}
+	h.Set("ETag", `"`+etag+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// acceptsEncoding 判断Accept-Encoding中是否有指定的压缩方式，q为0表示不接受
+func acceptsEncoding(accept string, encoding string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if strings.TrimSpace(name) != encoding {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// pagesFS 可以直接访问的页面所在的目录，由Configure设置
+var pagesFS fs.FS
+
+// StaticHandler 响应/static/下的静态资源，使用时需要去掉/static/前缀
+func StaticHandler() http.Handler {
+	return assets
+}
+
+// PagesHandler 直接响应pages目录下的页面，如登录和注册页面，使用时需要去掉/pages/前缀
+func PagesHandler() http.Handler {
+	return http.FileServer(http.FS(pagesFS))
+}
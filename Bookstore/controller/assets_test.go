@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"bookstore/views"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// getAsset 请求静态资源，acceptEncoding为浏览器支持的压缩方式
+func getAsset(h http.Handler, target string, acceptEncoding string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", target, nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	http.StripPrefix("/static/", h).ServeHTTP(w, r)
+	return w
+}
+
+func TestStaticAssets(t *testing.T) {
+	css := strings.Repeat("body { color: red; }\n", 50)
+	a, err := newStaticAssets(fstest.MapFS{
+		"css/style.css":    {Data: []byte(css)},
+		"css/style.css.br": {Data: []byte("预先压缩的内容")},
+		"img/logo.gif":     {Data: []byte("GIF89a")},
+	})
+	if err != nil {
+		t.Fatalf("读取静态资源失败: %v", err)
+	}
+	hashedURL := a.url("css/style.css")
+	if hashedURL == "/static/css/style.css" || !strings.HasSuffix(hashedURL, ".css") {
+		t.Fatalf("地址中应带有内容哈希，实际为%s", hashedURL)
+	}
+
+	t.Run("带有哈希的地址可以长期缓存", func(t *testing.T) {
+		w := getAsset(a, hashedURL, "")
+		if w.Code != http.StatusOK || w.Body.String() != css {
+			t.Fatalf("应返回文件内容，实际为%d", w.Code)
+		}
+		if got := w.Header().Get("Cache-Control"); got != assetCacheControl {
+			t.Errorf("Cache-Control应为%q，实际为%q", assetCacheControl, got)
+		}
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/css") {
+			t.Errorf("Content-Type应为text/css，实际为%q", got)
+		}
+	})
+
+	t.Run("没有哈希的地址需要确认是否有更新", func(t *testing.T) {
+		w := getAsset(a, "/static/css/style.css", "")
+		if got := w.Header().Get("Cache-Control"); got != "no-cache" {
+			t.Errorf("Cache-Control应为no-cache，实际为%q", got)
+		}
+		r := httptest.NewRequest("GET", "/static/css/style.css", nil)
+		r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+		w = httptest.NewRecorder()
+		http.StripPrefix("/static/", a).ServeHTTP(w, r)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("ETag相同时状态码应为304，实际为%d", w.Code)
+		}
+	})
+
+	t.Run("旧的哈希地址返回当前的内容", func(t *testing.T) {
+		w := getAsset(a, "/static/css/style.00000000.css", "")
+		if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-cache" {
+			t.Errorf("旧的哈希地址应返回当前的内容且不能长期缓存，实际为%d %q", w.Code, w.Header().Get("Cache-Control"))
+		}
+	})
+
+	t.Run("压缩", func(t *testing.T) {
+		w := getAsset(a, hashedURL, "gzip, deflate")
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("支持gzip时应响应压缩后的内容")
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := io.ReadAll(zr); string(b) != css {
+			t.Errorf("解压后的内容不正确")
+		}
+		w = getAsset(a, hashedURL, "gzip, br")
+		if w.Header().Get("Content-Encoding") != "br" || w.Body.String() != "预先压缩的内容" {
+			t.Errorf("支持br时应响应预先压缩的.br文件")
+		}
+		w = getAsset(a, hashedURL, "br;q=0, gzip")
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("q=0表示不接受br压缩")
+		}
+		w = getAsset(a, hashedURL, "gzip;q=0, br;q=0.5")
+		if w.Header().Get("Content-Encoding") != "br" {
+			t.Errorf("不接受gzip但接受br时应响应br压缩的内容")
+		}
+		w = getAsset(a, hashedURL, "br;q=0, gzip;q=0")
+		if w.Header().Get("Content-Encoding") != "" || w.Body.String() != css {
+			t.Errorf("都不接受时应响应原来的内容")
+		}
+		if w.Header().Get("ETag") == getAsset(a, hashedURL, "br").Header().Get("ETag") {
+			t.Errorf("不同的压缩方式应使用不同的ETag")
+		}
+		w = getAsset(a, a.url("img/logo.gif"), "gzip")
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("图片不需要压缩")
+		}
+	})
+
+	t.Run("不存在的文件", func(t *testing.T) {
+		for _, target := range []string{"/static/css/nothing.css", "/static/css/style.css.br"} {
+			if w := getAsset(a, target, ""); w.Code != http.StatusNotFound {
+				t.Errorf("%s的状态码应为404，实际为%d", target, w.Code)
+			}
+		}
+	})
+
+	t.Run("比资源旧的.br文件不使用", func(t *testing.T) {
+		now := time.Now()
+		a, err := newStaticAssets(fstest.MapFS{
+			"css/style.css":    {Data: []byte(css), ModTime: now},
+			"css/style.css.br": {Data: []byte("修改之前压缩的内容"), ModTime: now.Add(-time.Hour)},
+		})
+		if err != nil {
+			t.Fatalf("读取静态资源失败: %v", err)
+		}
+		if w := getAsset(a, a.url("css/style.css"), "br, gzip"); w.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("资源修改后没有重新生成的.br文件不应使用，实际为%q", w.Header().Get("Content-Encoding"))
+		}
+	})
+
+	t.Run("编译进程序中的.br文件", func(t *testing.T) {
+		for _, name := range []string{"css/style.css", "script/jquery-1.7.2.js", "script/csrf.js"} {
+			orig, err := fs.ReadFile(views.FS, "static/"+name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := getAsset(StaticHandler(), assets.url(name), "gzip, deflate, br")
+			if w.Header().Get("Content-Encoding") != "br" {
+				t.Fatalf("%s应有预先压缩的.br文件，修改后需要在views目录下运行go generate", name)
+			}
+			if b, err := io.ReadAll(brotli.NewReader(w.Body)); err != nil || !bytes.Equal(b, orig) {
+				t.Errorf("%s.br解压后的内容与原文件不同，需要在views目录下运行go generate", name)
+			}
+			w = getAsset(StaticHandler(), assets.url(name), "gzip")
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("%s应响应gzip压缩的内容: %v", name, err)
+			}
+			if b, _ := io.ReadAll(zr); !bytes.Equal(b, orig) {
+				t.Errorf("%s gzip解压后的内容与原文件不同", name)
+			}
+			if w := getAsset(StaticHandler(), assets.url(name), ""); w.Header().Get("Content-Encoding") != "" || !bytes.Equal(w.Body.Bytes(), orig) {
+				t.Errorf("%s不支持压缩时应响应原来的内容", name)
+			}
+		}
+	})
+
+	t.Run("页面中使用编译进程序中的静态资源", func(t *testing.T) {
+		w := serve(HandlerFunc(GetPageBooksByPrice), "GET", "/main", nil, nil)
+		url := assets.url("css/style.css")
+		if !strings.Contains(w.Body.String(), url) {
+			t.Fatalf("首页中应使用带有哈希的地址%s", url)
+		}
+		if w := getAsset(StaticHandler(), url, ""); w.Code != http.StatusOK {
+			t.Errorf("状态码应为200，实际为%d", w.Code)
+		}
+		w = serve(http.StripPrefix("/pages/", PagesHandler()), "GET", "/pages/user/login.html", nil, nil)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "欢迎登录") {
+			t.Errorf("应能直接访问登录页面，实际为%d", w.Code)
+		}
+	})
+}
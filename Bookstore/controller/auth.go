@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"context"
+	"net/http"
+)
+
+// sessionKey 请求的context中保存当前请求的Session的键
+type sessionKey struct{}
+
+// requestSession 当前请求的Session，第一次使用时才查询，没有登录时session为nil
+type requestSession struct {
+	loaded  bool
+	session *model.Session
+}
+
+// LoadSession 在请求的context中为当前请求保存Session，CSRF、RequireLogin和处理器都使用同一个Session，
+// 每个请求最多查询一次sessions表；不需要Session的请求（如静态资源）不会查询
+func LoadSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(sessionKey{}).(*requestSession); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, &requestSession{})))
+	})
+}
+
+// isLogin 判断当前请求是否已经登录，经过LoadSession的请求只在第一次调用时查询Session
+func isLogin(r *http.Request) (bool, *model.Session) {
+	rs, ok := r.Context().Value(sessionKey{}).(*requestSession)
+	if !ok {
+		return dao.IsLogin(r)
+	}
+	if !rs.loaded {
+		_, rs.session = dao.IsLogin(r)
+		rs.loaded = true
+	}
+	return rs.session != nil, rs.session
+}
+
+// forgetSession 注销后当前请求之后的处理视为没有登录
+func forgetSession(r *http.Request) {
+	if rs, ok := r.Context().Value(sessionKey{}).(*requestSession); ok {
+		rs.loaded, rs.session = true, nil
+	}
+}
+
+// RequireRole 返回一个中间件，只允许指定角色的用户访问被包装的处理器：
+// 没有登录时跳转到登录页面，已登录但角色不符时返回403
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			flag, session := isLogin(r)
+			if !flag {
+				//没有登录，去登录
+				http.Redirect(w, r, "/pages/user/login.html", http.StatusFound)
+				return
+			}
+			for _, role := range roles {
+				if session.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			//权限不足
+			renderError(w, r, forbidden("您没有权限访问该页面！"))
+		})
+	}
+}
+
+// RequireLogin 返回一个中间件，只允许已经登录的用户访问被包装的处理器，没有登录时跳转到登录页面
+func RequireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flag, _ := isLogin(r)
+		if !flag {
+			//没有登录，去登录
+			http.Redirect(w, r, "/pages/user/login.html", http.StatusFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// currentSession 获取当前登录用户的Session，没有登录时返回的错误会跳转到登录页面
+func currentSession(r *http.Request) (*model.Session, error) {
+	flag, session := isLogin(r)
+	if !flag {
+		return nil, unauthorized()
+	}
+	return session, nil
+}
+
+// checkOwner 判断当前用户能否操作属于userID的购物车或订单：只有本人以及店员和管理员可以操作，
+// 否则返回403错误
+func checkOwner(session *model.Session, userID int) error {
+	if isOwner(session, userID) {
+		return nil
+	}
+	return forbidden("您没有权限操作该资源！")
+}
+
+// isOwner 判断当前用户是否是资源的所有者，店员和管理员可以操作所有用户的资源
+func isOwner(session *model.Session, userID int) bool {
+	return session.UserID == userID || session.IsStaff()
+}
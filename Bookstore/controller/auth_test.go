@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"bookstore/config"
+	"bookstore/dao"
+	"bookstore/model"
+	"bookstore/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	//使用嵌入式数据库运行测试，无需MySQL服务
+	cfg := config.Default()
+	cfg.Database.Driver = utils.DriverSQLite
+	cfg.Payment.Provider = "mock"
+	cfg.Payment.AllowMock = true
+	cfg.Payment.WebhookSecret = "controller-test-secret"
+	if err := Configure(cfg); err != nil {
+		fmt.Println("解析模板失败：", err)
+		os.Exit(1)
+	}
+	if err := dao.Setup(cfg); err != nil {
+		fmt.Println("初始化测试数据库失败：", err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// loginAs 创建指定角色的测试用户并登录，返回带有Session的Cookie
+func loginAs(t *testing.T, username string, role string) *http.Cookie {
+	t.Helper()
+	user, err := dao.CheckUserName(t.Context(), username)
+	if err != nil {
+		t.Fatalf("查询测试用户失败: %v", err)
+	}
+	if user.ID == 0 {
+		if err := dao.SaveUser(t.Context(), username, "secret", username+"@example.com"); err != nil {
+			t.Fatalf("创建测试用户失败: %v", err)
+		}
+		if user, err = dao.CheckUserName(t.Context(), username); err != nil || user.ID == 0 {
+			t.Fatalf("查询创建的测试用户失败: %+v %v", user, err)
+		}
+	}
+	if err := dao.UpdateUserRole(t.Context(), user.ID, role); err != nil {
+		t.Fatalf("设置用户角色失败: %v", err)
+	}
+	sess := dao.NewSession(utils.CreateUUID(), user, time.Now())
+	if err := dao.AddSession(t.Context(), sess); err != nil {
+		t.Fatalf("添加Session失败: %v", err)
+	}
+	return &http.Cookie{Name: conf.Session.CookieName, Value: sess.SessionID}
+}
+
+// serve 使用指定的Cookie请求处理器
+func serve(h http.Handler, method string, target string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	LoadSession(h).ServeHTTP(w, r)
+	return w
+}
+
+func TestRequireRole(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	staff := RequireRole(model.RoleStaff, model.RoleAdmin)(ok)
+	admin := RequireRole(model.RoleAdmin)(ok)
+	customerCookie := loginAs(t, "rbaccustomer", model.RoleCustomer)
+	staffCookie := loginAs(t, "rbacstaff", model.RoleStaff)
+	adminCookie := loginAs(t, "rbacadmin", model.RoleAdmin)
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		cookie  *http.Cookie
+		status  int
+	}{
+		{"没有登录访问后台", staff, nil, http.StatusFound},
+		{"顾客访问后台", staff, customerCookie, http.StatusForbidden},
+		{"店员访问后台", staff, staffCookie, http.StatusOK},
+		{"管理员访问后台", staff, adminCookie, http.StatusOK},
+		{"店员访问用户管理", admin, staffCookie, http.StatusForbidden},
+		{"管理员访问用户管理", admin, adminCookie, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.handler, "GET", "/deleteBook?bookId=1", nil, tt.cookie)
+			if w.Code != tt.status {
+				t.Errorf("状态码应为%d，实际为%d", tt.status, w.Code)
+			}
+		})
+	}
+
+	t.Run("角色修改后立即生效", func(t *testing.T) {
+		cookie := loginAs(t, "rbacdemoted", model.RoleStaff)
+		if w := serve(staff, "GET", "/getOrders", nil, cookie); w.Code != http.StatusOK {
+			t.Fatalf("店员应可以访问后台，实际状态码为%d", w.Code)
+		}
+		user, _ := dao.CheckUserName(t.Context(), "rbacdemoted")
+		dao.UpdateUserRole(t.Context(), user.ID, model.RoleCustomer)
+		if w := serve(staff, "GET", "/getOrders", nil, cookie); w.Code != http.StatusForbidden {
+			t.Errorf("降为顾客后不应再访问后台，实际状态码为%d", w.Code)
+		}
+	})
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	adminCookie := loginAs(t, "roleadmin", model.RoleAdmin)
+	loginAs(t, "roletarget", model.RoleCustomer)
+	target, _ := dao.CheckUserName(t.Context(), "roletarget")
+	admin, _ := dao.CheckUserName(t.Context(), "roleadmin")
+	h := HandlerFunc(UpdateUserRole)
+
+	form := url.Values{"userId": {fmt.Sprint(target.ID)}, "role": {model.RoleStaff}}
+	if w := serve(h, "POST", "/updateUserRole", form, adminCookie); w.Code != http.StatusOK {
+		t.Fatalf("修改角色失败，状态码为%d", w.Code)
+	}
+	if user, _ := dao.CheckUserName(t.Context(), "roletarget"); user.Role != model.RoleStaff {
+		t.Errorf("角色应修改为staff，实际为%s", user.Role)
+	}
+
+	form = url.Values{"userId": {fmt.Sprint(target.ID)}, "role": {"root"}}
+	if w := serve(h, "POST", "/updateUserRole", form, adminCookie); w.Code != http.StatusBadRequest {
+		t.Errorf("不存在的角色应返回400，实际为%d", w.Code)
+	}
+
+	form = url.Values{"userId": {fmt.Sprint(admin.ID)}, "role": {model.RoleCustomer}}
+	if w := serve(h, "POST", "/updateUserRole", form, adminCookie); w.Code != http.StatusBadRequest {
+		t.Errorf("修改自己的角色应返回400，实际为%d", w.Code)
+	}
+	if user, _ := dao.CheckUserName(t.Context(), "roleadmin"); user.Role != model.RoleAdmin {
+		t.Errorf("管理员的角色不应被修改")
+	}
+}
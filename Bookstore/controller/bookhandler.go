@@ -1,11 +1,14 @@
 package controller
 
 import (
-	"bookstore0612/dao"
-	"bookstore0612/model"
+	"bookstore/dao"
+	"bookstore/model"
+	"context"
 	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 // IndexHandler 去首页
@@ -23,55 +26,117 @@ import (
 // 	t.Execute(w, page)
 // }
 
-//GetPageBooksByPrice 获取带分页和价格范围的图书
-func GetPageBooksByPrice(w http.ResponseWriter, r *http.Request) {
-	//获取页码
-	pageNo := r.FormValue("pageNo")
-	//获取价格范围
-	minPrice := r.FormValue("min")
-	maxPrice := r.FormValue("max")
-	if pageNo == "" {
-		pageNo = "1"
-	}
-	var page *model.Page
-	if minPrice == "" && maxPrice == "" {
-		//调用bookdao中获取带分页的图书的函数
-		page, _ = dao.GetPageBooks(pageNo)
-	} else {
-		//调用bookdao中获取带分页和价格范围的图书的函数
-		page, _ = dao.GetPageBooksByPrice(pageNo, minPrice, maxPrice)
-		//将价格范围设置到page中
-		page.MinPrice = minPrice
-		page.MaxPrice = maxPrice
+//GetPageBooksByPrice 获取带分页和价格范围的图书，同时支持按关键字、库存筛选和排序
+func GetPageBooksByPrice(w http.ResponseWriter, r *http.Request) error {
+	//获取查询条件，格式不正确的条件忽略
+	q, _ := parseBookQuery(r)
+	//调用bookdao中根据查询条件获取带分页的图书的函数
+	page, err := dao.SearchBooks(r.Context(), q)
+	if err != nil {
+		return err
 	}
+	//将查询条件设置到page中
+	setPageQuery(page, r, q)
 	//调用IsLogin函数判断是否已经登录
-	flag, session := dao.IsLogin(r)
+	flag, session := isLogin(r)
 
 	if flag {
 		//已经登录，设置page中的IsLogin字段和Username的字段值
 		page.IsLogin = true
 		page.Username = session.UserName
+		page.IsStaff = session.IsStaff()
 	}
 
-	//解析模板文件
-	t := template.Must(template.ParseFiles("views/index.html"))
-	//执行
-	t.Execute(w, page)
+	//渲染模板
+	return render(w, "index.html", page)
 }
 
 //GetPageBooks 获取带分页的图书
-func GetPageBooks(w http.ResponseWriter, r *http.Request) {
-	//获取页码
-	pageNo := r.FormValue("pageNo")
-	if pageNo == "" {
-		pageNo = "1"
-	}
-	//调用bookdao中获取带分页的图书的函数
-	page, _ := dao.GetPageBooks(pageNo)
-	//解析模板文件
-	t := template.Must(template.ParseFiles("views/pages/manager/book_manager.html"))
-	//执行
-	t.Execute(w, page)
+func GetPageBooks(w http.ResponseWriter, r *http.Request) error {
+	//获取查询条件，格式不正确的条件忽略
+	q, _ := parseBookQuery(r)
+	//调用bookdao中根据查询条件获取带分页的图书的函数
+	page, err := dao.SearchBooks(r.Context(), q)
+	if err != nil {
+		return err
+	}
+	//将查询条件设置到page中
+	setPageQuery(page, r, q)
+	//渲染模板
+	return render(w, "pages/manager/book_manager.html", page)
+}
+
+// parseBookQuery 从请求中获取图书的查询条件，返回第一个格式不正确的条件对应的400错误，
+// 格式不正确的条件不会设置到查询条件中
+func parseBookQuery(r *http.Request) (*dao.BookQuery, error) {
+	var err error
+	q := &dao.BookQuery{
+		Keyword:  strings.TrimSpace(r.FormValue("keyword")),
+		InStock:  r.FormValue("inStock") != "",
+		PageSize: conf.Books.PageSize,
+	}
+	//页码
+	if pageNo := r.FormValue("pageNo"); pageNo != "" {
+		iPageNo, err2 := strconv.ParseInt(pageNo, 10, 64)
+		if err2 != nil || iPageNo < 1 {
+			err = badRequest("页码不正确！")
+		} else {
+			q.PageNo = iPageNo
+		}
+	}
+	//价格范围
+	for _, v := range []struct {
+		name  string
+		price *model.Money
+	}{{"min", &q.MinPrice}, {"max", &q.MaxPrice}} {
+		if s := r.FormValue(v.name); s != "" {
+			price, err2 := model.ParseMoney(s)
+			if err2 != nil || price.Minor < 0 {
+				if err == nil {
+					err = badRequest("价格范围不正确！")
+				}
+				continue
+			}
+			*v.price = price
+		}
+	}
+	if q.MaxPrice.Minor > 0 && q.MinPrice.Cmp(q.MaxPrice) > 0 && err == nil {
+		err = badRequest("价格范围不正确！")
+	}
+	//排序方式
+	if sort := r.FormValue("sort"); dao.IsValidSort(sort) {
+		q.Sort = sort
+	} else if err == nil {
+		err = badRequest("排序方式不正确！")
+	}
+	return q, err
+}
+
+// setPageQuery 将查询条件设置到page中，用于回显查询表单和拼接分页链接
+func setPageQuery(page *model.Page, r *http.Request, q *dao.BookQuery) {
+	values := url.Values{}
+	page.Keyword = q.Keyword
+	if q.Keyword != "" {
+		values.Set("keyword", q.Keyword)
+	}
+	page.MinPrice = r.FormValue("min")
+	if q.MinPrice.Minor > 0 {
+		values.Set("min", page.MinPrice)
+	}
+	page.MaxPrice = r.FormValue("max")
+	if q.MaxPrice.Minor > 0 {
+		values.Set("max", page.MaxPrice)
+	}
+	page.InStock = q.InStock
+	if q.InStock {
+		values.Set("inStock", "1")
+	}
+	page.Sort = q.Sort
+	if q.Sort != dao.SortDefault {
+		values.Set("sort", q.Sort)
+	}
+	//查询条件都经过了URL编码
+	page.Query = template.URL(values.Encode())
 }
 
 //GetBooks 获取所有图书
@@ -112,38 +177,50 @@ func GetPageBooks(w http.ResponseWriter, r *http.Request) {
 // }
 
 //DeleteBook 删除图书
-func DeleteBook(w http.ResponseWriter, r *http.Request) {
+func DeleteBook(w http.ResponseWriter, r *http.Request) error {
 	//获取要删除的图书的id
 	bookID := r.FormValue("bookId")
 	//调用bookdao中删除图书的函数
-	dao.DeleteBook(bookID)
+	if err := dao.DeleteBook(r.Context(), bookID); err != nil {
+		return err
+	}
 	//调用GetBooks处理器函数再次查询一次数据库
-	GetPageBooks(w, r)
+	return GetPageBooks(w, r)
 }
 
 //ToUpdateBookPage 去更新或者添加图书的页面
-func ToUpdateBookPage(w http.ResponseWriter, r *http.Request) {
+func ToUpdateBookPage(w http.ResponseWriter, r *http.Request) error {
 	//获取要更新的图书的id
 	bookID := r.FormValue("bookId")
-	//调用bookdao中获取图书的函数
-	book, _ := dao.GetBookByID(bookID)
-	if book.ID > 0 {
-		//在更新图书
-		//解析模板
-		t := template.Must(template.ParseFiles("views/pages/manager/book_edit.html"))
-		//执行
-		t.Execute(w, book)
-	} else {
+	if bookID == "" {
 		//在添加图书
-		//解析模板
-		t := template.Must(template.ParseFiles("views/pages/manager/book_edit.html"))
-		//执行
-		t.Execute(w, "")
+		//渲染模板
+		return render(w, "pages/manager/book_edit.html", "")
+	}
+	//在更新图书
+	//调用bookdao中获取图书的函数
+	book, err := getBookByID(r.Context(), bookID)
+	if err != nil {
+		return err
 	}
+	//渲染模板
+	return render(w, "pages/manager/book_edit.html", book)
+}
+
+// getBookByID 根据图书的id获取图书，图书不存在时返回404错误
+func getBookByID(ctx context.Context, bookID string) (*model.Book, error) {
+	book, err := dao.GetBookByID(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	if book.ID == 0 {
+		return nil, notFound("图书不存在！")
+	}
+	return book, nil
 }
 
 //UpdateOrAddBook 更新或添加图书
-func UpdateOrAddBook(w http.ResponseWriter, r *http.Request) {
+func UpdateOrAddBook(w http.ResponseWriter, r *http.Request) error {
 	//获取图书信息
 	bookID := r.PostFormValue("bookId")
 	title := r.PostFormValue("title")
@@ -152,29 +229,46 @@ func UpdateOrAddBook(w http.ResponseWriter, r *http.Request) {
 	sales := r.PostFormValue("sales")
 	stock := r.PostFormValue("stock")
 	//将价格、销量和库存进行转换
-	fPrice, _ := strconv.ParseFloat(price, 64)
-	iSales, _ := strconv.ParseInt(sales, 10, 0)
-	iStock, _ := strconv.ParseInt(stock, 10, 0)
+	mPrice, err1 := model.ParseMoney(price)
+	iSales, err2 := strconv.ParseInt(sales, 10, 0)
+	iStock, err3 := strconv.ParseInt(stock, 10, 0)
+	if strings.TrimSpace(title) == "" || err1 != nil || err2 != nil || err3 != nil || mPrice.Minor < 0 || iSales < 0 || iStock < 0 {
+		return badRequest("书名不能为空，价格、销量和库存必须是不小于0的数字！")
+	}
+	//添加图书时没有图书的id
 	ibookID, _ := strconv.ParseInt(bookID, 10, 0)
 	//创建Book
 	book := &model.Book{
 		ID:      int(ibookID),
 		Title:   title,
 		Author:  author,
-		Price:   fPrice,
+		Price:   mPrice,
 		Sales:   int(iSales),
 		Stock:   int(iStock),
 		ImgPath: "/static/img/default.jpg",
 	}
+	var err error
 	if book.ID > 0 {
 		//在更新图书
+		var old *model.Book
+		old, err = getBookByID(r.Context(), bookID)
+		if err != nil {
+			return err
+		}
 		//调用bookdao中更新图书的函数
-		dao.UpdateBook(book)
+		err = dao.UpdateBook(r.Context(), book)
+		//价格变化后只重新计算有该图书的购物车中保存的金额
+		if err == nil && old.Price.Cmp(book.Price) != 0 {
+			_, err = dao.RecalculateBookCarts(r.Context(), book.ID)
+		}
 	} else {
 		//在添加图书
 		//调用bookdao中添加图书的函数
-		dao.AddBook(book)
+		err = dao.AddBook(r.Context(), book)
+	}
+	if err != nil {
+		return err
 	}
 	//调用GetBooks处理器函数再次查询一次数据库
-	GetPageBooks(w, r)
+	return GetPageBooks(w, r)
 }
@@ -4,138 +4,233 @@ import (
 	"bookstore/dao"
 	"bookstore/model"
 	"bookstore/utils"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
 	"net/http"
 	"strconv"
 )
 
-// AddBook2Cart 添加图书到购物车
-func AddBook2Cart(w http.ResponseWriter, r *http.Request) {
+// AddBook2Cart 添加图书到购物车，没有登录时添加到游客购物车中，登录后合并到用户的购物车
+func AddBook2Cart(w http.ResponseWriter, r *http.Request) error {
+	//获取要添加的图书的id
+	bookID := r.FormValue("bookId")
+	//根据图书的id获取图书信息
+	book, err := getBookByID(r.Context(), bookID)
+	if err != nil {
+		return err
+	}
 	//判断是否登录
-	flag, session := dao.IsLogin(r)
-	if flag {
-		//已经登录
-		//获取要添加的图书的id
-		bookID := r.FormValue("bookId")
-		//根据图书的id获取图书信息
-		book, _ := dao.GetBookByID(bookID)
+	var cartItem *model.CartItem
+	if flag, session := isLogin(r); flag {
+		//将图书添加到当前用户的购物车中
+		_, cartItem, err = addBookToCart(r.Context(), session.UserID, book, 1)
+	} else {
+		//没有登录，将图书添加到游客购物车中
+		cartItem, err = addBookToGuestCart(w, r, book, 1)
+	}
+	if err != nil {
+		return err
+	}
+	if len(cartItem.Notices) > 0 {
+		//库存不足，数量已被调整
+		_, err = w.Write([]byte(cartItem.Notices[0]))
+		return err
+	}
+	_, err = w.Write([]byte("您刚刚将" + book.Title + "添加到了购物车！"))
+	return err
+}
 
-		//获取用户的id
-		userID := session.UserID
-		//判断数据库中是否有当前用户的购物车
-		cart, _ := dao.GetCartByUserID(userID)
-		if cart != nil {
-			//当前用户已经有购物车，此时需要判断购物车中是否有当前这本图书
-			carItem, _ := dao.GetCartItemByBookIDAndCartID(bookID, cart.CartID)
-			if carItem != nil {
-				//购物车的购物项中已经有该图书，只需要将该图书所对应的购物项中的数量加1即可
-				//1.获取购物车切片中的所有的购物项
-				cts := cart.CartItems
-				//2.遍历得到每一个购物项
-				for _, v := range cts {
-					fmt.Println("当前购物项中是否有Book：", v)
-					fmt.Println("查询到的Book是：", carItem.Book)
-					//3.找到当前的购物项
-					if v.Book.ID == carItem.Book.ID {
-						//将购物项中的图书的数量加1
-						v.Count = v.Count + 1
-						//更新数据库中该购物项的图书的数量
-						dao.UpdateBookCount(v)
-					}
-				}
-			} else {
-				//购物车的购物项中还没有该图书，此时需要创建一个购物项并添加到数据库中
-				//创建购物车中的购物项
-				cartItem := &model.CartItem{
-					Book:   book,
-					Count:  1,
-					CartID: cart.CartID,
-				}
-				//将购物项添加到当前cart的切片中
-				cart.CartItems = append(cart.CartItems, cartItem)
-				//将新创建的购物项添加到数据库中
-				dao.AddCartItem(cartItem)
-			}
-			//不管之前购物车中是否有当前图书对应的购物项，都需要更新购物车中的图书的总数量和总金额
-			dao.UpdateCart(cart)
-		} else {
-			//证明当前用户还没有购物车，需要创建一个购物车并添加到数据库中
-			//1.创建购物车
-			//生成购物车的id
-			cartID := utils.CreateUUID()
-			cart := &model.Cart{
-				CartID: cartID,
-				UserID: userID,
-			}
-			//2.创建购物车中的购物项
-			//声明一个CartItem类型的切片
-			var cartItems []*model.CartItem
-			cartItem := &model.CartItem{
-				Book:   book,
-				Count:  1,
-				CartID: cartID,
-			}
-			//将购物项添加到切片中
-			cartItems = append(cartItems, cartItem)
-			//3将切片设置到cart中
-			cart.CartItems = cartItems
-			//4.将购物车cart保存到数据库中
-			dao.AddCart(cart)
+// getCartByUserID 获取用户的购物车，用户还没有购物车时返回nil
+func getCartByUserID(ctx context.Context, userID int) (*model.Cart, error) {
+	cart, err := dao.GetCartByUserID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return cart, err
+}
+
+// addBookToCart 将count本图书添加到用户的购物车中，页面和API共用，
+// 返回更新后的购物车和图书所在的购物项，超过可以购买的库存时数量会被调整，并在购物项中带有提示信息
+func addBookToCart(ctx context.Context, userID int, book *model.Book, count int64) (*model.Cart, *model.CartItem, error) {
+	//判断数据库中是否有当前用户的购物车
+	cart, err := getCartByUserID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cart != nil {
+		//当前用户已经有购物车
+		cartItem, err := addBookToExistingCart(ctx, cart, book, count)
+		return cart, cartItem, err
+	}
+	//证明当前用户还没有购物车，需要创建一个购物车并添加到数据库中
+	cart, err = createCart(ctx, userID, book, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cart, cart.CartItems[0], nil
+}
+
+// addBookToExistingCart 将count本图书添加到已有的购物车中，cart随之更新，返回图书所在的购物项
+func addBookToExistingCart(ctx context.Context, cart *model.Cart, book *model.Book, count int64) (*model.CartItem, error) {
+	//判断购物车中是否有当前这本图书，购物车中已经有所有的购物项，不需要再查询
+	var cartItem *model.CartItem
+	for _, v := range cart.CartItems {
+		if v.Book.ID == book.ID {
+			cartItem = v
+		}
+	}
+	if cartItem != nil {
+		//购物车的购物项中已经有该图书，只需要将该图书所对应的购物项中的数量增加即可
+		//增加后的数量不能超过可以购买的库存
+		newCount, notice, err := clampToStock(ctx, cart.CartID, book, cartItem.Count+count)
+		if err != nil {
+			return nil, err
+		}
+		if newCount <= cartItem.Count {
+			return nil, outOfStock(fmt.Sprintf("《%s》库存不足，购物车中已有%d本！", book.Title, cartItem.Count))
+		}
+		//将购物项中的图书的数量增加
+		cartItem.Count = newCount
+		if notice != "" {
+			cartItem.Notices = append(cartItem.Notices, notice)
+		}
+		//更新数据库中该购物项的图书的数量
+		if err := dao.UpdateBookCount(ctx, cartItem); err != nil {
+			return nil, err
 		}
-		w.Write([]byte("您刚刚将" + book.Title + "添加到了购物车！"))
 	} else {
-		//没有登录
-		w.Write([]byte("请先登录！"))
+		//购物车的购物项中还没有该图书，此时需要创建一个购物项并添加到数据库中
+		var err error
+		cartItem, err = newCartItem(ctx, cart.CartID, book, count)
+		if err != nil {
+			return nil, err
+		}
+		//将购物项添加到当前cart的切片中
+		cart.CartItems = append(cart.CartItems, cartItem)
+		//将新创建的购物项添加到数据库中
+		if err := dao.AddCartItem(ctx, cartItem); err != nil {
+			return nil, err
+		}
+	}
+	//不管之前购物车中是否有当前图书对应的购物项，都需要更新购物车中的图书的总数量和总金额
+	cart.Recalculate()
+	if err := dao.UpdateCart(ctx, cart); err != nil {
+		return nil, err
+	}
+	return cartItem, nil
+}
+
+// createCart 创建一个只有一个购物项的购物车并保存到数据库中，userID为0时是游客购物车
+func createCart(ctx context.Context, userID int, book *model.Book, count int64) (*model.Cart, error) {
+	//生成购物车的id
+	cartID := utils.CreateUUID()
+	cartItem, err := newCartItem(ctx, "", book, count)
+	if err != nil {
+		return nil, err
+	}
+	cartItem.CartID = cartID
+	cart := &model.Cart{
+		CartID:    cartID,
+		UserID:    userID,
+		CartItems: []*model.CartItem{cartItem},
+	}
+	cart.Recalculate()
+	//将购物车cart保存到数据库中
+	if err := dao.AddCart(ctx, cart); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// newCartItem 创建一个新的购物项，数量超过可以购买的库存时会被调整
+func newCartItem(ctx context.Context, cartID string, book *model.Book, count int64) (*model.CartItem, error) {
+	count, notice, err := clampToStock(ctx, cartID, book, count)
+	if err != nil {
+		return nil, err
+	}
+	cartItem := &model.CartItem{
+		Book:   book,
+		Count:  count,
+		Price:  book.Price,
+		CartID: cartID,
+	}
+	if notice != "" {
+		cartItem.Notices = append(cartItem.Notices, notice)
 	}
+	return cartItem, nil
 }
 
-// GetCartInfo 根据用户的id获取购物车信息
-func GetCartInfo(w http.ResponseWriter, r *http.Request) {
-	_, session := dao.IsLogin(r)
+// GetCartInfo 根据用户的id获取购物车信息，没有登录时显示游客购物车
+func GetCartInfo(w http.ResponseWriter, r *http.Request) error {
+	flag, session := isLogin(r)
+	if !flag {
+		//没有登录，游客购物车不存在时为nil
+		cart, err := getGuestCart(r.Context(), r)
+		if err != nil {
+			return err
+		}
+		return renderCart(w, nil, cart)
+	}
 	//获取用户的id
 	userID := session.UserID
-	//根据用户的id从数据库中获取对应的购物车
-	cart, _ := dao.GetCartByUserID(userID)
-	if cart != nil {
-		//将购物车设置到session中
-		session.Cart = cart
-		//解析模板文件
-		t := template.Must(template.ParseFiles("views/pages/cart/cart.html"))
-		//执行
-		t.Execute(w, session)
-	} else {
-		//该用户还没有购物车
-		//解析模板文件
-		t := template.Must(template.ParseFiles("views/pages/cart/cart.html"))
-		//执行
-		t.Execute(w, session)
+	//根据用户的id从数据库中获取对应的购物车，该用户还没有购物车时为nil
+	cart, err := getCartByUserID(r.Context(), userID)
+	if err != nil {
+		return err
 	}
+	return renderCart(w, session, cart)
+}
+
+// renderCart 渲染购物车页面，session为nil时是游客，价格有变化的图书需要提示用户
+func renderCart(w http.ResponseWriter, session *model.Session, cart *model.Cart) error {
+	if session == nil {
+		session = &model.Session{}
+	}
+	//将购物车设置到session中
+	session.Cart = withPriceNotices(cart)
+	//渲染模板
+	return render(w, "pages/cart/cart.html", session)
 }
 
 // DeleteCart 清空购物车
-func DeleteCart(w http.ResponseWriter, r *http.Request) {
+func DeleteCart(w http.ResponseWriter, r *http.Request) error {
 	//获取要删除的购物车的id
 	cartID := r.FormValue("cartId")
+	//获取要清空的购物车
+	cart, err := dao.GetCartByCartID(r.Context(), cartID)
+	if err != nil {
+		return orNotFound(err, "购物车不存在！")
+	}
+	//只能清空自己的购物车
+	if err := checkCartAccess(r, cart); err != nil {
+		return err
+	}
 	//清空购物车
-	dao.DeleteCartByCartID(cartID)
+	if err := dao.DeleteCartByCartID(r.Context(), cartID); err != nil {
+		return err
+	}
 	//调用GetCartInfo函数再次查询购物车信息
-	GetCartInfo(w, r)
+	return GetCartInfo(w, r)
 }
 
 // DeleteCartItem 删除购物项
-func DeleteCartItem(w http.ResponseWriter, r *http.Request) {
+func DeleteCartItem(w http.ResponseWriter, r *http.Request) error {
 	//获取要删除的购物项的id
 	cartItemID := r.FormValue("cartItemId")
 	//将购物项的id转换为int64
 	iCartItemID, _ := strconv.ParseInt(cartItemID, 10, 64)
-	//获取session
-	_, session := dao.IsLogin(r)
-	//获取用户的id
-	userID := session.UserID
-	//获取该用户的购物车
-	cart, _ := dao.GetCartByUserID(userID)
+	//获取购物项所属的购物车
+	cart, err := getCartByCartItemID(r.Context(), cartItemID)
+	if err != nil {
+		return err
+	}
+	//只能删除自己购物车中的购物项
+	if err := checkCartAccess(r, cart); err != nil {
+		return err
+	}
 	//获取购物车中的所有的购物项
 	cartItems := cart.CartItems
 	//遍历得到每一个购物项
@@ -148,30 +243,49 @@ func DeleteCartItem(w http.ResponseWriter, r *http.Request) {
 			//将删除购物项之后的切片再次赋给购物车中的切片
 			cart.CartItems = cartItems
 			//将当前购物项从数据库中删除
-			dao.DeleteCartItemByID(cartItemID)
+			if err := dao.DeleteCartItemByID(r.Context(), cartItemID); err != nil {
+				return err
+			}
+			break
 		}
 	}
 	//更新购物车中的图书的总数量和总金额
-	dao.UpdateCart(cart)
-	//调用获取购物项信息的函数再次查询购物车信息
-	GetCartInfo(w, r)
+	cart.Recalculate()
+	if err := dao.UpdateCart(r.Context(), cart); err != nil {
+		return err
+	}
+	//删除的是自己的购物项时直接显示更新后的购物车，店员删除别人的购物项后查询自己的购物车
+	if flag, session := isLogin(r); !flag || session.UserID == cart.UserID {
+		return renderCart(w, session, cart)
+	}
+	return GetCartInfo(w, r)
 }
 
 // UpdateCartItem 更新购物项
-func UpdateCartItem(w http.ResponseWriter, r *http.Request) {
+func UpdateCartItem(w http.ResponseWriter, r *http.Request) error {
 	//获取要更新的购物项的id
 	cartItemID := r.FormValue("cartItemId")
 	//将购物项的id转换为int64
 	iCartItemID, _ := strconv.ParseInt(cartItemID, 10, 64)
 	//获取用户输入的图书的数量
 	bookCount := r.FormValue("bookCount")
-	iBookCount, _ := strconv.ParseInt(bookCount, 10, 64)
-	//获取session
-	_, session := dao.IsLogin(r)
-	//获取用户的id
-	userID := session.UserID
-	//获取该用户的购物车
-	cart, _ := dao.GetCartByUserID(userID)
+	iBookCount, err := strconv.ParseInt(bookCount, 10, 64)
+	if err != nil || iBookCount < 1 {
+		return badRequest("图书的数量必须大于0！")
+	}
+	//获取购物项所属的购物车
+	cart, err := getCartByCartItemID(r.Context(), cartItemID)
+	if err != nil {
+		return err
+	}
+	//只能更新自己购物车中的购物项
+	if err := checkCartAccess(r, cart); err != nil {
+		return err
+	}
+	//数量被调整时的提示信息
+	var message string
+	//更新后的购物项
+	var cartItem *model.CartItem
 	//获取购物车中的所有的购物项
 	cartItems := cart.CartItems
 	//遍历得到每一个购物项
@@ -179,38 +293,52 @@ func UpdateCartItem(w http.ResponseWriter, r *http.Request) {
 		//寻找要更新的购物项
 		if v.CartItemID == iCartItemID {
 			//这个就是我们要更新的购物项
-			//将当前购物项中的图书的数量设置为用户输入的值
-			v.Count = iBookCount
+			//用户输入的数量不能超过可以购买的库存
+			count, notice, err := clampToStock(r.Context(), cart.CartID, v.Book, iBookCount)
+			if err != nil {
+				return err
+			}
+			message = notice
+			//将当前购物项中的图书的数量设置为调整后的值
+			v.Count = count
 			//更新数据库中该购物项的图书的数量和金额小计
-			dao.UpdateBookCount(v)
+			if err := dao.UpdateBookCount(r.Context(), v); err != nil {
+				return err
+			}
+			cartItem = v
 		}
 	}
-	//更新购物车中的图书的总数量和总金额
-	dao.UpdateCart(cart)
-	//调用获取购物项信息的函数再次查询购物车信息
-	cart, _ = dao.GetCartByUserID(userID)
-	// GetCartInfo(w, r)
-	//获取购物车中图书的总数量
-	totalCount := cart.TotalCount
-	//获取购物车中图书的总金额
-	totalAmount := cart.TotalAmount
-	var amount float64
-	//获取购物车中更新的购物项中的金额小计
-	cIs := cart.CartItems
-	for _, v := range cIs {
-		if iCartItemID == v.CartItemID {
-			//这个就是我们寻找的购物项，此时获取当前购物项中的金额小计
-			amount = v.Amount
-		}
+	if cartItem == nil {
+		return notFound("购物项不存在！")
+	}
+	//更新购物车中的图书的总数量和总金额，响应中的金额直接使用更新后的购物车，不再查询
+	cart.Recalculate()
+	if err := dao.UpdateCart(r.Context(), cart); err != nil {
+		return err
 	}
 	//创建Data结构
 	data := model.Data{
-		Amount:      amount,
-		TotalAmount: totalAmount,
-		TotalCount:  totalCount,
+		Amount:      cartItem.Amount,
+		TotalAmount: cart.TotalAmount,
+		TotalCount:  cart.TotalCount,
+		Count:       cartItem.Count,
+		Message:     message,
 	}
 	//将data转换为json字符串
-	json, _ := json.Marshal(data)
+	json, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
 	//响应到浏览器
-	w.Write(json)
+	_, err = w.Write(json)
+	return err
+}
+
+// getCartByCartItemID 根据购物项的id获取该购物项所属的购物车，购物项不存在时返回404错误
+func getCartByCartItemID(ctx context.Context, cartItemID string) (*model.Cart, error) {
+	cart, err := dao.GetCartByCartItemID(ctx, cartItemID)
+	if err != nil {
+		return nil, orNotFound(err, "购物项不存在！")
+	}
+	return cart, nil
 }
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"bookstore/config"
+	"bookstore/payment"
+	"bookstore/views"
+	"io/fs"
+	"os"
+)
+
+// conf 当前使用的配置，由Configure设置
+var conf = config.Default()
+
+// Configure 设置处理器使用的配置，解析所有模板并读取静态资源，应在启动时调用一次，模板不正确时返回错误
+func Configure(cfg *config.Config) error {
+	templateFS, err := viewsFS(cfg.Views.TemplateRoot, ".")
+	if err != nil {
+		return err
+	}
+	staticFS, err := viewsFS(cfg.Views.StaticRoot, "static")
+	if err != nil {
+		return err
+	}
+	pages, err := fs.Sub(templateFS, "pages")
+	if err != nil {
+		return err
+	}
+	reg, err := newTemplateRegistry(templateFS, cfg.Views.Reload)
+	if err != nil {
+		return err
+	}
+	a, err := newStaticAssets(staticFS)
+	if err != nil {
+		return err
+	}
+	//没有配置支付网关时不使用在线支付
+	var provider payment.Provider
+	if cfg.Payment.Enabled() {
+		provider, err = payment.New(cfg.Payment.Provider, cfg.Payment.WebhookSecret)
+		if err != nil {
+			return err
+		}
+	}
+	conf = cfg
+	templates = reg
+	assets = a
+	pagesFS = pages
+	payments = provider
+	return nil
+}
+
+// viewsFS 配置了目录时使用该目录中的文件，否则使用编译进程序中的views目录下的dir目录
+func viewsFS(root string, dir string) (fs.FS, error) {
+	if root != "" {
+		return os.DirFS(root), nil
+	}
+	return fs.Sub(views.FS, dir)
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// csrfCookieName 保存CSRF令牌的Cookie，页面中的csrf.js读取后随表单和Ajax请求一起提交，因此不能设置HttpOnly
+	csrfCookieName = "csrf_token"
+	// csrfFieldName 表单中提交CSRF令牌的字段
+	csrfFieldName = "csrf_token"
+	// csrfHeaderName Ajax请求中提交CSRF令牌的请求头
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+// CSRF 防止跨站请求伪造：确保浏览器中有当前的CSRF令牌，并检查所有修改数据的请求提交的令牌是否正确。
+// 已经登录时令牌由Session的id计算得出，没有登录时令牌是Cookie中的随机值（登录、注册表单使用）
+func CSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ensureCSRFToken(w, r)
+		if !isSafeMethod(r.Method) && !isCSRFExempt(r) && !validCSRFToken(r, token) {
+			renderError(w, r, forbidden("页面已过期，请刷新页面后重试！"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isSafeMethod 判断请求是否只读取数据
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// isCSRFExempt 判断请求是否不可能由其他网站伪造：使用令牌而不是Cookie登录的API请求，
+// 以及JSON格式的请求（其他网站发送时浏览器会先进行跨域检查，而书城没有允许任何跨域请求）
+func isCSRFExempt(r *http.Request) bool {
+	if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// validCSRFToken 判断请求头或表单中提交的令牌是否正确
+func validCSRFToken(r *http.Request, token string) bool {
+	submitted := r.Header.Get(csrfHeaderName)
+	if submitted == "" {
+		submitted = r.PostFormValue(csrfFieldName)
+	}
+	return submitted != "" && subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) == 1
+}
+
+// ensureCSRFToken 获取当前请求应提交的令牌，浏览器中的Cookie不是该令牌时重新设置
+func ensureCSRFToken(w http.ResponseWriter, r *http.Request) string {
+	var cookieToken string
+	if cookie, err := r.Cookie(csrfCookieName); err == nil {
+		cookieToken = cookie.Value
+	}
+	token := cookieToken
+	if sessID := loggedInSessionID(r); sessID != "" {
+		token = sessionCSRFToken(sessID)
+	} else if token == "" {
+		token = randomCSRFToken()
+	}
+	if token != cookieToken {
+		setCSRFCookie(w, token)
+	}
+	return token
+}
+
+// loggedInSessionID 获取有效的Session的id，没有登录时返回空字符串
+func loggedInSessionID(r *http.Request) string {
+	if flag, session := isLogin(r); flag {
+		return session.SessionID
+	}
+	return ""
+}
+
+// sessionCSRFToken 由Session的id计算CSRF令牌，Session的id保存在HttpOnly的Cookie中，其他网站无法得到
+func sessionCSRFToken(sessID string) string {
+	mac := hmac.New(sha256.New, []byte(sessID))
+	mac.Write([]byte("csrf"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// randomCSRFToken 生成没有登录时使用的随机令牌
+func randomCSRFToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// setCSRFCookie 将令牌保存到Cookie中，登录之后需要立即换成新Session的令牌
+func setCSRFCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   conf.Session.CookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"bookstore/model"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// postWithToken 以POST方式提交表单，cookies为浏览器中的Cookie，token不为空时作为表单中的CSRF令牌提交
+func postWithToken(h http.Handler, form url.Values, token string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	if token != "" {
+		form.Set(csrfFieldName, token)
+	}
+	r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// csrfCookie 获取响应中设置的CSRF令牌的Cookie
+func csrfCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == csrfCookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestCSRF(t *testing.T) {
+	h := CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	session := loginAs(t, "csrfuser", model.RoleCustomer)
+	token := sessionCSRFToken(session.Value)
+
+	t.Run("GET请求时设置令牌", func(t *testing.T) {
+		w := serve(h, "GET", "/", nil, nil)
+		cookie := csrfCookie(w)
+		if w.Code != http.StatusOK || cookie == nil || cookie.Value == "" {
+			t.Fatalf("没有令牌时应设置Cookie")
+		}
+		if cookie.HttpOnly {
+			t.Errorf("页面中的脚本需要读取令牌，Cookie不能设置HttpOnly")
+		}
+		w = serve(h, "GET", "/", nil, session)
+		if cookie := csrfCookie(w); cookie == nil || cookie.Value != token {
+			t.Errorf("登录后的令牌应由Session的id计算得出")
+		}
+	})
+
+	t.Run("没有令牌或令牌不正确", func(t *testing.T) {
+		for _, submitted := range []string{"", "wrong-token"} {
+			w := postWithToken(h, url.Values{"orderId": {"1"}}, submitted, session)
+			if w.Code != http.StatusForbidden {
+				t.Errorf("令牌为%q时状态码应为403，实际为%d", submitted, w.Code)
+			}
+		}
+		//Session中的令牌不能在其他用户的Session中使用
+		other := loginAs(t, "csrfother", model.RoleCustomer)
+		if w := postWithToken(h, url.Values{}, token, other); w.Code != http.StatusForbidden {
+			t.Errorf("使用其他Session的令牌时状态码应为403，实际为%d", w.Code)
+		}
+	})
+
+	t.Run("令牌正确", func(t *testing.T) {
+		if w := postWithToken(h, url.Values{}, token, session); w.Code != http.StatusOK {
+			t.Errorf("表单中的令牌正确时状态码应为200，实际为%d", w.Code)
+		}
+		r := httptest.NewRequest("POST", "/", nil)
+		r.AddCookie(session)
+		r.Header.Set(csrfHeaderName, token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("请求头中的令牌正确时状态码应为200，实际为%d", w.Code)
+		}
+		//没有登录时使用Cookie中的随机令牌
+		anonymous := &http.Cookie{Name: csrfCookieName, Value: "random-token"}
+		if w := postWithToken(h, url.Values{}, "random-token", anonymous); w.Code != http.StatusOK {
+			t.Errorf("没有登录时提交Cookie中的令牌状态码应为200，实际为%d", w.Code)
+		}
+	})
+
+	t.Run("JSON接口不需要令牌", func(t *testing.T) {
+		for _, header := range [][2]string{{"Authorization", "Bearer abc"}, {"Content-Type", "application/json; charset=utf-8"}} {
+			r := httptest.NewRequest("POST", "/api/v1/cart/items", strings.NewReader("{}"))
+			r.Header.Set(header[0], header[1])
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Errorf("%s为%q时状态码应为200，实际为%d", header[0], header[1], w.Code)
+			}
+		}
+	})
+
+	t.Run("登录后更换令牌", func(t *testing.T) {
+		loginAs(t, "csrflogin", model.RoleCustomer)
+		w := serve(HandlerFunc(Login), "POST", "/login", url.Values{"username": {"csrflogin"}, "password": {"secret"}}, nil)
+		var sessID string
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == conf.Session.CookieName {
+				sessID = cookie.Value
+			}
+		}
+		cookie := csrfCookie(w)
+		if sessID == "" || cookie == nil || cookie.Value != sessionCSRFToken(sessID) {
+			t.Errorf("登录后应设置新Session对应的令牌")
+		}
+	})
+
+	t.Run("页面中的表单以POST方式提交", func(t *testing.T) {
+		newOwnedCart(t, "csrfuser")
+		w := serve(HandlerFunc(GetCartInfo), "GET", "/getCartInfo", nil, session)
+		body := w.Body.String()
+		for _, want := range []string{`action="/deleteCart" method="POST"`, assets.url("script/csrf.js")} {
+			if !strings.Contains(body, want) {
+				t.Errorf("购物车页面中应有%q", want)
+			}
+		}
+		w = serve(HandlerFunc(ToCheckout), "GET", "/toCheckout", nil, session)
+		if body := w.Body.String(); !strings.Contains(body, `action="/checkout" method="POST"`) {
+			t.Errorf("确认订单页面中应有以POST方式提交的结账表单")
+		}
+	})
+}
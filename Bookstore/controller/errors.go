@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/utils"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// HandlerFunc 返回错误的页面处理器，处理器只负责返回错误，由ServeHTTP统一记录日志并渲染错误页面
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP 实现http.Handler接口
+func (fn HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tw := &trackingWriter{ResponseWriter: w}
+	if err := fn(tw, r); err != nil {
+		if tw.wrote {
+			//已经开始响应，只能记录日志
+			utils.Logger(r.Context()).Error("响应过程中出错", slog.Any("error", err))
+			return
+		}
+		renderError(w, r, err)
+	}
+}
+
+// trackingWriter 记录处理器是否已经开始响应
+type trackingWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+// WriteHeader 记录已经开始响应
+func (w *trackingWriter) WriteHeader(status int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write 记录已经开始响应
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+// httpError 可以展示给用户的错误，带有响应的状态码和API的错误码
+type httpError struct {
+	Status  int
+	Code    string
+	Message string
+	Details []string
+}
+
+// Error 实现error接口
+func (e *httpError) Error() string {
+	return e.Message
+}
+
+// badRequest 请求的参数不正确，响应400
+func badRequest(message string, details ...string) error {
+	return &httpError{Status: http.StatusBadRequest, Code: apiCodeBadRequest, Message: message, Details: details}
+}
+
+// unauthorized 没有登录，页面跳转到登录页面，API响应401
+func unauthorized() error {
+	return &httpError{Status: http.StatusUnauthorized, Code: apiCodeUnauthorized, Message: "请先登录！"}
+}
+
+// forbidden 没有权限，响应403
+func forbidden(message string) error {
+	return &httpError{Status: http.StatusForbidden, Code: apiCodeForbidden, Message: message}
+}
+
+// notFound 资源不存在，响应404
+func notFound(message string) error {
+	return &httpError{Status: http.StatusNotFound, Code: apiCodeNotFound, Message: message}
+}
+
+// conflict 与资源当前的状态冲突，响应409
+func conflict(message string) error {
+	return &httpError{Status: http.StatusConflict, Code: apiCodeConflict, Message: message}
+}
+
+// outOfStock 库存不足，响应409
+func outOfStock(message string) error {
+	return &httpError{Status: http.StatusConflict, Code: apiCodeOutOfStock, Message: message}
+}
+
+// orNotFound 将查询不到记录的错误替换为带有提示信息的404错误，其他错误原样返回
+func orNotFound(err error, message string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound(message)
+	}
+	return err
+}
+
+// toHTTPError 将处理器返回的错误转换为响应的状态码和提示信息，没有预期到的错误都视为500
+func toHTTPError(err error) *httpError {
+	var he *httpError
+	var stateErr *dao.IllegalTransitionError
+	var stockErr *dao.OutOfStockError
+	switch {
+	case errors.As(err, &he):
+		return he
+	case errors.Is(err, sql.ErrNoRows):
+		return &httpError{Status: http.StatusNotFound, Code: apiCodeNotFound, Message: "您访问的内容不存在！"}
+	case errors.As(err, &stateErr):
+		return &httpError{Status: http.StatusConflict, Code: apiCodeConflict, Message: stateErr.Error()}
+	case errors.As(err, &stockErr):
+		return &httpError{Status: http.StatusConflict, Code: apiCodeOutOfStock, Message: "库存不足！", Details: stockErr.Messages()}
+	}
+	return &httpError{Status: http.StatusInternalServerError, Code: apiCodeInternal, Message: "服务器出错了，请稍后重试！"}
+}
+
+// logError 记录服务器内部的错误，其他错误是用户的请求导致的，不需要记录
+func logError(r *http.Request, he *httpError, err error) {
+	if he.Status >= http.StatusInternalServerError {
+		utils.Logger(r.Context()).Error("处理请求失败", slog.Any("error", err))
+	}
+}
+
+// isAjax 判断是否是页面中通过jQuery发送的Ajax请求
+func isAjax(r *http.Request) bool {
+	return r.Header.Get("X-Requested-With") == "XMLHttpRequest"
+}
+
+// renderError 记录错误并渲染错误页面：没有登录时跳转到登录页面，Ajax请求只响应提示信息
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	he := toHTTPError(err)
+	logError(r, he, err)
+	switch {
+	case isAjax(r):
+		http.Error(w, he.Message, he.Status)
+	case he.Status == http.StatusUnauthorized:
+		http.Redirect(w, r, "/pages/user/login.html", http.StatusFound)
+	default:
+		buf, err := templates.execute("pages/error.html", he)
+		if err != nil {
+			utils.Logger(r.Context()).Error("渲染错误页面失败", slog.Any("error", err))
+			http.Error(w, he.Message, he.Status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(he.Status)
+		buf.WriteTo(w)
+	}
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"bookstore/model"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHandlerErrors(t *testing.T) {
+	staff := loginAs(t, "errorstaff", model.RoleStaff)
+	customer := loginAs(t, "errorcustomer", model.RoleCustomer)
+
+	tests := []struct {
+		name    string
+		handler error
+		status  int
+		message string
+	}{
+		{"提示信息的错误", badRequest("参数不正确！"), http.StatusBadRequest, "参数不正确！"},
+		{"查询不到记录", fmt.Errorf("查询订单: %w", sql.ErrNoRows), http.StatusNotFound, "您访问的内容不存在！"},
+		{"没有预期到的错误", errors.New("连接数据库失败"), http.StatusInternalServerError, "服务器出错了，请稍后重试！"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return tt.handler })
+			w := serve(h, "GET", "/main", nil, nil)
+			if w.Code != tt.status {
+				t.Errorf("状态码应为%d，实际为%d", tt.status, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.message) {
+				t.Errorf("错误页面中应有提示信息%q", tt.message)
+			}
+			if strings.Contains(w.Body.String(), "连接数据库失败") {
+				t.Errorf("内部错误的详细信息不应展示给用户")
+			}
+		})
+	}
+
+	t.Run("修改不存在的图书", func(t *testing.T) {
+		h := RequireRole(model.RoleStaff)(HandlerFunc(ToUpdateBookPage))
+		w := serve(h, "GET", "/toUpdateBookPage?bookId=999999", nil, staff)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("状态码应为404，实际为%d", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "图书不存在！") {
+			t.Errorf("错误页面中应提示图书不存在")
+		}
+	})
+
+	t.Run("Ajax请求只响应提示信息", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/updateCartItem", strings.NewReader(url.Values{"cartItemId": {"1"}, "bookCount": {"0"}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("X-Requested-With", "XMLHttpRequest")
+		r.AddCookie(customer)
+		w := httptest.NewRecorder()
+		RequireLogin(HandlerFunc(UpdateCartItem)).ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("状态码应为400，实际为%d", w.Code)
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != "图书的数量必须大于0！" {
+			t.Errorf("响应应只有提示信息，实际为%q", got)
+		}
+	})
+
+	t.Run("已经开始响应后出错", func(t *testing.T) {
+		h := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			w.Write([]byte("部分内容"))
+			return errors.New("写入失败")
+		})
+		w := serve(h, "GET", "/main", nil, nil)
+		if w.Code != http.StatusOK || w.Body.String() != "部分内容" {
+			t.Errorf("已经开始响应后不应再渲染错误页面，实际为%d %q", w.Code, w.Body.String())
+		}
+	})
+}
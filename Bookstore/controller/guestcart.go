@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"bookstore/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// guestCartID 获取Cookie中保存的游客购物车的id，没有时返回空字符串
+func guestCartID(r *http.Request) string {
+	cookie, err := r.Cookie(conf.Cart.GuestCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// newGuestCartCookie 根据配置创建保存游客购物车的id的Cookie，有效期与游客购物车的保留时间一致
+func newGuestCartCookie(cartID string) *http.Cookie {
+	return &http.Cookie{
+		Name:     conf.Cart.GuestCookieName,
+		Value:    cartID,
+		Path:     "/",
+		MaxAge:   int(conf.Cart.GuestTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   conf.Session.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// clearGuestCartCookie 删除浏览器中保存游客购物车的id的Cookie
+func clearGuestCartCookie(w http.ResponseWriter) {
+	cookie := newGuestCartCookie("")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
+// getGuestCart 获取Cookie对应的游客购物车，没有Cookie、购物车已被清理或已经属于某个用户时返回nil
+func getGuestCart(ctx context.Context, r *http.Request) (*model.Cart, error) {
+	cartID := guestCartID(r)
+	if cartID == "" {
+		return nil, nil
+	}
+	cart, err := dao.GetCartByCartID(ctx, cartID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cart.UserID != 0 {
+		return nil, nil
+	}
+	return cart, nil
+}
+
+// addBookToGuestCart 将图书添加到游客购物车中，还没有游客购物车时创建一个并将id保存到Cookie中，
+// 返回图书所在的购物项
+func addBookToGuestCart(w http.ResponseWriter, r *http.Request, book *model.Book, count int64) (*model.CartItem, error) {
+	cart, err := getGuestCart(r.Context(), r)
+	if err != nil {
+		return nil, err
+	}
+	if cart != nil {
+		return addBookToExistingCart(r.Context(), cart, book, count)
+	}
+	cart, err = createCart(r.Context(), 0, book, count)
+	if err != nil {
+		return nil, err
+	}
+	http.SetCookie(w, newGuestCartCookie(cart.CartID))
+	return cart.CartItems[0], nil
+}
+
+// checkCartAccess 判断当前请求能否操作购物车：登录的用户只能操作自己的购物车，店员和管理员除外；
+// 没有登录时只能操作Cookie中保存的游客购物车，否则返回401错误
+func checkCartAccess(r *http.Request, cart *model.Cart) error {
+	if flag, session := isLogin(r); flag {
+		return checkOwner(session, cart.UserID)
+	}
+	if cart.UserID == 0 && cart.CartID == guestCartID(r) {
+		return nil
+	}
+	return unauthorized()
+}
+
+// mergeGuestCart 登录后将游客购物车合并到用户的购物车中并删除Cookie，返回库存不足时数量被调整的提示信息，
+// 合并失败不影响登录，游客购物车之后会被后台清理
+func mergeGuestCart(w http.ResponseWriter, r *http.Request, userID int) []string {
+	cartID := guestCartID(r)
+	if cartID == "" {
+		return nil
+	}
+	clearGuestCartCookie(w)
+	shortages, err := dao.MergeGuestCart(r.Context(), cartID, userID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			utils.Logger(r.Context()).Error("合并游客购物车失败", slog.String("cart_id", cartID), slog.Any("error", err))
+		}
+		return nil
+	}
+	var messages []string
+	for _, v := range shortages {
+		//与添加购物项时的提示一致
+		if v.Stock < 1 {
+			messages = append(messages, fmt.Sprintf("《%s》库存不足，已从购物车中删除", v.Title))
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("《%s》库存不足，数量已调整为%d本", v.Title, v.Stock))
+	}
+	return messages
+}
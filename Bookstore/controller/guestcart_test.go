@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// guestCookie 获取响应中设置的游客购物车的Cookie
+func guestCookie(w http.ResponseWriter) *http.Cookie {
+	for _, c := range (&http.Response{Header: w.Header()}).Cookies() {
+		if c.Name == conf.Cart.GuestCookieName {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestGuestCart(t *testing.T) {
+	addBook2Cart := HandlerFunc(AddBook2Cart)
+	book, _ := dao.GetBookByID(t.Context(), "1")
+	add := url.Values{"bookId": {"1"}}
+
+	//没有登录时添加图书，创建游客购物车并保存到Cookie中
+	w := serve(addBook2Cart, "POST", "/addBook2Cart", add, nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), book.Title) {
+		t.Fatalf("没有登录时应能添加图书到购物车，实际为%d %s", w.Code, w.Body.String())
+	}
+	cookie := guestCookie(w)
+	if cookie == nil || cookie.Value == "" || !cookie.HttpOnly {
+		t.Fatalf("应设置游客购物车的Cookie，实际为%v", cookie)
+	}
+	//再次添加时使用同一个游客购物车
+	w = serve(addBook2Cart, "POST", "/addBook2Cart", add, cookie)
+	if guestCookie(w) != nil {
+		t.Errorf("已有游客购物车时不应再次设置Cookie")
+	}
+	cart, err := dao.GetCartByCartID(t.Context(), cookie.Value)
+	if err != nil || cart.UserID != 0 || cart.TotalCount != 2 {
+		t.Fatalf("游客购物车中应有2本图书，实际为%+v %v", cart, err)
+	}
+
+	t.Run("查看游客购物车", func(t *testing.T) {
+		w := serve(HandlerFunc(GetCartInfo), "GET", "/getCartInfo", nil, cookie)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), book.Title) || !strings.Contains(w.Body.String(), "登录后结账") {
+			t.Errorf("应显示游客购物车中的图书，实际为%d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("不能操作别人的游客购物车", func(t *testing.T) {
+		form := url.Values{"cartId": {cart.CartID}}
+		if w := serve(HandlerFunc(DeleteCart), "POST", "/deleteCart", form, nil); w.Code != http.StatusFound {
+			t.Errorf("没有对应的Cookie时应跳转到登录页面，实际状态码为%d", w.Code)
+		}
+		other := loginAs(t, "guestcartother", model.RoleCustomer)
+		if w := serve(HandlerFunc(DeleteCart), "POST", "/deleteCart", form, other); w.Code != http.StatusForbidden {
+			t.Errorf("登录的用户不能清空游客购物车，实际状态码为%d", w.Code)
+		}
+		if _, err := dao.GetCartByCartID(t.Context(), cart.CartID); err != nil {
+			t.Errorf("游客购物车不应被清空: %v", err)
+		}
+	})
+
+	t.Run("登录后合并到用户的购物车", func(t *testing.T) {
+		loginAs(t, "guestcartuser", model.RoleCustomer)
+		owned := newOwnedCart(t, "guestcartuser")
+		form := url.Values{"username": {"guestcartuser"}, "password": {"secret"}}
+		w := serve(HandlerFunc(Login), "POST", "/login", form, cookie)
+		if w.Code != http.StatusOK {
+			t.Fatalf("登录失败，状态码为%d", w.Code)
+		}
+		if c := guestCookie(w); c == nil || c.MaxAge >= 0 {
+			t.Errorf("登录后应删除游客购物车的Cookie，实际为%v", c)
+		}
+		merged, err := dao.GetCartByCartID(t.Context(), owned.CartID)
+		if err != nil || len(merged.CartItems) != 1 || merged.CartItems[0].Count != 3 {
+			t.Fatalf("相同的图书数量应相加为3，实际为%+v %v", merged, err)
+		}
+		if _, err := dao.GetCartByCartID(t.Context(), cart.CartID); err == nil {
+			t.Errorf("合并后应删除游客购物车")
+		}
+	})
+
+	t.Run("合并后超过库存时调整数量并提示", func(t *testing.T) {
+		book := addStockBook(t, "合并游客购物车库存不足", 2)
+		add := url.Values{"bookId": {strconv.Itoa(book.ID)}}
+		w := serve(addBook2Cart, "POST", "/addBook2Cart", add, nil)
+		cookie := guestCookie(w)
+		serve(addBook2Cart, "POST", "/addBook2Cart", add, cookie)
+		//加入游客购物车之后库存减少了
+		book.Stock = 1
+		if err := dao.UpdateBook(t.Context(), book); err != nil {
+			t.Fatalf("更新图书失败: %v", err)
+		}
+		loginAs(t, "guestcartstock", model.RoleCustomer)
+		form := url.Values{"username": {"guestcartstock"}, "password": {"secret"}}
+		w = serve(HandlerFunc(Login), "POST", "/login", form, cookie)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "数量已调整为1本") {
+			t.Fatalf("登录后应提示数量已被调整，实际为%d %s", w.Code, w.Body.String())
+		}
+		merged, err := dao.GetCartByCartID(t.Context(), cookie.Value)
+		if err != nil || merged.TotalCount != 1 {
+			t.Errorf("合并后的数量应限制在库存以内，实际为%+v %v", merged, err)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/utils"
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// readyTimeout 就绪检查中检查数据库连接的超时时间
+const readyTimeout = 2 * time.Second
+
+// shuttingDown 收到退出信号之后为true，就绪检查失败，负载均衡不再转发新的请求
+var shuttingDown atomic.Bool
+
+// SetShuttingDown 标记服务正在退出，之后的就绪检查都会失败
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// Healthz 存活检查，进程能够响应请求即可，不检查数据库
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte("ok"))
+}
+
+// Readyz 就绪检查，服务正在退出或者数据库连接不可用时响应503
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if shuttingDown.Load() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	if err := dao.Ping(ctx); err != nil {
+		utils.Logger(ctx).Warn("就绪检查失败", slog.Any("error", err))
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
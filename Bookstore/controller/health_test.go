@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	if w := serve(http.HandlerFunc(Healthz), "GET", "/healthz", nil, nil); w.Code != http.StatusOK {
+		t.Errorf("存活检查的状态码应为200，实际为%d", w.Code)
+	}
+	if w := serve(http.HandlerFunc(Readyz), "GET", "/readyz", nil, nil); w.Code != http.StatusOK {
+		t.Errorf("数据库可用时就绪检查的状态码应为200，实际为%d", w.Code)
+	}
+	SetShuttingDown()
+	defer shuttingDown.Store(false)
+	if w := serve(http.HandlerFunc(Readyz), "GET", "/readyz", nil, nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("正在退出时就绪检查的状态码应为503，实际为%d", w.Code)
+	}
+	if w := serve(http.HandlerFunc(Healthz), "GET", "/healthz", nil, nil); w.Code != http.StatusOK {
+		t.Errorf("正在退出时存活检查的状态码仍应为200，实际为%d", w.Code)
+	}
+}
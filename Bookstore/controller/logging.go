@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// requestIDHeader 保存请求id的请求头和响应头，反向代理已经分配了请求id时沿用
+const requestIDHeader = "X-Request-ID"
+
+// RequestID 为每个请求分配请求id，并将带有请求id的日志记录器放入请求的context中，
+// 处理器和dao中通过utils.Logger获取
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		logger := slog.Default().With(slog.String("request_id", id))
+		next.ServeHTTP(w, r.WithContext(utils.WithLogger(r.Context(), logger)))
+	})
+}
+
+// validRequestID 判断请求中带有的请求id能否沿用，只接受长度不超过64的字母、数字和-_.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID 生成16位十六进制的请求id
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AccessLog 请求处理完成后记录一条访问日志，包括用户、路由、状态码、耗时和执行的sql语句的次数，
+// 应放在RequestID之内，服务器错误记录为error级别
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, stats := dao.WithQueryStats(r.Context())
+		r = r.WithContext(ctx)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		//Session在处理过程中才验证，因此日志记录器在处理完成后才带有user_id
+		utils.Logger(ctx).LogAttrs(ctx, level, "access",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", r.Pattern),
+			slog.Int("status", status),
+			slog.Int64("bytes", sw.bytes),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int64("db_queries", stats.Count()),
+			slog.Float64("db_ms", float64(stats.Duration().Microseconds())/1000),
+		)
+	})
+}
+
+// statusWriter 记录响应的状态码和字节数
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader 记录响应的状态码
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write 记录响应的字节数，没有设置状态码时为200
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap 供http.ResponseController使用
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
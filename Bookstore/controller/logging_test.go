@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// captureLogs 将默认的日志记录器替换为输出到缓冲区的JSON记录器，测试结束后恢复
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return buf
+}
+
+// decodeLogs 解析缓冲区中每行一条的JSON日志
+func decodeLogs(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var entry map[string]interface{}
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("日志不是JSON格式: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRequestLogging(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /main", HandlerFunc(GetPageBooksByPrice))
+	h := RequestID(AccessLog(mux))
+	session := loginAs(t, "loguser", model.RoleCustomer)
+
+	t.Run("访问日志", func(t *testing.T) {
+		buf := captureLogs(t)
+		w := serve(h, "GET", "/main?pageNo=1", nil, session)
+		id := w.Header().Get(requestIDHeader)
+		if id == "" {
+			t.Fatalf("响应中应有请求id")
+		}
+		var access map[string]interface{}
+		var queries int
+		for _, entry := range decodeLogs(t, buf) {
+			if entry["request_id"] != id {
+				t.Errorf("日志中的请求id应为%s，实际为%v", id, entry["request_id"])
+			}
+			switch entry["msg"] {
+			case "access":
+				access = entry
+			case "执行sql":
+				queries++
+			}
+		}
+		if access == nil {
+			t.Fatalf("应记录访问日志")
+		}
+		if access["route"] != "GET /main" || access["status"] != float64(http.StatusOK) || access["path"] != "/main" {
+			t.Errorf("访问日志中的路由或状态码不正确: %v", access)
+		}
+		if access["user_id"] == nil || access["user_id"] == float64(0) {
+			t.Errorf("访问日志中应有当前用户的id: %v", access)
+		}
+		if queries == 0 || access["db_queries"] != float64(queries) {
+			t.Errorf("访问日志中的查询次数应为%d，实际为%v", queries, access["db_queries"])
+		}
+	})
+
+	t.Run("沿用请求中的请求id", func(t *testing.T) {
+		captureLogs(t)
+		for id, keep := range map[string]bool{"abc-123": true, "带有中文": false, "": false} {
+			r := httptest.NewRequest("GET", "/main", nil)
+			r.Header.Set(requestIDHeader, id)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			got := w.Header().Get(requestIDHeader)
+			if (got == id) != keep || got == "" {
+				t.Errorf("请求id为%q时响应的请求id为%q", id, got)
+			}
+		}
+	})
+
+	t.Run("没有匹配的路由", func(t *testing.T) {
+		buf := captureLogs(t)
+		serve(h, "GET", "/nothing", nil, nil)
+		entries := decodeLogs(t, buf)
+		if len(entries) != 1 || entries[0]["status"] != float64(http.StatusNotFound) || entries[0]["route"] != "" {
+			t.Errorf("应记录404的访问日志: %v", entries)
+		}
+	})
+}
+
+// countRequestQueries 经过和线上相同的中间件处理一个请求，返回访问日志中记录的sql语句的次数，
+// 修改数据的请求带上当前Session的CSRF令牌
+func countRequestQueries(t *testing.T, h http.Handler, method string, target string, form url.Values, cookie *http.Cookie) int64 {
+	t.Helper()
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	if cookie != nil {
+		r.AddCookie(cookie)
+		r.Header.Set(csrfHeaderName, sessionCSRFToken(cookie.Value))
+	}
+	buf := &bytes.Buffer{}
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buf, nil)))
+	w := httptest.NewRecorder()
+	RequestID(AccessLog(LoadSession(CSRF(h)))).ServeHTTP(w, r)
+	slog.SetDefault(old)
+	if w.Code >= http.StatusBadRequest {
+		t.Fatalf("%s %s的状态码为%d", method, target, w.Code)
+	}
+	for _, entry := range decodeLogs(t, buf) {
+		if entry["msg"] == "access" {
+			return int64(entry["db_queries"].(float64))
+		}
+	}
+	t.Fatalf("%s %s没有记录访问日志", method, target)
+	return 0
+}
+
+func TestCartQueryCounts(t *testing.T) {
+	buyer := loginAs(t, "querybuyer", model.RoleCustomer)
+	for _, title := range []string{"请求查询次数一", "请求查询次数二", "请求查询次数三"} {
+		book := addStockBook(t, title, 10)
+		serve(HandlerFunc(AddBook2Cart), "POST", "/addBook2Cart", url.Values{"bookId": {strconv.Itoa(book.ID)}}, buyer)
+	}
+	user, err := dao.CheckUserName(t.Context(), "querybuyer")
+	if err != nil || user.ID == 0 {
+		t.Fatalf("获取用户失败: %v", err)
+	}
+	cart, err := dao.GetCartByUserID(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("获取购物车失败: %v", err)
+	}
+	if len(cart.CartItems) != 3 {
+		t.Fatalf("购物车中应有3个购物项，实际为%d", len(cart.CartItems))
+	}
+	itemID := strconv.FormatInt(cart.CartItems[0].CartItemID, 10)
+
+	//CSRF、RequireLogin和处理器共用一次Session查询
+	//查询Session 1条，查询购物车2条
+	if n := countRequestQueries(t, HandlerFunc(GetCartInfo), "GET", "/getCartInfo", nil, buyer); n != 3 {
+		t.Errorf("查看购物车应执行3条sql语句，实际为%d", n)
+	}
+	//查询Session 1条，查询购物车2条，查询库存1条，计算库存的保留期1条，更新购物项和购物车各1条
+	update := url.Values{"cartItemId": {itemID}, "bookCount": {"2"}}
+	if n := countRequestQueries(t, HandlerFunc(UpdateCartItem), "POST", "/updateCartItem", update, buyer); n != 7 {
+		t.Errorf("更新购物项应执行7条sql语句，实际为%d", n)
+	}
+	//查询Session 1条，查询购物车2条，删除购物项和更新购物车各1条，直接显示更新后的购物车
+	del := url.Values{"cartItemId": {itemID}}
+	if n := countRequestQueries(t, HandlerFunc(DeleteCartItem), "POST", "/deleteCartItem", del, buyer); n != 5 {
+		t.Errorf("删除购物项应执行5条sql语句，实际为%d", n)
+	}
+	//需要登录的页面：查询Session 1条，查询购物车2条，查询收货地址1条
+	if n := countRequestQueries(t, RequireLogin(HandlerFunc(ToCheckout)), "GET", "/toCheckout", nil, buyer); n != 4 {
+		t.Errorf("确认订单应执行4条sql语句，实际为%d", n)
+	}
+	//店员的页面：查询Session 1条，查询订单1条
+	staff := loginAs(t, "querystaff", model.RoleStaff)
+	if n := countRequestQueries(t, RequireRole(model.RoleStaff, model.RoleAdmin)(HandlerFunc(GetOrders)), "GET", "/getOrders", nil, staff); n != 2 {
+		t.Errorf("查看所有订单应执行2条sql语句，实际为%d", n)
+	}
+}
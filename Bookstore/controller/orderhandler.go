@@ -4,122 +4,292 @@ import (
 	"bookstore/dao"
 	"bookstore/model"
 	"bookstore/utils"
-	"html/template"
+	"context"
+	"errors"
 	"net/http"
 	"time"
 )
 
-// Checkout //去结账
-func Checkout(w http.ResponseWriter, r *http.Request) {
+// ToCheckout 去结账，显示确认订单的页面，在页面中选择地址簿中的地址或填写新的收货地址
+func ToCheckout(w http.ResponseWriter, r *http.Request) error {
 	//获取session
-	_, session := dao.IsLogin(r)
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	//获取购物车
+	cart, err := getCartByUserID(r.Context(), session.UserID)
+	if err != nil {
+		return err
+	}
+	if cart == nil || len(cart.CartItems) == 0 {
+		//购物车是空的，没有可以结账的图书
+		return GetCartInfo(w, r)
+	}
+	return renderConfirm(w, r, session, cart)
+}
+
+// renderConfirm 显示确认订单的页面，包括购物车中的图书和用户的地址簿
+func renderConfirm(w http.ResponseWriter, r *http.Request, session *model.Session, cart *model.Cart) error {
+	addresses, err := dao.GetAddressesByUserID(r.Context(), session.UserID)
+	if err != nil {
+		return err
+	}
+	session.Cart = withPriceNotices(cart)
+	session.Addresses = addresses
+	return render(w, "pages/cart/confirm.html", session)
+}
+
+// Checkout 确认订单后结账，收货地址会复制到订单中
+func Checkout(w http.ResponseWriter, r *http.Request) error {
+	//获取session
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
 	//获取用户的id
 	userID := session.UserID
 	//获取购物车
-	cart, _ := dao.GetCartByUserID(userID)
-	//生成订单号
-	orderID := utils.CreateUUID()
-	//创建生成订单的时间
-	timeStr := time.Now().Format("2006-01-02 15:04:05")
-	//创建Order
-	order := &model.Order{
-		OrderID:     orderID,
-		CreateTime:  timeStr,
-		TotalCount:  cart.TotalCount,
-		TotalAmount: cart.TotalAmount,
-		State:       0,
-		UserID:      int64(userID),
-	}
-	//将订单保存到数据库中
-	dao.AddOrder(order)
-	//保存订单项
-	//获取购物车中的购物项
-	cartItems := cart.CartItems
-	//遍历得到每一个购物项
-	for _, v := range cartItems {
-		//创建OrderItem
-		orderItem := &model.OrderItem{
-			Count:   v.Count,
-			Amount:  v.Amount,
-			Title:   v.Book.Title,
-			Author:  v.Book.Author,
-			Price:   v.Book.Price,
-			ImgPath: v.Book.ImgPath,
-			OrderID: orderID,
+	cart, err := getCartByUserID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+	if cart == nil || len(cart.CartItems) == 0 {
+		//购物车是空的，没有可以结账的图书
+		return GetCartInfo(w, r)
+	}
+	//确定收货地址，没有选择或填写不正确时回到确认订单页面并保留填写的内容
+	address, err := shippingAddress(r.Context(), session, r.PostFormValue("addressId"), addressFromForm(r), r.PostFormValue("saveAddress") != "")
+	if err != nil {
+		var httpErr *httpError
+		if errors.As(err, &httpErr) && httpErr.Status == http.StatusBadRequest {
+			session.Address = address
+			session.Messages = []string{httpErr.Message}
+			return renderConfirm(w, r, session, cart)
+		}
+		return err
+	}
+	//根据购物车创建订单
+	order := newOrder(cart)
+	order.SetShipping(address)
+	orderID := order.OrderID
+	//在一个事务中保存订单和订单项、更新图书的库存和销量并清空购物车
+	err = dao.Checkout(r.Context(), order, cart)
+	if err != nil {
+		if errors.Is(err, dao.ErrCartEmpty) {
+			//重复提交时购物车已经在另一次结账中被清空
+			return GetCartInfo(w, r)
+		}
+		var stockErr *dao.OutOfStockError
+		if errors.As(err, &stockErr) {
+			//库存不足，回到购物车页面并提示每一本库存不足的图书
+			session.Cart = withPriceNotices(cart)
+			session.Messages = stockErr.Messages()
+			return render(w, "pages/cart/cart.html", session)
 		}
-		//将购物项保存到数据库中
-		dao.AddOrderItem(orderItem)
-		//更新当前购物项中图书的库存和销量
-		book := v.Book
-		book.Sales = book.Sales + int(v.Count)
-		book.Stock = book.Stock - int(v.Count)
-		//更新图书的信息
-		dao.UpdateBook(book)
-	}
-	//清空购物车
-	dao.DeleteCartByCartID(cart.CartID)
+		return err
+	}
 	//将订单号设置到session中
 	session.OrderID = orderID
-	//解析模板
-	t := template.Must(template.ParseFiles("views/pages/cart/checkout.html"))
-	//执行
-	t.Execute(w, session)
+	//渲染模板
+	return render(w, "pages/cart/checkout.html", session)
+}
+
+// newOrder 根据购物车创建一个订单，使用在线支付时订单等待付款，否则直接为已付款，页面和API共用
+func newOrder(cart *model.Cart) *model.Order {
+	state := model.OrderStatePaid
+	if paymentsEnabled() {
+		state = model.OrderStatePendingPayment
+	}
+	return &model.Order{
+		//生成订单号
+		OrderID: utils.CreateUUID(),
+		//创建生成订单的时间
+		CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+		TotalCount:  cart.GetTotalCount(),
+		TotalAmount: cart.GetTotalAmount(),
+		//使用在线支付时，网关通知付款成功后才变为等待发货
+		State:  state,
+		UserID: int64(cart.UserID),
+	}
 }
 
 // GetOrders 获取所有订单
-func GetOrders(w http.ResponseWriter, r *http.Request) {
+func GetOrders(w http.ResponseWriter, r *http.Request) error {
+	//获取session，只有管理员可以取消别人的订单
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
 	//调用dao中获取所有订单的函数
-	orders, _ := dao.GetOrders()
-	//解析模板
-	t := template.Must(template.ParseFiles("views/pages/order/order_manager.html"))
-	//执行
-	t.Execute(w, orders)
+	orders, err := dao.GetOrders(r.Context())
+	if err != nil {
+		return err
+	}
+	//将订单设置到session中
+	session.Orders = orders
+	//渲染模板
+	return render(w, "pages/order/order_manager.html", session)
 }
 
 // GetOrderInfo 获取订单对应的订单项
-func GetOrderInfo(w http.ResponseWriter, r *http.Request) {
+func GetOrderInfo(w http.ResponseWriter, r *http.Request) error {
 	//获取订单号
 	orderID := r.FormValue("orderId")
+	//获取session
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	//获取订单，只能查看自己的订单
+	order, err := getOrderByID(r.Context(), session, orderID)
+	if err != nil {
+		return err
+	}
 	//根据订单号调用dao中获取所有订单项的函数
-	orderItems, _ := dao.GetOrderItemsByOrderID(orderID)
-	//解析模板
-	t := template.Must(template.ParseFiles("views/pages/order/order_info.html"))
-	//执行
-	t.Execute(w, orderItems)
+	orderItems, err := dao.GetOrderItemsByOrderID(r.Context(), orderID)
+	if err != nil {
+		return err
+	}
+	//获取订单状态的变更记录
+	history, err := dao.GetOrderHistory(r.Context(), orderID)
+	if err != nil {
+		return err
+	}
+	//获取订单的支付记录
+	ps, err := dao.GetPaymentsByOrderID(r.Context(), orderID)
+	if err != nil {
+		return err
+	}
+	detail := &model.OrderDetail{
+		Order:      order,
+		OrderItems: orderItems,
+		History:    history,
+		Payments:   ps,
+		IsStaff:    session.IsStaff(),
+		IsOwner:    session.UserID == int(order.UserID),
+	}
+	//渲染模板
+	return render(w, "pages/order/order_info.html", detail)
 }
 
 // GetMyOrders 获取我的订单
-func GetMyOrders(w http.ResponseWriter, r *http.Request) {
+func GetMyOrders(w http.ResponseWriter, r *http.Request) error {
 	//获取session
-	_, session := dao.IsLogin(r)
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
 	//获取用户的id
 	userID := session.UserID
 	//调用dao中获取用户的所有订单的函数
-	orders, _ := dao.GetMyOrders(userID)
+	orders, err := dao.GetMyOrders(r.Context(), userID)
+	if err != nil {
+		return err
+	}
 	//将订单设置到session中
 	session.Orders = orders
-	//解析模板
-	t := template.Must(template.ParseFiles("views/pages/order/order.html"))
-	//执行
-	t.Execute(w, session)
+	//渲染模板
+	return render(w, "pages/order/order.html", session)
 }
 
 // SendOrder 发货
-func SendOrder(w http.ResponseWriter, r *http.Request) {
+func SendOrder(w http.ResponseWriter, r *http.Request) error {
 	//获取要发货的订单号
 	orderID := r.FormValue("orderId")
-	//调用dao中的更新订单状态的函数
-	dao.UpdateOrderState(orderID, 1)
+	//获取session
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	//只有等待发货的订单可以发货
+	if err := changeOrderState(r.Context(), orderID, model.OrderStateShipped, session.UserID); err != nil {
+		return err
+	}
+	//调用GetOrders函数再次查询一下所有的订单
+	return GetOrders(w, r)
+}
+
+// DeliverOrder 标记订单已送达
+func DeliverOrder(w http.ResponseWriter, r *http.Request) error {
+	//获取已送达的订单号
+	orderID := r.FormValue("orderId")
+	//获取session
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	//只有已发货的订单可以标记为已送达
+	if err := changeOrderState(r.Context(), orderID, model.OrderStateDelivered, session.UserID); err != nil {
+		return err
+	}
 	//调用GetOrders函数再次查询一下所有的订单
-	GetOrders(w, r)
+	return GetOrders(w, r)
 }
 
 // TakeOrder 收货
-func TakeOrder(w http.ResponseWriter, r *http.Request) {
+func TakeOrder(w http.ResponseWriter, r *http.Request) error {
 	//获取要收货的订单号
 	orderID := r.FormValue("orderId")
-	//调用dao中的更新订单状态的函数
-	dao.UpdateOrderState(orderID, 2)
+	//获取session
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	//获取订单，只能确认自己的订单收货
+	if _, err := getOrderByID(r.Context(), session, orderID); err != nil {
+		return err
+	}
+	//只有已发货或已送达的订单可以确认收货
+	if err := changeOrderState(r.Context(), orderID, model.OrderStateCompleted, session.UserID); err != nil {
+		return err
+	}
 	//调用获取我的订单的函数再次查询我的订单
-	GetMyOrders(w, r)
+	return GetMyOrders(w, r)
+}
+
+// CancelOrder 取消订单，用户可以取消自己还没有发货的订单，管理员可以取消任何还没有发货的订单
+func CancelOrder(w http.ResponseWriter, r *http.Request) error {
+	//获取要取消的订单号
+	orderID := r.FormValue("orderId")
+	//获取session
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	order, err := dao.GetOrderByID(r.Context(), orderID)
+	if err != nil {
+		return orNotFound(err, "订单不存在！")
+	}
+	isMine := session.UserID == int(order.UserID)
+	if !isMine && !session.IsAdmin() {
+		return forbidden("您没有权限取消该订单！")
+	}
+	//取消订单、恢复库存并退还已经支付的款项
+	if err := cancelOrder(r.Context(), orderID, session.UserID); err != nil {
+		return orNotFound(err, "订单不存在！")
+	}
+	if isMine {
+		//再次查询我的订单
+		return GetMyOrders(w, r)
+	}
+	//管理员回到订单管理页面
+	return GetOrders(w, r)
+}
+
+// getOrderByID 获取当前用户可以操作的订单，订单不存在时返回404错误，不属于当前用户时返回403错误
+func getOrderByID(ctx context.Context, session *model.Session, orderID string) (*model.Order, error) {
+	order, err := dao.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, orNotFound(err, "订单不存在！")
+	}
+	if err := checkOwner(session, int(order.UserID)); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// changeOrderState 按照订单的状态机变更订单的状态，不允许的变更返回的错误会响应409
+func changeOrderState(ctx context.Context, orderID string, to int64, actorID int) error {
+	return orNotFound(dao.TransitionOrder(ctx, orderID, to, actorID), "订单不存在！")
 }
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCancelOrder(t *testing.T) {
+	owner := loginAs(t, "cancelowner", model.RoleCustomer)
+	other := loginAs(t, "cancelother", model.RoleCustomer)
+	staff := loginAs(t, "cancelstaff", model.RoleStaff)
+	admin := loginAs(t, "canceladmin", model.RoleAdmin)
+	cancel := RequireLogin(HandlerFunc(CancelOrder))
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		status int
+		state  int64
+	}{
+		{"取消别人的订单", other, http.StatusForbidden, model.OrderStatePaid},
+		{"店员取消顾客的订单", staff, http.StatusForbidden, model.OrderStatePaid},
+		{"取消自己的订单", owner, http.StatusOK, model.OrderStateCancelled},
+		{"管理员取消顾客的订单", admin, http.StatusOK, model.OrderStateCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := newOwnedOrder(t, "cancelowner", model.OrderStatePaid)
+			w := serve(cancel, "POST", "/cancelOrder", url.Values{"orderId": {order.OrderID}}, tt.cookie)
+			if w.Code != tt.status {
+				t.Errorf("状态码应为%d，实际为%d", tt.status, w.Code)
+			}
+			if got, _ := dao.GetOrderByID(t.Context(), order.OrderID); got.State != tt.state {
+				t.Errorf("订单状态应为%s，实际为%s", model.OrderStateName(tt.state), got.StateName())
+			}
+		})
+	}
+
+	t.Run("取消已发货的订单", func(t *testing.T) {
+		order := newOwnedOrder(t, "cancelowner", model.OrderStateShipped)
+		w := serve(cancel, "POST", "/cancelOrder", url.Values{"orderId": {order.OrderID}}, owner)
+		if w.Code != http.StatusConflict {
+			t.Errorf("状态码应为409，实际为%d", w.Code)
+		}
+	})
+
+	t.Run("取消不存在的订单", func(t *testing.T) {
+		w := serve(cancel, "POST", "/cancelOrder", url.Values{"orderId": {"no-such-order"}}, admin)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("状态码应为404，实际为%d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"bookstore/utils"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newOwnedCart 为用户创建一个包含一本图书的购物车
+func newOwnedCart(t *testing.T, username string) *model.Cart {
+	t.Helper()
+	user, err := dao.CheckUserName(t.Context(), username)
+	if err != nil || user.ID == 0 {
+		t.Fatalf("获取用户%s失败: %v", username, err)
+	}
+	book, err := dao.GetBookByID(t.Context(), "1")
+	if err != nil || book.ID == 0 {
+		t.Fatalf("获取图书失败: %v", err)
+	}
+	cartID := utils.CreateUUID()
+	cart := &model.Cart{
+		CartID: cartID,
+		UserID: user.ID,
+		CartItems: []*model.CartItem{
+			{Book: book, Count: 1, CartID: cartID},
+		},
+	}
+	if err := dao.AddCart(t.Context(), cart); err != nil {
+		t.Fatalf("添加购物车失败: %v", err)
+	}
+	cart, err = dao.GetCartByCartID(t.Context(), cartID)
+	if err != nil {
+		t.Fatalf("获取购物车失败: %v", err)
+	}
+	return cart
+}
+
+// newOwnedOrder 为用户创建一个指定状态的订单
+func newOwnedOrder(t *testing.T, username string, state int64) *model.Order {
+	t.Helper()
+	user, err := dao.CheckUserName(t.Context(), username)
+	if err != nil || user.ID == 0 {
+		t.Fatalf("获取用户%s失败: %v", username, err)
+	}
+	order := &model.Order{
+		OrderID:     utils.CreateUUID(),
+		CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+		TotalCount:  1,
+		TotalAmount: model.Cents(1000),
+		State:       state,
+		UserID:      int64(user.ID),
+	}
+	if err := dao.AddOrder(t.Context(), order); err != nil {
+		t.Fatalf("添加订单失败: %v", err)
+	}
+	return order
+}
+
+func TestCartOwnership(t *testing.T) {
+	owner := loginAs(t, "cartowner", model.RoleCustomer)
+	other := loginAs(t, "cartother", model.RoleCustomer)
+	staff := loginAs(t, "cartstaff", model.RoleStaff)
+	//没有登录时也可以操作游客购物车，因此不经过RequireLogin
+	deleteCart := HandlerFunc(DeleteCart)
+	deleteCartItem := HandlerFunc(DeleteCartItem)
+	updateCartItem := HandlerFunc(UpdateCartItem)
+
+	t.Run("没有登录", func(t *testing.T) {
+		cart := newOwnedCart(t, "cartowner")
+		defer dao.DeleteCartByCartID(t.Context(), cart.CartID)
+		w := serve(deleteCart, "POST", "/deleteCart", url.Values{"cartId": {cart.CartID}}, nil)
+		if w.Code != http.StatusFound {
+			t.Errorf("没有登录应跳转到登录页面，实际状态码为%d", w.Code)
+		}
+	})
+
+	t.Run("清空别人的购物车", func(t *testing.T) {
+		cart := newOwnedCart(t, "cartowner")
+		defer dao.DeleteCartByCartID(t.Context(), cart.CartID)
+		w := serve(deleteCart, "POST", "/deleteCart", url.Values{"cartId": {cart.CartID}}, other)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("状态码应为403，实际为%d", w.Code)
+		}
+		if got, _ := dao.GetCartByCartID(t.Context(), cart.CartID); got == nil || len(got.CartItems) != 1 {
+			t.Errorf("别人的购物车不应被清空")
+		}
+	})
+
+	t.Run("清空不存在的购物车", func(t *testing.T) {
+		w := serve(deleteCart, "POST", "/deleteCart", url.Values{"cartId": {"no-such-cart"}}, owner)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("状态码应为404，实际为%d", w.Code)
+		}
+	})
+
+	t.Run("清空自己的购物车", func(t *testing.T) {
+		cart := newOwnedCart(t, "cartowner")
+		w := serve(deleteCart, "POST", "/deleteCart", url.Values{"cartId": {cart.CartID}}, owner)
+		if w.Code != http.StatusOK {
+			t.Errorf("状态码应为200，实际为%d", w.Code)
+		}
+		if got, _ := dao.GetCartByCartID(t.Context(), cart.CartID); got != nil {
+			t.Errorf("自己的购物车应被清空")
+		}
+	})
+
+	t.Run("店员清空顾客的购物车", func(t *testing.T) {
+		cart := newOwnedCart(t, "cartowner")
+		w := serve(deleteCart, "POST", "/deleteCart", url.Values{"cartId": {cart.CartID}}, staff)
+		if w.Code != http.StatusOK {
+			t.Errorf("状态码应为200，实际为%d", w.Code)
+		}
+		if got, _ := dao.GetCartByCartID(t.Context(), cart.CartID); got != nil {
+			t.Errorf("店员应可以清空顾客的购物车")
+		}
+	})
+
+	t.Run("删除别人的购物项", func(t *testing.T) {
+		cart := newOwnedCart(t, "cartowner")
+		defer dao.DeleteCartByCartID(t.Context(), cart.CartID)
+		itemID := fmt.Sprint(cart.CartItems[0].CartItemID)
+		w := serve(deleteCartItem, "POST", "/deleteCartItem", url.Values{"cartItemId": {itemID}}, other)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("状态码应为403，实际为%d", w.Code)
+		}
+		if got, _ := dao.GetCartItemByID(t.Context(), itemID); got == nil {
+			t.Errorf("别人的购物项不应被删除")
+		}
+		w = serve(deleteCartItem, "POST", "/deleteCartItem", url.Values{"cartItemId": {"-1"}}, other)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("删除不存在的购物项状态码应为404，实际为%d", w.Code)
+		}
+		w = serve(deleteCartItem, "POST", "/deleteCartItem", url.Values{"cartItemId": {itemID}}, owner)
+		if w.Code != http.StatusOK {
+			t.Errorf("删除自己的购物项状态码应为200，实际为%d", w.Code)
+		}
+		if got, _ := dao.GetCartItemByID(t.Context(), itemID); got != nil {
+			t.Errorf("自己的购物项应被删除")
+		}
+	})
+
+	t.Run("更新别人的购物项", func(t *testing.T) {
+		cart := newOwnedCart(t, "cartowner")
+		defer dao.DeleteCartByCartID(t.Context(), cart.CartID)
+		itemID := fmt.Sprint(cart.CartItems[0].CartItemID)
+		form := url.Values{"cartItemId": {itemID}, "bookCount": {"5"}}
+		w := serve(updateCartItem, "POST", "/updateCartItem", form, other)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("状态码应为403，实际为%d", w.Code)
+		}
+		if got, _ := dao.GetCartItemByID(t.Context(), itemID); got.Count != 1 {
+			t.Errorf("别人的购物项数量不应被修改，实际为%d", got.Count)
+		}
+		w = serve(updateCartItem, "POST", "/updateCartItem", form, owner)
+		if w.Code != http.StatusOK {
+			t.Errorf("更新自己的购物项状态码应为200，实际为%d", w.Code)
+		}
+		if got, _ := dao.GetCartItemByID(t.Context(), itemID); got.Count != 5 {
+			t.Errorf("购物项数量应更新为5，实际为%d", got.Count)
+		}
+	})
+}
+
+func TestOrderOwnership(t *testing.T) {
+	owner := loginAs(t, "orderowner", model.RoleCustomer)
+	other := loginAs(t, "orderother", model.RoleCustomer)
+	staff := loginAs(t, "orderstaff", model.RoleStaff)
+	getOrderInfo := RequireLogin(HandlerFunc(GetOrderInfo))
+	takeOrder := RequireLogin(HandlerFunc(TakeOrder))
+	order := newOwnedOrder(t, "orderowner", model.OrderStateShipped)
+
+	tests := []struct {
+		name    string
+		orderID string
+		cookie  *http.Cookie
+		status  int
+	}{
+		{"没有登录", order.OrderID, nil, http.StatusFound},
+		{"查看别人的订单", order.OrderID, other, http.StatusForbidden},
+		{"查看不存在的订单", "no-such-order", owner, http.StatusNotFound},
+		{"查看自己的订单", order.OrderID, owner, http.StatusOK},
+		{"店员查看顾客的订单", order.OrderID, staff, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(getOrderInfo, "GET", "/getOrderInfo?orderId="+tt.orderID, nil, tt.cookie)
+			if w.Code != tt.status {
+				t.Errorf("状态码应为%d，实际为%d", tt.status, w.Code)
+			}
+		})
+	}
+
+	t.Run("确认别人的订单收货", func(t *testing.T) {
+		w := serve(takeOrder, "POST", "/takeOrder", url.Values{"orderId": {order.OrderID}}, other)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("状态码应为403，实际为%d", w.Code)
+		}
+		if got, _ := dao.GetOrderByID(t.Context(), order.OrderID); got.State != model.OrderStateShipped {
+			t.Errorf("别人的订单状态不应被修改，实际为%d", got.State)
+		}
+		w = serve(takeOrder, "POST", "/takeOrder", url.Values{"orderId": {"no-such-order"}}, other)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("不存在的订单状态码应为404，实际为%d", w.Code)
+		}
+	})
+
+	t.Run("确认自己的订单收货", func(t *testing.T) {
+		w := serve(takeOrder, "POST", "/takeOrder", url.Values{"orderId": {order.OrderID}}, owner)
+		if w.Code != http.StatusOK {
+			t.Errorf("状态码应为200，实际为%d", w.Code)
+		}
+		if got, _ := dao.GetOrderByID(t.Context(), order.OrderID); got.State != model.OrderStateCompleted {
+			t.Errorf("订单状态应为交易完成，实际为%d", got.State)
+		}
+	})
+}
@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"bookstore/payment"
+	"bookstore/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// payments 当前使用的支付网关，由Configure根据配置设置，没有配置支付网关时为nil
+var payments payment.Provider
+
+// paymentsEnabled 是否使用在线支付，不使用时订单创建后直接为已付款，也不显示去支付的按钮
+func paymentsEnabled() bool {
+	return payments != nil
+}
+
+// startPayment 为待付款的订单创建支付记录并请求网关授权，返回用户付款的页面地址
+func startPayment(ctx context.Context, order *model.Order) (string, error) {
+	if !order.CanPay() {
+		return "", conflict(fmt.Sprintf("订单当前的状态为%s，不需要付款！", order.StateName()))
+	}
+	p := &model.Payment{
+		OrderID:  order.OrderID,
+		Provider: payments.Name(),
+		Amount:   order.TotalAmount,
+		Status:   model.PaymentStatusPending,
+	}
+	if err := dao.AddPayment(ctx, p); err != nil {
+		return "", err
+	}
+	auth, err := payments.Authorize(ctx, p)
+	if err != nil {
+		//网关不可用时将这次支付标记为失败，用户可以重新支付
+		dao.UpdatePaymentStatus(ctx, p.ID, model.PaymentStatusPending, model.PaymentStatusFailed)
+		return "", err
+	}
+	if err := dao.SetPaymentRef(ctx, p.ID, auth.Ref); err != nil {
+		return "", err
+	}
+	return auth.RedirectURL, nil
+}
+
+// getOwnOrder 获取当前用户自己的订单，店员和管理员也不能替别人付款
+func getOwnOrder(ctx context.Context, session *model.Session, orderID string) (*model.Order, error) {
+	order, err := dao.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, orNotFound(err, "订单不存在！")
+	}
+	if int(order.UserID) != session.UserID {
+		return nil, forbidden("您没有权限操作该订单！")
+	}
+	return order, nil
+}
+
+// Pay 支付待付款的订单，跳转到网关的付款页面
+func Pay(w http.ResponseWriter, r *http.Request) error {
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	order, err := getOwnOrder(r.Context(), session, r.FormValue("orderId"))
+	if err != nil {
+		return err
+	}
+	redirectURL, err := startPayment(r.Context(), order)
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	return nil
+}
+
+// PaymentWebhookHandler 接收支付网关的回调，路径为 POST /payments/webhook/{provider}，
+// 回调由网关直接发送，通过签名而不是登录验证，错误按JSON格式响应
+func PaymentWebhookHandler() http.Handler {
+	return apiHandlerFunc(PaymentWebhook)
+}
+
+// PaymentWebhook 验证回调的签名并处理其中的事件，网关重复发送的回调只处理一次
+func PaymentWebhook(w http.ResponseWriter, r *http.Request) error {
+	if r.PathValue("provider") != payments.Name() {
+		return notFound("支付网关不存在！")
+	}
+	event, err := payments.VerifyWebhook(r)
+	if errors.Is(err, payment.ErrInvalidSignature) {
+		return forbidden("回调的签名不正确！")
+	}
+	if err != nil {
+		return badRequest("回调的格式不正确！")
+	}
+	if err := handlePaymentEvent(r.Context(), event); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// handlePaymentEvent 处理网关通知的事件，不认识的事件忽略
+func handlePaymentEvent(ctx context.Context, event *payment.Event) error {
+	p, err := dao.GetPaymentByRef(ctx, payments.Name(), event.Ref)
+	if err != nil {
+		return orNotFound(err, "支付不存在！")
+	}
+	switch event.Type {
+	case payment.EventAuthorized:
+		return capturePayment(ctx, p, event.Amount)
+	case payment.EventFailed:
+		err := dao.UpdatePaymentStatus(ctx, p.ID, model.PaymentStatusPending, model.PaymentStatusFailed)
+		if errors.Is(err, dao.ErrPaymentStatusChanged) {
+			return nil
+		}
+		return err
+	}
+	utils.Logger(ctx).Warn("忽略不认识的支付事件", slog.String("type", event.Type), slog.String("ref", event.Ref))
+	return nil
+}
+
+// capturePayment 用户授权付款后扣款，并将订单变更为已付款。
+// 订单在付款期间已经取消时不扣款；扣款之后订单才被取消或已经由其他支付付款时，立即退还这笔款项；
+// 扣款之后因为其他原因没有保存结果时，将支付标记为待对账
+func capturePayment(ctx context.Context, p *model.Payment, amount model.Money) error {
+	if amount.Cmp(p.Amount) != 0 {
+		return badRequest("授权的金额与订单的金额不一致！")
+	}
+	err := dao.UpdatePaymentStatus(ctx, p.ID, model.PaymentStatusPending, model.PaymentStatusAuthorized)
+	if errors.Is(err, dao.ErrPaymentStatusChanged) {
+		//重复的回调
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	order, err := dao.GetOrderByID(ctx, p.OrderID)
+	if err != nil {
+		return err
+	}
+	if !order.CanPay() {
+		//没有扣款，授权的款项由网关释放
+		return dao.UpdatePaymentStatus(ctx, p.ID, model.PaymentStatusAuthorized, model.PaymentStatusFailed)
+	}
+	if err := payments.Capture(ctx, p.ProviderRef, p.Amount); err != nil {
+		dao.UpdatePaymentStatus(ctx, p.ID, model.PaymentStatusAuthorized, model.PaymentStatusFailed)
+		return err
+	}
+	err = dao.CapturePayment(ctx, p.ID, p.OrderID)
+	var stateErr *dao.IllegalTransitionError
+	if errors.As(err, &stateErr) {
+		return refundPayment(ctx, p, model.PaymentStatusAuthorized)
+	}
+	if err != nil {
+		markForReconciliation(ctx, p, err)
+		return err
+	}
+	return nil
+}
+
+// markForReconciliation 网关已经扣款但没有保存扣款的结果，记录网关中的支付号并将支付标记为待对账，
+// 重复的回调不会再次扣款，需要人工与网关核对后补记付款或退款
+func markForReconciliation(ctx context.Context, p *model.Payment, cause error) {
+	logger := utils.Logger(ctx).With(slog.Int64("payment_id", p.ID), slog.String("order_id", p.OrderID),
+		slog.String("provider", p.Provider), slog.String("provider_ref", p.ProviderRef))
+	logger.Error("网关已经扣款但保存扣款的结果失败，需要人工对账", slog.Any("error", cause))
+	if err := dao.UpdatePaymentStatus(ctx, p.ID, model.PaymentStatusAuthorized, model.PaymentStatusReconcile); err != nil {
+		logger.Error("将支付标记为待对账失败", slog.Any("error", err))
+	}
+}
+
+// refundPayment 通过网关退还已经扣除的款项，并将支付从from状态变更为已退款
+func refundPayment(ctx context.Context, p *model.Payment, from string) error {
+	if err := payments.Refund(ctx, p.ProviderRef, p.Amount); err != nil {
+		return err
+	}
+	return dao.UpdatePaymentStatus(ctx, p.ID, from, model.PaymentStatusRefunded)
+}
+
+// cancelOrder 取消订单并恢复库存，再退还已经支付的款项，页面和API共用。
+// 订单已经取消，退款失败不影响取消的结果，只记录日志，需要人工处理
+func cancelOrder(ctx context.Context, orderID string, actorID int) error {
+	if err := dao.CancelOrder(ctx, orderID, actorID); err != nil {
+		return err
+	}
+	ps, err := dao.GetPaymentsByOrderID(ctx, orderID)
+	if err != nil {
+		utils.Logger(ctx).Error("查询订单的支付记录失败，无法退款", slog.String("order_id", orderID), slog.Any("error", err))
+		return nil
+	}
+	for _, p := range ps {
+		if p.Status != model.PaymentStatusCaptured {
+			continue
+		}
+		if !paymentsEnabled() || p.Provider != payments.Name() {
+			err = fmt.Errorf("支付网关%s已经停用", p.Provider)
+		} else {
+			err = refundPayment(ctx, p, model.PaymentStatusCaptured)
+		}
+		if err != nil {
+			utils.Logger(ctx).Error("取消订单后退款失败", slog.String("order_id", orderID), slog.Int64("payment_id", p.ID), slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// mockPayPage 模拟网关的付款页面的数据
+type mockPayPage struct {
+	UserName string
+	Payment  payment.MockPayment
+	Order    *model.Order
+}
+
+// getMockPayment 获取模拟网关中当前用户的订单的支付，没有使用模拟网关时返回404错误
+func getMockPayment(r *http.Request) (*payment.Mock, *mockPayPage, error) {
+	mock, ok := payments.(*payment.Mock)
+	if !ok {
+		return nil, nil, notFound("没有使用模拟的支付网关！")
+	}
+	session, err := currentSession(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, ok := mock.Lookup(r.FormValue("ref"))
+	if !ok {
+		return nil, nil, notFound("支付不存在！")
+	}
+	order, err := getOwnOrder(r.Context(), session, p.OrderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mock, &mockPayPage{UserName: session.UserName, Payment: p, Order: order}, nil
+}
+
+// GetMockPay 模拟网关的付款页面，用户在其中选择付款或拒绝付款
+func GetMockPay(w http.ResponseWriter, r *http.Request) error {
+	_, page, err := getMockPayment(r)
+	if err != nil {
+		return err
+	}
+	return render(w, "pages/payment/mockpay.html", page)
+}
+
+// MockPay 模拟用户在网关中付款或拒绝付款，之后回到订单详情页面。
+// 模拟网关在同一进程中处理生成的回调请求，验证签名和处理事件的过程与通过回调地址接收时相同
+func MockPay(w http.ResponseWriter, r *http.Request) error {
+	mock, page, err := getMockPayment(r)
+	if err != nil {
+		return err
+	}
+	webhook, err := mock.Complete(page.Payment.Ref, r.PostFormValue("result") == "approve")
+	if err != nil {
+		return conflict("该支付已经完成！")
+	}
+	event, err := mock.VerifyWebhook(webhook)
+	if err != nil {
+		return err
+	}
+	if err := handlePaymentEvent(r.Context(), event); err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/getOrderInfo?orderId="+url.QueryEscape(page.Order.OrderID), http.StatusSeeOther)
+	return nil
+}
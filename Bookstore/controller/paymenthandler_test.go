@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"bookstore/payment"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// webhookMux 只有回调地址的路由，与main中的配置相同
+func webhookMux() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /payments/webhook/{provider}", PaymentWebhookHandler())
+	return mux
+}
+
+// mockRef 获取模拟网关的付款页面地址中的支付号
+func mockRef(t *testing.T, redirectURL string) string {
+	t.Helper()
+	u, err := url.Parse(redirectURL)
+	if err != nil || u.Path != "/mockpay" || u.Query().Get("ref") == "" {
+		t.Fatalf("付款页面的地址不正确: %q", redirectURL)
+	}
+	return u.Query().Get("ref")
+}
+
+// completeMockPayment 模拟用户在网关中付款或拒绝付款，并将网关的回调发送到回调地址
+func completeMockPayment(t *testing.T, redirectURL string, approve bool) *httptest.ResponseRecorder {
+	t.Helper()
+	webhook, err := payments.(*payment.Mock).Complete(mockRef(t, redirectURL), approve)
+	if err != nil {
+		t.Fatalf("完成支付失败: %v", err)
+	}
+	w := httptest.NewRecorder()
+	webhookMux().ServeHTTP(w, webhook)
+	return w
+}
+
+// newPendingOrder 结账生成一个待付款的订单
+func newPendingOrder(t *testing.T, buyer *http.Cookie, title string) *model.Order {
+	t.Helper()
+	book := addStockBook(t, title, 10)
+	serve(HandlerFunc(AddBook2Cart), "POST", "/addBook2Cart", url.Values{"bookId": {strconv.Itoa(book.ID)}}, buyer)
+	if w := serve(HandlerFunc(Checkout), "POST", "/checkout", testAddress, buyer); w.Code != http.StatusOK {
+		t.Fatalf("结账失败，状态码为%d", w.Code)
+	}
+	orders, err := dao.GetOrders(t.Context())
+	if err != nil {
+		t.Fatalf("获取订单失败: %v", err)
+	}
+	for _, order := range orders {
+		items, err := dao.GetOrderItemsByOrderID(t.Context(), order.OrderID)
+		if err != nil {
+			t.Fatalf("获取订单项失败: %v", err)
+		}
+		if len(items) == 1 && items[0].Title == title {
+			return order
+		}
+	}
+	t.Fatalf("没有找到《%s》的订单", title)
+	return nil
+}
+
+// payOrder 在页面中支付订单，返回模拟网关的付款页面地址
+func payOrder(t *testing.T, buyer *http.Cookie, orderID string) string {
+	t.Helper()
+	w := serve(HandlerFunc(Pay), "POST", "/pay", url.Values{"orderId": {orderID}}, buyer)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("支付应跳转到付款页面，状态码为%d", w.Code)
+	}
+	return w.Header().Get("Location")
+}
+
+// orderState 获取订单当前的状态
+func orderState(t *testing.T, orderID string) int64 {
+	t.Helper()
+	order, err := dao.GetOrderByID(t.Context(), orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order.State
+}
+
+func TestPayShipFlow(t *testing.T) {
+	buyer := loginAs(t, "paybuyer", model.RoleCustomer)
+	staff := loginAs(t, "paystaff", model.RoleStaff)
+	order := newPendingOrder(t, buyer, "付款发货的图书")
+	if order.State != model.OrderStatePendingPayment {
+		t.Fatalf("结账后订单应为待付款，实际为%s", order.StateName())
+	}
+	send := url.Values{"orderId": {order.OrderID}}
+	if w := serve(HandlerFunc(SendOrder), "POST", "/sendOrder", send, staff); w.Code != http.StatusConflict {
+		t.Errorf("待付款的订单不能发货，状态码为%d", w.Code)
+	}
+
+	//在模拟网关的页面中拒绝付款，订单仍然待付款，可以重新支付
+	redirectURL := payOrder(t, buyer, order.OrderID)
+	if w := serve(HandlerFunc(GetMockPay), "GET", redirectURL, nil, buyer); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "确认付款") {
+		t.Fatalf("应显示模拟网关的付款页面，状态码为%d", w.Code)
+	}
+	other := loginAs(t, "payother", model.RoleCustomer)
+	if w := serve(HandlerFunc(GetMockPay), "GET", redirectURL, nil, other); w.Code != http.StatusForbidden {
+		t.Errorf("别人不能查看付款页面，状态码为%d", w.Code)
+	}
+	decline := url.Values{"ref": {mockRef(t, redirectURL)}, "result": {"decline"}}
+	if w := serve(HandlerFunc(MockPay), "POST", "/mockpay", decline, buyer); w.Code != http.StatusSeeOther {
+		t.Fatalf("拒绝付款后应回到订单详情页面，状态码为%d", w.Code)
+	}
+	if state := orderState(t, order.OrderID); state != model.OrderStatePendingPayment {
+		t.Errorf("拒绝付款后订单应仍为待付款，实际为%s", model.OrderStateName(state))
+	}
+
+	//重新支付并确认付款
+	redirectURL = payOrder(t, buyer, order.OrderID)
+	approve := url.Values{"ref": {mockRef(t, redirectURL)}, "result": {"approve"}}
+	if w := serve(HandlerFunc(MockPay), "POST", "/mockpay", approve, buyer); w.Code != http.StatusSeeOther {
+		t.Fatalf("付款后应回到订单详情页面，状态码为%d", w.Code)
+	}
+	if state := orderState(t, order.OrderID); state != model.OrderStatePaid {
+		t.Fatalf("付款后订单应为已付款，实际为%s", model.OrderStateName(state))
+	}
+	ps, _ := dao.GetPaymentsByOrderID(t.Context(), order.OrderID)
+	if len(ps) != 2 || ps[0].Status != model.PaymentStatusFailed || ps[1].Status != model.PaymentStatusCaptured {
+		t.Errorf("应有一条失败和一条已付款的支付记录: %+v %+v", ps[0], ps[1])
+	}
+	if w := serve(HandlerFunc(Pay), "POST", "/pay", send, buyer); w.Code != http.StatusConflict {
+		t.Errorf("已付款的订单不能再次支付，状态码为%d", w.Code)
+	}
+	if w := serve(HandlerFunc(SendOrder), "POST", "/sendOrder", send, staff); w.Code != http.StatusOK {
+		t.Errorf("已付款的订单应可以发货，状态码为%d", w.Code)
+	}
+	if state := orderState(t, order.OrderID); state != model.OrderStateShipped {
+		t.Errorf("发货后订单应为已发货，实际为%s", model.OrderStateName(state))
+	}
+}
+
+func TestPaymentWebhook(t *testing.T) {
+	buyer := loginAs(t, "webhookbuyer", model.RoleCustomer)
+	order := newPendingOrder(t, buyer, "回调测试的图书")
+	redirectURL := payOrder(t, buyer, order.OrderID)
+	ref := mockRef(t, redirectURL)
+	mock := payments.(*payment.Mock)
+
+	t.Run("签名不正确", func(t *testing.T) {
+		forged, _ := payment.NewMock([]byte("forged")).NewWebhookRequest(&payment.Event{Type: payment.EventAuthorized, Ref: ref, Amount: order.TotalAmount})
+		w := httptest.NewRecorder()
+		webhookMux().ServeHTTP(w, forged)
+		if w.Code != http.StatusForbidden || orderState(t, order.OrderID) != model.OrderStatePendingPayment {
+			t.Errorf("伪造的回调应返回403且不改变订单，状态码为%d", w.Code)
+		}
+	})
+
+	t.Run("金额不一致", func(t *testing.T) {
+		r, _ := mock.NewWebhookRequest(&payment.Event{Type: payment.EventAuthorized, Ref: ref, Amount: model.Cents(1)})
+		w := httptest.NewRecorder()
+		webhookMux().ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest || orderState(t, order.OrderID) != model.OrderStatePendingPayment {
+			t.Errorf("金额不一致的回调应返回400且不改变订单，状态码为%d", w.Code)
+		}
+	})
+
+	t.Run("重复的回调只处理一次", func(t *testing.T) {
+		webhook, err := mock.Complete(ref, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature := webhook.Header.Get(payment.MockSignatureHeader)
+		w := httptest.NewRecorder()
+		webhookMux().ServeHTTP(w, webhook)
+		if w.Code != http.StatusNoContent || orderState(t, order.OrderID) != model.OrderStatePaid {
+			t.Fatalf("付款的回调应将订单变为已付款，状态码为%d", w.Code)
+		}
+		again, _ := mock.NewWebhookRequest(&payment.Event{Type: payment.EventAuthorized, Ref: ref, Amount: order.TotalAmount})
+		if again.Header.Get(payment.MockSignatureHeader) != signature {
+			t.Fatalf("重复的回调应与第一次相同")
+		}
+		w = httptest.NewRecorder()
+		webhookMux().ServeHTTP(w, again)
+		history, _ := dao.GetOrderHistory(t.Context(), order.OrderID)
+		if w.Code != http.StatusNoContent || len(history) != 2 {
+			t.Errorf("重复的回调应返回204且不重复变更订单，状态码为%d，变更记录为%d条", w.Code, len(history))
+		}
+	})
+
+	t.Run("其他网关的回调地址不存在", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/payments/webhook/alipay", strings.NewReader("{}"))
+		w := httptest.NewRecorder()
+		webhookMux().ServeHTTP(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("其他网关的回调应返回404，实际为%d", w.Code)
+		}
+	})
+}
+
+func TestCancelPaidOrderRefunds(t *testing.T) {
+	buyer := loginAs(t, "refundbuyer", model.RoleCustomer)
+	order := newPendingOrder(t, buyer, "取消后退款的图书")
+	first := payOrder(t, buyer, order.OrderID)
+	//付款期间打开了另一个付款页面，订单取消后才完成
+	late := payOrder(t, buyer, order.OrderID)
+	completeMockPayment(t, first, true)
+	if orderState(t, order.OrderID) != model.OrderStatePaid {
+		t.Fatalf("订单应为已付款")
+	}
+	if w := serve(HandlerFunc(CancelOrder), "POST", "/cancelOrder", url.Values{"orderId": {order.OrderID}}, buyer); w.Code != http.StatusOK {
+		t.Fatalf("取消订单失败，状态码为%d", w.Code)
+	}
+	ps, _ := dao.GetPaymentsByOrderID(t.Context(), order.OrderID)
+	if len(ps) != 2 || ps[0].Status != model.PaymentStatusRefunded {
+		t.Fatalf("取消已付款的订单后应退款: %+v", ps[0])
+	}
+	if p, _ := payments.(*payment.Mock).Lookup(ps[0].ProviderRef); p.Status != model.PaymentStatusRefunded {
+		t.Errorf("网关中的支付应为已退款，实际为%s", p.Status)
+	}
+	//订单取消之后才完成的付款不扣款
+	if w := completeMockPayment(t, late, true); w.Code != http.StatusNoContent {
+		t.Fatalf("处理回调失败，状态码为%d", w.Code)
+	}
+	ps, _ = dao.GetPaymentsByOrderID(t.Context(), order.OrderID)
+	if ps[1].Status != model.PaymentStatusFailed || orderState(t, order.OrderID) != model.OrderStateCancelled {
+		t.Errorf("已取消的订单不应扣款: %+v", ps[1])
+	}
+}
+
+// failingCapture 扣款之后保存结果时数据库出错的支付记录数据访问实现
+type failingCapture struct {
+	dao.PaymentRepository
+}
+
+func (failingCapture) CapturePayment(ctx context.Context, paymentID int64, orderID string) error {
+	return errors.New("数据库连接已断开")
+}
+
+func TestCaptureNotSaved(t *testing.T) {
+	buyer := loginAs(t, "reconcilebuyer", model.RoleCustomer)
+	order := newPendingOrder(t, buyer, "需要对账的图书")
+	redirectURL := payOrder(t, buyer, order.OrderID)
+	store := dao.Current()
+	broken := *store
+	broken.Payments = failingCapture{store.Payments}
+	dao.Use(&broken)
+	t.Cleanup(func() { dao.Use(store) })
+	logs := captureLogs(t)
+
+	if w := completeMockPayment(t, redirectURL, true); w.Code != http.StatusInternalServerError {
+		t.Fatalf("保存扣款的结果失败时应返回500，状态码为%d", w.Code)
+	}
+	ref := mockRef(t, redirectURL)
+	if p, _ := payments.(*payment.Mock).Lookup(ref); p.Status != model.PaymentStatusCaptured {
+		t.Fatalf("网关中应已经扣款，实际为%s", p.Status)
+	}
+	ps, err := dao.GetPaymentsByOrderID(t.Context(), order.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 1 || ps[0].Status != model.PaymentStatusReconcile {
+		t.Errorf("支付应标记为待对账: %+v", ps)
+	}
+	if orderState(t, order.OrderID) != model.OrderStatePendingPayment {
+		t.Errorf("没有保存扣款的结果时订单应仍为待付款")
+	}
+	logged := false
+	for _, entry := range decodeLogs(t, logs) {
+		if entry["level"] == "ERROR" && entry["provider_ref"] == ref {
+			logged = true
+		}
+	}
+	if !logged {
+		t.Errorf("应记录网关中的支付号以便对账: %s", logs)
+	}
+}
+
+func TestPaymentsDisabled(t *testing.T) {
+	//没有配置支付网关
+	provider := payments
+	payments = nil
+	t.Cleanup(func() { payments = provider })
+	buyer := loginAs(t, "nopaybuyer", model.RoleCustomer)
+	book := addStockBook(t, "不使用在线支付的图书", 10)
+	serve(HandlerFunc(AddBook2Cart), "POST", "/addBook2Cart", url.Values{"bookId": {strconv.Itoa(book.ID)}}, buyer)
+	w := serve(HandlerFunc(Checkout), "POST", "/checkout", testAddress, buyer)
+	if w.Code != http.StatusOK {
+		t.Fatalf("结账失败，状态码为%d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "去支付") {
+		t.Error("没有配置支付网关时不应显示去支付的按钮")
+	}
+	order := newPendingOrder(t, buyer, "不使用在线支付的图书2")
+	if order.State != model.OrderStatePaid {
+		t.Errorf("没有配置支付网关时订单应直接为已付款，实际为%s", order.StateName())
+	}
+	r := httptest.NewRequest("POST", "/api/v1/orders/"+order.OrderID+"/pay", nil)
+	r.AddCookie(buyer)
+	w = httptest.NewRecorder()
+	NewAPIHandler().ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("没有配置支付网关时不应有支付的接口，状态码为%d", w.Code)
+	}
+}
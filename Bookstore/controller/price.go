@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"bookstore/model"
+	"fmt"
+)
+
+// withPriceNotices 为加入购物车之后价格发生变化的购物项添加提示信息，购物车为nil时直接返回
+func withPriceNotices(cart *model.Cart) *model.Cart {
+	if cart == nil {
+		return nil
+	}
+	for _, v := range cart.CartItems {
+		if v.PriceChanged() {
+			v.Notices = append(v.Notices, fmt.Sprintf("《%s》的价格已从%s元调整为%s元", v.Book.Title, v.Price, v.Book.Price))
+		}
+	}
+	return cart
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCartPriceChange(t *testing.T) {
+	book := addStockBook(t, "调价提示图书", 5)
+	buyer := loginAs(t, "pricebuyer", model.RoleCustomer)
+	staff := loginAs(t, "pricestaff", model.RoleStaff)
+	add := url.Values{"bookId": {strconv.Itoa(book.ID)}}
+	if w := serve(HandlerFunc(AddBook2Cart), "POST", "/addBook2Cart", add, buyer); w.Code != http.StatusOK {
+		t.Fatalf("添加图书失败，状态码为%d", w.Code)
+	}
+	notice := "《调价提示图书》的价格已从10.00元调整为12.50元"
+
+	//店员修改价格
+	edit := url.Values{
+		"bookId": {strconv.Itoa(book.ID)}, "title": {book.Title}, "author": {book.Author},
+		"price": {"12.5"}, "sales": {"0"}, "stock": {"5"},
+	}
+	if w := serve(HandlerFunc(UpdateOrAddBook), "POST", "/updateOraddBook", edit, staff); w.Code != http.StatusOK {
+		t.Fatalf("修改图书失败，状态码为%d", w.Code)
+	}
+	if n, err := dao.RecalculateCartTotals(t.Context()); err != nil || n != 0 {
+		t.Errorf("修改价格时应已修正购物车中保存的金额，实际还有%d个 %v", n, err)
+	}
+
+	t.Run("购物车页面提示价格变化", func(t *testing.T) {
+		w := serve(HandlerFunc(GetCartInfo), "GET", "/getCartInfo", nil, buyer)
+		body := w.Body.String()
+		if !strings.Contains(body, notice) || !strings.Contains(body, `id="totalAmount">12.50<`) {
+			t.Errorf("购物车页面应按照新价格计算并提示价格变化: %s", body)
+		}
+	})
+
+	t.Run("API提示价格变化", func(t *testing.T) {
+		w := serve(NewAPIHandler(), "GET", "/api/v1/cart", nil, buyer)
+		if !strings.Contains(w.Body.String(), notice) {
+			t.Errorf("购物车的JSON中应有价格变化的提示: %s", w.Body.String())
+		}
+	})
+
+	t.Run("结账按照新价格", func(t *testing.T) {
+		user, err := dao.CheckUserName(t.Context(), "pricebuyer")
+		if err != nil || user.ID == 0 {
+			t.Fatalf("获取用户失败: %v", err)
+		}
+		if w := serve(HandlerFunc(Checkout), "POST", "/checkout", testAddress, buyer); w.Code != http.StatusOK {
+			t.Fatalf("结账失败，状态码为%d", w.Code)
+		}
+		orders, err := dao.GetMyOrders(t.Context(), user.ID)
+		if err != nil {
+			t.Fatalf("获取订单失败: %v", err)
+		}
+		if len(orders) != 1 || orders[0].TotalAmount != model.Cents(1250) {
+			t.Errorf("订单的总金额应为12.50: %+v", orders)
+		}
+	})
+}
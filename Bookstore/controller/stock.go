@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+// clampToStock 将购物车中图书的数量限制在可以购买的库存以内，其他购物车保留的库存不能购买，
+// 数量被调整时返回提示信息，已经没有可以购买的库存时返回409错误，cartID为空时表示新的购物车
+func clampToStock(ctx context.Context, cartID string, book *model.Book, count int64) (int64, string, error) {
+	available, err := dao.AvailableStock(ctx, book.ID, cartID, time.Now())
+	if err != nil {
+		return 0, "", err
+	}
+	if available < 1 {
+		return 0, "", outOfStock(fmt.Sprintf("《%s》库存不足！", book.Title))
+	}
+	if count > available {
+		return available, fmt.Sprintf("《%s》库存不足，数量已调整为%d本", book.Title, available), nil
+	}
+	return count, "", nil
+}
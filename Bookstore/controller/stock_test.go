@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"bookstore/dao"
+	"bookstore/model"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// addStockBook 添加一本指定库存的测试图书
+func addStockBook(t *testing.T, title string, stock int) *model.Book {
+	t.Helper()
+	if err := dao.AddBook(t.Context(), &model.Book{Title: title, Author: "库存测试", Price: model.Cents(1000), Stock: stock, ImgPath: "/static/img/default.jpg"}); err != nil {
+		t.Fatalf("添加图书失败: %v", err)
+	}
+	books, err := dao.GetBooks(t.Context())
+	if err != nil {
+		t.Fatalf("获取图书失败: %v", err)
+	}
+	for _, b := range books {
+		if b.Title == title {
+			return b
+		}
+	}
+	t.Fatalf("没有找到图书%s", title)
+	return nil
+}
+
+func TestCartStock(t *testing.T) {
+	addBook2Cart := HandlerFunc(AddBook2Cart)
+	updateCartItem := HandlerFunc(UpdateCartItem)
+	book := addStockBook(t, "库存有限的图书", 3)
+	add := url.Values{"bookId": {strconv.Itoa(book.ID)}}
+	buyer := loginAs(t, "stockbuyer", model.RoleCustomer)
+	other := loginAs(t, "stockother", model.RoleCustomer)
+
+	if w := serve(addBook2Cart, "POST", "/addBook2Cart", add, buyer); w.Code != http.StatusOK {
+		t.Fatalf("添加图书失败，状态码为%d", w.Code)
+	}
+	user, err := dao.CheckUserName(t.Context(), "stockbuyer")
+	if err != nil || user.ID == 0 {
+		t.Fatalf("获取用户失败: %v", err)
+	}
+	cart, err := dao.GetCartByUserID(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("获取购物车失败: %v", err)
+	}
+	itemID := strconv.FormatInt(cart.CartItems[0].CartItemID, 10)
+
+	t.Run("数量不正确", func(t *testing.T) {
+		for _, count := range []string{"0", "-1", "abc", ""} {
+			w := serve(updateCartItem, "POST", "/updateCartItem", url.Values{"cartItemId": {itemID}, "bookCount": {count}}, buyer)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("数量为%q时应返回400，实际为%d", count, w.Code)
+			}
+		}
+	})
+
+	t.Run("超过库存时调整数量", func(t *testing.T) {
+		w := serve(updateCartItem, "POST", "/updateCartItem", url.Values{"cartItemId": {itemID}, "bookCount": {"10"}}, buyer)
+		var data model.Data
+		if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+			t.Fatalf("响应不是JSON: %v %s", err, w.Body.String())
+		}
+		if data.Count != 3 || data.TotalCount != 3 || !strings.Contains(data.Message, "库存不足") {
+			t.Errorf("数量应调整为库存3本并提示，实际为%+v", data)
+		}
+		//已经达到库存时不能继续添加
+		if w := serve(addBook2Cart, "POST", "/addBook2Cart", add, buyer); w.Code != http.StatusConflict {
+			t.Errorf("超过库存时不能继续添加，实际状态码为%d", w.Code)
+		}
+	})
+
+	t.Run("其他购物车保留的库存", func(t *testing.T) {
+		w := serve(addBook2Cart, "POST", "/addBook2Cart", add, other)
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "库存不足") {
+			t.Errorf("库存被其他购物车保留时不能添加，实际为%d %s", w.Code, w.Body.String())
+		}
+		//保留过期之后可以添加
+		if _, err := dao.ReleaseExpiredReservations(t.Context(), time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("释放过期的保留失败: %v", err)
+		}
+		if w := serve(addBook2Cart, "POST", "/addBook2Cart", add, other); w.Code != http.StatusOK {
+			t.Errorf("保留过期之后应能添加，实际状态码为%d", w.Code)
+		}
+	})
+}
+
+func TestGuestCartDoesNotReserve(t *testing.T) {
+	book := addStockBook(t, "游客不能占用的图书", 3)
+	add := url.Values{"bookId": {strconv.Itoa(book.ID)}}
+	//游客将所有库存放入购物车
+	w := serve(HandlerFunc(AddBook2Cart), "POST", "/addBook2Cart", add, nil)
+	guest := guestCookie(w)
+	if w.Code != http.StatusOK || guest == nil {
+		t.Fatalf("没有登录时应能添加图书，实际为%d", w.Code)
+	}
+	cart, err := dao.GetCartByCartID(t.Context(), guest.Value)
+	if err != nil {
+		t.Fatalf("获取游客购物车失败: %v", err)
+	}
+	itemID := strconv.FormatInt(cart.CartItems[0].CartItemID, 10)
+	w = serve(HandlerFunc(UpdateCartItem), "POST", "/updateCartItem", url.Values{"cartItemId": {itemID}, "bookCount": {"1000"}}, guest)
+	if w.Code != http.StatusOK {
+		t.Fatalf("修改游客购物车失败，状态码为%d", w.Code)
+	}
+	//游客购物车不保留库存，登录的用户仍然可以购买
+	buyer := loginAs(t, "guestreservebuyer", model.RoleCustomer)
+	if w := serve(HandlerFunc(AddBook2Cart), "POST", "/addBook2Cart", add, buyer); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "库存不足") {
+		t.Errorf("游客购物车不应占用库存，实际为%d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"bookstore/model"
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+// templateDirs 模板根目录下存放公共模板的目录，其中的模板会提供给每一个页面使用
+var templateDirs = []string{"layouts", "partials"}
+
+// templateFuncs 模板中可以使用的函数
+var templateFuncs = template.FuncMap{
+	//money 显示带有货币符号的金额，如￥89.00
+	"money": func(m model.Money) string {
+		return "￥" + m.String()
+	},
+	//date 显示到分钟的时间，可以是time.Time或者数据库中保存的时间字符串
+	"date": formatDate,
+	//dict 将成对的键和值组成map，用于向公共模板传递多个参数
+	"dict": dict,
+	//asset 获取静态资源带有内容哈希的地址，如{{asset "css/style.css"}}
+	"asset": func(name string) string {
+		return assets.url(name)
+	},
+	//paymentsEnabled 是否使用在线支付，不使用时不显示去支付的按钮
+	"paymentsEnabled": paymentsEnabled,
+}
+
+// dict 将"键1", 值1, "键2", 值2...组成map，键必须是字符串
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict的参数必须成对出现")
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict的键必须是字符串，实际为%T", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// formatDate 将时间格式化为"2006-01-02 15:04"，不能识别的字符串原样返回
+func formatDate(v interface{}) string {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format("2006-01-02 15:04")
+	case string:
+		parsed, err := time.ParseInLocation("2006-01-02 15:04:05", t, time.Local)
+		if err != nil {
+			return t
+		}
+		return parsed.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprint(v)
+}
+
+// templateRegistry 启动时解析好的所有页面模板，开发模式下模板文件修改后会重新解析
+type templateRegistry struct {
+	fsys     fs.FS
+	reload   bool
+	mu       sync.RWMutex
+	pages    map[string]*template.Template
+	modTimes map[string]time.Time //解析时每个模板文件的修改时间
+}
+
+// templates 处理器使用的模板，由Configure创建
+var templates *templateRegistry
+
+// newTemplateRegistry 解析fsys中的所有模板，有模板不正确时返回错误
+func newTemplateRegistry(fsys fs.FS, reload bool) (*templateRegistry, error) {
+	reg := &templateRegistry{fsys: fsys, reload: reload}
+	if err := reg.load(); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// load 解析公共模板，再为每一个页面复制一份公共模板并解析页面
+func (reg *templateRegistry) load() error {
+	modTimes, err := reg.templateModTimes()
+	if err != nil {
+		return err
+	}
+	base := template.New("").Funcs(templateFuncs)
+	for _, dir := range templateDirs {
+		files, err := fs.Glob(reg.fsys, path.Join(dir, "*.html"))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := reg.parseFile(base, file); err != nil {
+				return err
+			}
+		}
+	}
+	pages := make(map[string]*template.Template)
+	err = reg.walkPages(func(name string) error {
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if err := reg.parseFile(t, name); err != nil {
+			return err
+		}
+		pages[name] = t
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	reg.mu.Lock()
+	reg.pages = pages
+	reg.modTimes = modTimes
+	reg.mu.Unlock()
+	return nil
+}
+
+// parseFile 将模板文件解析为t中的模板，模板的名称为相对于模板根目录的路径，如pages/cart/cart.html
+func (reg *templateRegistry) parseFile(t *template.Template, name string) error {
+	b, err := fs.ReadFile(reg.fsys, name)
+	if err != nil {
+		return err
+	}
+	if _, err := t.New(name).Parse(string(b)); err != nil {
+		return fmt.Errorf("解析模板%s失败: %w", name, err)
+	}
+	return nil
+}
+
+// walkPages 遍历模板根目录下除公共模板和静态资源之外的所有页面
+func (reg *templateRegistry) walkPages(fn func(name string) error) error {
+	return fs.WalkDir(reg.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name == "static" || isTemplateDir(name) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if path.Ext(name) != ".html" {
+			return nil
+		}
+		return fn(name)
+	})
+}
+
+// isTemplateDir 判断是否是存放公共模板的目录
+func isTemplateDir(name string) bool {
+	for _, dir := range templateDirs {
+		if name == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// templateModTimes 获取所有模板文件的修改时间
+func (reg *templateRegistry) templateModTimes() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	err := fs.WalkDir(reg.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != ".html" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		modTimes[name] = info.ModTime()
+		return nil
+	})
+	return modTimes, err
+}
+
+// reloadIfChanged 开发模式下模板文件有修改时重新解析所有模板，
+// 新增、删除、重命名的文件以及修改时间变早的文件（如从备份中恢复）也视为修改
+func (reg *templateRegistry) reloadIfChanged() error {
+	modTimes, err := reg.templateModTimes()
+	if err != nil {
+		return err
+	}
+	reg.mu.RLock()
+	changed := !sameModTimes(modTimes, reg.modTimes)
+	reg.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	return reg.load()
+}
+
+// sameModTimes 判断两次获取的模板文件及其修改时间是否完全相同
+func sameModTimes(a map[string]time.Time, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, t := range a {
+		if other, ok := b[name]; !ok || !t.Equal(other) {
+			return false
+		}
+	}
+	return true
+}
+
+// execute 将页面渲染到缓冲区中，name为相对于模板根目录的路径
+func (reg *templateRegistry) execute(name string, data interface{}) (*bytes.Buffer, error) {
+	if reg.reload {
+		if err := reg.reloadIfChanged(); err != nil {
+			return nil, err
+		}
+	}
+	reg.mu.RLock()
+	t, ok := reg.pages[name]
+	reg.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("模板%s不存在", name)
+	}
+	buf := &bytes.Buffer{}
+	if err := t.ExecuteTemplate(buf, name, data); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// render 渲染页面并响应到浏览器，渲染出错时不会向浏览器发送不完整的页面
+func (reg *templateRegistry) render(w http.ResponseWriter, name string, data interface{}) error {
+	buf, err := reg.execute(name, data)
+	if err != nil {
+		return err
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// render 使用启动时解析好的模板渲染页面
+func render(w http.ResponseWriter, name string, data interface{}) error {
+	return templates.render(w, name, data)
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"bookstore/model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplates(t *testing.T) {
+	t.Run("页面使用公共布局", func(t *testing.T) {
+		w := serve(HandlerFunc(GetPageBooksByPrice), "GET", "/main", nil, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("状态码应为200，实际为%d", w.Code)
+		}
+		body := w.Body.String()
+		for _, want := range []string{"<title>书城首页</title>", "网上书城", "404书城.Copyright", `action="/getPageBooksByPrice"`, "￥"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("首页中应有%q", want)
+			}
+		}
+	})
+
+	t.Run("模板函数", func(t *testing.T) {
+		if got := templateFuncs["money"].(func(model.Money) string)(model.Cents(8950)); got != "￥89.50" {
+			t.Errorf("money应为￥89.50，实际为%s", got)
+		}
+		if got := formatDate("2024-05-06 07:08:09"); got != "2024-05-06 07:08" {
+			t.Errorf("date应为2024-05-06 07:08，实际为%s", got)
+		}
+		if got := formatDate(time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)); got != "2024-05-06 07:08" {
+			t.Errorf("date应为2024-05-06 07:08，实际为%s", got)
+		}
+		if got := formatDate("不是时间"); got != "不是时间" {
+			t.Errorf("不能识别的时间应原样显示，实际为%s", got)
+		}
+		if _, err := dict("key"); err == nil {
+			t.Errorf("dict的参数不成对时应返回错误")
+		}
+	})
+
+	t.Run("模板有错误时启动失败", func(t *testing.T) {
+		root := t.TempDir()
+		writeTemplate(t, root, "index.html", "{{if}}")
+		if _, err := newTemplateRegistry(os.DirFS(root), false); err == nil {
+			t.Errorf("模板有语法错误时应返回错误")
+		}
+	})
+
+	t.Run("开发模式下修改模板后重新解析", func(t *testing.T) {
+		root := t.TempDir()
+		writeTemplate(t, root, "partials/footer.html", `{{define "footer"}}旧的页脚{{end}}`)
+		writeTemplate(t, root, "pages/hello.html", `{{template "footer"}}`)
+		for _, reload := range []bool{false, true} {
+			reg, err := newTemplateRegistry(os.DirFS(root), reload)
+			if err != nil {
+				t.Fatalf("解析模板失败: %v", err)
+			}
+			writeTemplate(t, root, "partials/footer.html", `{{define "footer"}}新的页脚{{end}}`)
+			//保证修改时间比解析时晚
+			later := time.Now().Add(time.Second)
+			os.Chtimes(filepath.Join(root, "partials/footer.html"), later, later)
+			w := httptest.NewRecorder()
+			if err := reg.render(w, "pages/hello.html", nil); err != nil {
+				t.Fatalf("渲染模板失败: %v", err)
+			}
+			want := "旧的页脚"
+			if reload {
+				want = "新的页脚"
+			}
+			if got := w.Body.String(); got != want {
+				t.Errorf("reload为%v时应显示%q，实际为%q", reload, want, got)
+			}
+			writeTemplate(t, root, "partials/footer.html", `{{define "footer"}}旧的页脚{{end}}`)
+		}
+	})
+
+	t.Run("开发模式下删除模板或修改时间变早时重新解析", func(t *testing.T) {
+		root := t.TempDir()
+		writeTemplate(t, root, "partials/footer.html", `{{define "footer"}}新的页脚{{end}}`)
+		writeTemplate(t, root, "pages/hello.html", `{{template "footer"}}`)
+		writeTemplate(t, root, "pages/old.html", `旧的页面`)
+		reg, err := newTemplateRegistry(os.DirFS(root), true)
+		if err != nil {
+			t.Fatalf("解析模板失败: %v", err)
+		}
+		//从备份中恢复了修改时间更早的旧页脚
+		writeTemplate(t, root, "partials/footer.html", `{{define "footer"}}旧的页脚{{end}}`)
+		earlier := time.Now().Add(-time.Hour)
+		os.Chtimes(filepath.Join(root, "partials/footer.html"), earlier, earlier)
+		w := httptest.NewRecorder()
+		if err := reg.render(w, "pages/hello.html", nil); err != nil || w.Body.String() != "旧的页脚" {
+			t.Errorf("修改时间变早的模板应重新解析，实际为%q %v", w.Body.String(), err)
+		}
+		//删除的页面不能再渲染
+		if err := os.Remove(filepath.Join(root, "pages/old.html")); err != nil {
+			t.Fatal(err)
+		}
+		if err := reg.render(httptest.NewRecorder(), "pages/old.html", nil); err == nil {
+			t.Errorf("删除的模板不应再被渲染")
+		}
+	})
+}
+
+// writeTemplate 在root目录下写入模板文件
+func writeTemplate(t *testing.T, root string, name string, content string) {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
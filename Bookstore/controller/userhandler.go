@@ -4,104 +4,192 @@ import (
 	"bookstore/dao"
 	"bookstore/model"
 	"bookstore/utils"
-	"html/template"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // Logout //处理用户注销的函数
-func Logout(w http.ResponseWriter, r *http.Request) {
+func Logout(w http.ResponseWriter, r *http.Request) error {
 	//获取Cookie
-	cookie, _ := r.Cookie("user")
+	cookie, _ := r.Cookie(conf.Session.CookieName)
 	if cookie != nil {
 		//获取cookie的value值
 		cookieValue := cookie.Value
 		//删除数据库中与之对应的Session
-		dao.DeleteSession(cookieValue)
+		if err := dao.DeleteSession(r.Context(), cookieValue); err != nil {
+			return err
+		}
 		//设置cookie失效
+		cookie = newSessionCookie("")
 		cookie.MaxAge = -1
 		//将修改之后的cookie发送给浏览器
 		http.SetCookie(w, cookie)
+		forgetSession(r)
+	}
+	//去首页
+	return GetPageBooksByPrice(w, r)
+}
+
+// LogoutAll 在所有设备上注销，删除当前用户所有的Session
+func LogoutAll(w http.ResponseWriter, r *http.Request) error {
+	flag, session := isLogin(r)
+	if flag {
+		//删除数据库中该用户所有的Session
+		if err := dao.DeleteSessionsByUserID(r.Context(), session.UserID); err != nil {
+			return err
+		}
+		//设置cookie失效
+		cookie := newSessionCookie("")
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+		forgetSession(r)
 	}
 	//去首页
-	GetPageBooksByPrice(w, r)
+	return GetPageBooksByPrice(w, r)
+}
+
+// newSessionCookie 根据配置创建保存Session的id的Cookie
+func newSessionCookie(sessID string) *http.Cookie {
+	return &http.Cookie{
+		Name:     conf.Session.CookieName,
+		Value:    sessID,
+		Path:     "/",
+		MaxAge:   int(conf.Session.MaxLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   conf.Session.CookieSecure,
+		SameSite: conf.Session.SameSite(),
+	}
 }
 
 // Login 处理用户登录的函数
-func Login(w http.ResponseWriter, r *http.Request) {
+func Login(w http.ResponseWriter, r *http.Request) error {
 	//判断是否已经登录
-	flag, _ := dao.IsLogin(r)
+	flag, _ := isLogin(r)
 	if flag {
 		//已经登录
 		//去首页
-		GetPageBooksByPrice(w, r)
-	} else {
-		//获取用户名和密码
-		username := r.PostFormValue("username")
-		password := r.PostFormValue("password")
-		//调用userdao中验证用户名和密码的方法
-		user, _ := dao.CheckUserNameAndPassword(username, password)
-		if user.ID > 0 {
-			//用户名和密码正确
-			//生成UUID作为Session的id
-			uuid := utils.CreateUUID()
-			//创建一个Session
-			sess := &model.Session{
-				SessionID: uuid,
-				UserName:  user.Username,
-				UserID:    user.ID,
-			}
-			//将Session保存到数据库中
-			dao.AddSession(sess)
-			//创建一个Cookie，让它与Session相关联
-			cookie := http.Cookie{
-				Name:     "user",
-				Value:    uuid,
-				HttpOnly: true,
-			}
-			//将cookie发送给浏览器
-			http.SetCookie(w, &cookie)
-			t := template.Must(template.ParseFiles("views/pages/user/login_success.html"))
-			t.Execute(w, user)
-		} else {
-			//用户名或密码不正确
-			t := template.Must(template.ParseFiles("views/pages/user/login.html"))
-			t.Execute(w, "用户名或密码不正确！")
+		return GetPageBooksByPrice(w, r)
+	}
+	//获取用户名和密码
+	username := r.PostFormValue("username")
+	password := r.PostFormValue("password")
+	//调用userdao中验证用户名和密码的方法
+	user, err := dao.CheckUserNameAndPassword(r.Context(), username, password)
+	if err != nil {
+		return err
+	}
+	if user.ID == 0 {
+		//用户名或密码不正确
+		return render(w, "pages/user/login.html", "用户名或密码不正确！")
+	}
+	//用户名和密码正确
+	//登录前浏览器中已有的Session作废，防止Session固定攻击
+	if oldCookie, _ := r.Cookie(conf.Session.CookieName); oldCookie != nil {
+		if err := dao.DeleteSession(r.Context(), oldCookie.Value); err != nil {
+			return err
 		}
 	}
+	//生成UUID作为Session的id，每次登录都使用新的id
+	uuid := utils.CreateUUID()
+	//创建一个Session
+	sess := dao.NewSession(uuid, user, time.Now())
+	//将Session保存到数据库中
+	if err := dao.AddSession(r.Context(), sess); err != nil {
+		return err
+	}
+	//创建一个Cookie，让它与Session相关联
+	cookie := newSessionCookie(uuid)
+	//将cookie发送给浏览器
+	http.SetCookie(w, cookie)
+	//登录后的页面中的表单需要提交新Session对应的CSRF令牌
+	setCSRFCookie(w, sessionCSRFToken(uuid))
+	//登录前添加到游客购物车中的图书合并到用户的购物车
+	messages := mergeGuestCart(w, r, user.ID)
+	return render(w, "pages/user/login_success.html", &model.Session{UserID: user.ID, UserName: user.Username, Messages: messages})
 }
 
 // Regist 处理用户的函注册数
-func Regist(w http.ResponseWriter, r *http.Request) {
+func Regist(w http.ResponseWriter, r *http.Request) error {
+	//关闭注册时不再接受新用户
+	if !conf.Features.Registration {
+		return render(w, "pages/user/regist.html", "暂停注册新用户！")
+	}
 	//获取用户名和密码
 	username := r.PostFormValue("username")
 	password := r.PostFormValue("password")
 	email := r.PostFormValue("email")
+	if username == "" || password == "" {
+		return badRequest("用户名和密码不能为空！")
+	}
 	//调用userdao中验证用户名和密码的方法
-	user, _ := dao.CheckUserName(username)
+	user, err := dao.CheckUserName(r.Context(), username)
+	if err != nil {
+		return err
+	}
 	if user.ID > 0 {
 		//用户名已存在
-		t := template.Must(template.ParseFiles("views/pages/user/regist.html"))
-		t.Execute(w, "用户名已存在！")
-	} else {
-		//用户名可用，将用户信息保存到数据库中
-		dao.SaveUser(username, password, email)
-		//用户名和密码正确
-		t := template.Must(template.ParseFiles("views/pages/user/regist_success.html"))
-		t.Execute(w, "")
+		return render(w, "pages/user/regist.html", "用户名已存在！")
 	}
+	//用户名可用，将用户信息保存到数据库中
+	if err := dao.SaveUser(r.Context(), username, password, email); err != nil {
+		return err
+	}
+	//用户名和密码正确
+	return render(w, "pages/user/regist_success.html", "")
 }
 
 // CheckUserName 通过发送Ajax验证用户名是否可用
-func CheckUserName(w http.ResponseWriter, r *http.Request) {
+func CheckUserName(w http.ResponseWriter, r *http.Request) error {
 	//获取用户输入的用户名
 	username := r.PostFormValue("username")
 	//调用userdao中验证用户名和密码的方法
-	user, _ := dao.CheckUserName(username)
+	user, err := dao.CheckUserName(r.Context(), username)
+	if err != nil {
+		return err
+	}
 	if user.ID > 0 {
 		//用户名已存在
-		w.Write([]byte("用户名已存在！"))
+		_, err = w.Write([]byte("用户名已存在！"))
 	} else {
 		//用户名可用
-		w.Write([]byte("<font style='color:green'>用户名可用！</font>"))
+		_, err = w.Write([]byte("<font style='color:green'>用户名可用！</font>"))
+	}
+	return err
+}
+
+// GetUsers 获取所有用户，管理员在此修改用户的角色
+func GetUsers(w http.ResponseWriter, r *http.Request) error {
+	//调用userdao中获取所有用户的函数
+	users, err := dao.GetUsers(r.Context())
+	if err != nil {
+		return err
+	}
+	//渲染模板
+	return render(w, "pages/manager/user_manager.html", users)
+}
+
+// UpdateUserRole 修改用户的角色
+func UpdateUserRole(w http.ResponseWriter, r *http.Request) error {
+	//获取要修改的用户的id和新的角色
+	userID := r.PostFormValue("userId")
+	role := r.PostFormValue("role")
+	iUserID, err := strconv.Atoi(userID)
+	if err != nil || !model.IsValidRole(role) {
+		return badRequest("用户或角色不正确！")
+	}
+	//管理员不能修改自己的角色，避免系统中没有管理员
+	session, err := currentSession(r)
+	if err != nil {
+		return err
+	}
+	if session.UserID == iUserID {
+		return badRequest("不能修改自己的角色！")
+	}
+	//调用userdao中修改用户角色的函数
+	if err := dao.UpdateUserRole(r.Context(), iUserID, role); err != nil {
+		return err
 	}
+	//再次查询所有用户
+	return GetUsers(w, r)
 }
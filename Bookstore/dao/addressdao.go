@@ -0,0 +1,81 @@
+package dao
+
+import (
+	"bookstore/model"
+	"context"
+	"time"
+)
+
+// sqlAddressRepository 基于SQL数据库的收货地址数据访问实现
+type sqlAddressRepository struct {
+	db loggedDB
+}
+
+// AddAddress 向用户的地址簿中添加收货地址，并将生成的id保存到address中
+func (r *sqlAddressRepository) AddAddress(ctx context.Context, address *model.Address) error {
+	//写sql语句
+	sqlStr := "insert into addresses(user_id,recipient,phone,address,created_at) values(?,?,?,?,?)"
+	//执行
+	res, err := r.db.ExecContext(ctx, sqlStr, address.UserID, address.Recipient, address.Phone, address.Detail, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	address.ID = id
+	return nil
+}
+
+// GetAddressesByUserID 获取用户保存的所有收货地址，按照添加的顺序排列
+func (r *sqlAddressRepository) GetAddressesByUserID(ctx context.Context, userID int) ([]*model.Address, error) {
+	//写sql语句
+	sqlStr := "select id,user_id,recipient,phone,address from addresses where user_id = ? order by id"
+	//执行
+	rows, err := r.db.QueryContext(ctx, sqlStr, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var addresses []*model.Address
+	for rows.Next() {
+		address := &model.Address{}
+		if err := rows.Scan(&address.ID, &address.UserID, &address.Recipient, &address.Phone, &address.Detail); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+// GetAddressByID 根据id获取收货地址，地址不存在时返回sql.ErrNoRows
+func (r *sqlAddressRepository) GetAddressByID(ctx context.Context, addressID string) (*model.Address, error) {
+	//写sql语句
+	sqlStr := "select id,user_id,recipient,phone,address from addresses where id = ?"
+	//执行
+	row := r.db.QueryRowContext(ctx, sqlStr, addressID)
+	address := &model.Address{}
+	if err := row.Scan(&address.ID, &address.UserID, &address.Recipient, &address.Phone, &address.Detail); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// UpdateAddress 修改收货地址，已经下单的订单中保存的是地址的快照，不受影响
+func (r *sqlAddressRepository) UpdateAddress(ctx context.Context, address *model.Address) error {
+	//写sql语句
+	sqlStr := "update addresses set recipient = ?, phone = ?, address = ? where id = ? and user_id = ?"
+	//执行
+	_, err := r.db.ExecContext(ctx, sqlStr, address.Recipient, address.Phone, address.Detail, address.ID, address.UserID)
+	return err
+}
+
+// DeleteAddressByID 删除收货地址
+func (r *sqlAddressRepository) DeleteAddressByID(ctx context.Context, addressID string) error {
+	//写sql语句
+	sqlStr := "delete from addresses where id = ?"
+	//执行
+	_, err := r.db.ExecContext(ctx, sqlStr, addressID)
+	return err
+}
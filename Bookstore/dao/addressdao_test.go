@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"bookstore/model"
+	"database/sql"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestAddresses(t *testing.T) {
+	userID := newMergeUser(t, "addressuser")
+	home := &model.Address{UserID: userID, Recipient: "张三", Phone: "13800000000", Detail: "北京市海淀区"}
+	office := &model.Address{UserID: userID, Recipient: "张三", Phone: "010-12345678", Detail: "北京市朝阳区"}
+	for _, address := range []*model.Address{home, office} {
+		if err := AddAddress(t.Context(), address); err != nil {
+			t.Fatalf("添加地址失败: %v", err)
+		}
+	}
+	if home.ID == 0 || office.ID <= home.ID {
+		t.Fatalf("添加地址后应得到地址的id: %d %d", home.ID, office.ID)
+	}
+	addresses, err := GetAddressesByUserID(t.Context(), userID)
+	if err != nil || len(addresses) != 2 || addresses[0].Detail != "北京市海淀区" || addresses[1].Phone != "010-12345678" {
+		t.Fatalf("应按照添加的顺序得到2个地址: %+v %v", addresses, err)
+	}
+
+	//下单时复制地址，之后修改地址不影响订单
+	book := addCheckoutBook(t, "收货地址图书", 5)
+	cart := newCheckoutCart(t, "address-cart", userID, map[*model.Book]int64{book: 1})
+	order := newCheckoutOrder("address-order", cart)
+	order.SetShipping(home)
+	if err := Checkout(t.Context(), order, cart); err != nil {
+		t.Fatalf("结账失败: %v", err)
+	}
+	home.Detail = "上海市浦东新区"
+	if err := UpdateAddress(t.Context(), home); err != nil {
+		t.Fatal(err)
+	}
+	homeID := strconv.FormatInt(home.ID, 10)
+	if got, err := GetAddressByID(t.Context(), homeID); err != nil || got.Detail != "上海市浦东新区" {
+		t.Errorf("地址应被修改: %+v %v", got, err)
+	}
+	if err := DeleteAddressByID(t.Context(), homeID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetAddressByID(t.Context(), homeID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("删除后查询地址应返回sql.ErrNoRows，实际为%v", err)
+	}
+	saved, err := GetOrderByID(t.Context(), "address-order")
+	if err != nil || saved.ShipRecipient != "张三" || saved.ShipPhone != "13800000000" || saved.ShipAddress != "北京市海淀区" {
+		t.Errorf("订单中应保存下单时的地址: %+v %v", saved, err)
+	}
+
+	//只能修改自己的地址
+	office.UserID = userID + 1
+	office.Detail = "别人的地址"
+	UpdateAddress(t.Context(), office)
+	if got, _ := GetAddressByID(t.Context(), strconv.FormatInt(office.ID, 10)); got.Detail != "北京市朝阳区" {
+		t.Errorf("不应修改其他用户的地址: %+v", got)
+	}
+}
@@ -2,36 +2,46 @@ package dao
 
 import (
 	"bookstore/model"
-	"bookstore/utils"
+	"context"
+	"database/sql"
+	"errors"
 	"strconv"
 )
 
+// sqlBookRepository 基于SQL数据库的图书数据访问实现
+type sqlBookRepository struct {
+	db loggedDB
+}
+
 // GetBooks 获取数据库中所有的图书
-func GetBooks() ([]*model.Book, error) {
+func (r *sqlBookRepository) GetBooks(ctx context.Context) ([]*model.Book, error) {
 	//写sql语句
 	sqlStr := "select id,title,author,price,sales,stock,img_path from books"
 	//执行
-	rows, err := utils.Db.Query(sqlStr)
+	rows, err := r.db.QueryContext(ctx, sqlStr)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	var books []*model.Book
 	for rows.Next() {
 		book := &model.Book{}
 		//给book中的字段赋值
-		rows.Scan(&book.ID, &book.Title, &book.Author, &book.Price, &book.Sales, &book.Stock, &book.ImgPath)
+		if err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.Price, &book.Sales, &book.Stock, &book.ImgPath); err != nil {
+			return nil, err
+		}
 		//将book添加到books中
 		books = append(books, book)
 	}
-	return books, nil
+	return books, rows.Err()
 }
 
 // AddBook 向数据库中添加一本图书
-func AddBook(b *model.Book) error {
+func (r *sqlBookRepository) AddBook(ctx context.Context, b *model.Book) error {
 	//写sql语句
 	slqStr := "insert into books(title,author,price,sales,stock,img_path) values(?,?,?,?,?,?)"
 	//执行
-	_, err := utils.Db.Exec(slqStr, b.Title, b.Author, b.Price, b.Sales, b.Stock, b.ImgPath)
+	_, err := r.db.ExecContext(ctx, slqStr, b.Title, b.Author, b.Price, b.Sales, b.Stock, b.ImgPath)
 	if err != nil {
 		return err
 	}
@@ -39,36 +49,42 @@ func AddBook(b *model.Book) error {
 }
 
 // DeleteBook 根据图书的id从数据库中删除一本图书
-func DeleteBook(bookID string) error {
+func (r *sqlBookRepository) DeleteBook(ctx context.Context, bookID string) error {
 	//写sql语句
 	sqlStr := "delete from books where id = ?"
 	//执行
-	_, err := utils.Db.Exec(sqlStr, bookID)
+	_, err := r.db.ExecContext(ctx, sqlStr, bookID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// GetBookByID 根据图书的id从数据库中查询出一本图书
-func GetBookByID(bookID string) (*model.Book, error) {
+// GetBookByID 根据图书的id从数据库中查询出一本图书，图书不存在时返回ID为0的图书
+func (r *sqlBookRepository) GetBookByID(ctx context.Context, bookID string) (*model.Book, error) {
 	//写sql语句
 	sqlStr := "select id,title,author,price,sales,stock,img_path from books where id = ?"
 	//执行
-	row := utils.Db.QueryRow(sqlStr, bookID)
+	row := r.db.QueryRowContext(ctx, sqlStr, bookID)
 	//创建Book
 	book := &model.Book{}
 	//为book中的字段赋值
-	row.Scan(&book.ID, &book.Title, &book.Author, &book.Price, &book.Sales, &book.Stock, &book.ImgPath)
+	err := row.Scan(&book.ID, &book.Title, &book.Author, &book.Price, &book.Sales, &book.Stock, &book.ImgPath)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &model.Book{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 	return book, nil
 }
 
 // UpdateBook 根据图书的id更新图书信息
-func UpdateBook(b *model.Book) error {
+func (r *sqlBookRepository) UpdateBook(ctx context.Context, b *model.Book) error {
 	//写sql语句
 	sqlStr := "update books set title=?,author=?,price=?,sales=?,stock=? where id=?"
 	//执行
-	_, err := utils.Db.Exec(sqlStr, b.Title, b.Author, b.Price, b.Sales, b.Stock, b.ID)
+	_, err := r.db.ExecContext(ctx, sqlStr, b.Title, b.Author, b.Price, b.Sales, b.Stock, b.ID)
 	if err != nil {
 		return err
 	}
@@ -76,99 +92,61 @@ func UpdateBook(b *model.Book) error {
 }
 
 // GetPageBooks 获取带分页的图书信息
-func GetPageBooks(pageNo string) (*model.Page, error) {
+func (r *sqlBookRepository) GetPageBooks(ctx context.Context, pageNo string) (*model.Page, error) {
 	//将页码转换为int64类型
 	iPageNo, _ := strconv.ParseInt(pageNo, 10, 64)
-	//获取数据库中图书的总记录数
-	sqlStr := "select count(*) from books"
-	//设置一个变量接收总记录数
-	var totalRecord int64
-	//执行
-	row := utils.Db.QueryRow(sqlStr)
-	row.Scan(&totalRecord)
-	//设置每页只显示4条记录
-	var pageSize int64 = 4
-	//设置一个变量接收总页数
-	var totalPageNo int64
-	if totalRecord%pageSize == 0 {
-		totalPageNo = totalRecord / pageSize
-	} else {
-		totalPageNo = totalRecord/pageSize + 1
-	}
-	/*
-		math.Ceil( totalRecord/PageSize )
-		(totalRecord - 1)/pageSize + 1
-	*/
-	//获取当前页中的图书
-	sqlStr2 := "select id,title,author,price,sales,stock,img_path from books limit ?,?"
-	//执行
-	rows, err := utils.Db.Query(sqlStr2, (iPageNo-1)*pageSize, pageSize)
-	if err != nil {
-		return nil, err
-	}
-	var books []*model.Book
-	for rows.Next() {
-		book := &model.Book{}
-		rows.Scan(&book.ID, &book.Title, &book.Author, &book.Price, &book.Sales, &book.Stock, &book.ImgPath)
-		//将book添加到books中
-		books = append(books, book)
-	}
-	//创建page
-	page := &model.Page{
-		Books:       books,
-		PageNo:      iPageNo,
-		PageSize:    pageSize,
-		TotalPageNo: totalPageNo,
-		TotalRecord: totalRecord,
-	}
-	return page, nil
+	return r.SearchBooks(ctx, &BookQuery{PageNo: iPageNo})
 }
 
 // GetPageBooksByPrice 获取带分页和价格范围的图书信息
-func GetPageBooksByPrice(pageNo string, minPrice string, maxPrice string) (*model.Page, error) {
-	//将页码转换为int64类型
+func (r *sqlBookRepository) GetPageBooksByPrice(ctx context.Context, pageNo string, minPrice string, maxPrice string) (*model.Page, error) {
+	//将页码和价格转换为数字
 	iPageNo, _ := strconv.ParseInt(pageNo, 10, 64)
-	//获取数据库中图书的总记录数
-	sqlStr := "select count(*) from books where price between ? and ?"
+	mMinPrice, _ := model.ParseMoney(minPrice)
+	mMaxPrice, _ := model.ParseMoney(maxPrice)
+	return r.SearchBooks(ctx, &BookQuery{PageNo: iPageNo, MinPrice: mMinPrice, MaxPrice: mMaxPrice})
+}
+
+// SearchBooks 根据查询条件获取带分页的图书信息
+func (r *sqlBookRepository) SearchBooks(ctx context.Context, q *BookQuery) (*model.Page, error) {
+	where, args := q.where()
+	//where子句随关键字的数量变化，不使用预编译语句
+	db := r.db.uncached()
+	//获取符合条件的图书的总记录数
+	sqlStr := "select count(*) from books" + where
 	//设置一个变量接收总记录数
 	var totalRecord int64
 	//执行
-	row := utils.Db.QueryRow(sqlStr, minPrice, maxPrice)
-	row.Scan(&totalRecord)
-	//设置每页只显示4条记录
-	var pageSize int64 = 4
-	//设置一个变量接收总页数
-	var totalPageNo int64
-	if totalRecord%pageSize == 0 {
-		totalPageNo = totalRecord / pageSize
-	} else {
-		totalPageNo = totalRecord/pageSize + 1
+	if err := db.QueryRowContext(ctx, sqlStr, args...).Scan(&totalRecord); err != nil {
+		return nil, err
 	}
-	/*
-		math.Ceil( totalRecord/PageSize )
-		(totalRecord - 1)/pageSize + 1
-	*/
+	pageNo, pageSize := q.page()
+	//计算总页数
+	totalPageNo := (totalRecord + pageSize - 1) / pageSize
 	//获取当前页中的图书
-	sqlStr2 := "select id,title,author,price,sales,stock,img_path from books where price between ? and ? limit ?,?"
+	sqlStr2 := "select id,title,author,price,sales,stock,img_path from books" + where + q.orderBy() + " limit ?,?"
 	//执行
-	rows, err := utils.Db.Query(sqlStr2, minPrice, maxPrice, (iPageNo-1)*pageSize, pageSize)
+	rows, err := db.QueryContext(ctx, sqlStr2, append(args, (pageNo-1)*pageSize, pageSize)...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	var books []*model.Book
 	for rows.Next() {
 		book := &model.Book{}
-		rows.Scan(&book.ID, &book.Title, &book.Author, &book.Price, &book.Sales, &book.Stock, &book.ImgPath)
+		if err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.Price, &book.Sales, &book.Stock, &book.ImgPath); err != nil {
+			return nil, err
+		}
 		//将book添加到books中
 		books = append(books, book)
 	}
 	//创建page
 	page := &model.Page{
 		Books:       books,
-		PageNo:      iPageNo,
+		PageNo:      pageNo,
 		PageSize:    pageSize,
 		TotalPageNo: totalPageNo,
 		TotalRecord: totalRecord,
 	}
-	return page, nil
+	return page, rows.Err()
 }
@@ -0,0 +1,96 @@
+package dao
+
+import (
+	"bookstore/model"
+	"strings"
+)
+
+// 图书的排序方式
+const (
+	// SortDefault 默认按图书的id排序
+	SortDefault = ""
+	// SortPriceAsc 价格从低到高
+	SortPriceAsc = "price_asc"
+	// SortPriceDesc 价格从高到低
+	SortPriceDesc = "price_desc"
+	// SortSales 销量从高到低
+	SortSales = "sales"
+	// SortNewest 最新上架的在前
+	SortNewest = "newest"
+)
+
+// bookSortOrders 每种排序方式对应的order by子句，排序方式只能从这里选择，不能拼接用户的输入
+var bookSortOrders = map[string]string{
+	SortDefault:   " order by id",
+	SortPriceAsc:  " order by price, id",
+	SortPriceDesc: " order by price desc, id",
+	SortSales:     " order by sales desc, id",
+	SortNewest:    " order by id desc",
+}
+
+// IsValidSort 判断是否是支持的排序方式
+func IsValidSort(sort string) bool {
+	_, ok := bookSortOrders[sort]
+	return ok
+}
+
+// BookQuery 图书的查询条件，各个条件可以任意组合，零值表示不限制
+type BookQuery struct {
+	Keyword  string      //书名或作者中包含的关键字，多个关键字用空格分隔，每个关键字都要匹配
+	MinPrice model.Money //最低价格
+	MaxPrice model.Money //最高价格，为0时不限制
+	InStock  bool        //只查询有库存的图书
+	Sort     string      //排序方式，取值为Sort开头的常量
+	PageNo   int64       //页码，小于1时为第一页
+	PageSize int64       //每页的记录数，小于1时使用配置中的books.pageSize
+}
+
+// where 根据查询条件生成where子句和对应的参数
+func (q *BookQuery) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, keyword := range strings.Fields(q.Keyword) {
+		//转义通配符，关键字中的%和_按普通字符匹配
+		like := "%" + likeEscaper.Replace(keyword) + "%"
+		conds = append(conds, "(title like ? escape '!' or author like ? escape '!')")
+		args = append(args, like, like)
+	}
+	if q.MinPrice.Minor > 0 {
+		conds = append(conds, "price >= ?")
+		args = append(args, q.MinPrice)
+	}
+	if q.MaxPrice.Minor > 0 {
+		conds = append(conds, "price <= ?")
+		args = append(args, q.MaxPrice)
+	}
+	if q.InStock {
+		conds = append(conds, "stock > 0")
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " where " + strings.Join(conds, " and "), args
+}
+
+// orderBy 获取排序方式对应的order by子句，不支持的排序方式按默认排序
+func (q *BookQuery) orderBy() string {
+	if order, ok := bookSortOrders[q.Sort]; ok {
+		return order
+	}
+	return bookSortOrders[SortDefault]
+}
+
+// page 获取有效的页码和每页的记录数
+func (q *BookQuery) page() (pageNo int64, pageSize int64) {
+	pageNo, pageSize = q.PageNo, q.PageSize
+	if pageNo < 1 {
+		pageNo = 1
+	}
+	if pageSize < 1 {
+		pageSize = conf.Books.PageSize
+	}
+	return pageNo, pageSize
+}
+
+// likeEscaper 转义like语句中的通配符，转义字符为!
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
@@ -0,0 +1,73 @@
+package dao
+
+import (
+	"bookstore/model"
+	"testing"
+)
+
+func TestSearchBooks(t *testing.T) {
+	for _, b := range []*model.Book{
+		{Title: "搜索测试%甲", Author: "检索作者", Price: model.Cents(1150), Sales: 5, Stock: 0},
+		{Title: "搜索测试乙", Author: "其他作者", Price: model.Cents(2250), Sales: 9, Stock: 3},
+		{Title: "搜索测试丙", Author: "其他作者", Price: model.Cents(3350), Sales: 1, Stock: 1},
+	} {
+		b.ImgPath = "/static/img/default.jpg"
+		if err := AddBook(t.Context(), b); err != nil {
+			t.Fatalf("添加图书失败: %v", err)
+		}
+	}
+	tests := []struct {
+		name   string
+		query  BookQuery
+		titles []string
+	}{
+		{"按书名查询", BookQuery{Keyword: "搜索测试"}, []string{"搜索测试%甲", "搜索测试乙", "搜索测试丙"}},
+		{"按作者查询", BookQuery{Keyword: "检索作者"}, []string{"搜索测试%甲"}},
+		{"通配符按普通字符匹配", BookQuery{Keyword: "试%"}, []string{"搜索测试%甲"}},
+		{"多个关键字都要匹配", BookQuery{Keyword: " 搜索测试  其他作者 丙"}, []string{"搜索测试丙"}},
+		{"价格范围", BookQuery{Keyword: "搜索测试", MinPrice: model.Cents(2000), MaxPrice: model.Cents(3000)}, []string{"搜索测试乙"}},
+		{"只有最低价格", BookQuery{Keyword: "搜索测试", MinPrice: model.Cents(2000)}, []string{"搜索测试乙", "搜索测试丙"}},
+		{"只看有货", BookQuery{Keyword: "搜索测试", InStock: true}, []string{"搜索测试乙", "搜索测试丙"}},
+		{"价格从高到低", BookQuery{Keyword: "搜索测试", Sort: SortPriceDesc}, []string{"搜索测试丙", "搜索测试乙", "搜索测试%甲"}},
+		{"按销量排序", BookQuery{Keyword: "搜索测试", Sort: SortSales}, []string{"搜索测试乙", "搜索测试%甲", "搜索测试丙"}},
+		{"最新上架", BookQuery{Keyword: "搜索测试", Sort: SortNewest}, []string{"搜索测试丙", "搜索测试乙", "搜索测试%甲"}},
+		{"分页", BookQuery{Keyword: "搜索测试", PageNo: 2, PageSize: 2}, []string{"搜索测试丙"}},
+		{"没有匹配的图书", BookQuery{Keyword: "不存在的图书"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := SearchBooks(t.Context(), &tt.query)
+			if err != nil {
+				t.Fatalf("查询图书失败: %v", err)
+			}
+			var titles []string
+			for _, b := range page.Books {
+				titles = append(titles, b.Title)
+			}
+			if len(titles) != len(tt.titles) {
+				t.Fatalf("查询到的图书应为%v，实际为%v", tt.titles, titles)
+			}
+			for i := range titles {
+				if titles[i] != tt.titles[i] {
+					t.Fatalf("查询到的图书应为%v，实际为%v", tt.titles, titles)
+				}
+			}
+		})
+	}
+	page, _ := SearchBooks(t.Context(), &BookQuery{Keyword: "搜索测试", PageNo: 2, PageSize: 2})
+	if page.TotalRecord != 3 || page.TotalPageNo != 2 || page.PageNo != 2 {
+		t.Errorf("分页信息不正确: %+v", page)
+	}
+	//没有指定每页的记录数时使用配置中的books.pageSize
+	defer func(size int64) { conf.Books.PageSize = size }(conf.Books.PageSize)
+	conf.Books.PageSize = 2
+	for _, page := range []func() (*model.Page, error){
+		func() (*model.Page, error) { return SearchBooks(t.Context(), &BookQuery{Keyword: "搜索测试"}) },
+		func() (*model.Page, error) { return GetPageBooks(t.Context(), "1") },
+		func() (*model.Page, error) { return GetPageBooksByPrice(t.Context(), "1", "0", "") },
+	} {
+		if p, err := page(); err != nil || p.PageSize != 2 || len(p.Books) != 2 {
+			t.Errorf("每页应有2本图书，实际为%+v %v", p, err)
+		}
+	}
+}
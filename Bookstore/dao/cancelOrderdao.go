@@ -0,0 +1,73 @@
+package dao
+
+import (
+	"bookstore/model"
+	"bookstore/utils"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// CancelOrder 在一个事务中取消订单：根据订单项恢复图书的库存和销量，将订单变更为已取消并记录操作人，
+// 订单不存在时返回sql.ErrNoRows，已经发货的订单返回*IllegalTransitionError
+func (r *sqlOrderRepository) CancelOrder(ctx context.Context, orderID string, actorID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	//提交之后再回滚不会有任何影响
+	defer tx.Rollback()
+	//锁定订单，避免同时发货和取消
+	sqlStr := "select state from orders where id = ?"
+	if r.driver == utils.DriverMySQL {
+		sqlStr += " for update"
+	}
+	var from int64
+	if err := tx.QueryRowContext(ctx, sqlStr, orderID).Scan(&from); err != nil {
+		return err
+	}
+	if !model.CanTransition(from, model.OrderStateCancelled) {
+		return &IllegalTransitionError{OrderID: orderID, From: from, To: model.OrderStateCancelled}
+	}
+	//先读出所有的订单项再更新图书，嵌入式数据库只有一个连接
+	rows, err := tx.QueryContext(ctx, "select count,title,author,book_id from order_items where order_id = ?", orderID)
+	if err != nil {
+		return err
+	}
+	var orderItems []*model.OrderItem
+	for rows.Next() {
+		orderItem := &model.OrderItem{}
+		var bookID sql.NullInt64
+		if err := rows.Scan(&orderItem.Count, &orderItem.Title, &orderItem.Author, &bookID); err != nil {
+			rows.Close()
+			return err
+		}
+		orderItem.BookID = int(bookID.Int64)
+		orderItems = append(orderItems, orderItem)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	//恢复库存，减少销量，销量不会减到0以下
+	restore := "update books set stock = stock + ?, sales = case when sales > ? then sales - ? else 0 end"
+	for _, v := range orderItems {
+		if v.BookID > 0 {
+			_, err = tx.ExecContext(ctx, restore+" where id = ?", v.Count, v.Count, v.Count, v.BookID)
+		} else {
+			//旧的订单项没有保存图书的id，按书名和作者查找图书
+			_, err = tx.ExecContext(ctx, restore+" where title = ? and author = ?", v.Count, v.Count, v.Count, v.Title, v.Author)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, "update orders set state = ? where id = ? and state = ?", model.OrderStateCancelled, orderID, from)
+	if err != nil {
+		return err
+	}
+	if err := addOrderHistory(ctx, tx, orderID, from, model.OrderStateCancelled, actorID, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
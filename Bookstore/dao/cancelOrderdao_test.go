@@ -0,0 +1,90 @@
+package dao
+
+import (
+	"bookstore/model"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestCancelOrder(t *testing.T) {
+	t.Run("测试取消订单恢复库存", testCancelOrderRestoresStock)
+	t.Run("测试已发货的订单不能取消", testCancelShippedOrder)
+	t.Run("测试取消旧的订单", testCancelLegacyOrder)
+}
+
+// checkoutForCancel 购买count本图书并返回生成的订单
+func checkoutForCancel(t *testing.T, orderID string, book *model.Book, count int64) *model.Order {
+	t.Helper()
+	cart := newCheckoutCart(t, orderID+"-cart", 3, map[*model.Book]int64{book: count})
+	order := newCheckoutOrder(orderID, cart)
+	order.State = model.OrderStatePaid
+	if err := Checkout(t.Context(), order, cart); err != nil {
+		t.Fatalf("结账失败: %v", err)
+	}
+	return order
+}
+
+// assertStock 检查图书的库存和销量
+func assertStock(t *testing.T, book *model.Book, stock int, sales int) {
+	t.Helper()
+	got, _ := GetBookByID(t.Context(), strconv.Itoa(book.ID))
+	if got.Stock != stock || got.Sales != sales {
+		t.Errorf("《%s》的库存和销量应为%d和%d，实际为%d和%d", book.Title, stock, sales, got.Stock, got.Sales)
+	}
+}
+
+func testCancelOrderRestoresStock(t *testing.T) {
+	book := addCheckoutBook(t, "取消订单测试", 5)
+	order := checkoutForCancel(t, "cancel-order", book, 2)
+	assertStock(t, book, 3, 2)
+	if err := CancelOrder(t.Context(), order.OrderID, 3); err != nil {
+		t.Fatalf("取消订单失败: %v", err)
+	}
+	assertStock(t, book, 5, 0)
+	got, _ := GetOrderByID(t.Context(), order.OrderID)
+	if got.State != model.OrderStateCancelled {
+		t.Errorf("订单状态应为已取消，实际为%s", got.StateName())
+	}
+	history, _ := GetOrderHistory(t.Context(), order.OrderID)
+	if last := history[len(history)-1]; last.ToState != model.OrderStateCancelled || last.ActorID != 3 {
+		t.Errorf("应记录取消订单的操作，实际为%+v", last)
+	}
+	//不能重复取消，库存也不会重复恢复
+	var stateErr *IllegalTransitionError
+	if err := CancelOrder(t.Context(), order.OrderID, 3); !errors.As(err, &stateErr) {
+		t.Errorf("重复取消应返回IllegalTransitionError，实际为%v", err)
+	}
+	assertStock(t, book, 5, 0)
+}
+
+func testCancelShippedOrder(t *testing.T) {
+	book := addCheckoutBook(t, "取消已发货订单测试", 5)
+	order := checkoutForCancel(t, "cancel-shipped", book, 1)
+	if err := TransitionOrder(t.Context(), order.OrderID, model.OrderStateShipped, 4); err != nil {
+		t.Fatalf("发货失败: %v", err)
+	}
+	var stateErr *IllegalTransitionError
+	if err := CancelOrder(t.Context(), order.OrderID, 4); !errors.As(err, &stateErr) {
+		t.Fatalf("已发货的订单不能取消，实际为%v", err)
+	}
+	assertStock(t, book, 4, 1)
+	if got, _ := GetOrderByID(t.Context(), order.OrderID); got.State != model.OrderStateShipped {
+		t.Errorf("订单状态不应被修改，实际为%s", got.StateName())
+	}
+}
+
+func testCancelLegacyOrder(t *testing.T) {
+	book := addCheckoutBook(t, "旧订单测试", 5)
+	order := &model.Order{OrderID: "cancel-legacy", CreateTime: "2024-01-01 00:00:00", TotalCount: 2, TotalAmount: model.Cents(2000), State: model.OrderStatePaid, UserID: 3}
+	if err := AddOrder(t.Context(), order); err != nil {
+		t.Fatalf("添加订单失败: %v", err)
+	}
+	//旧的订单项没有图书的id
+	AddOrderItem(t.Context(), &model.OrderItem{Count: 2, Amount: model.Cents(2000), Title: book.Title, Author: book.Author, Price: model.Cents(1000), ImgPath: book.ImgPath, OrderID: order.OrderID})
+	if err := CancelOrder(t.Context(), order.OrderID, 0); err != nil {
+		t.Fatalf("取消订单失败: %v", err)
+	}
+	//销量不会减到0以下
+	assertStock(t, book, 7, 0)
+}
@@ -2,86 +2,102 @@ package dao
 
 import (
 	"bookstore/model"
-	"bookstore/utils"
+	"context"
+	"time"
 )
 
-// AddCartItem 向购物项表中插入购物项
-func AddCartItem(cartItem *model.CartItem) error {
+// AddCartItem 向购物项表中插入购物项，记录图书当前的单价，并按照配置为其保留库存
+func (r *sqlCartRepository) AddCartItem(ctx context.Context, cartItem *model.CartItem) error {
+	until, err := reservedUntil(ctx, r.db, cartItem.CartID, cartItem.Book.ID, cartItem.Count, time.Now())
+	if err != nil {
+		return err
+	}
 	//写sql
-	sqlStr := "insert into cart_items(count,amount,book_id,cart_id) values(?,?,?,?)"
+	sqlStr := "insert into cart_items(count,amount,price,book_id,cart_id,reserved_until) values(?,?,?,?,?,?)"
 	//执行sql
-	_, err := utils.Db.Exec(sqlStr, cartItem.Count, cartItem.GetAmount(), cartItem.Book.ID, cartItem.CartID)
+	_, err = r.db.ExecContext(ctx, sqlStr, cartItem.Count, cartItem.GetAmount(), cartItem.Book.Price, cartItem.Book.ID, cartItem.CartID, until)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// GetCartItemByBookIDAndCartID 根据图书的id和购物车的id获取对应的购物项
-func GetCartItemByBookIDAndCartID(bookID string, cartID string) (*model.CartItem, error) {
-	//写sql语句
-	sqlStr := "select id,count,amount,cart_id from cart_items where book_id = ? and cart_id = ?"
-	//执行
-	row := utils.Db.QueryRow(sqlStr, bookID, cartID)
-	//设置一个变量接收图书的id
-	//创建cartItem
-	cartItem := &model.CartItem{}
-	err := row.Scan(&cartItem.CartItemID, &cartItem.Count, &cartItem.Amount, &cartItem.CartID)
+// cartItemQuery 查询购物项的sql语句，通过连接查询同时得到购物项中的图书，避免逐个查询图书
+const cartItemQuery = "select ci.id,ci.count,ci.amount,ci.price,ci.cart_id,b.id,b.title,b.author,b.price,b.sales,b.stock,b.img_path from cart_items ci join books b on b.id = ci.book_id"
+
+// rowScanner *sql.Row和*sql.Rows共有的Scan方法
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCartItem 扫描cartItemQuery查询出的一行，金额小计按照图书当前的价格计算
+func scanCartItem(row rowScanner) (*model.CartItem, error) {
+	cartItem := &model.CartItem{Book: &model.Book{}}
+	book := cartItem.Book
+	err := row.Scan(&cartItem.CartItemID, &cartItem.Count, &cartItem.Amount, &cartItem.Price, &cartItem.CartID,
+		&book.ID, &book.Title, &book.Author, &book.Price, &book.Sales, &book.Stock, &book.ImgPath)
 	if err != nil {
 		return nil, err
 	}
-	//根据图书的id查询图书信息
-	book, _ := GetBookByID(bookID)
-	//将book设置到购物项
-	cartItem.Book = book
+	cartItem.Amount = cartItem.GetAmount()
 	return cartItem, nil
 }
 
-// UpdateBookCount 根据购物项中的相关信息更新购物项中图书的数量和金额小计
-func UpdateBookCount(cartItem *model.CartItem) error {
+// GetCartItemByBookIDAndCartID 根据图书的id和购物车的id获取对应的购物项，购物车中没有该图书时返回sql.ErrNoRows
+func (r *sqlCartRepository) GetCartItemByBookIDAndCartID(ctx context.Context, bookID string, cartID string) (*model.CartItem, error) {
+	//执行
+	row := r.db.QueryRowContext(ctx, cartItemQuery+" where ci.book_id = ? and ci.cart_id = ?", bookID, cartID)
+	return scanCartItem(row)
+}
+
+// GetCartItemByID 根据购物项的id获取对应的购物项，购物项不存在时返回sql.ErrNoRows
+func (r *sqlCartRepository) GetCartItemByID(ctx context.Context, cartItemID string) (*model.CartItem, error) {
+	//执行
+	row := r.db.QueryRowContext(ctx, cartItemQuery+" where ci.id = ?", cartItemID)
+	return scanCartItem(row)
+}
+
+// UpdateBookCount 根据购物项中的相关信息更新购物项中图书的数量和金额小计，并重新开始保留库存
+func (r *sqlCartRepository) UpdateBookCount(ctx context.Context, cartItem *model.CartItem) error {
+	until, err := reservedUntil(ctx, r.db, cartItem.CartID, cartItem.Book.ID, cartItem.Count, time.Now())
+	if err != nil {
+		return err
+	}
 	//写sql语句
-	sql := "update cart_items set count = ? , amount = ? where book_id = ? and cart_id = ?"
+	sql := "update cart_items set count = ? , amount = ? , reserved_until = ? where book_id = ? and cart_id = ?"
 	//执行
-	_, err := utils.Db.Exec(sql, cartItem.Count, cartItem.GetAmount(), cartItem.Book.ID, cartItem.CartID)
+	_, err = r.db.ExecContext(ctx, sql, cartItem.Count, cartItem.GetAmount(), until, cartItem.Book.ID, cartItem.CartID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// GetCartItemsByCartID 根据购物车的id获取购物车中所有的购物项
-func GetCartItemsByCartID(cartID string) ([]*model.CartItem, error) {
-	//写sql语句
-	sqlStr := "select id,count,amount,book_id,cart_id from cart_items where cart_id = ?"
+// GetCartItemsByCartID 根据购物车的id获取购物车中所有的购物项，按照加入的顺序排列，
+// 图书通过连接查询一起得到，金额小计按照图书当前的价格计算
+func (r *sqlCartRepository) GetCartItemsByCartID(ctx context.Context, cartID string) ([]*model.CartItem, error) {
 	//执行
-	rows, err := utils.Db.Query(sqlStr, cartID)
+	rows, err := r.db.QueryContext(ctx, cartItemQuery+" where ci.cart_id = ? order by ci.id", cartID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	var cartItems []*model.CartItem
 	for rows.Next() {
-		//设置一个变量接收bookId
-		var bookID string
-		//创建cartItem
-		cartItem := &model.CartItem{}
-		err2 := rows.Scan(&cartItem.CartItemID, &cartItem.Count, &cartItem.Amount, &bookID, &cartItem.CartID)
-		if err2 != nil {
-			return nil, err2
+		cartItem, err := scanCartItem(rows)
+		if err != nil {
+			return nil, err
 		}
-		//根据bookID获取图书信息
-		book, _ := GetBookByID(bookID)
-		//将book设置到购物项中
-		cartItem.Book = book
 		cartItems = append(cartItems, cartItem)
 	}
-	return cartItems, nil
+	return cartItems, rows.Err()
 }
 
 // DeleteCartItemsByCartID 根据购物车的id删除所有的购物项
-func DeleteCartItemsByCartID(cartID string) error {
+func (r *sqlCartRepository) DeleteCartItemsByCartID(ctx context.Context, cartID string) error {
 	//写sql语句
 	sql := "delete from cart_items where cart_id = ?"
-	_, err := utils.Db.Exec(sql, cartID)
+	_, err := r.db.ExecContext(ctx, sql, cartID)
 	if err != nil {
 		return err
 	}
@@ -89,11 +105,11 @@ func DeleteCartItemsByCartID(cartID string) error {
 }
 
 // DeleteCartItemByID 根据购物项的id删除购物项
-func DeleteCartItemByID(cartItemID string) error {
+func (r *sqlCartRepository) DeleteCartItemByID(ctx context.Context, cartItemID string) error {
 	//写sql语句
 	sql := "delete from cart_items where id = ?"
 	//执行
-	_, err := utils.Db.Exec(sql, cartItemID)
+	_, err := r.db.ExecContext(ctx, sql, cartItemID)
 	if err != nil {
 		return err
 	}
@@ -2,15 +2,21 @@ package dao
 
 import (
 	"bookstore/model"
-	"bookstore/utils"
+	"context"
+	"time"
 )
 
-// AddCart 向购物车表中插入购物车
-func AddCart(cart *model.Cart) error {
+// sqlCartRepository 基于SQL数据库的购物车数据访问实现
+type sqlCartRepository struct {
+	db loggedDB
+}
+
+// AddCart 向购物车表中插入购物车，UserID为0时是游客的购物车
+func (r *sqlCartRepository) AddCart(ctx context.Context, cart *model.Cart) error {
 	//写sql语句
-	sqlStr := "insert into carts(id,total_count,total_amount,user_id) values(?,?,?,?)"
+	sqlStr := "insert into carts(id,total_count,total_amount,user_id,updated_at) values(?,?,?,?,?)"
 	//执行sql
-	_, err := utils.Db.Exec(sqlStr, cart.CartID, cart.GetTotalCount(), cart.GetTotalAmount(), cart.UserID)
+	_, err := r.db.ExecContext(ctx, sqlStr, cart.CartID, cart.GetTotalCount(), cart.GetTotalAmount(), nullUserID(cart.UserID), time.Now().Unix())
 	if err != nil {
 		return err
 	}
@@ -19,17 +25,34 @@ func AddCart(cart *model.Cart) error {
 	//遍历得到每一个购物项
 	for _, cartItem := range cartItems {
 		//将购物项插入到数据库中
-		AddCartItem(cartItem)
+		if err := r.AddCartItem(ctx, cartItem); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// GetCartByUserID 根据用户的id从数据库中查询对应的购物车
-func GetCartByUserID(userID int) (*model.Cart, error) {
+// GetCartByUserID 根据用户的id从数据库中查询对应的购物车，用户还没有购物车时返回sql.ErrNoRows
+func (r *sqlCartRepository) GetCartByUserID(ctx context.Context, userID int) (*model.Cart, error) {
+	return r.getCart(ctx, "user_id = ?", userID)
+}
+
+// GetCartByCartID 根据购物车的id从数据库中查询对应的购物车，游客购物车的UserID为0，购物车不存在时返回sql.ErrNoRows
+func (r *sqlCartRepository) GetCartByCartID(ctx context.Context, cartID string) (*model.Cart, error) {
+	return r.getCart(ctx, "id = ?", cartID)
+}
+
+// GetCartByCartItemID 根据购物项的id查询该购物项所属的购物车，购物项不存在时返回sql.ErrNoRows
+func (r *sqlCartRepository) GetCartByCartItemID(ctx context.Context, cartItemID string) (*model.Cart, error) {
+	return r.getCart(ctx, "id = (select cart_id from cart_items where id = ?)", cartItemID)
+}
+
+// getCart 查询满足条件的购物车及其所有的购物项，不论有多少购物项都只执行两条sql语句
+func (r *sqlCartRepository) getCart(ctx context.Context, where string, args ...interface{}) (*model.Cart, error) {
 	//写sql语句
-	sql := "select id,total_count,total_amount,user_id from carts where user_id = ?"
+	sql := "select id,total_count,total_amount,coalesce(user_id,0) from carts where " + where
 	//执行sql
-	row := utils.Db.QueryRow(sql, userID)
+	row := r.db.QueryRowContext(ctx, sql, args...)
 	//创建一个购物车
 	cart := &model.Cart{}
 	err := row.Scan(&cart.CartID, &cart.TotalCount, &cart.TotalAmount, &cart.UserID)
@@ -37,18 +60,23 @@ func GetCartByUserID(userID int) (*model.Cart, error) {
 		return nil, err
 	}
 	//获取当前购物车中所有的购物项
-	cartItems, _ := GetCartItemsByCartID(cart.CartID)
+	cartItems, err := r.GetCartItemsByCartID(ctx, cart.CartID)
+	if err != nil {
+		return nil, err
+	}
 	//将所有的购物项设置到购物车中
 	cart.CartItems = cartItems
+	//保存的总金额可能是按照之前的价格计算的，以图书当前的价格为准
+	cart.Recalculate()
 	return cart, nil
 }
 
-// UpdateCart 更新购物车中的图书的总数量和总金额
-func UpdateCart(cart *model.Cart) error {
+// UpdateCart 更新购物车中的图书的总数量和总金额，同时记录修改的时间
+func (r *sqlCartRepository) UpdateCart(ctx context.Context, cart *model.Cart) error {
 	//写sql语句
-	sql := "update carts set total_count = ? , total_amount = ? where id = ?"
+	sql := "update carts set total_count = ? , total_amount = ? , updated_at = ? where id = ?"
 	//执行
-	_, err := utils.Db.Exec(sql, cart.GetTotalCount(), cart.GetTotalAmount(), cart.CartID)
+	_, err := r.db.ExecContext(ctx, sql, cart.GetTotalCount(), cart.GetTotalAmount(), time.Now().Unix(), cart.CartID)
 	if err != nil {
 		return err
 	}
@@ -56,18 +84,93 @@ func UpdateCart(cart *model.Cart) error {
 }
 
 // DeleteCartByCartID 根据购物车的id删除购物车
-func DeleteCartByCartID(cartID string) error {
+func (r *sqlCartRepository) DeleteCartByCartID(ctx context.Context, cartID string) error {
 	//删除购物车之前需要先删除所有的购物项
-	err := DeleteCartItemsByCartID(cartID)
+	err := r.DeleteCartItemsByCartID(ctx, cartID)
 	if err != nil {
 		return err
 	}
 	//写sql语句
 	sql := "delete from carts where id = ?"
 	//执行
-	_, err2 := utils.Db.Exec(sql, cartID)
+	_, err2 := r.db.ExecContext(ctx, sql, cartID)
 	if err2 != nil {
 		return err2
 	}
 	return nil
 }
+
+// DeleteExpiredGuestCarts 删除在before之后没有修改过的游客购物车及其购物项，返回删除的购物车的数量
+func (r *sqlCartRepository) DeleteExpiredGuestCarts(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	//先删除购物项，再删除购物车
+	_, err = tx.ExecContext(ctx, "delete from cart_items where cart_id in (select id from carts where user_id is null and updated_at < ?)", before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, "delete from carts where user_id is null and updated_at < ?", before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// RecalculateCartTotals 按照图书当前的价格重新计算所有购物项的金额小计和购物车的总数量、总金额，
+// 返回保存的值有误被修正的购物车的数量，用于修复数据
+func (r *sqlCartRepository) RecalculateCartTotals(ctx context.Context) (int64, error) {
+	return r.recalculateCarts(ctx, 0)
+}
+
+// RecalculateBookCarts 只重新计算有该图书的购物车，修改图书的价格之后使用
+func (r *sqlCartRepository) RecalculateBookCarts(ctx context.Context, bookID int) (int64, error) {
+	return r.recalculateCarts(ctx, bookID)
+}
+
+// recalculateCarts 按照图书当前的价格重新计算购物车中保存的金额，bookID为0时重新计算所有的购物车
+func (r *sqlCartRepository) recalculateCarts(ctx context.Context, bookID int) (int64, error) {
+	itemWhere, cartWhere := "", ""
+	var args []interface{}
+	if bookID > 0 {
+		itemWhere = " and book_id = ?"
+		cartWhere = " and id in (select cart_id from cart_items where book_id = ?)"
+		args = append(args, bookID)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	//先更新购物项的金额小计，再根据购物项更新购物车，不修改updated_at，游客购物车的保留时间不变
+	_, err = tx.ExecContext(ctx, "update cart_items set amount = count * (select price from books where books.id = cart_items.book_id) where amount <> count * (select price from books where books.id = cart_items.book_id)"+itemWhere, args...)
+	if err != nil {
+		return 0, err
+	}
+	sumCount := "(select coalesce(sum(count), 0) from cart_items where cart_items.cart_id = carts.id)"
+	sumAmount := "(select coalesce(sum(amount), 0) from cart_items where cart_items.cart_id = carts.id)"
+	res, err := tx.ExecContext(ctx, "update carts set total_count = "+sumCount+", total_amount = "+sumAmount+
+		" where (total_count <> "+sumCount+" or total_amount <> "+sumAmount+")"+cartWhere, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// nullUserID 游客购物车的用户id为0，保存为NULL
+func nullUserID(userID int) interface{} {
+	if userID == 0 {
+		return nil
+	}
+	return userID
+}
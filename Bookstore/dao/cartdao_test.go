@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"bookstore/model"
+	"testing"
+)
+
+func TestCartPriceChange(t *testing.T) {
+	book := addCheckoutBook(t, "调价图书", 5)
+	userID := newMergeUser(t, "priceuser")
+	cart := newCheckoutCart(t, "price-cart", userID, map[*model.Book]int64{book: 2})
+	if item := cart.CartItems[0]; item.Price.Minor != 1000 || item.PriceChanged() {
+		t.Fatalf("购物项应记录加入时的单价10.00，实际为%s", item.Price)
+	}
+
+	//调价之后购物车按照当前的价格计算，并记得加入时的单价
+	book.Price = model.Cents(1500)
+	if err := UpdateBook(t.Context(), book); err != nil {
+		t.Fatal(err)
+	}
+	cart, _ = GetCartByUserID(t.Context(), userID)
+	item := cart.CartItems[0]
+	if cart.TotalAmount.Minor != 3000 || item.Amount.Minor != 3000 || !item.PriceChanged() || item.Price.Minor != 1000 {
+		t.Errorf("购物车应按照15.00计算，实际总金额%s、小计%s、加入时单价%s", cart.TotalAmount, item.Amount, item.Price)
+	}
+	//修复保存的金额，已经正确时不再修改
+	if n, err := RecalculateCartTotals(t.Context()); err != nil || n < 1 {
+		t.Errorf("应修正调价图书所在的购物车，实际修正了%d个 %v", n, err)
+	}
+	if n, err := RecalculateCartTotals(t.Context()); err != nil || n != 0 {
+		t.Errorf("再次修复时不应修改任何购物车，实际修正了%d个 %v", n, err)
+	}
+
+	//结账时以事务中读取的价格为准
+	book.Price = model.Cents(2000)
+	if err := UpdateBook(t.Context(), book); err != nil {
+		t.Fatal(err)
+	}
+	order := newCheckoutOrder("price-order", cart)
+	if err := Checkout(t.Context(), order, cart); err != nil {
+		t.Fatalf("结账失败: %v", err)
+	}
+	if order.TotalAmount.Minor != 4000 || order.TotalCount != 2 {
+		t.Errorf("订单的总金额应为40.00，实际为%s", order.TotalAmount)
+	}
+	orderItems, _ := GetOrderItemsByOrderID(t.Context(), "price-order")
+	if len(orderItems) != 1 || orderItems[0].Amount.Minor != 4000 || orderItems[0].Price.Minor != 2000 {
+		t.Errorf("订单项应按照20.00计算: %+v", orderItems)
+	}
+}
+
+func TestRecalculateBookCarts(t *testing.T) {
+	edited := addCheckoutBook(t, "只重新计算的调价图书", 5)
+	other := addCheckoutBook(t, "没有调价的其他图书", 5)
+	editedCart := newCheckoutCart(t, "recalc-edited-cart", newMergeUser(t, "recalcedited"), map[*model.Book]int64{edited: 1})
+	newCheckoutCart(t, "recalc-other-cart", newMergeUser(t, "recalcother"), map[*model.Book]int64{other: 1})
+	for _, b := range []*model.Book{edited, other} {
+		b.Price = model.Cents(1100)
+		if err := UpdateBook(t.Context(), b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//只修正有该图书的购物车
+	if n, err := RecalculateBookCarts(t.Context(), edited.ID); err != nil || n != 1 {
+		t.Fatalf("应只修正调价图书所在的购物车，实际修正了%d个 %v", n, err)
+	}
+	if cart, _ := GetCartByCartID(t.Context(), editedCart.CartID); cart.TotalAmount != model.Cents(1100) {
+		t.Errorf("调价图书所在的购物车总金额应为11.00，实际为%s", cart.TotalAmount)
+	}
+	//其他购物车留给修复命令
+	if n, err := RecalculateCartTotals(t.Context()); err != nil || n != 1 {
+		t.Errorf("其他图书所在的购物车不应被修正，实际修复时修正了%d个 %v", n, err)
+	}
+}
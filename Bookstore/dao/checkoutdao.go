@@ -0,0 +1,175 @@
+package dao
+
+import (
+	"bookstore/model"
+	"bookstore/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrCartEmpty 结账时购物车已经不存在或者没有购物项，例如同一个购物车被重复提交结账
+var ErrCartEmpty = errors.New("购物车是空的")
+
+// StockShortage 结账时库存不足的购物项
+type StockShortage struct {
+	BookID int    //图书的id
+	Title  string //图书的书名
+	Stock  int    //图书当前可以购买的库存，不包括其他购物车保留的数量
+	Count  int64  //购物项中图书的数量
+}
+
+// Message 获取库存不足的提示信息
+func (s StockShortage) Message() string {
+	return fmt.Sprintf("《%s》库存不足，当前库存%d本，您要购买%d本", s.Title, s.Stock, s.Count)
+}
+
+// OutOfStockError 结账时有图书的库存不足
+type OutOfStockError struct {
+	Shortages []StockShortage
+}
+
+// Error 实现error接口
+func (e *OutOfStockError) Error() string {
+	return strings.Join(e.Messages(), "；")
+}
+
+// Messages 获取每个库存不足的购物项的提示信息
+func (e *OutOfStockError) Messages() []string {
+	var msgs []string
+	for _, v := range e.Shortages {
+		msgs = append(msgs, v.Message())
+	}
+	return msgs
+}
+
+// Checkout 在一个事务中保存订单和订单项、扣减图书库存、增加销量并删除购物车，
+// 订单项和订单的金额按照图书当前的价格重新计算，结账成功后cart中的购物项、图书价格和订单的总金额都是当前的值。
+// 购物项在事务中锁定购物车后重新读取，购物车已经被结账或者是空的时返回ErrCartEmpty
+func (r *sqlOrderRepository) Checkout(ctx context.Context, order *model.Order, cart *model.Cart) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	//提交之后再回滚不会有任何影响
+	defer tx.Rollback()
+	//锁定购物车并重新读取购物项，同时提交的另一次结账要等这次结账完成，之后购物车已经被删除
+	if err := lockCart(ctx, tx, r.driver, cart); err != nil {
+		return err
+	}
+	//按照图书的id顺序加锁，避免并发结账时死锁
+	cartItems := make([]*model.CartItem, len(cart.CartItems))
+	copy(cartItems, cart.CartItems)
+	sort.Slice(cartItems, func(i, j int) bool {
+		return cartItems[i].Book.ID < cartItems[j].Book.ID
+	})
+	//锁定图书、检查库存并读取当前的价格，SQLite只有一个连接，事务本身就是独占的
+	sqlStr := "select stock,price from books where id = ?"
+	if r.driver == utils.DriverMySQL {
+		sqlStr += " for update"
+	}
+	//其他购物车中还在保留期内的数量不能购买
+	reservedStr := "select coalesce(sum(count), 0) from cart_items where book_id = ? and cart_id <> ? and reserved_until > ?"
+	now := time.Now()
+	var shortages []StockShortage
+	for _, v := range cartItems {
+		var stock, reserved int
+		err := tx.QueryRowContext(ctx, sqlStr, v.Book.ID).Scan(&stock, &v.Book.Price)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, reservedStr, v.Book.ID, cart.CartID, now.Unix()).Scan(&reserved)
+		if err != nil {
+			return err
+		}
+		stock = max(stock-reserved, 0)
+		if int64(stock) < v.Count {
+			shortages = append(shortages, StockShortage{
+				BookID: v.Book.ID,
+				Title:  v.Book.Title,
+				Stock:  stock,
+				Count:  v.Count,
+			})
+		}
+	}
+	if len(shortages) > 0 {
+		return &OutOfStockError{Shortages: shortages}
+	}
+	//购物车中保存的金额可能是加入时的价格，以图书当前的价格为准
+	order.TotalCount = cart.GetTotalCount()
+	order.TotalAmount = cart.GetTotalAmount()
+	//保存订单
+	_, err = tx.ExecContext(ctx, insertOrderSQL, orderArgs(order)...)
+	if err != nil {
+		return err
+	}
+	//记录订单的创建
+	if err := addOrderHistory(ctx, tx, order.OrderID, model.OrderStateNone, order.State, int(order.UserID), now); err != nil {
+		return err
+	}
+	for _, v := range cartItems {
+		//保存订单项
+		_, err = tx.ExecContext(ctx, "insert into order_items(count,amount,title,author,price,img_path,book_id,order_id) values(?,?,?,?,?,?,?,?)",
+			v.Count, v.GetAmount(), v.Book.Title, v.Book.Author, v.Book.Price, v.Book.ImgPath, v.Book.ID, order.OrderID)
+		if err != nil {
+			return err
+		}
+		//扣减库存，增加销量
+		_, err = tx.ExecContext(ctx, "update books set stock = stock - ?, sales = sales + ? where id = ?", v.Count, v.Count, v.Book.ID)
+		if err != nil {
+			return err
+		}
+	}
+	//清空购物车
+	_, err = tx.ExecContext(ctx, "delete from cart_items where cart_id = ?", cart.CartID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "delete from carts where id = ?", cart.CartID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockCart 在事务中锁定购物车并重新读取其中的购物项，替换cart中事务开始之前读取的购物项，
+// 购物车不存在或者没有购物项时返回ErrCartEmpty
+func lockCart(ctx context.Context, tx loggedTx, driver string, cart *model.Cart) error {
+	sqlStr := "select id from carts where id = ?"
+	if driver == utils.DriverMySQL {
+		sqlStr += " for update"
+	}
+	var cartID string
+	err := tx.QueryRowContext(ctx, sqlStr, cart.CartID).Scan(&cartID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCartEmpty
+	}
+	if err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, cartItemQuery+" where ci.cart_id = ? order by ci.id", cart.CartID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var cartItems []*model.CartItem
+	for rows.Next() {
+		cartItem, err := scanCartItem(rows)
+		if err != nil {
+			return err
+		}
+		cartItems = append(cartItems, cartItem)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(cartItems) == 0 {
+		return ErrCartEmpty
+	}
+	cart.CartItems = cartItems
+	return nil
+}
@@ -0,0 +1,163 @@
+package dao
+
+import (
+	"bookstore/model"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// addCheckoutBook 添加一本结账测试用的图书
+func addCheckoutBook(t *testing.T, title string, stock int) *model.Book {
+	t.Helper()
+	if err := AddBook(t.Context(), &model.Book{Title: title, Author: "结账测试", Price: model.Cents(1000), Stock: stock, ImgPath: "/static/img/default.jpg"}); err != nil {
+		t.Fatalf("添加图书失败: %v", err)
+	}
+	books, err := GetBooks(t.Context())
+	if err != nil {
+		t.Fatalf("获取图书失败: %v", err)
+	}
+	for _, v := range books {
+		if v.Title == title {
+			return v
+		}
+	}
+	t.Fatalf("没有找到图书%s", title)
+	return nil
+}
+
+// newCheckoutCart 创建并保存一个购物车
+func newCheckoutCart(t *testing.T, cartID string, userID int, counts map[*model.Book]int64) *model.Cart {
+	t.Helper()
+	cart := &model.Cart{CartID: cartID, UserID: userID}
+	for book, count := range counts {
+		cart.CartItems = append(cart.CartItems, &model.CartItem{Book: book, Count: count, CartID: cartID})
+	}
+	if err := AddCart(t.Context(), cart); err != nil {
+		t.Fatalf("添加购物车失败: %v", err)
+	}
+	cart, err := GetCartByUserID(t.Context(), userID)
+	if err != nil {
+		t.Fatalf("获取购物车失败: %v", err)
+	}
+	return cart
+}
+
+func newCheckoutOrder(orderID string, cart *model.Cart) *model.Order {
+	return &model.Order{
+		OrderID:     orderID,
+		CreateTime:  "2024-01-01 00:00:00",
+		TotalCount:  cart.TotalCount,
+		TotalAmount: cart.TotalAmount,
+		UserID:      int64(cart.UserID),
+	}
+}
+
+func TestCheckout(t *testing.T) {
+	t.Run("测试结账成功", testCheckoutSuccess)
+	t.Run("测试库存不足", testCheckoutOutOfStock)
+	t.Run("测试失败时回滚", testCheckoutRollback)
+	t.Run("测试重复结账", testCheckoutTwice)
+}
+
+func testCheckoutSuccess(t *testing.T) {
+	book := addCheckoutBook(t, "结账成功图书", 5)
+	cart := newCheckoutCart(t, "checkout-ok", 3, map[*model.Book]int64{book: 2})
+	if err := Checkout(t.Context(), newCheckoutOrder("checkout-ok-order", cart), cart); err != nil {
+		t.Fatalf("结账失败: %v", err)
+	}
+	got, _ := GetBookByID(t.Context(), strconv.Itoa(book.ID))
+	if got.Stock != 3 || got.Sales != 2 {
+		t.Errorf("库存应为3、销量应为2，实际为%d、%d", got.Stock, got.Sales)
+	}
+	orderItems, _ := GetOrderItemsByOrderID(t.Context(), "checkout-ok-order")
+	if len(orderItems) != 1 || orderItems[0].Count != 2 {
+		t.Errorf("订单项不正确: %+v", orderItems)
+	}
+	if _, err := GetCartByUserID(t.Context(), 3); err == nil {
+		t.Errorf("结账后购物车应已被删除")
+	}
+}
+
+func testCheckoutOutOfStock(t *testing.T) {
+	enough := addCheckoutBook(t, "库存充足图书", 5)
+	short := addCheckoutBook(t, "库存不足图书", 1)
+	cart := newCheckoutCart(t, "checkout-short", 3, map[*model.Book]int64{enough: 1, short: 2})
+	defer DeleteCartByCartID(t.Context(), cart.CartID)
+	err := Checkout(t.Context(), newCheckoutOrder("checkout-short-order", cart), cart)
+	var stockErr *OutOfStockError
+	if !errors.As(err, &stockErr) {
+		t.Fatalf("应返回库存不足的错误，实际为%v", err)
+	}
+	if len(stockErr.Shortages) != 1 || stockErr.Shortages[0].Title != short.Title {
+		t.Errorf("库存不足的图书不正确: %+v", stockErr.Shortages)
+	}
+	if msgs := stockErr.Messages(); len(msgs) != 1 || msgs[0] != "《库存不足图书》库存不足，当前库存1本，您要购买2本" {
+		t.Errorf("提示信息不正确: %v", msgs)
+	}
+	got, _ := GetBookByID(t.Context(), strconv.Itoa(enough.ID))
+	if got.Stock != 5 {
+		t.Errorf("库存不足时不应扣减其他图书的库存，实际库存为%d", got.Stock)
+	}
+	if orderItems, _ := GetOrderItemsByOrderID(t.Context(), "checkout-short-order"); len(orderItems) != 0 {
+		t.Errorf("库存不足时不应保存订单项")
+	}
+	if _, err := GetCartByUserID(t.Context(), 3); err != nil {
+		t.Errorf("库存不足时不应删除购物车")
+	}
+}
+
+func testCheckoutRollback(t *testing.T) {
+	book := addCheckoutBook(t, "回滚测试图书", 5)
+	cart := newCheckoutCart(t, "checkout-rollback", 3, map[*model.Book]int64{book: 1})
+	defer DeleteCartByCartID(t.Context(), cart.CartID)
+	//订单号重复，保存订单时失败
+	if err := AddOrder(t.Context(), newCheckoutOrder("checkout-dup-order", cart)); err != nil {
+		t.Fatalf("添加订单失败: %v", err)
+	}
+	if err := Checkout(t.Context(), newCheckoutOrder("checkout-dup-order", cart), cart); err == nil {
+		t.Fatalf("订单号重复时结账应失败")
+	}
+	got, _ := GetBookByID(t.Context(), strconv.Itoa(book.ID))
+	if got.Stock != 5 || got.Sales != 0 {
+		t.Errorf("结账失败后库存和销量应保持不变，实际为%d、%d", got.Stock, got.Sales)
+	}
+	if cart, _ := GetCartByUserID(t.Context(), 3); cart == nil || len(cart.CartItems) != 1 {
+		t.Errorf("结账失败后购物车应保持不变")
+	}
+}
+
+func testCheckoutTwice(t *testing.T) {
+	book := addCheckoutBook(t, "重复结账图书", 5)
+	other := addCheckoutBook(t, "结账前删除的图书", 5)
+	cart := newCheckoutCart(t, "checkout-twice", 3, map[*model.Book]int64{book: 1, other: 1})
+	//两次提交在结账之前读取到的是同一个购物车
+	stale, _ := GetCartByUserID(t.Context(), 3)
+	//读取之后删除了一个购物项，结账以事务中读取的购物项为准
+	for _, v := range cart.CartItems {
+		if v.Book.ID == other.ID {
+			DeleteCartItemByID(t.Context(), strconv.FormatInt(v.CartItemID, 10))
+		}
+	}
+	if err := Checkout(t.Context(), newCheckoutOrder("checkout-twice-1", cart), cart); err != nil {
+		t.Fatalf("结账失败: %v", err)
+	}
+	if len(cart.CartItems) != 1 || cart.GetTotalCount() != 1 {
+		t.Errorf("结账后的购物项应为事务中读取的1项，实际为%d项", len(cart.CartItems))
+	}
+	if orderItems, _ := GetOrderItemsByOrderID(t.Context(), "checkout-twice-1"); len(orderItems) != 1 || orderItems[0].Title != book.Title {
+		t.Errorf("订单项不正确: %+v", orderItems)
+	}
+	err := Checkout(t.Context(), newCheckoutOrder("checkout-twice-2", stale), stale)
+	if !errors.Is(err, ErrCartEmpty) {
+		t.Fatalf("重复结账应返回ErrCartEmpty，实际为%v", err)
+	}
+	if _, err := GetOrderByID(t.Context(), "checkout-twice-2"); err == nil {
+		t.Errorf("重复结账不应创建订单")
+	}
+	for b, want := range map[*model.Book]int{book: 4, other: 5} {
+		if got, _ := GetBookByID(t.Context(), strconv.Itoa(b.ID)); got.Stock != want {
+			t.Errorf("《%s》的库存应为%d，实际为%d", b.Title, want, got.Stock)
+		}
+	}
+}
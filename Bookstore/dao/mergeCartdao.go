@@ -0,0 +1,119 @@
+package dao
+
+import (
+	"bookstore/model"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// MergeGuestCart 将游客购物车合并到用户的购物车中：用户还没有购物车时游客购物车直接归用户所有，
+// 否则游客购物车中的购物项移到用户的购物车中，两边都有的图书数量相加，最后删除游客购物车。
+// 与添加购物项一样，合并后的数量限制在可以购买的库存以内并重新开始保留库存，没有库存的购物项被删除，
+// 返回数量被调整的购物项，Stock为调整后的数量。
+// 游客购物车不存在时返回sql.ErrNoRows，已经属于某个用户时不做任何处理
+func (r *sqlCartRepository) MergeGuestCart(ctx context.Context, guestCartID string, userID int) ([]StockShortage, error) {
+	guest, err := r.GetCartByCartID(ctx, guestCartID)
+	if err != nil {
+		return nil, err
+	}
+	if guest.UserID != 0 {
+		return nil, nil
+	}
+	cart, err := r.GetCartByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	//查询都在事务之外完成，嵌入式数据库只有一个连接
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	//合并时修改过的购物项，只有这些购物项需要检查库存
+	var merged []*model.CartItem
+	if cart == nil {
+		//用户还没有购物车，游客购物车直接归用户所有
+		res, err := tx.ExecContext(ctx, "update carts set user_id = ?, updated_at = ? where id = ? and user_id is null", userID, now.Unix(), guestCartID)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			//同时登录的另一个请求已经合并了该购物车
+			return nil, err
+		}
+		cart = guest
+		merged = guest.CartItems
+	} else {
+		//用户购物车中已有的图书
+		items := map[int]*model.CartItem{}
+		for _, v := range cart.CartItems {
+			items[v.Book.ID] = v
+		}
+		for _, v := range guest.CartItems {
+			if item, ok := items[v.Book.ID]; ok {
+				//用户的购物车中已经有该图书，数量相加后删除游客的购物项
+				item.Count = item.Count + v.Count
+				if _, err := tx.ExecContext(ctx, "delete from cart_items where id = ?", v.CartItemID); err != nil {
+					return nil, err
+				}
+				merged = append(merged, item)
+				continue
+			}
+			//用户的购物车中还没有该图书，将购物项移到用户的购物车中
+			if _, err := tx.ExecContext(ctx, "update cart_items set cart_id = ? where id = ?", cart.CartID, v.CartItemID); err != nil {
+				return nil, err
+			}
+			v.CartID = cart.CartID
+			cart.CartItems = append(cart.CartItems, v)
+			merged = append(merged, v)
+		}
+		if _, err := tx.ExecContext(ctx, "delete from carts where id = ?", guestCartID); err != nil {
+			return nil, err
+		}
+	}
+	//其他购物车中还在保留期内的数量不能购买，游客购物车已经合并，也不计入
+	availableStr := "select b.stock - coalesce((select sum(ci.count) from cart_items ci where ci.book_id = b.id and ci.cart_id not in (?, ?) and ci.reserved_until > ?), 0) from books b where b.id = ?"
+	var shortages []StockShortage
+	for _, v := range merged {
+		var available int64
+		if err := tx.QueryRowContext(ctx, availableStr, cart.CartID, guestCartID, now.Unix(), v.Book.ID).Scan(&available); err != nil {
+			return nil, err
+		}
+		available = max(available, 0)
+		if v.Count > available {
+			shortages = append(shortages, StockShortage{BookID: v.Book.ID, Title: v.Book.Title, Stock: int(available), Count: v.Count})
+			v.Count = available
+		}
+		if v.Count == 0 {
+			if _, err := tx.ExecContext(ctx, "delete from cart_items where id = ?", v.CartItemID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		until, err := reservedUntil(ctx, tx, cart.CartID, v.Book.ID, v.Count, now)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, "update cart_items set count = ?, amount = ?, reserved_until = ? where id = ?", v.Count, v.GetAmount(), until, v.CartItemID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	//删除没有库存的购物项后更新用户购物车中的图书的总数量和总金额
+	var cartItems []*model.CartItem
+	for _, v := range cart.CartItems {
+		if v.Count > 0 {
+			cartItems = append(cartItems, v)
+		}
+	}
+	cart.CartItems = cartItems
+	_, err = tx.ExecContext(ctx, "update carts set total_count = ?, total_amount = ?, updated_at = ? where id = ?",
+		cart.GetTotalCount(), cart.GetTotalAmount(), now.Unix(), cart.CartID)
+	if err != nil {
+		return nil, err
+	}
+	return shortages, tx.Commit()
+}
@@ -0,0 +1,169 @@
+package dao
+
+import (
+	"bookstore/model"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGuestCart(t *testing.T) {
+	t.Run("测试合并到已有的购物车", testMergeGuestCart)
+	t.Run("测试用户没有购物车时直接归用户所有", testAdoptGuestCart)
+	t.Run("测试合并后的数量超过库存", testMergeGuestCartStock)
+	t.Run("测试清理过期的游客购物车", testDeleteExpiredGuestCarts)
+}
+
+// newMergeUser 创建一个合并购物车测试用的用户
+func newMergeUser(t *testing.T, username string) int {
+	t.Helper()
+	if err := SaveUser(t.Context(), username, "secret", username+"@example.com"); err != nil {
+		t.Fatalf("保存用户失败: %v", err)
+	}
+	user, err := CheckUserName(t.Context(), username)
+	if err != nil || user.ID == 0 {
+		t.Fatalf("获取用户%s失败: %v", username, err)
+	}
+	return user.ID
+}
+
+// newGuestCart 创建并保存一个游客购物车
+func newGuestCart(t *testing.T, cartID string, counts map[*model.Book]int64) *model.Cart {
+	t.Helper()
+	cart := &model.Cart{CartID: cartID}
+	for book, count := range counts {
+		cart.CartItems = append(cart.CartItems, &model.CartItem{Book: book, Count: count, CartID: cartID})
+	}
+	if err := AddCart(t.Context(), cart); err != nil {
+		t.Fatalf("添加游客购物车失败: %v", err)
+	}
+	cart, err := GetCartByCartID(t.Context(), cartID)
+	if err != nil || cart.UserID != 0 {
+		t.Fatalf("游客购物车的UserID应为0，实际为%+v %v", cart, err)
+	}
+	return cart
+}
+
+func testMergeGuestCart(t *testing.T) {
+	both := addCheckoutBook(t, "合并购物车两边都有", 10)
+	guestOnly := addCheckoutBook(t, "合并购物车只有游客有", 10)
+	userID := newMergeUser(t, "mergeuser")
+	newCheckoutCart(t, "merge-user-cart", userID, map[*model.Book]int64{both: 2})
+	newGuestCart(t, "merge-guest-cart", map[*model.Book]int64{both: 3, guestOnly: 1})
+	if shortages, err := MergeGuestCart(t.Context(), "merge-guest-cart", userID); err != nil || len(shortages) != 0 {
+		t.Fatalf("合并购物车失败: %v %+v", err, shortages)
+	}
+	cart, err := GetCartByUserID(t.Context(), userID)
+	if err != nil {
+		t.Fatalf("获取用户的购物车失败: %v", err)
+	}
+	if cart.CartID != "merge-user-cart" || len(cart.CartItems) != 2 {
+		t.Fatalf("应合并到用户原来的购物车中，实际为%+v", cart)
+	}
+	for _, v := range cart.CartItems {
+		want := map[int]int64{both.ID: 5, guestOnly.ID: 1}[v.Book.ID]
+		if v.Count != want || v.Amount != v.Book.Price.Mul(want) {
+			t.Errorf("《%s》的数量应为%d，实际为%d，小计为%v", v.Book.Title, want, v.Count, v.Amount)
+		}
+	}
+	if cart.TotalCount != 6 || cart.TotalAmount != model.Cents(6000) {
+		t.Errorf("合并后总数量应为6、总金额应为6000分，实际为%d和%v", cart.TotalCount, cart.TotalAmount)
+	}
+	if _, err := GetCartByCartID(t.Context(), "merge-guest-cart"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("合并后应删除游客购物车，实际为%v", err)
+	}
+	if _, err := MergeGuestCart(t.Context(), "merge-guest-cart", userID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("游客购物车不存在时应返回sql.ErrNoRows，实际为%v", err)
+	}
+}
+
+func testAdoptGuestCart(t *testing.T) {
+	book := addCheckoutBook(t, "游客购物车归用户所有", 10)
+	userID := newMergeUser(t, "adoptuser")
+	newGuestCart(t, "adopt-guest-cart", map[*model.Book]int64{book: 2})
+	if _, err := MergeGuestCart(t.Context(), "adopt-guest-cart", userID); err != nil {
+		t.Fatalf("合并购物车失败: %v", err)
+	}
+	cart, err := GetCartByUserID(t.Context(), userID)
+	if err != nil || cart.CartID != "adopt-guest-cart" || cart.TotalCount != 2 {
+		t.Fatalf("游客购物车应归用户所有，实际为%+v %v", cart, err)
+	}
+	//已经属于用户的购物车不会被合并到其他用户
+	otherID := newMergeUser(t, "adoptother")
+	if _, err := MergeGuestCart(t.Context(), "adopt-guest-cart", otherID); err != nil {
+		t.Fatalf("合并购物车失败: %v", err)
+	}
+	if cart, _ := GetCartByCartID(t.Context(), "adopt-guest-cart"); cart.UserID != userID {
+		t.Errorf("用户的购物车不应被其他用户合并，实际属于%d", cart.UserID)
+	}
+}
+
+func testMergeGuestCartStock(t *testing.T) {
+	clamped := addCheckoutBook(t, "合并后超过库存", 4)
+	soldOut := addCheckoutBook(t, "合并时已经售完", 1)
+	newGuestCart(t, "stock-guest-cart", map[*model.Book]int64{clamped: 3, soldOut: 1})
+	//游客购物车的保留已经过期
+	if _, err := ReleaseExpiredReservations(t.Context(), time.Now().Add(conf.Cart.ReservationTTL.Duration+time.Second)); err != nil {
+		t.Fatalf("释放过期的保留失败: %v", err)
+	}
+	//其他用户保留了每本图书各1本
+	newCheckoutCart(t, "stock-other-cart", newMergeUser(t, "stockother"), map[*model.Book]int64{clamped: 1, soldOut: 1})
+	userID := newMergeUser(t, "stockuser")
+	newCheckoutCart(t, "stock-user-cart", userID, map[*model.Book]int64{clamped: 2})
+	shortages, err := MergeGuestCart(t.Context(), "stock-guest-cart", userID)
+	if err != nil {
+		t.Fatalf("合并购物车失败: %v", err)
+	}
+	want := map[int]StockShortage{
+		clamped.ID: {BookID: clamped.ID, Title: clamped.Title, Stock: 3, Count: 5},
+		soldOut.ID: {BookID: soldOut.ID, Title: soldOut.Title, Stock: 0, Count: 1},
+	}
+	if len(shortages) != len(want) {
+		t.Fatalf("应返回%d个数量被调整的购物项，实际为%+v", len(want), shortages)
+	}
+	for _, v := range shortages {
+		if v != want[v.BookID] {
+			t.Errorf("数量被调整的购物项应为%+v，实际为%+v", want[v.BookID], v)
+		}
+	}
+	cart, err := GetCartByUserID(t.Context(), userID)
+	if err != nil || len(cart.CartItems) != 1 || cart.CartItems[0].Count != 3 {
+		t.Fatalf("没有库存的购物项应被删除，其余数量限制在库存以内，实际为%+v %v", cart, err)
+	}
+	if cart.TotalCount != 3 || cart.TotalAmount != model.Cents(3000) {
+		t.Errorf("总数量应为3、总金额应为3000分，实际为%d和%v", cart.TotalCount, cart.TotalAmount)
+	}
+	//合并后重新开始保留库存
+	if n, _ := AvailableStock(t.Context(), clamped.ID, "", time.Now()); n != 0 {
+		t.Errorf("合并后的数量应重新被保留，剩余可以购买的库存应为0，实际为%d", n)
+	}
+}
+
+func testDeleteExpiredGuestCarts(t *testing.T) {
+	book := addCheckoutBook(t, "过期的游客购物车", 10)
+	newGuestCart(t, "expired-guest-cart", map[*model.Book]int64{book: 1})
+	userID := newMergeUser(t, "expireuser")
+	newCheckoutCart(t, "expire-user-cart", userID, map[*model.Book]int64{book: 1})
+	//还没有过期的游客购物车不会被删除
+	if _, err := DeleteExpiredGuestCarts(t.Context(), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("清理游客购物车失败: %v", err)
+	}
+	if _, err := GetCartByCartID(t.Context(), "expired-guest-cart"); err != nil {
+		t.Fatalf("还没有过期的游客购物车不应被删除: %v", err)
+	}
+	n, err := DeleteExpiredGuestCarts(t.Context(), time.Now().Add(time.Hour))
+	if err != nil || n < 1 {
+		t.Fatalf("应删除过期的游客购物车，实际删除了%d个 %v", n, err)
+	}
+	if _, err := GetCartByCartID(t.Context(), "expired-guest-cart"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("过期的游客购物车应被删除，实际为%v", err)
+	}
+	if items, _ := GetCartItemsByCartID(t.Context(), "expired-guest-cart"); len(items) != 0 {
+		t.Errorf("过期的游客购物车中的购物项应被删除")
+	}
+	//用户的购物车不会因为长期没有修改而被删除
+	if _, err := GetCartByCartID(t.Context(), "expire-user-cart"); err != nil {
+		t.Errorf("用户的购物车不应被删除: %v", err)
+	}
+}
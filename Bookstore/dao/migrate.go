@@ -0,0 +1,346 @@
+package dao
+
+import (
+	"bookstore/utils"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles 每种数据库各自的迁移脚本，文件名格式为 版本号_名称.up.sql 和 版本号_名称.down.sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration 一次数据库表结构的变更，版本号从1开始连续编号
+type Migration struct {
+	Version int
+	Name    string
+	Up      string //升级时执行的sql语句
+	Down    string //回退时执行的sql语句
+}
+
+// MigrationStatus 一次变更在数据库中的执行情况
+type MigrationStatus struct {
+	*Migration
+	Applied   bool
+	AppliedAt string
+}
+
+// SchemaVersionError 数据库的版本低于程序需要的版本
+type SchemaVersionError struct {
+	Current  int
+	Required int
+}
+
+func (e *SchemaVersionError) Error() string {
+	return fmt.Sprintf("数据库的版本为%d，程序需要版本%d，请先执行 migrate up", e.Current, e.Required)
+}
+
+// Migrator 根据schema_migrations表中的记录升级或回退数据库的表结构
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+}
+
+// NewMigrator 创建数据库的迁移工具，并在需要时创建schema_migrations表
+func NewMigrator(db *sql.DB, driver string) (*Migrator, error) {
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return nil, err
+	}
+	m := &Migrator{db: db, migrations: migrations}
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// loadMigrations 读取驱动对应的所有迁移脚本，并按版本号排序
+func loadMigrations(driver string) ([]*Migration, error) {
+	if driver != utils.DriverMySQL && driver != utils.DriverSQLite {
+		return nil, fmt.Errorf("不支持的数据库驱动: %q", driver)
+	}
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		var up bool
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			up = true
+		case strings.HasSuffix(name, ".down.sql"):
+		default:
+			return nil, fmt.Errorf("迁移脚本%s的文件名不正确", name)
+		}
+		prefix, rest, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("迁移脚本%s的版本号不正确", name)
+		}
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+		if up {
+			m.Name = strings.TrimSuffix(rest, ".up.sql")
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("缺少版本%d的迁移脚本", i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("版本%d的迁移脚本必须同时包含up和down", m.Version)
+		}
+	}
+	return migrations, nil
+}
+
+// legacyProbes 旧的sql.sql在被迁移脚本取代之前陆续加入了版本3到6的变更，但一直没有版本2的下单时间，
+// 没有版本记录的数据库按照这些表和列判断由哪个版本的sql.sql创建
+var legacyProbes = []struct {
+	Version int
+	Table   string
+	Column  string
+}{
+	{2, "orders", "create_time"},
+	{3, "sessions", "expires_at"},
+	{4, "users", "role"},
+	{5, "order_history", "id"},
+	{6, "order_items", "book_id"},
+}
+
+// init 创建schema_migrations表。由旧的sql.sql创建的数据库没有版本记录，根据已有的表结构判断版本：
+// 记录已经包含的版本，缺少的版本2在此补上，之后的版本由Up执行；表结构与任何一个版本的sql.sql都不一致时返回错误
+func (m *Migrator) init() error {
+	//写sql语句
+	sqlStr := "create table if not exists schema_migrations(version int primary key, name varchar(100) not null, applied_at varchar(20) not null)"
+	if _, err := m.db.Exec(sqlStr); err != nil {
+		return err
+	}
+	version, err := m.Version()
+	if err != nil || version > 0 {
+		return err
+	}
+	var count int
+	if err := m.db.QueryRow("select count(*) from users").Scan(&count); err != nil {
+		//users表不存在，是一个新的数据库
+		return nil
+	}
+	//已有的最新的变更决定版本
+	version = 1
+	present := map[int]bool{}
+	for _, p := range legacyProbes {
+		if m.hasColumn(p.Table, p.Column) {
+			present[p.Version] = true
+			version = p.Version
+		}
+	}
+	//该版本之前的变更都应该已经包含，只有版本2可能缺少
+	for _, p := range legacyProbes {
+		if p.Version > 2 && p.Version < version && !present[p.Version] {
+			return fmt.Errorf("数据库没有版本记录，已有版本%d的变更却缺少%s表的%s列，表结构与旧的sql.sql的任何版本都不一致，"+
+				"请检查后在schema_migrations表中手动记录已经执行的版本", version, p.Table, p.Column)
+		}
+	}
+	createTime := present[2]
+	now := time.Now()
+	for _, migration := range m.migrations[:version] {
+		if migration.Version == 2 && !createTime {
+			//旧的sql.sql没有下单时间，先补上再记录之后的版本
+			if err := m.apply(migration, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := m.record(m.db, migration, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasColumn 判断表和列是否存在
+func (m *Migrator) hasColumn(table string, column string) bool {
+	rows, err := m.db.Query("select " + column + " from " + table + " where 1 = 0")
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+// Latest 获取程序需要的数据库版本，即最新的版本
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version 获取数据库当前的版本，没有执行过任何迁移时为0
+func (m *Migrator) Version() (int, error) {
+	var version int
+	err := m.db.QueryRow("select coalesce(max(version), 0) from schema_migrations").Scan(&version)
+	return version, err
+}
+
+// Check 检查数据库的版本，低于程序需要的版本时返回*SchemaVersionError
+func (m *Migrator) Check() error {
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if version < m.Latest() {
+		return &SchemaVersionError{Current: version, Required: m.Latest()}
+	}
+	return nil
+}
+
+// Up 依次执行所有还没有执行的迁移，返回本次执行的迁移
+func (m *Migrator) Up() ([]*Migration, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	var applied []*Migration
+	if version >= m.Latest() {
+		return applied, nil
+	}
+	for _, migration := range m.migrations[version:] {
+		if err := m.apply(migration, true); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// Down 从当前版本开始依次回退steps个版本，返回本次回退的迁移
+func (m *Migrator) Down(steps int) ([]*Migration, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version > m.Latest() {
+		return nil, fmt.Errorf("数据库的版本%d高于程序支持的版本%d，不能回退", version, m.Latest())
+	}
+	var reverted []*Migration
+	for ; steps > 0 && version > 0; steps, version = steps-1, version-1 {
+		migration := m.migrations[version-1]
+		if err := m.apply(migration, false); err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, migration)
+	}
+	return reverted, nil
+}
+
+// Status 获取每个迁移的执行情况
+func (m *Migrator) Status() ([]*MigrationStatus, error) {
+	rows, err := m.db.Query("select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	appliedAt := map[int]string{}
+	for rows.Next() {
+		var version int
+		var at string
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var statuses []*MigrationStatus
+	for _, migration := range m.migrations {
+		at, ok := appliedAt[migration.Version]
+		statuses = append(statuses, &MigrationStatus{Migration: migration, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// apply 在一个事务中执行迁移并更新版本记录，
+// MySQL的DDL语句会隐式提交事务，失败时需要根据错误信息手动修复
+func (m *Migrator) apply(migration *Migration, up bool) error {
+	script := migration.Down
+	if up {
+		script = migration.Up
+	}
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range splitStatements(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("执行迁移%04d_%s失败: %w", migration.Version, migration.Name, err)
+		}
+	}
+	if up {
+		err = m.record(tx, migration, time.Now())
+	} else {
+		_, err = tx.Exec("delete from schema_migrations where version = ?", migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execer 可以执行sql语句的数据库连接或事务
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// record 记录已经执行的迁移
+func (m *Migrator) record(db execer, migration *Migration, now time.Time) error {
+	//写sql语句
+	sqlStr := "insert into schema_migrations(version,name,applied_at) values(?,?,?)"
+	_, err := db.Exec(sqlStr, migration.Version, migration.Name, now.Format("2006-01-02 15:04:05"))
+	return err
+}
+
+// splitStatements 将脚本拆分为单独的sql语句，MySQL驱动默认不支持一次执行多条语句，
+// 以--开头的注释行会被去掉，脚本的注释和字符串中不能包含分号
+func splitStatements(script string) []string {
+	var b strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	var stmts []string
+	for _, stmt := range strings.Split(b.String(), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
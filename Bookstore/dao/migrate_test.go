@@ -0,0 +1,229 @@
+package dao
+
+import (
+	"bookstore/model"
+	"bookstore/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// openMigrateDB 打开一个新的内存数据库，与其他测试使用的数据库互不影响
+func openMigrateDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(utils.DriverSQLite, ":memory:")
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLoadMigrations(t *testing.T) {
+	mysql, err := loadMigrations(utils.DriverMySQL)
+	if err != nil {
+		t.Fatalf("读取MySQL的迁移脚本失败: %v", err)
+	}
+	sqlite, err := loadMigrations(utils.DriverSQLite)
+	if err != nil {
+		t.Fatalf("读取SQLite的迁移脚本失败: %v", err)
+	}
+	if len(mysql) != len(sqlite) {
+		t.Fatalf("MySQL和SQLite的迁移版本数应一致，实际为%d和%d", len(mysql), len(sqlite))
+	}
+	for i := range mysql {
+		if mysql[i].Name != sqlite[i].Name {
+			t.Errorf("版本%d的名称不一致: %s和%s", mysql[i].Version, mysql[i].Name, sqlite[i].Name)
+		}
+	}
+	if _, err := loadMigrations("oracle"); err == nil {
+		t.Error("不支持的驱动应返回错误")
+	}
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	db := openMigrateDB(t)
+	m, err := NewMigrator(db, utils.DriverSQLite)
+	if err != nil {
+		t.Fatalf("创建迁移工具失败: %v", err)
+	}
+	var versionErr *SchemaVersionError
+	if err := m.Check(); !errors.As(err, &versionErr) || versionErr.Current != 0 {
+		t.Fatalf("新的数据库应返回SchemaVersionError，实际为%v", err)
+	}
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatalf("升级失败: %v", err)
+	}
+	if len(applied) != m.Latest() {
+		t.Errorf("应执行%d个迁移，实际为%d个", m.Latest(), len(applied))
+	}
+	if err := m.Check(); err != nil {
+		t.Errorf("升级后版本检查应通过，实际为%v", err)
+	}
+	if applied, _ := m.Up(); len(applied) != 0 {
+		t.Errorf("已经是最新版本时不应再执行迁移，实际执行了%d个", len(applied))
+	}
+	//升级后的表结构可以正常使用
+	store := newSQLStore(db, utils.DriverSQLite)
+	if user, _ := store.Users.CheckUserName(t.Context(), "admin"); user.ID != 0 {
+		t.Errorf("不应内置管理员，实际为%+v", user)
+	}
+
+	reverted, err := m.Down(1)
+	if err != nil {
+		t.Fatalf("回退失败: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != m.Latest() {
+		t.Errorf("应回退最新的版本，实际为%+v", reverted)
+	}
+	if err := m.Check(); !errors.As(err, &versionErr) || versionErr.Current != m.Latest()-1 {
+		t.Errorf("回退后版本检查应失败，实际为%v", err)
+	}
+	statuses, _ := m.Status()
+	for _, s := range statuses {
+		if s.Applied != (s.Version < m.Latest()) {
+			t.Errorf("版本%d的执行情况不正确: %+v", s.Version, s)
+		}
+	}
+	//全部回退后可以重新升级
+	if _, err := m.Down(m.Latest()); err != nil {
+		t.Fatalf("全部回退失败: %v", err)
+	}
+	if version, _ := m.Version(); version != 0 {
+		t.Errorf("全部回退后版本应为0，实际为%d", version)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("重新升级失败: %v", err)
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	db := openMigrateDB(t)
+	//模拟由旧的sql.sql创建的数据库，只执行第一个迁移且没有版本记录
+	execMigrations(t, db, 1)
+	if _, err := db.Exec("insert into orders(id,total_count,total_amount,state,user_id) values('legacy-order',1,27.2,1,1)"); err != nil {
+		t.Fatalf("插入旧的订单失败: %v", err)
+	}
+	if _, err := db.Exec("insert into carts(id,total_count,total_amount,user_id) values('legacy-cart',2,27.2,1)"); err != nil {
+		t.Fatalf("插入旧的购物车失败: %v", err)
+	}
+	if _, err := db.Exec("insert into cart_items(count,amount,book_id,cart_id) values(2,27.2,1,'legacy-cart')"); err != nil {
+		t.Fatalf("插入旧的购物项失败: %v", err)
+	}
+	m, err := NewMigrator(db, utils.DriverSQLite)
+	if err != nil {
+		t.Fatalf("创建迁移工具失败: %v", err)
+	}
+	if version, _ := m.Version(); version != 1 {
+		t.Fatalf("旧的数据库应视为版本1，实际为%d", version)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("升级旧的数据库失败: %v", err)
+	}
+	order, err := newSQLStore(db, utils.DriverSQLite).Orders.GetOrderByID(t.Context(), "legacy-order")
+	if err != nil || order.CreateTime == "" {
+		t.Fatalf("旧的订单应补充下单时间，实际为%+v %v", order, err)
+	}
+	//旧版本的1为已发货
+	if order.State != model.OrderStateShipped {
+		t.Errorf("旧的订单状态应重新编号为已发货，实际为%s", order.StateName())
+	}
+	//以小数保存的金额转换为以分为单位的整数
+	if order.TotalAmount != model.Cents(2720) {
+		t.Errorf("旧的订单金额应转换为2720分，实际为%d分", order.TotalAmount.Minor)
+	}
+	//重建购物车表时保留已有的购物车和购物项，并根据金额小计推算加入时的单价
+	cart, err := newSQLStore(db, utils.DriverSQLite).Carts.GetCartByUserID(t.Context(), 1)
+	if err != nil || cart.CartID != "legacy-cart" || len(cart.CartItems) != 1 || cart.CartItems[0].Price != model.Cents(1360) {
+		t.Errorf("旧的购物车应保留，实际为%+v %v", cart, err)
+	}
+}
+
+// execMigrations 不记录版本，直接执行指定版本的升级脚本，模拟由旧的sql.sql创建的数据库
+func execMigrations(t *testing.T, db *sql.DB, versions ...int) {
+	t.Helper()
+	migrations, _ := loadMigrations(utils.DriverSQLite)
+	for _, v := range versions {
+		for _, stmt := range splitStatements(migrations[v-1].Up) {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatalf("执行版本%d的脚本失败: %v", v, err)
+			}
+		}
+	}
+}
+
+func TestMigrateLegacySnapshots(t *testing.T) {
+	//后来的sql.sql包含了版本3到6的变更，但没有版本2的下单时间
+	db := openMigrateDB(t)
+	execMigrations(t, db, 1, 3, 4, 5, 6)
+	m, err := NewMigrator(db, utils.DriverSQLite)
+	if err != nil {
+		t.Fatalf("创建迁移工具失败: %v", err)
+	}
+	if version, _ := m.Version(); version != 6 {
+		t.Fatalf("数据库应视为版本6，实际为%d", version)
+	}
+	if !m.hasColumn("orders", "create_time") {
+		t.Errorf("应补上版本2的下单时间")
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("升级失败: %v", err)
+	}
+
+	//与任何一个版本的sql.sql都不一致时拒绝猜测
+	db = openMigrateDB(t)
+	execMigrations(t, db, 1, 4)
+	if _, err := NewMigrator(db, utils.DriverSQLite); err == nil {
+		t.Errorf("缺少版本3的变更时应返回错误")
+	}
+}
+
+func TestMigrateDownOrderStates(t *testing.T) {
+	db := openMigrateDB(t)
+	m, _ := NewMigrator(db, utils.DriverSQLite)
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("升级失败: %v", err)
+	}
+	store := newSQLStore(db, utils.DriverSQLite)
+	//每个状态回退后在旧版本中的状态：0-未发货, 1-已发货, 2-交易完成
+	legacy := map[int64]int64{
+		model.OrderStatePendingPayment: 0,
+		model.OrderStatePaid:           0,
+		model.OrderStateShipped:        1,
+		model.OrderStateDelivered:      1,
+		model.OrderStateCompleted:      2,
+		model.OrderStateCancelled:      2,
+		model.OrderStateRefunded:       2,
+		//不认识的状态保持不变，回退不会因为NOT NULL约束而中断
+		99: 99,
+	}
+	for state := range legacy {
+		order := &model.Order{OrderID: fmt.Sprintf("down-order-%d", state), CreateTime: "2024-01-01 00:00:00", TotalCount: 1, TotalAmount: model.Cents(100), State: state, UserID: 1}
+		if err := store.Orders.AddOrder(t.Context(), order); err != nil {
+			t.Fatalf("添加订单失败: %v", err)
+		}
+	}
+	//回退到订单状态重新编号之前的版本
+	if _, err := m.Down(m.Latest() - 4); err != nil {
+		t.Fatalf("回退失败: %v", err)
+	}
+	for state, want := range legacy {
+		var got int64
+		if err := db.QueryRow("select state from orders where id = ?", fmt.Sprintf("down-order-%d", state)).Scan(&got); err != nil {
+			t.Fatalf("查询订单失败: %v", err)
+		}
+		if got != want {
+			t.Errorf("%s的订单回退后的状态应为%d，实际为%d", model.OrderStateName(state), want, got)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	stmts := splitStatements("-- 注释\nCREATE TABLE a(id INT); \n\n-- 另一个注释\nINSERT INTO a VALUES(1);\n")
+	if len(stmts) != 2 || stmts[0] != "CREATE TABLE a(id INT)" || stmts[1] != "INSERT INTO a VALUES(1)" {
+		t.Errorf("拆分结果不正确: %q", stmts)
+	}
+}
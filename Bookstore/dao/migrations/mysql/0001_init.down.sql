@@ -0,0 +1,8 @@
+-- 按照依赖关系的相反顺序删除所有的表
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS cart_items;
+DROP TABLE IF EXISTS carts;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS books;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,7 @@
+-- dao.AddOrder和结账都会写入下单时间，但最早的sql.sql中没有这一列
+ALTER TABLE orders ADD COLUMN create_time DATETIME NULL AFTER id;
+
+-- 已有的订单无法得知下单时间，以升级的时间代替
+UPDATE orders SET create_time = NOW() WHERE create_time IS NULL;
+
+ALTER TABLE orders MODIFY create_time DATETIME NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE sessions
+    DROP INDEX idx_sessions_expires_at,
+    DROP COLUMN created_at,
+    DROP COLUMN last_seen_at,
+    DROP COLUMN expires_at;
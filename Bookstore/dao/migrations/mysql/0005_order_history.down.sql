@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS order_history;
+
+-- 旧版本只有 0-未发货, 1-已发货, 2-交易完成：待付款和已付款按未发货、已送达按已发货回退，
+-- 已取消和已退款的订单不会再发货，按交易完成回退，避免回退后重新出现在待发货的订单中，
+-- 其他不认识的状态保持不变，不能变为NULL
+UPDATE orders SET state = CASE state
+    WHEN 0 THEN 0
+    WHEN 1 THEN 0
+    WHEN 2 THEN 1
+    WHEN 4 THEN 1
+    WHEN 3 THEN 2
+    WHEN 5 THEN 2
+    WHEN 6 THEN 2
+    ELSE state
+END;
@@ -0,0 +1,16 @@
+-- 订单状态重新编号：0-待付款, 1-已付款, 2-已发货, 3-已完成, 4-已送达, 5-已取消, 6-已退款
+-- 旧版本按 0-未发货, 1-已发货, 2-交易完成 保存订单状态
+UPDATE orders SET state = state + 1;
+
+-- 订单状态变更记录表（依赖orders表和users表）
+CREATE TABLE IF NOT EXISTS order_history(
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    order_id VARCHAR(100) NOT NULL,
+    from_state INT,           -- 变更前的状态，订单创建时为空
+    to_state INT NOT NULL,    -- 变更后的状态
+    actor_id INT,             -- 操作人，系统自动变更时为空
+    create_time DATETIME NOT NULL,
+    INDEX idx_order_history_order_id (order_id),
+    FOREIGN KEY(order_id) REFERENCES orders(id),
+    FOREIGN KEY(actor_id) REFERENCES users(id)
+);
@@ -0,0 +1,14 @@
+ALTER TABLE books MODIFY price DOUBLE(11,2) NOT NULL;
+UPDATE books SET price = price / 100;
+
+ALTER TABLE carts MODIFY total_amount DOUBLE(11,2) NOT NULL;
+UPDATE carts SET total_amount = total_amount / 100;
+
+ALTER TABLE cart_items MODIFY amount DOUBLE(11,2) NOT NULL;
+UPDATE cart_items SET amount = amount / 100;
+
+ALTER TABLE orders MODIFY total_amount DOUBLE(11,2) NOT NULL;
+UPDATE orders SET total_amount = total_amount / 100;
+
+ALTER TABLE order_items MODIFY amount DOUBLE(11,2) NOT NULL, MODIFY price DOUBLE(11,2) NOT NULL;
+UPDATE order_items SET amount = amount / 100, price = price / 100;
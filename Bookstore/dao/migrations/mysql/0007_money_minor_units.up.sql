@@ -0,0 +1,22 @@
+-- 金额改为以分为单位的整数保存，避免DOUBLE的舍入误差，原来的金额四舍五入到分
+ALTER TABLE books ADD COLUMN price_minor BIGINT NOT NULL DEFAULT 0 AFTER price;
+UPDATE books SET price_minor = ROUND(price * 100);
+ALTER TABLE books DROP COLUMN price, CHANGE price_minor price BIGINT NOT NULL;
+
+ALTER TABLE carts ADD COLUMN total_amount_minor BIGINT NOT NULL DEFAULT 0 AFTER total_amount;
+UPDATE carts SET total_amount_minor = ROUND(total_amount * 100);
+ALTER TABLE carts DROP COLUMN total_amount, CHANGE total_amount_minor total_amount BIGINT NOT NULL;
+
+ALTER TABLE cart_items ADD COLUMN amount_minor BIGINT NOT NULL DEFAULT 0 AFTER amount;
+UPDATE cart_items SET amount_minor = ROUND(amount * 100);
+ALTER TABLE cart_items DROP COLUMN amount, CHANGE amount_minor amount BIGINT NOT NULL;
+
+ALTER TABLE orders ADD COLUMN total_amount_minor BIGINT NOT NULL DEFAULT 0 AFTER total_amount;
+UPDATE orders SET total_amount_minor = ROUND(total_amount * 100);
+ALTER TABLE orders DROP COLUMN total_amount, CHANGE total_amount_minor total_amount BIGINT NOT NULL;
+
+ALTER TABLE order_items ADD COLUMN amount_minor BIGINT NOT NULL DEFAULT 0 AFTER amount,
+    ADD COLUMN price_minor BIGINT NOT NULL DEFAULT 0 AFTER price;
+UPDATE order_items SET amount_minor = ROUND(amount * 100), price_minor = ROUND(price * 100);
+ALTER TABLE order_items DROP COLUMN amount, DROP COLUMN price,
+    CHANGE amount_minor amount BIGINT NOT NULL, CHANGE price_minor price BIGINT NOT NULL;
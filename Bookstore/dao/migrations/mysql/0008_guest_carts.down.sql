@@ -0,0 +1,7 @@
+-- 回退前删除所有游客的购物车
+DELETE FROM cart_items WHERE cart_id IN (SELECT id FROM carts WHERE user_id IS NULL);
+DELETE FROM carts WHERE user_id IS NULL;
+ALTER TABLE carts
+    DROP INDEX idx_carts_updated_at,
+    DROP COLUMN updated_at;
+ALTER TABLE carts MODIFY user_id INT NOT NULL;
@@ -0,0 +1,6 @@
+-- 没有登录的用户也可以使用购物车，游客的购物车没有用户
+ALTER TABLE carts MODIFY user_id INT NULL;
+-- 购物车最后修改的时间，Unix时间戳，长期没有修改的游客购物车会被后台清理
+ALTER TABLE carts
+    ADD COLUMN updated_at BIGINT NOT NULL DEFAULT 0,
+    ADD INDEX idx_carts_updated_at(updated_at);
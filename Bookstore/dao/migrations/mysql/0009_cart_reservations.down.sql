@@ -0,0 +1 @@
+ALTER TABLE cart_items DROP COLUMN reserved_until;
@@ -0,0 +1,2 @@
+-- 购物项保留库存的截止时间，Unix时间戳，为0或已经过去时没有保留库存
+ALTER TABLE cart_items ADD COLUMN reserved_until BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- 加入购物车时图书的单价，与当前价格不同时提示用户价格已经变化
+ALTER TABLE cart_items ADD COLUMN price BIGINT NOT NULL DEFAULT 0;
+-- 已有的购物项根据保存的金额小计推算加入时的单价
+UPDATE cart_items SET price = amount DIV count WHERE count > 0;
@@ -0,0 +1,16 @@
+-- 用户的收货地址簿，一个用户可以保存多个地址（依赖users表）
+CREATE TABLE IF NOT EXISTS addresses(
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    recipient VARCHAR(50) NOT NULL,   -- 收货人
+    phone VARCHAR(20) NOT NULL,       -- 联系电话
+    address VARCHAR(200) NOT NULL,    -- 详细地址
+    created_at BIGINT NOT NULL,       -- 添加的时间（Unix时间戳）
+    INDEX idx_addresses_user_id (user_id),
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+-- 下单时收货地址的快照，之后修改或删除地址簿中的地址不影响已有的订单，旧的订单为空
+ALTER TABLE orders ADD COLUMN ship_recipient VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN ship_phone VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN ship_address VARCHAR(200) NOT NULL DEFAULT '';
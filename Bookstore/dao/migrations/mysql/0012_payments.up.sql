@@ -0,0 +1,14 @@
+-- 订单的支付记录，支付失败后可以重新支付，因此一个订单可以有多条记录（依赖orders表）
+CREATE TABLE IF NOT EXISTS payments(
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    order_id VARCHAR(100) NOT NULL,
+    provider VARCHAR(20) NOT NULL,                  -- 支付网关的名称
+    provider_ref VARCHAR(100) NULL DEFAULT NULL,    -- 网关中的支付号，请求授权之后才有，之前为NULL，不受唯一索引的限制
+    amount BIGINT NOT NULL,                         -- 支付的金额，单位为分
+    status VARCHAR(20) NOT NULL,                    -- pending待付款，authorized已授权，captured已扣款，failed失败，refunded已退款，reconcile待对账
+    created_at BIGINT NOT NULL,                     -- 创建的时间（Unix时间戳）
+    updated_at BIGINT NOT NULL,                     -- 最后一次变更状态的时间（Unix时间戳）
+    INDEX idx_payments_order_id (order_id),
+    UNIQUE INDEX uk_payments_provider_ref (provider, provider_ref),
+    FOREIGN KEY(order_id) REFERENCES orders(id)
+);
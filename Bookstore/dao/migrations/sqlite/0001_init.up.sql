@@ -0,0 +1,83 @@
+-- 初始的表结构，与MySQL的初始版本一致，之后的变更都在后续版本中完成
+
+-- 1. 用户表
+CREATE TABLE IF NOT EXISTS users(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) NOT NULL UNIQUE,
+    password VARCHAR(100) NOT NULL,
+    email VARCHAR(100) NOT NULL UNIQUE
+);
+
+-- 密码分别为 password123、password456、password789
+INSERT OR IGNORE INTO users (id, username, password, email) VALUES
+(1, 'user1', '$2a$10$BpK8xSbNhYcvGpnV2c2wsu4L6MZmalj8.JIf/tRWgqBGBaripHNJG', 'user1@example.com'),
+(2, 'user2', '$2a$10$gUsOEoQRHe1O0X6uYNdlEe10PSqzlXhyvEzOiQQyq5WpHkzmvC0uq', 'user2@example.com'),
+(3, 'user3', '$2a$10$5JvUiQYqEiBpaEpouLMK8u/lRSe5BZa4ddCEdBgoFX0yhOUqTJ5j6', 'user3@example.com');
+
+-- 2. 图书表
+CREATE TABLE IF NOT EXISTS books(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    author VARCHAR(100) NOT NULL,
+    price DOUBLE(11,2) NOT NULL,
+    sales INT NOT NULL,
+    stock INT NOT NULL,
+    img_path VARCHAR(100)
+);
+
+INSERT OR IGNORE INTO books (id, title, author, price, sales, stock, img_path) VALUES
+(1, 'Go编程实战', 'John Smith', 89.00, 2, 100, '/static/img/Go.jpg'),
+(2, 'Python数据分析', 'Jane Doe', 79.00, 2, 80, '/static/img/python.jpg'),
+(3, 'MySQL从入门到精通', 'Michael Brown', 69.00, 2, 120, '/static/img/mysql.jpg'),
+(4, 'JavaScript高级程序设计', 'David Wilson', 99.00, 2, 70, '/static/img/js.jpg');
+
+-- 3. 会话表
+CREATE TABLE IF NOT EXISTS sessions(
+    session_id VARCHAR(100) PRIMARY KEY,
+    username VARCHAR(100) NOT NULL,
+    user_id INT NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+-- 4. 购物车表
+CREATE TABLE IF NOT EXISTS carts(
+    id VARCHAR(100) PRIMARY KEY,
+    total_count INT NOT NULL,
+    total_amount DOUBLE(11,2) NOT NULL,
+    user_id INT NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+-- 5. 购物项表
+CREATE TABLE IF NOT EXISTS cart_items(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    count INT NOT NULL,
+    amount DOUBLE(11,2) NOT NULL,
+    book_id INT NOT NULL,
+    cart_id VARCHAR(100) NOT NULL,
+    FOREIGN KEY(book_id) REFERENCES books(id),
+    FOREIGN KEY(cart_id) REFERENCES carts(id)
+);
+
+-- 6. 订单表
+CREATE TABLE IF NOT EXISTS orders(
+    id VARCHAR(100) PRIMARY KEY,
+    total_count INT NOT NULL,
+    total_amount DOUBLE(11,2) NOT NULL,
+    state INT NOT NULL,
+    user_id INT,
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+-- 7. 订单项表
+CREATE TABLE IF NOT EXISTS order_items(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    count INT NOT NULL,
+    amount DOUBLE(11,2) NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    author VARCHAR(100) NOT NULL,
+    price DOUBLE(11,2) NOT NULL,
+    img_path VARCHAR(100) NOT NULL,
+    order_id VARCHAR(100) NOT NULL,
+    FOREIGN KEY(order_id) REFERENCES orders(id)
+);
@@ -0,0 +1,6 @@
+-- dao.AddOrder和结账都会写入下单时间，但最早的表结构中没有这一列
+-- 以文本保存时间，读取结果与MySQL的DATETIME一致
+ALTER TABLE orders ADD COLUMN create_time VARCHAR(20) NOT NULL DEFAULT '';
+
+-- 已有的订单无法得知下单时间，以升级的时间代替
+UPDATE orders SET create_time = strftime('%Y-%m-%d %H:%M:%S', 'now', 'localtime') WHERE create_time = '';
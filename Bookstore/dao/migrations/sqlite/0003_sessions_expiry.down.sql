@@ -0,0 +1,6 @@
+-- SQLite不能删除带索引的列，先删除索引
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+
+ALTER TABLE sessions DROP COLUMN created_at;
+ALTER TABLE sessions DROP COLUMN last_seen_at;
+ALTER TABLE sessions DROP COLUMN expires_at;
@@ -0,0 +1,7 @@
+-- Session的创建时间、最后访问时间和过期时间，均为Unix时间戳
+-- 已有的Session过期时间为0，会被后台清理
+ALTER TABLE sessions ADD COLUMN created_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN last_seen_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN expires_at BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
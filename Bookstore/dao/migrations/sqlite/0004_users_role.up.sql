@@ -0,0 +1,4 @@
+-- 角色：customer 顾客、staff 店员、admin 管理员
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'customer';
+
+-- 不再内置管理员账号，部署后通过 create-admin 命令创建第一个管理员
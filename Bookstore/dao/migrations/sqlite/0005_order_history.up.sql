@@ -0,0 +1,18 @@
+-- 订单状态重新编号：0-待付款, 1-已付款, 2-已发货, 3-已完成, 4-已送达, 5-已取消, 6-已退款
+-- 旧版本按 0-未发货, 1-已发货, 2-交易完成 保存订单状态
+UPDATE orders SET state = state + 1;
+
+-- 订单状态变更记录表
+CREATE TABLE IF NOT EXISTS order_history(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id VARCHAR(100) NOT NULL,
+    -- 订单创建时为空
+    from_state INT,
+    to_state INT NOT NULL,
+    -- 系统自动变更时为空
+    actor_id INT,
+    create_time VARCHAR(20) NOT NULL,
+    FOREIGN KEY(order_id) REFERENCES orders(id),
+    FOREIGN KEY(actor_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_order_history_order_id ON order_history(order_id);
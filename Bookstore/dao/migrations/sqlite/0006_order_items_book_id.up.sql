@@ -0,0 +1,2 @@
+-- 取消订单时据此恢复库存，旧的订单项为空
+ALTER TABLE order_items ADD COLUMN book_id INT;
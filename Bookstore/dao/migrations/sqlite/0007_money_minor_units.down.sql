@@ -0,0 +1,27 @@
+ALTER TABLE books ADD COLUMN price_decimal DOUBLE(11,2) NOT NULL DEFAULT 0;
+UPDATE books SET price_decimal = price / 100.0;
+ALTER TABLE books DROP COLUMN price;
+ALTER TABLE books RENAME COLUMN price_decimal TO price;
+
+ALTER TABLE carts ADD COLUMN total_amount_decimal DOUBLE(11,2) NOT NULL DEFAULT 0;
+UPDATE carts SET total_amount_decimal = total_amount / 100.0;
+ALTER TABLE carts DROP COLUMN total_amount;
+ALTER TABLE carts RENAME COLUMN total_amount_decimal TO total_amount;
+
+ALTER TABLE cart_items ADD COLUMN amount_decimal DOUBLE(11,2) NOT NULL DEFAULT 0;
+UPDATE cart_items SET amount_decimal = amount / 100.0;
+ALTER TABLE cart_items DROP COLUMN amount;
+ALTER TABLE cart_items RENAME COLUMN amount_decimal TO amount;
+
+ALTER TABLE orders ADD COLUMN total_amount_decimal DOUBLE(11,2) NOT NULL DEFAULT 0;
+UPDATE orders SET total_amount_decimal = total_amount / 100.0;
+ALTER TABLE orders DROP COLUMN total_amount;
+ALTER TABLE orders RENAME COLUMN total_amount_decimal TO total_amount;
+
+ALTER TABLE order_items ADD COLUMN amount_decimal DOUBLE(11,2) NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN price_decimal DOUBLE(11,2) NOT NULL DEFAULT 0;
+UPDATE order_items SET amount_decimal = amount / 100.0, price_decimal = price / 100.0;
+ALTER TABLE order_items DROP COLUMN amount;
+ALTER TABLE order_items DROP COLUMN price;
+ALTER TABLE order_items RENAME COLUMN amount_decimal TO amount;
+ALTER TABLE order_items RENAME COLUMN price_decimal TO price;
@@ -0,0 +1,28 @@
+-- 金额改为以分为单位的整数保存，避免DOUBLE的舍入误差，原来的金额四舍五入到分
+ALTER TABLE books ADD COLUMN price_minor BIGINT NOT NULL DEFAULT 0;
+UPDATE books SET price_minor = CAST(ROUND(price * 100) AS INTEGER);
+ALTER TABLE books DROP COLUMN price;
+ALTER TABLE books RENAME COLUMN price_minor TO price;
+
+ALTER TABLE carts ADD COLUMN total_amount_minor BIGINT NOT NULL DEFAULT 0;
+UPDATE carts SET total_amount_minor = CAST(ROUND(total_amount * 100) AS INTEGER);
+ALTER TABLE carts DROP COLUMN total_amount;
+ALTER TABLE carts RENAME COLUMN total_amount_minor TO total_amount;
+
+ALTER TABLE cart_items ADD COLUMN amount_minor BIGINT NOT NULL DEFAULT 0;
+UPDATE cart_items SET amount_minor = CAST(ROUND(amount * 100) AS INTEGER);
+ALTER TABLE cart_items DROP COLUMN amount;
+ALTER TABLE cart_items RENAME COLUMN amount_minor TO amount;
+
+ALTER TABLE orders ADD COLUMN total_amount_minor BIGINT NOT NULL DEFAULT 0;
+UPDATE orders SET total_amount_minor = CAST(ROUND(total_amount * 100) AS INTEGER);
+ALTER TABLE orders DROP COLUMN total_amount;
+ALTER TABLE orders RENAME COLUMN total_amount_minor TO total_amount;
+
+ALTER TABLE order_items ADD COLUMN amount_minor BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN price_minor BIGINT NOT NULL DEFAULT 0;
+UPDATE order_items SET amount_minor = CAST(ROUND(amount * 100) AS INTEGER), price_minor = CAST(ROUND(price * 100) AS INTEGER);
+ALTER TABLE order_items DROP COLUMN amount;
+ALTER TABLE order_items DROP COLUMN price;
+ALTER TABLE order_items RENAME COLUMN amount_minor TO amount;
+ALTER TABLE order_items RENAME COLUMN price_minor TO price;
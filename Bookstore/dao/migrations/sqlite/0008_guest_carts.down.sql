@@ -0,0 +1,27 @@
+-- 回退前删除所有游客的购物车，再重建购物车表恢复用户不能为空的约束
+DELETE FROM cart_items WHERE cart_id IN (SELECT id FROM carts WHERE user_id IS NULL);
+DELETE FROM carts WHERE user_id IS NULL;
+CREATE TABLE cart_items_old AS SELECT id, count, amount, book_id, cart_id FROM cart_items;
+DROP TABLE cart_items;
+DROP INDEX IF EXISTS idx_carts_updated_at;
+CREATE TABLE carts_old(
+    id VARCHAR(100) PRIMARY KEY,
+    total_count INT NOT NULL,
+    total_amount BIGINT NOT NULL DEFAULT 0,
+    user_id INT NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+INSERT INTO carts_old(id, total_count, total_amount, user_id) SELECT id, total_count, total_amount, user_id FROM carts;
+DROP TABLE carts;
+ALTER TABLE carts_old RENAME TO carts;
+CREATE TABLE cart_items(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    count INT NOT NULL,
+    amount BIGINT NOT NULL DEFAULT 0,
+    book_id INT NOT NULL,
+    cart_id VARCHAR(100) NOT NULL,
+    FOREIGN KEY(book_id) REFERENCES books(id),
+    FOREIGN KEY(cart_id) REFERENCES carts(id)
+);
+INSERT INTO cart_items(id, count, amount, book_id, cart_id) SELECT id, count, amount, book_id, cart_id FROM cart_items_old;
+DROP TABLE cart_items_old;
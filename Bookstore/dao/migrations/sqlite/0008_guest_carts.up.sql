@@ -0,0 +1,28 @@
+-- 没有登录的用户也可以使用购物车，游客的购物车没有用户
+-- SQLite不能修改列的约束，需要重建购物车表，购物项表引用了购物车表，一起重建
+CREATE TABLE cart_items_old AS SELECT id, count, amount, book_id, cart_id FROM cart_items;
+DROP TABLE cart_items;
+CREATE TABLE carts_new(
+    id VARCHAR(100) PRIMARY KEY,
+    total_count INT NOT NULL,
+    total_amount BIGINT NOT NULL DEFAULT 0,
+    user_id INT,
+    -- 购物车最后修改的时间，Unix时间戳，长期没有修改的游客购物车会被后台清理
+    updated_at BIGINT NOT NULL DEFAULT 0,
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+INSERT INTO carts_new(id, total_count, total_amount, user_id) SELECT id, total_count, total_amount, user_id FROM carts;
+DROP TABLE carts;
+ALTER TABLE carts_new RENAME TO carts;
+CREATE INDEX IF NOT EXISTS idx_carts_updated_at ON carts(updated_at);
+CREATE TABLE cart_items(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    count INT NOT NULL,
+    amount BIGINT NOT NULL DEFAULT 0,
+    book_id INT NOT NULL,
+    cart_id VARCHAR(100) NOT NULL,
+    FOREIGN KEY(book_id) REFERENCES books(id),
+    FOREIGN KEY(cart_id) REFERENCES carts(id)
+);
+INSERT INTO cart_items(id, count, amount, book_id, cart_id) SELECT id, count, amount, book_id, cart_id FROM cart_items_old;
+DROP TABLE cart_items_old;
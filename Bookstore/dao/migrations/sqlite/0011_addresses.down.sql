@@ -0,0 +1,4 @@
+ALTER TABLE orders DROP COLUMN ship_address;
+ALTER TABLE orders DROP COLUMN ship_phone;
+ALTER TABLE orders DROP COLUMN ship_recipient;
+DROP TABLE IF EXISTS addresses;
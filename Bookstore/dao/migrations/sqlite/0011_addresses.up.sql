@@ -0,0 +1,18 @@
+-- 用户的收货地址簿，一个用户可以保存多个地址
+CREATE TABLE IF NOT EXISTS addresses(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INT NOT NULL,
+    -- 收货人、联系电话和详细地址
+    recipient VARCHAR(50) NOT NULL,
+    phone VARCHAR(20) NOT NULL,
+    address VARCHAR(200) NOT NULL,
+    -- 添加的时间，Unix时间戳
+    created_at BIGINT NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_addresses_user_id ON addresses(user_id);
+
+-- 下单时收货地址的快照，之后修改或删除地址簿中的地址不影响已有的订单，旧的订单为空
+ALTER TABLE orders ADD COLUMN ship_recipient VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN ship_phone VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN ship_address VARCHAR(200) NOT NULL DEFAULT '';
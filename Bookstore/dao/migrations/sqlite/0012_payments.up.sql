@@ -0,0 +1,18 @@
+-- 订单的支付记录，支付失败后可以重新支付，因此一个订单可以有多条记录
+CREATE TABLE IF NOT EXISTS payments(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id VARCHAR(100) NOT NULL,
+    -- 支付网关的名称，以及网关中的支付号，请求授权之后才有，之前为NULL，不受唯一索引的限制
+    provider VARCHAR(20) NOT NULL,
+    provider_ref VARCHAR(100) NULL DEFAULT NULL,
+    -- 支付的金额，单位为分
+    amount BIGINT NOT NULL,
+    -- pending待付款，authorized已授权，captured已扣款，failed失败，refunded已退款，reconcile待对账
+    status VARCHAR(20) NOT NULL,
+    -- 创建和最后一次变更状态的时间，Unix时间戳
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL,
+    FOREIGN KEY(order_id) REFERENCES orders(id)
+);
+CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_payments_provider_ref ON payments(provider, provider_ref);
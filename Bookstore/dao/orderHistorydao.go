@@ -0,0 +1,104 @@
+package dao
+
+import (
+	"bookstore/model"
+	"bookstore/utils"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// IllegalTransitionError 订单状态不允许变更为目标状态
+type IllegalTransitionError struct {
+	OrderID string
+	From    int64
+	To      int64
+}
+
+// Error 实现error接口
+func (e *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("订单%s当前的状态为%s，不能变更为%s", e.OrderID, model.OrderStateName(e.From), model.OrderStateName(e.To))
+}
+
+// addOrderHistory 保存一条订单状态的变更记录，actorID为0时表示系统自动变更
+func addOrderHistory(ctx context.Context, tx loggedTx, orderID string, from int64, to int64, actorID int, now time.Time) error {
+	//订单创建时没有之前的状态
+	var fromState, actor interface{}
+	if from != model.OrderStateNone {
+		fromState = from
+	}
+	if actorID > 0 {
+		actor = actorID
+	}
+	//写sql语句
+	sqlStr := "insert into order_history(order_id,from_state,to_state,actor_id,create_time) values(?,?,?,?,?)"
+	//执行
+	_, err := tx.ExecContext(ctx, sqlStr, orderID, fromState, to, actor, now.Format("2006-01-02 15:04:05"))
+	return err
+}
+
+// TransitionOrder 在一个事务中校验并变更订单的状态，同时记录操作人和时间，
+// 订单不存在时返回sql.ErrNoRows，不允许的变更返回*IllegalTransitionError
+func (r *sqlOrderRepository) TransitionOrder(ctx context.Context, orderID string, to int64, actorID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := transitionOrder(ctx, tx, r.driver, orderID, to, actorID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// transitionOrder 在事务中锁定订单、校验并变更订单的状态，同时记录变更历史
+func transitionOrder(ctx context.Context, tx loggedTx, driver string, orderID string, to int64, actorID int) error {
+	//锁定订单，避免并发变更同一个订单
+	sqlStr := "select state from orders where id = ?"
+	if driver == utils.DriverMySQL {
+		sqlStr += " for update"
+	}
+	var from int64
+	if err := tx.QueryRowContext(ctx, sqlStr, orderID).Scan(&from); err != nil {
+		return err
+	}
+	if !model.CanTransition(from, to) {
+		return &IllegalTransitionError{OrderID: orderID, From: from, To: to}
+	}
+	_, err := tx.ExecContext(ctx, "update orders set state = ? where id = ? and state = ?", to, orderID, from)
+	if err != nil {
+		return err
+	}
+	return addOrderHistory(ctx, tx, orderID, from, to, actorID, time.Now())
+}
+
+// GetOrderHistory 根据订单号获取订单状态的变更记录，按时间先后排列
+func (r *sqlOrderRepository) GetOrderHistory(ctx context.Context, orderID string) ([]*model.OrderHistory, error) {
+	//写sql语句
+	sqlStr := "select h.id,h.order_id,h.from_state,h.to_state,h.actor_id,u.username,h.create_time from order_history h left join users u on u.id = h.actor_id where h.order_id = ? order by h.id"
+	//执行
+	rows, err := r.db.QueryContext(ctx, sqlStr, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history []*model.OrderHistory
+	for rows.Next() {
+		h := &model.OrderHistory{}
+		var fromState, actorID sql.NullInt64
+		var actor sql.NullString
+		err := rows.Scan(&h.ID, &h.OrderID, &fromState, &h.ToState, &actorID, &actor, &h.CreateTime)
+		if err != nil {
+			return nil, err
+		}
+		h.FromState = model.OrderStateNone
+		if fromState.Valid {
+			h.FromState = fromState.Int64
+		}
+		h.ActorID = int(actorID.Int64)
+		h.Actor = actor.String
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
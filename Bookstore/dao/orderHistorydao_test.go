@@ -0,0 +1,114 @@
+package dao
+
+import (
+	"bookstore/model"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOrderStateMachine(t *testing.T) {
+	t.Run("测试合法的状态变更", testOrderTransitions)
+	t.Run("测试拒绝不允许的状态变更", testIllegalOrderTransition)
+	t.Run("测试结账时记录订单创建", testCheckoutHistory)
+}
+
+// newStateOrder 添加一个指定状态的订单
+func newStateOrder(t *testing.T, orderID string, state int64) *model.Order {
+	t.Helper()
+	order := &model.Order{
+		OrderID:     orderID,
+		CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+		TotalCount:  1,
+		TotalAmount: model.Cents(1000),
+		State:       state,
+		UserID:      1,
+	}
+	if err := AddOrder(t.Context(), order); err != nil {
+		t.Fatalf("添加订单失败: %v", err)
+	}
+	return order
+}
+
+func testOrderTransitions(t *testing.T) {
+	order := newStateOrder(t, "state-order", model.OrderStatePendingPayment)
+	SaveAdmin(t.Context(), "historyadmin", "history-pass", "historyadmin@example.com")
+	admin, _ := CheckUserName(t.Context(), "historyadmin")
+	//付款、发货、送达、确认收货、退款
+	steps := []struct {
+		to    int64
+		actor int
+	}{
+		{model.OrderStatePaid, 0},
+		{model.OrderStateShipped, admin.ID},
+		{model.OrderStateDelivered, admin.ID},
+		{model.OrderStateCompleted, 1},
+		{model.OrderStateRefunded, admin.ID},
+	}
+	for _, step := range steps {
+		if err := TransitionOrder(t.Context(), order.OrderID, step.to, step.actor); err != nil {
+			t.Fatalf("订单变更为%s失败: %v", model.OrderStateName(step.to), err)
+		}
+	}
+	got, _ := GetOrderByID(t.Context(), order.OrderID)
+	if got.State != model.OrderStateRefunded {
+		t.Errorf("订单状态应为已退款，实际为%s", got.StateName())
+	}
+	history, err := GetOrderHistory(t.Context(), order.OrderID)
+	if err != nil {
+		t.Fatalf("获取订单历史失败: %v", err)
+	}
+	if len(history) != len(steps) {
+		t.Fatalf("应有%d条变更记录，实际为%d条", len(steps), len(history))
+	}
+	from := model.OrderStatePendingPayment
+	for k, h := range history {
+		if h.FromState != from || h.ToState != steps[k].to || h.ActorID != steps[k].actor || h.CreateTime == "" {
+			t.Errorf("第%d条变更记录不正确: %+v", k+1, h)
+		}
+		from = h.ToState
+	}
+	if history[0].ActorName() != "系统" || history[1].ActorName() != "historyadmin" {
+		t.Errorf("操作人不正确: %s, %s", history[0].ActorName(), history[1].ActorName())
+	}
+}
+
+func testIllegalOrderTransition(t *testing.T) {
+	order := newStateOrder(t, "illegal-order", model.OrderStatePaid)
+	//没有发货不能确认收货
+	err := TransitionOrder(t.Context(), order.OrderID, model.OrderStateCompleted, 1)
+	var stateErr *IllegalTransitionError
+	if !errors.As(err, &stateErr) || stateErr.From != model.OrderStatePaid || stateErr.To != model.OrderStateCompleted {
+		t.Fatalf("应返回IllegalTransitionError，实际为%v", err)
+	}
+	if got, _ := GetOrderByID(t.Context(), order.OrderID); got.State != model.OrderStatePaid {
+		t.Errorf("不允许的变更不应修改订单状态，实际为%s", got.StateName())
+	}
+	if history, _ := GetOrderHistory(t.Context(), order.OrderID); len(history) != 0 {
+		t.Errorf("不允许的变更不应记录历史: %+v", history)
+	}
+	//已取消的订单不能再变更
+	TransitionOrder(t.Context(), order.OrderID, model.OrderStateCancelled, 1)
+	for _, to := range []int64{model.OrderStatePaid, model.OrderStateShipped, model.OrderStateRefunded} {
+		if err := TransitionOrder(t.Context(), order.OrderID, to, 4); !errors.As(err, &stateErr) {
+			t.Errorf("已取消的订单不应变更为%s", model.OrderStateName(to))
+		}
+	}
+	if err := TransitionOrder(t.Context(), "no-such-order", model.OrderStatePaid, 1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("订单不存在时应返回sql.ErrNoRows，实际为%v", err)
+	}
+}
+
+func testCheckoutHistory(t *testing.T) {
+	book := addCheckoutBook(t, "订单历史测试", 5)
+	cart := newCheckoutCart(t, "history-cart", 3, map[*model.Book]int64{book: 1})
+	order := newCheckoutOrder("history-order", cart)
+	if err := Checkout(t.Context(), order, cart); err != nil {
+		t.Fatalf("结账失败: %v", err)
+	}
+	history, _ := GetOrderHistory(t.Context(), order.OrderID)
+	if len(history) != 1 || history[0].FromState != model.OrderStateNone || history[0].ToState != order.State || history[0].ActorID != 3 {
+		t.Errorf("结账后应有一条创建订单的记录，实际为%+v", history)
+	}
+}
@@ -1,37 +1,70 @@
 package dao
 
 import (
-	"bookstore0612/model"
-	"bookstore0612/utils"
+	"bookstore/model"
+	"context"
+	"database/sql"
 )
 
-//AddOrderItem 向数据库中插入订单项
-func AddOrderItem(orderItem *model.OrderItem) error {
+// AddOrderItem 向数据库中插入订单项
+func (r *sqlOrderRepository) AddOrderItem(ctx context.Context, orderItem *model.OrderItem) error {
 	//写sql语句
-	sql := "insert into order_items(count,amount,title,author,price,img_path,order_id) values(?,?,?,?,?,?,?)"
+	sqlStr := "insert into order_items(count,amount,title,author,price,img_path,book_id,order_id) values(?,?,?,?,?,?,?,?)"
 	//执行
-	_, err := utils.Db.Exec(sql, orderItem.Count, orderItem.Amount, orderItem.Title, orderItem.Author, orderItem.Price, orderItem.ImgPath, orderItem.OrderID)
+	_, err := r.db.ExecContext(ctx, sqlStr, orderItem.Count, orderItem.Amount, orderItem.Title, orderItem.Author, orderItem.Price, orderItem.ImgPath, nullBookID(orderItem.BookID), orderItem.OrderID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-//GetOrderItemsByOrderID 根据订单号获取该订单的所有订单项
-func GetOrderItemsByOrderID(orderID string) ([]*model.OrderItem, error) {
+// GetOrderItemsByOrderID 根据订单号获取该订单的所有订单项
+func (r *sqlOrderRepository) GetOrderItemsByOrderID(ctx context.Context, orderID string) ([]*model.OrderItem, error) {
 	//写sql语句
-	sql := "select id,count,amount,title,author,price,img_path,order_id from order_items where order_id = ?"
+	sqlStr := "select id,count,amount,title,author,price,img_path,book_id,order_id from order_items where order_id = ?"
 	//执行
-	rows, err := utils.Db.Query(sql, orderID)
+	rows, err := r.db.QueryContext(ctx, sqlStr, orderID)
 	if err != nil {
 		return nil, err
 	}
+	return scanOrderItems(rows)
+}
+
+// GetOrderItemsByUserID 一次查询出用户所有订单的订单项，按照订单项的id排列
+func (r *sqlOrderRepository) GetOrderItemsByUserID(ctx context.Context, userID int) ([]*model.OrderItem, error) {
+	//写sql语句
+	sqlStr := "select oi.id,oi.count,oi.amount,oi.title,oi.author,oi.price,oi.img_path,oi.book_id,oi.order_id from order_items oi join orders o on o.id = oi.order_id where o.user_id = ? order by oi.id"
+	//执行
+	rows, err := r.db.QueryContext(ctx, sqlStr, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanOrderItems(rows)
+}
+
+// scanOrderItems 扫描查询出的所有订单项并关闭结果集
+func scanOrderItems(rows *sql.Rows) ([]*model.OrderItem, error) {
+	defer rows.Close()
 	var orderItems []*model.OrderItem
 	for rows.Next() {
 		orderItem := &model.OrderItem{}
-		rows.Scan(&orderItem.OrderItemID, &orderItem.Count, &orderItem.Amount, &orderItem.Title, &orderItem.Author, &orderItem.Price, &orderItem.ImgPath, &orderItem.OrderID)
+		//旧的订单项没有保存图书的id
+		var bookID sql.NullInt64
+		err := rows.Scan(&orderItem.OrderItemID, &orderItem.Count, &orderItem.Amount, &orderItem.Title, &orderItem.Author, &orderItem.Price, &orderItem.ImgPath, &bookID, &orderItem.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		orderItem.BookID = int(bookID.Int64)
 		//添加到切片中
 		orderItems = append(orderItems, orderItem)
 	}
-	return orderItems, nil
+	return orderItems, rows.Err()
+}
+
+// nullBookID 图书的id为0时保存为NULL
+func nullBookID(bookID int) interface{} {
+	if bookID == 0 {
+		return nil
+	}
+	return bookID
 }
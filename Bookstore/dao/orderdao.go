@@ -2,15 +2,42 @@ package dao
 
 import (
 	"bookstore/model"
-	"bookstore/utils"
+	"context"
 )
 
+// sqlOrderRepository 基于SQL数据库的订单数据访问实现
+type sqlOrderRepository struct {
+	db     loggedDB
+	driver string
+}
+
+// orderColumns 查询订单时的字段，与scanOrder中的顺序一致
+const orderColumns = "id,create_time,total_count,total_amount,state,user_id,ship_recipient,ship_phone,ship_address"
+
+// insertOrderSQL 插入订单的sql语句，参数由orderArgs生成
+const insertOrderSQL = "insert into orders(" + orderColumns + ") values(?,?,?,?,?,?,?,?,?)"
+
+// orderArgs 插入订单时的参数
+func orderArgs(order *model.Order) []interface{} {
+	return []interface{}{order.OrderID, order.CreateTime, order.TotalCount, order.TotalAmount, order.State, order.UserID,
+		order.ShipRecipient, order.ShipPhone, order.ShipAddress}
+}
+
+// scanOrder 扫描按照orderColumns查询出的一行
+func scanOrder(row rowScanner) (*model.Order, error) {
+	order := &model.Order{}
+	err := row.Scan(&order.OrderID, &order.CreateTime, &order.TotalCount, &order.TotalAmount, &order.State, &order.UserID,
+		&order.ShipRecipient, &order.ShipPhone, &order.ShipAddress)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
 // AddOrder 向数据库中插入订单
-func AddOrder(order *model.Order) error {
-	//写sql语句
-	sql := "insert into orders(id,create_time,total_count,total_amount,state,user_id) values(?,?,?,?,?,?)"
+func (r *sqlOrderRepository) AddOrder(ctx context.Context, order *model.Order) error {
 	//执行
-	_, err := utils.Db.Exec(sql, order.OrderID, order.CreateTime, order.TotalCount, order.TotalAmount, order.State, order.UserID)
+	_, err := r.db.ExecContext(ctx, insertOrderSQL, orderArgs(order)...)
 	if err != nil {
 		return err
 	}
@@ -18,53 +45,79 @@ func AddOrder(order *model.Order) error {
 }
 
 // GetOrders 获取数据库中所有的订单
-func GetOrders() ([]*model.Order, error) {
+func (r *sqlOrderRepository) GetOrders(ctx context.Context) ([]*model.Order, error) {
 	//写sql语句
-	sql := "select id,create_time,total_count,total_amount,state,user_id from orders"
+	sql := "select " + orderColumns + " from orders"
 	//执行
-	rows, err := utils.Db.Query(sql)
+	rows, err := r.db.QueryContext(ctx, sql)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	var orders []*model.Order
 	for rows.Next() {
-		order := &model.Order{}
-		rows.Scan(&order.OrderID, &order.CreateTime, &order.TotalCount, &order.TotalAmount, &order.State, &order.UserID)
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
 		orders = append(orders, order)
 	}
-	return orders, nil
+	return orders, rows.Err()
 }
 
-// GetMyOrders 获取我的订单
-func GetMyOrders(userID int) ([]*model.Order, error) {
+// GetOrderByID 根据订单号获取订单，订单不存在时返回sql.ErrNoRows
+func (r *sqlOrderRepository) GetOrderByID(ctx context.Context, orderID string) (*model.Order, error) {
 	//写sql语句
-	sql := "select id,create_time,total_count,total_amount,state,user_id from orders where user_id = ?"
+	sql := "select " + orderColumns + " from orders where id = ?"
 	//执行
-	rows, err := utils.Db.Query(sql, userID)
+	row := r.db.QueryRowContext(ctx, sql, orderID)
+	return scanOrder(row)
+}
+
+// GetMyOrdersWithItems 获取我的订单以及每个订单的订单项，不论有多少订单都只执行两条sql语句
+func (r *sqlOrderRepository) GetMyOrdersWithItems(ctx context.Context, userID int) ([]*model.Order, error) {
+	orders, err := r.GetMyOrders(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	//声明一个切片
-	var orders []*model.Order
-	for rows.Next() {
-		//创建Order
-		order := &model.Order{}
-		//给Order中的字段赋值
-		rows.Scan(&order.OrderID, &order.CreateTime, &order.TotalCount, &order.TotalAmount, &order.State, &order.UserID)
-		//将Order添加到切片中
-		orders = append(orders, order)
+	items, err := r.GetOrderItemsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	//按照订单号将订单项分配到订单中
+	byID := make(map[string]*model.Order, len(orders))
+	for _, order := range orders {
+		order.Items = []*model.OrderItem{}
+		byID[order.OrderID] = order
+	}
+	for _, item := range items {
+		if order, ok := byID[item.OrderID]; ok {
+			order.Items = append(order.Items, item)
+		}
 	}
 	return orders, nil
 }
 
-// UpdateOrderState 更新订单的状态，即发货和收货
-func UpdateOrderState(orderID string, state int64) error {
+// GetMyOrders 获取我的订单
+func (r *sqlOrderRepository) GetMyOrders(ctx context.Context, userID int) ([]*model.Order, error) {
 	//写sql语句
-	sql := "update orders set state = ? where id = ?"
+	sql := "select " + orderColumns + " from orders where user_id = ?"
 	//执行
-	_, err := utils.Db.Exec(sql, state, orderID)
+	rows, err := r.db.QueryContext(ctx, sql, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	defer rows.Close()
+	//声明一个切片
+	var orders []*model.Order
+	for rows.Next() {
+		//创建Order并给其中的字段赋值
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		//将Order添加到切片中
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
 }
@@ -0,0 +1,135 @@
+package dao
+
+import (
+	"bookstore/model"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrPaymentStatusChanged 支付已经不是预期的状态，例如网关重复发送了同一个回调
+var ErrPaymentStatusChanged = errors.New("支付的状态已经改变")
+
+// sqlPaymentRepository 基于SQL数据库的支付记录数据访问实现
+type sqlPaymentRepository struct {
+	db     loggedDB
+	driver string
+}
+
+// paymentColumns 查询支付记录时的字段，与scanPayment中的顺序一致
+const paymentColumns = "id,order_id,provider,provider_ref,amount,status,created_at,updated_at"
+
+// scanPayment 扫描按照paymentColumns查询出的一行
+func scanPayment(row rowScanner) (*model.Payment, error) {
+	p := &model.Payment{}
+	//请求授权之前没有网关中的支付号，数据库中为NULL
+	var ref sql.NullString
+	err := row.Scan(&p.ID, &p.OrderID, &p.Provider, &ref, &p.Amount, &p.Status, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.ProviderRef = ref.String
+	return p, nil
+}
+
+// paymentRef 空的支付号保存为NULL，同一个网关中的支付号是唯一的，NULL不受唯一索引的限制
+func paymentRef(ref string) sql.NullString {
+	return sql.NullString{String: ref, Valid: ref != ""}
+}
+
+// AddPayment 保存一条支付记录，并将生成的id和创建时间保存到p中
+func (r *sqlPaymentRepository) AddPayment(ctx context.Context, p *model.Payment) error {
+	p.CreatedAt = time.Now().Unix()
+	p.UpdatedAt = p.CreatedAt
+	//写sql语句
+	sqlStr := "insert into payments(order_id,provider,provider_ref,amount,status,created_at,updated_at) values(?,?,?,?,?,?,?)"
+	//执行
+	res, err := r.db.ExecContext(ctx, sqlStr, p.OrderID, p.Provider, paymentRef(p.ProviderRef), p.Amount, p.Status, p.CreatedAt, p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	p.ID = id
+	return nil
+}
+
+// GetPaymentByRef 根据网关的名称和网关中的支付号获取支付记录，不存在时返回sql.ErrNoRows
+func (r *sqlPaymentRepository) GetPaymentByRef(ctx context.Context, provider string, ref string) (*model.Payment, error) {
+	row := r.db.QueryRowContext(ctx, "select "+paymentColumns+" from payments where provider = ? and provider_ref = ?", provider, ref)
+	return scanPayment(row)
+}
+
+// GetPaymentsByOrderID 获取订单所有的支付记录，按照创建的顺序排列
+func (r *sqlPaymentRepository) GetPaymentsByOrderID(ctx context.Context, orderID string) ([]*model.Payment, error) {
+	rows, err := r.db.QueryContext(ctx, "select "+paymentColumns+" from payments where order_id = ? order by id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var payments []*model.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// SetPaymentRef 保存网关在请求授权时返回的支付号，同一个网关中的支付号已经存在时返回唯一索引冲突的错误
+func (r *sqlPaymentRepository) SetPaymentRef(ctx context.Context, paymentID int64, ref string) error {
+	_, err := r.db.ExecContext(ctx, "update payments set provider_ref = ?, updated_at = ? where id = ?", paymentRef(ref), time.Now().Unix(), paymentID)
+	return err
+}
+
+// UpdatePaymentStatus 将支付从from状态变更为to状态，支付已经不是from状态时返回ErrPaymentStatusChanged，
+// 因此同一个回调重复处理时只有第一次生效
+func (r *sqlPaymentRepository) UpdatePaymentStatus(ctx context.Context, paymentID int64, from string, to string) error {
+	//写sql语句
+	sqlStr := "update payments set status = ?, updated_at = ? where id = ? and status = ?"
+	//执行
+	res, err := r.db.ExecContext(ctx, sqlStr, to, time.Now().Unix(), paymentID, from)
+	if err != nil {
+		return err
+	}
+	return checkPaymentUpdated(res.RowsAffected())
+}
+
+// CapturePayment 网关扣款成功后，在一个事务中将已授权的支付变更为已扣款，并将待付款的订单变更为已付款，
+// 支付已经不是已授权状态时返回ErrPaymentStatusChanged，订单不是待付款状态时返回*IllegalTransitionError
+func (r *sqlPaymentRepository) CapturePayment(ctx context.Context, paymentID int64, orderID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	sqlStr := "update payments set status = ?, updated_at = ? where id = ? and status = ?"
+	res, err := tx.ExecContext(ctx, sqlStr, model.PaymentStatusCaptured, time.Now().Unix(), paymentID, model.PaymentStatusAuthorized)
+	if err != nil {
+		return err
+	}
+	if err := checkPaymentUpdated(res.RowsAffected()); err != nil {
+		return err
+	}
+	//由网关的回调触发，没有操作人
+	if err := transitionOrder(ctx, tx, r.driver, orderID, model.OrderStatePaid, 0); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkPaymentUpdated 没有更新任何支付记录时返回ErrPaymentStatusChanged
+func checkPaymentUpdated(n int64, err error) error {
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPaymentStatusChanged
+	}
+	return nil
+}
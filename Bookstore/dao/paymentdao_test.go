@@ -0,0 +1,91 @@
+package dao
+
+import (
+	"bookstore/model"
+	"errors"
+	"testing"
+)
+
+// newTestPayment 为订单添加一条已授权的支付
+func newTestPayment(t *testing.T, order *model.Order, ref string) *model.Payment {
+	t.Helper()
+	p := &model.Payment{
+		OrderID:  order.OrderID,
+		Provider: "mock",
+		Amount:   order.TotalAmount,
+		Status:   model.PaymentStatusPending,
+	}
+	if err := AddPayment(t.Context(), p); err != nil {
+		t.Fatalf("添加支付失败: %v", err)
+	}
+	if err := SetPaymentRef(t.Context(), p.ID, ref); err != nil {
+		t.Fatalf("保存网关的支付号失败: %v", err)
+	}
+	if err := UpdatePaymentStatus(t.Context(), p.ID, model.PaymentStatusPending, model.PaymentStatusAuthorized); err != nil {
+		t.Fatalf("变更支付状态失败: %v", err)
+	}
+	return p
+}
+
+func TestPayments(t *testing.T) {
+	order := newStateOrder(t, "payment-order", model.OrderStatePendingPayment)
+	p := newTestPayment(t, order, "payment-ref-1")
+	got, err := GetPaymentByRef(t.Context(), "mock", "payment-ref-1")
+	if err != nil || got.ID != p.ID || got.Status != model.PaymentStatusAuthorized || got.Amount != order.TotalAmount {
+		t.Fatalf("根据支付号查询的支付不正确: %+v %v", got, err)
+	}
+	//状态已经变更过，重复变更返回ErrPaymentStatusChanged
+	err = UpdatePaymentStatus(t.Context(), p.ID, model.PaymentStatusPending, model.PaymentStatusAuthorized)
+	if !errors.Is(err, ErrPaymentStatusChanged) {
+		t.Errorf("重复变更支付状态应返回ErrPaymentStatusChanged，实际为%v", err)
+	}
+
+	if err := CapturePayment(t.Context(), p.ID, order.OrderID); err != nil {
+		t.Fatalf("扣款失败: %v", err)
+	}
+	o, _ := GetOrderByID(t.Context(), order.OrderID)
+	history, _ := GetOrderHistory(t.Context(), order.OrderID)
+	if o.State != model.OrderStatePaid || len(history) != 1 || history[0].ActorID != 0 {
+		t.Errorf("扣款后订单应为已付款并记录一次没有操作人的变更: %s %+v", o.StateName(), history)
+	}
+	if err := CapturePayment(t.Context(), p.ID, order.OrderID); !errors.Is(err, ErrPaymentStatusChanged) {
+		t.Errorf("重复扣款应返回ErrPaymentStatusChanged，实际为%v", err)
+	}
+
+	//订单已经取消时扣款失败，支付状态保持不变
+	cancelled := newStateOrder(t, "payment-cancelled-order", model.OrderStateCancelled)
+	p = newTestPayment(t, cancelled, "payment-ref-2")
+	var stateErr *IllegalTransitionError
+	if err := CapturePayment(t.Context(), p.ID, cancelled.OrderID); !errors.As(err, &stateErr) {
+		t.Fatalf("订单已取消时扣款应返回IllegalTransitionError，实际为%v", err)
+	}
+	ps, _ := GetPaymentsByOrderID(t.Context(), cancelled.OrderID)
+	if len(ps) != 1 || ps[0].Status != model.PaymentStatusAuthorized {
+		t.Errorf("扣款失败后支付应仍为已授权: %+v", ps)
+	}
+}
+
+func TestPaymentRefUnique(t *testing.T) {
+	order := newStateOrder(t, "payment-ref-order", model.OrderStatePendingPayment)
+	//请求授权之前没有支付号，可以有多条
+	first := &model.Payment{OrderID: order.OrderID, Provider: "mock", Amount: order.TotalAmount, Status: model.PaymentStatusPending}
+	second := &model.Payment{OrderID: order.OrderID, Provider: "mock", Amount: order.TotalAmount, Status: model.PaymentStatusPending}
+	for _, p := range []*model.Payment{first, second} {
+		if err := AddPayment(t.Context(), p); err != nil {
+			t.Fatalf("添加支付失败: %v", err)
+		}
+	}
+	if err := SetPaymentRef(t.Context(), first.ID, "payment-ref-unique"); err != nil {
+		t.Fatalf("保存网关的支付号失败: %v", err)
+	}
+	if err := SetPaymentRef(t.Context(), second.ID, "payment-ref-unique"); err == nil {
+		t.Error("同一个网关中的支付号重复时应返回错误")
+	}
+	ps, err := GetPaymentsByOrderID(t.Context(), order.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 2 || ps[0].ProviderRef != "payment-ref-unique" || ps[1].ProviderRef != "" {
+		t.Errorf("支付号不正确: %+v %+v", ps[0], ps[1])
+	}
+}
@@ -0,0 +1,195 @@
+package dao
+
+import (
+	"bookstore/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueryStats 一次请求中执行的sql语句的次数和总耗时
+type QueryStats struct {
+	count    atomic.Int64
+	duration atomic.Int64 //纳秒
+}
+
+// queryStatsKey 查询统计在context中的键
+type queryStatsKey struct{}
+
+// WithQueryStats 返回带有查询统计的context，使用该context执行的sql语句都会计入统计
+func WithQueryStats(ctx context.Context) (context.Context, *QueryStats) {
+	stats := &QueryStats{}
+	return context.WithValue(ctx, queryStatsKey{}, stats), stats
+}
+
+// Count 获取执行的sql语句的次数
+func (s *QueryStats) Count() int64 {
+	return s.count.Load()
+}
+
+// Duration 获取执行sql语句的总耗时
+func (s *QueryStats) Duration() time.Duration {
+	return time.Duration(s.duration.Load())
+}
+
+// observeQuery 在sql语句执行之后计入context中的查询统计，并以Debug级别记录sql语句和耗时
+func observeQuery(ctx context.Context, query string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	if stats, ok := ctx.Value(queryStatsKey{}).(*QueryStats); ok {
+		stats.count.Add(1)
+		stats.duration.Add(int64(elapsed))
+	}
+	logger := utils.Logger(ctx)
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("sql", query), slog.Duration("duration", elapsed)}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, "执行sql", attrs...)
+}
+
+// loggedDB 执行sql语句时记录日志和查询统计的数据库连接，sql语句在第一次执行时预编译，之后重复使用
+type loggedDB struct {
+	*sql.DB
+	stmts *stmtCache
+}
+
+// maxCachedStmts 最多保存的预编译语句的数量，dao中固定的sql语句远少于该数量
+const maxCachedStmts = 256
+
+// newLoggedDB 创建记录日志和查询统计并重复使用预编译语句的数据库连接
+func newLoggedDB(db *sql.DB) loggedDB {
+	return loggedDB{DB: db, stmts: &stmtCache{stmts: make(map[string]*sql.Stmt), max: maxCachedStmts}}
+}
+
+// uncached 返回不使用预编译语句的数据库连接，用于根据查询条件在运行时拼接的sql语句，
+// 这些语句的组合没有上限，缓存之后会一直占用数据库的预编译语句
+func (db loggedDB) uncached() loggedDB {
+	return loggedDB{DB: db.DB}
+}
+
+// stmtCache 按照sql语句保存预编译的语句，只用于dao中固定的sql语句。
+// 保存的语句达到上限后不再预编译新的语句，直接执行，已保存的语句不会被关闭，正在使用时不会失效
+type stmtCache struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+	max   int
+}
+
+// stmt 获取预编译的sql语句，第一次使用时预编译，预编译失败时返回nil，由调用者直接执行
+func (db loggedDB) stmt(ctx context.Context, query string) *sql.Stmt {
+	if db.stmts == nil {
+		return nil
+	}
+	db.stmts.mu.Lock()
+	stmt, ok := db.stmts.stmts[query]
+	full := len(db.stmts.stmts) >= db.stmts.max
+	db.stmts.mu.Unlock()
+	if ok {
+		return stmt
+	}
+	if full {
+		return nil
+	}
+	//预编译时不持有锁，嵌入式数据库只有一个连接，等待连接时不能阻塞其他语句
+	stmt, err := db.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return nil
+	}
+	db.stmts.mu.Lock()
+	defer db.stmts.mu.Unlock()
+	if cached, ok := db.stmts.stmts[query]; ok {
+		//其他请求已经预编译了同样的语句
+		stmt.Close()
+		return cached
+	}
+	if len(db.stmts.stmts) >= db.stmts.max {
+		//预编译期间其他请求已经保存到了上限，关闭后直接执行
+		stmt.Close()
+		return nil
+	}
+	db.stmts.stmts[query] = stmt
+	return stmt
+}
+
+// QueryContext 执行查询
+func (db loggedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	var rows *sql.Rows
+	var err error
+	if stmt := db.stmt(ctx, query); stmt != nil {
+		rows, err = stmt.QueryContext(ctx, args...)
+	} else {
+		rows, err = db.DB.QueryContext(ctx, query, args...)
+	}
+	observeQuery(ctx, query, start, err)
+	return rows, err
+}
+
+// QueryRowContext 执行最多返回一行的查询
+func (db loggedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	var row *sql.Row
+	if stmt := db.stmt(ctx, query); stmt != nil {
+		row = stmt.QueryRowContext(ctx, args...)
+	} else {
+		row = db.DB.QueryRowContext(ctx, query, args...)
+	}
+	observeQuery(ctx, query, start, row.Err())
+	return row
+}
+
+// ExecContext 执行不返回结果的sql语句
+func (db loggedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	var res sql.Result
+	var err error
+	if stmt := db.stmt(ctx, query); stmt != nil {
+		res, err = stmt.ExecContext(ctx, args...)
+	} else {
+		res, err = db.DB.ExecContext(ctx, query, args...)
+	}
+	observeQuery(ctx, query, start, err)
+	return res, err
+}
+
+// BeginTx 开始事务，事务中执行的sql语句同样记录日志和查询统计
+func (db loggedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (loggedTx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	return loggedTx{tx}, err
+}
+
+// loggedTx 执行sql语句时记录日志和查询统计的事务
+type loggedTx struct {
+	*sql.Tx
+}
+
+// QueryContext 在事务中执行查询
+func (tx loggedTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	observeQuery(ctx, query, start, err)
+	return rows, err
+}
+
+// QueryRowContext 在事务中执行最多返回一行的查询
+func (tx loggedTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	observeQuery(ctx, query, start, row.Err())
+	return row
+}
+
+// ExecContext 在事务中执行不返回结果的sql语句
+func (tx loggedTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := tx.Tx.ExecContext(ctx, query, args...)
+	observeQuery(ctx, query, start, err)
+	return res, err
+}
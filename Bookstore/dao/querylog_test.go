@@ -0,0 +1,132 @@
+package dao
+
+import (
+	"bookstore/model"
+	"context"
+	"database/sql"
+	"strconv"
+	"testing"
+)
+
+func TestQueryStats(t *testing.T) {
+	ctx, stats := WithQueryStats(t.Context())
+	if _, err := GetBookByID(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Count() != 1 {
+		t.Errorf("查询一本图书应执行1条sql语句，实际为%d", stats.Count())
+	}
+	//事务中执行的sql语句同样计入统计
+	if err := TransitionOrder(ctx, "no-such-order", 1, 0); err == nil {
+		t.Fatal("订单不存在时应返回错误")
+	}
+	if stats.Count() != 2 {
+		t.Errorf("变更不存在的订单应执行1条sql语句，实际为%d", stats.Count()-1)
+	}
+	//没有查询统计的context不受影响
+	if _, err := GetBookByID(t.Context(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Count() != 2 {
+		t.Errorf("其他context中执行的sql语句不应计入统计")
+	}
+}
+
+// countQueries 获取f执行的sql语句的次数
+func countQueries(t *testing.T, f func(ctx context.Context)) int64 {
+	ctx, stats := WithQueryStats(t.Context())
+	f(ctx)
+	return stats.Count()
+}
+
+func TestQueryCounts(t *testing.T) {
+	books := map[*model.Book]int64{}
+	for _, title := range []string{"查询次数图书一", "查询次数图书二", "查询次数图书三"} {
+		books[addCheckoutBook(t, title, 10)] = 1
+	}
+	userID := newMergeUser(t, "queryuser")
+	cart := newCheckoutCart(t, "query-cart", userID, books)
+
+	//不论购物车中有多少购物项，都只执行两条sql语句
+	if n := countQueries(t, func(ctx context.Context) { GetCartByUserID(ctx, userID) }); n != 2 {
+		t.Errorf("查询有3个购物项的购物车应执行2条sql语句，实际为%d", n)
+	}
+	itemID := strconv.FormatInt(cart.CartItems[0].CartItemID, 10)
+	if n := countQueries(t, func(ctx context.Context) { GetCartByCartItemID(ctx, itemID) }); n != 2 {
+		t.Errorf("根据购物项查询购物车应执行2条sql语句，实际为%d", n)
+	}
+	if n := countQueries(t, func(ctx context.Context) { GetCartItemByID(ctx, itemID) }); n != 1 {
+		t.Errorf("查询购物项及其图书应执行1条sql语句，实际为%d", n)
+	}
+
+	//不论有多少订单，都只执行两条sql语句
+	for _, orderID := range []string{"query-order-1", "query-order-2"} {
+		cart := newCheckoutCart(t, orderID+"-cart", userID, books)
+		if err := Checkout(t.Context(), newCheckoutOrder(orderID, cart), cart); err != nil {
+			t.Fatalf("结账失败: %v", err)
+		}
+	}
+	var orders []*model.Order
+	if n := countQueries(t, func(ctx context.Context) { orders, _ = GetMyOrdersWithItems(ctx, userID) }); n != 2 {
+		t.Errorf("查询2个订单及其订单项应执行2条sql语句，实际为%d", n)
+	}
+	if len(orders) != 2 || len(orders[0].Items) != 3 || len(orders[1].Items) != 3 {
+		t.Errorf("每个订单应有3个订单项: %+v", orders)
+	}
+
+	//重复查询Session时使用同一个预编译的语句
+	cache := current.Sessions.(*sqlSessionRepository).db.stmts
+	GetSession(t.Context(), "no-such-session")
+	cache.mu.Lock()
+	before := len(cache.stmts)
+	cache.mu.Unlock()
+	for i := 0; i < 3; i++ {
+		if n := countQueries(t, func(ctx context.Context) { GetSession(ctx, "no-such-session") }); n != 1 {
+			t.Errorf("查询Session应执行1条sql语句，实际为%d", n)
+		}
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if len(cache.stmts) != before {
+		t.Errorf("重复查询Session不应再预编译新的语句，预编译的语句从%d个变为%d个", before, len(cache.stmts))
+	}
+}
+
+func TestStmtCacheBounded(t *testing.T) {
+	db := current.Books.(*sqlBookRepository).db
+	cacheSize := func() int {
+		db.stmts.mu.Lock()
+		defer db.stmts.mu.Unlock()
+		return len(db.stmts.stmts)
+	}
+	SearchBooks(t.Context(), &BookQuery{})
+	before := cacheSize()
+	//运行时拼接的sql语句不预编译，关键字的数量再多也不会增加预编译的语句
+	keyword := ""
+	for i := 0; i < 10; i++ {
+		keyword += " k" + strconv.Itoa(i)
+		if _, err := SearchBooks(t.Context(), &BookQuery{Keyword: keyword, InStock: i%2 == 0}); err != nil {
+			t.Fatalf("搜索图书失败: %v", err)
+		}
+	}
+	if n := cacheSize(); n != before {
+		t.Errorf("搜索图书不应预编译新的语句，预编译的语句从%d个变为%d个", before, n)
+	}
+
+	//达到上限后不再保存新的语句，直接执行
+	small := loggedDB{DB: db.DB, stmts: &stmtCache{stmts: make(map[string]*sql.Stmt), max: 1}}
+	defer func() {
+		for _, stmt := range small.stmts.stmts {
+			stmt.Close()
+		}
+	}()
+	for _, query := range []string{"select 1", "select 2", "select 1"} {
+		var n int
+		if err := small.QueryRowContext(t.Context(), query).Scan(&n); err != nil {
+			t.Fatalf("执行%s失败: %v", query, err)
+		}
+	}
+	if len(small.stmts.stmts) != 1 || small.stmts.stmts["select 1"] == nil {
+		t.Errorf("应只保存第一条语句，实际为%v", small.stmts.stmts)
+	}
+}
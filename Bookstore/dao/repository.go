@@ -0,0 +1,122 @@
+package dao
+
+import (
+	"bookstore/model"
+	"context"
+	"time"
+)
+
+// BookRepository 图书数据访问接口
+type BookRepository interface {
+	GetBooks(ctx context.Context) ([]*model.Book, error)
+	AddBook(ctx context.Context, b *model.Book) error
+	DeleteBook(ctx context.Context, bookID string) error
+	GetBookByID(ctx context.Context, bookID string) (*model.Book, error)
+	UpdateBook(ctx context.Context, b *model.Book) error
+	GetPageBooks(ctx context.Context, pageNo string) (*model.Page, error)
+	GetPageBooksByPrice(ctx context.Context, pageNo string, minPrice string, maxPrice string) (*model.Page, error)
+	SearchBooks(ctx context.Context, q *BookQuery) (*model.Page, error)
+}
+
+// CartRepository 购物车和购物项数据访问接口
+type CartRepository interface {
+	AddCart(ctx context.Context, cart *model.Cart) error
+	GetCartByUserID(ctx context.Context, userID int) (*model.Cart, error)
+	GetCartByCartID(ctx context.Context, cartID string) (*model.Cart, error)
+	GetCartByCartItemID(ctx context.Context, cartItemID string) (*model.Cart, error)
+	UpdateCart(ctx context.Context, cart *model.Cart) error
+	DeleteCartByCartID(ctx context.Context, cartID string) error
+	AddCartItem(ctx context.Context, cartItem *model.CartItem) error
+	GetCartItemByBookIDAndCartID(ctx context.Context, bookID string, cartID string) (*model.CartItem, error)
+	GetCartItemByID(ctx context.Context, cartItemID string) (*model.CartItem, error)
+	UpdateBookCount(ctx context.Context, cartItem *model.CartItem) error
+	GetCartItemsByCartID(ctx context.Context, cartID string) ([]*model.CartItem, error)
+	DeleteCartItemsByCartID(ctx context.Context, cartID string) error
+	DeleteCartItemByID(ctx context.Context, cartItemID string) error
+	// MergeGuestCart 登录时将游客购物车合并到用户的购物车中，两边都有的图书数量相加，
+	// 数量限制在可以购买的库存以内，返回数量被调整的购物项
+	MergeGuestCart(ctx context.Context, guestCartID string, userID int) ([]StockShortage, error)
+	DeleteExpiredGuestCarts(ctx context.Context, before time.Time) (int64, error)
+	// AvailableStock 获取图书可以放入购物车的数量，其他购物车保留的库存不计入
+	AvailableStock(ctx context.Context, bookID int, cartID string, now time.Time) (int64, error)
+	ReleaseExpiredReservations(ctx context.Context, now time.Time) (int64, error)
+	// RecalculateCartTotals 按照图书当前的价格重新计算购物车中保存的金额
+	RecalculateCartTotals(ctx context.Context) (int64, error)
+	// RecalculateBookCarts 按照图书当前的价格重新计算有该图书的购物车中保存的金额
+	RecalculateBookCarts(ctx context.Context, bookID int) (int64, error)
+}
+
+// OrderRepository 订单和订单项数据访问接口
+type OrderRepository interface {
+	AddOrder(ctx context.Context, order *model.Order) error
+	GetOrders(ctx context.Context) ([]*model.Order, error)
+	GetOrderByID(ctx context.Context, orderID string) (*model.Order, error)
+	GetMyOrders(ctx context.Context, userID int) ([]*model.Order, error)
+	// GetMyOrdersWithItems 获取用户的订单以及每个订单的订单项
+	GetMyOrdersWithItems(ctx context.Context, userID int) ([]*model.Order, error)
+	// TransitionOrder 按照订单的状态机变更订单的状态并记录变更历史，
+	// 不允许的变更返回*IllegalTransitionError
+	TransitionOrder(ctx context.Context, orderID string, to int64, actorID int) error
+	GetOrderHistory(ctx context.Context, orderID string) ([]*model.OrderHistory, error)
+	// CancelOrder 取消还没有发货的订单并恢复图书的库存和销量，任何一步失败都会回滚
+	CancelOrder(ctx context.Context, orderID string, actorID int) error
+	AddOrderItem(ctx context.Context, orderItem *model.OrderItem) error
+	GetOrderItemsByOrderID(ctx context.Context, orderID string) ([]*model.OrderItem, error)
+	GetOrderItemsByUserID(ctx context.Context, userID int) ([]*model.OrderItem, error)
+	// Checkout 保存订单和订单项、扣减库存并清空购物车，任何一步失败都会回滚，
+	// 库存不足时返回*OutOfStockError，购物车已经被结账或者是空的时返回ErrCartEmpty
+	Checkout(ctx context.Context, order *model.Order, cart *model.Cart) error
+}
+
+// UserRepository 用户数据访问接口
+type UserRepository interface {
+	CheckUserNameAndPassword(ctx context.Context, username string, password string) (*model.User, error)
+	CheckUserName(ctx context.Context, username string) (*model.User, error)
+	SaveUser(ctx context.Context, username string, password string, email string) error
+	// SaveAdmin 将用户设置为管理员并重新设置密码，用户不存在时创建
+	SaveAdmin(ctx context.Context, username string, password string, email string) (bool, error)
+	GetUsers(ctx context.Context) ([]*model.User, error)
+	UpdateUserRole(ctx context.Context, userID int, role string) error
+}
+
+// AddressRepository 收货地址数据访问接口
+type AddressRepository interface {
+	AddAddress(ctx context.Context, address *model.Address) error
+	GetAddressesByUserID(ctx context.Context, userID int) ([]*model.Address, error)
+	GetAddressByID(ctx context.Context, addressID string) (*model.Address, error)
+	UpdateAddress(ctx context.Context, address *model.Address) error
+	DeleteAddressByID(ctx context.Context, addressID string) error
+}
+
+// PaymentRepository 支付记录数据访问接口
+type PaymentRepository interface {
+	AddPayment(ctx context.Context, p *model.Payment) error
+	GetPaymentByRef(ctx context.Context, provider string, ref string) (*model.Payment, error)
+	GetPaymentsByOrderID(ctx context.Context, orderID string) ([]*model.Payment, error)
+	SetPaymentRef(ctx context.Context, paymentID int64, ref string) error
+	// UpdatePaymentStatus 将支付从from状态变更为to状态，已经不是from状态时返回ErrPaymentStatusChanged
+	UpdatePaymentStatus(ctx context.Context, paymentID int64, from string, to string) error
+	// CapturePayment 在一个事务中将已授权的支付变更为已扣款，并将待付款的订单变更为已付款
+	CapturePayment(ctx context.Context, paymentID int64, orderID string) error
+}
+
+// SessionRepository Session数据访问接口
+type SessionRepository interface {
+	AddSession(ctx context.Context, sess *model.Session) error
+	DeleteSession(ctx context.Context, sessID string) error
+	GetSession(ctx context.Context, sessID string) (*model.Session, error)
+	TouchSession(ctx context.Context, sess *model.Session) error
+	DeleteSessionsByUserID(ctx context.Context, userID int) error
+	DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Store 汇总了所有的数据访问接口，启动时选择一种实现
+type Store struct {
+	Books     BookRepository
+	Carts     CartRepository
+	Orders    OrderRepository
+	Users     UserRepository
+	Addresses AddressRepository
+	Payments  PaymentRepository
+	Sessions  SessionRepository
+}
@@ -0,0 +1,61 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// AvailableStock 获取图书可以放入购物车的数量，即库存减去其他购物车中还在保留期内的数量，
+// 不会小于0，cartID为空时不排除任何购物车
+func (r *sqlCartRepository) AvailableStock(ctx context.Context, bookID int, cartID string, now time.Time) (int64, error) {
+	//写sql语句
+	sqlStr := "select b.stock - coalesce((select sum(ci.count) from cart_items ci where ci.book_id = b.id and ci.cart_id <> ? and ci.reserved_until > ?), 0) from books b where b.id = ?"
+	var available int64
+	err := r.db.QueryRowContext(ctx, sqlStr, cartID, now.Unix(), bookID).Scan(&available)
+	if err != nil {
+		return 0, err
+	}
+	return max(available, 0), nil
+}
+
+// ReleaseExpiredReservations 释放在now之前已经过期的库存保留，返回释放的购物项的数量，
+// 过期的保留在计算可购买的数量时已经不再计入，这里只是清理
+func (r *sqlCartRepository) ReleaseExpiredReservations(ctx context.Context, now time.Time) (int64, error) {
+	//写sql语句
+	sqlStr := "update cart_items set reserved_until = 0 where reserved_until > 0 and reserved_until <= ?"
+	res, err := r.db.ExecContext(ctx, sqlStr, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// queryRower *sql.DB和*sql.Tx共有的QueryRowContext方法，事务中修改购物项时在事务中计算保留期
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// reservedUntil 计算在now将购物车cartID中图书bookID的数量设置为count时保留库存的截止时间，不保留库存时为0。
+// 只有登录用户的购物车保留库存，游客购物车不保留，避免匿名请求反复占用库存；
+// 购物车中保留的总数量不能超过cart.maxReservedCount，超过时该购物项不保留，结账时再检查库存
+func reservedUntil(ctx context.Context, q queryRower, cartID string, bookID int, count int64, now time.Time) (int64, error) {
+	ttl := conf.Cart.ReservationTTL.Duration
+	if ttl <= 0 {
+		return 0, nil
+	}
+	//写sql语句，查询购物车是否属于登录的用户以及购物车中其他图书保留的数量
+	sqlStr := "select c.user_id is not null, coalesce((select sum(ci.count) from cart_items ci where ci.cart_id = c.id and ci.book_id <> ? and ci.reserved_until > ?), 0) from carts c where c.id = ?"
+	var owned bool
+	var reserved int64
+	if err := q.QueryRowContext(ctx, sqlStr, bookID, now.Unix(), cartID).Scan(&owned, &reserved); err != nil {
+		return 0, err
+	}
+	if !owned {
+		return 0, nil
+	}
+	if limit := conf.Cart.MaxReservedCount; limit > 0 && reserved+count > limit {
+		return 0, nil
+	}
+	return now.Add(ttl).Unix(), nil
+}
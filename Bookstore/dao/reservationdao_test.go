@@ -0,0 +1,87 @@
+package dao
+
+import (
+	"bookstore/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReservation(t *testing.T) {
+	book := addCheckoutBook(t, "保留库存图书", 5)
+	reserved := newCheckoutCart(t, "reservation-cart", newMergeUser(t, "reservationuser"), map[*model.Book]int64{book: 2})
+	now := time.Now()
+
+	//其他购物车保留的数量不能购买，自己购物车中的数量不计入
+	if n, _ := AvailableStock(t.Context(), book.ID, "", now); n != 3 {
+		t.Errorf("新的购物车可以放入3本，实际为%d", n)
+	}
+	if n, _ := AvailableStock(t.Context(), book.ID, reserved.CartID, now); n != 5 {
+		t.Errorf("保留库存的购物车可以放入5本，实际为%d", n)
+	}
+	//其他购物车保留的库存不能结账
+	cart := newCheckoutCart(t, "reservation-checkout", newMergeUser(t, "reservationbuyer"), map[*model.Book]int64{book: 4})
+	var stockErr *OutOfStockError
+	if err := Checkout(t.Context(), newCheckoutOrder("reservation-order", cart), cart); !errors.As(err, &stockErr) || stockErr.Shortages[0].Stock != 3 {
+		t.Errorf("其他购物车保留的库存不能结账，实际为%v", err)
+	}
+	DeleteCartByCartID(t.Context(), cart.CartID)
+	//保留过期之后不再计入
+	if n, _ := AvailableStock(t.Context(), book.ID, "", now.Add(conf.Cart.ReservationTTL.Duration+time.Second)); n != 5 {
+		t.Errorf("保留过期之后可以放入5本，实际为%d", n)
+	}
+	if n, err := ReleaseExpiredReservations(t.Context(), now.Add(conf.Cart.ReservationTTL.Duration+time.Second)); err != nil || n < 1 {
+		t.Errorf("应释放过期的保留，实际释放了%d个 %v", n, err)
+	}
+	if n, _ := AvailableStock(t.Context(), book.ID, "", now); n != 5 {
+		t.Errorf("释放之后可以放入5本，实际为%d", n)
+	}
+	//游客购物车不保留库存，匿名请求不能占用库存
+	newGuestCart(t, "reservation-guest-cart", map[*model.Book]int64{book: 5})
+	if n, _ := AvailableStock(t.Context(), book.ID, "", now); n != 5 {
+		t.Errorf("游客购物车不保留库存，可以放入5本，实际为%d", n)
+	}
+	//不保留库存时添加的购物项不计入
+	ttl := conf.Cart.ReservationTTL
+	conf.Cart.ReservationTTL.Duration = 0
+	defer func() { conf.Cart.ReservationTTL = ttl }()
+	newCheckoutCart(t, "no-reservation-cart", newMergeUser(t, "noreservationuser"), map[*model.Book]int64{book: 2})
+	if n, _ := AvailableStock(t.Context(), book.ID, "", now); n != 5 {
+		t.Errorf("不保留库存时可以放入5本，实际为%d", n)
+	}
+}
+
+func TestReservationLimit(t *testing.T) {
+	limit := conf.Cart.MaxReservedCount
+	conf.Cart.MaxReservedCount = 3
+	defer func() { conf.Cart.MaxReservedCount = limit }()
+	first := addCheckoutBook(t, "保留上限图书一", 10)
+	second := addCheckoutBook(t, "保留上限图书二", 10)
+	cart := newCheckoutCart(t, "reservation-limit-cart", newMergeUser(t, "reservationlimit"), map[*model.Book]int64{first: 2})
+	now := time.Now()
+
+	if n, _ := AvailableStock(t.Context(), first.ID, "", now); n != 8 {
+		t.Errorf("没有超过上限时应保留2本，可以放入8本，实际为%d", n)
+	}
+	//加上购物车中已经保留的2本超过上限，新的购物项不保留
+	if err := AddCartItem(t.Context(), &model.CartItem{Book: second, Count: 2, CartID: cart.CartID}); err != nil {
+		t.Fatalf("添加购物项失败: %v", err)
+	}
+	if n, _ := AvailableStock(t.Context(), second.ID, "", now); n != 10 {
+		t.Errorf("超过上限的购物项不保留，可以放入10本，实际为%d", n)
+	}
+	//修改后超过上限的购物项也不再保留
+	if err := UpdateBookCount(t.Context(), &model.CartItem{Book: first, Count: 5, CartID: cart.CartID}); err != nil {
+		t.Fatalf("修改购物项失败: %v", err)
+	}
+	if n, _ := AvailableStock(t.Context(), first.ID, "", now); n != 10 {
+		t.Errorf("超过上限的购物项不保留，可以放入10本，实际为%d", n)
+	}
+	//减少数量后回到上限以内，重新保留
+	if err := UpdateBookCount(t.Context(), &model.CartItem{Book: second, Count: 3, CartID: cart.CartID}); err != nil {
+		t.Fatalf("修改购物项失败: %v", err)
+	}
+	if n, _ := AvailableStock(t.Context(), second.ID, "", now); n != 7 {
+		t.Errorf("上限以内的购物项应保留3本，可以放入7本，实际为%d", n)
+	}
+}
@@ -3,15 +3,28 @@ package dao
 import (
 	"bookstore/model"
 	"bookstore/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
+// sessionTouchInterval 两次更新最后访问时间的最小间隔，避免每个请求都写数据库
+const sessionTouchInterval = time.Minute
+
+// sqlSessionRepository 基于SQL数据库的Session数据访问实现
+type sqlSessionRepository struct {
+	db loggedDB
+}
+
 // AddSession 向数据库中添加Session
-func AddSession(sess *model.Session) error {
+func (r *sqlSessionRepository) AddSession(ctx context.Context, sess *model.Session) error {
 	//写sql语句
-	sqlStr := "insert into sessions values(?,?,?)"
+	sqlStr := "insert into sessions(session_id,username,user_id,created_at,last_seen_at,expires_at) values(?,?,?,?,?,?)"
 	//执行sql
-	_, err := utils.Db.Exec(sqlStr, sess.SessionID, sess.UserName, sess.UserID)
+	_, err := r.db.ExecContext(ctx, sqlStr, sess.SessionID, sess.UserName, sess.UserID, sess.CreatedAt.Unix(), sess.LastSeenAt.Unix(), sess.ExpiresAt.Unix())
 	if err != nil {
 		return err
 	}
@@ -19,45 +32,134 @@ func AddSession(sess *model.Session) error {
 }
 
 // DeleteSession 删除数据库中的Session
-func DeleteSession(sessID string) error {
+func (r *sqlSessionRepository) DeleteSession(ctx context.Context, sessID string) error {
 	//写sql语句
 	sqlStr := "delete from sessions where session_id = ?"
 	//执行sql
-	_, err := utils.Db.Exec(sqlStr, sessID)
+	_, err := r.db.ExecContext(ctx, sqlStr, sessID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// GetSession 根据session的Id值从数据库中查询Session
-func GetSession(sessID string) (*model.Session, error) {
+// GetSession 根据session的Id值从数据库中查询Session，Session不存在时返回UserID为0的Session
+func (r *sqlSessionRepository) GetSession(ctx context.Context, sessID string) (*model.Session, error) {
 	//写sql语句
-	sqlStr := "select session_id,username,user_id from sessions where session_id = ?"
-	//预编译
-	inStmt, err := utils.Db.Prepare(sqlStr)
-	if err != nil {
-		return nil, err
-	}
-	//执行
-	row := inStmt.QueryRow(sessID)
+	sqlStr := "select s.session_id,s.username,s.user_id,s.created_at,s.last_seen_at,s.expires_at,u.role from sessions s join users u on u.id = s.user_id where s.session_id = ?"
+	//执行，预编译的语句由r.db缓存，每次登录检查都重复使用
+	row := r.db.QueryRowContext(ctx, sqlStr, sessID)
 	//创建Session
 	sess := &model.Session{}
+	//时间以Unix时间戳保存
+	var createdAt, lastSeenAt, expiresAt int64
 	//扫描数据库中的字段值为Session的字段赋值
-	row.Scan(&sess.SessionID, &sess.UserName, &sess.UserID)
+	err := row.Scan(&sess.SessionID, &sess.UserName, &sess.UserID, &createdAt, &lastSeenAt, &expiresAt, &sess.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &model.Session{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sess.CreatedAt = time.Unix(createdAt, 0)
+	sess.LastSeenAt = time.Unix(lastSeenAt, 0)
+	sess.ExpiresAt = time.Unix(expiresAt, 0)
 	return sess, nil
 }
 
+// TouchSession 更新Session的最后访问时间和过期时间
+func (r *sqlSessionRepository) TouchSession(ctx context.Context, sess *model.Session) error {
+	//写sql语句
+	sqlStr := "update sessions set last_seen_at = ?, expires_at = ? where session_id = ?"
+	//执行sql
+	_, err := r.db.ExecContext(ctx, sqlStr, sess.LastSeenAt.Unix(), sess.ExpiresAt.Unix(), sess.SessionID)
+	return err
+}
+
+// DeleteSessionsByUserID 删除用户所有的Session，即在所有设备上注销
+func (r *sqlSessionRepository) DeleteSessionsByUserID(ctx context.Context, userID int) error {
+	//写sql语句
+	sqlStr := "delete from sessions where user_id = ?"
+	//执行sql
+	_, err := r.db.ExecContext(ctx, sqlStr, userID)
+	return err
+}
+
+// DeleteExpiredSessions 删除在指定时间之前已经过期的Session，返回删除的条数
+func (r *sqlSessionRepository) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	//写sql语句
+	sqlStr := "delete from sessions where expires_at <= ?"
+	//执行sql
+	res, err := r.db.ExecContext(ctx, sqlStr, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// NewSession 为登录的用户创建一个新的Session，Session的id每次登录都重新生成
+func NewSession(sessID string, user *model.User, now time.Time) *model.Session {
+	sess := &model.Session{
+		SessionID:  sessID,
+		UserName:   user.Username,
+		UserID:     user.ID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	sess.ExpiresAt = sessionExpiresAt(sess, now)
+	return sess
+}
+
+// sessionExpiresAt 根据配置计算Session在now访问之后的过期时间：空闲超时顺延，但不超过最长有效期
+func sessionExpiresAt(sess *model.Session, now time.Time) time.Time {
+	expiresAt := now.Add(conf.Session.IdleTimeout.Duration)
+	if maxAt := sess.CreatedAt.Add(conf.Session.MaxLifetime.Duration); maxAt.Before(expiresAt) {
+		expiresAt = maxAt
+	}
+	return expiresAt
+}
+
+// ValidateSession 根据Session的id获取有效的Session并顺延过期时间，
+// Session不存在、已经过期或者查询失败时返回nil，过期的Session会被删除
+func ValidateSession(ctx context.Context, sessID string, now time.Time) *model.Session {
+	session, err := GetSession(ctx, sessID)
+	if err != nil {
+		//查询失败时视为没有登录，但需要记录下来
+		utils.Logger(ctx).Error("查询Session失败", slog.Any("error", err))
+		return nil
+	}
+	if session.UserID <= 0 {
+		return nil
+	}
+	if session.IsExpired(now) {
+		if err := DeleteSession(ctx, sessID); err != nil {
+			utils.Logger(ctx).Error("删除过期的Session失败", slog.Any("error", err))
+		}
+		return nil
+	}
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		session.LastSeenAt = now
+		session.ExpiresAt = sessionExpiresAt(session, now)
+		//更新失败不影响本次访问，下次访问时会再次尝试
+		if err := TouchSession(ctx, session); err != nil {
+			utils.Logger(ctx).Error("更新Session的访问时间失败", slog.Any("error", err))
+		}
+	}
+	//之后的日志中都带上当前用户的id
+	utils.SetLogUser(ctx, session.UserID)
+	return session
+}
+
 // IsLogin 判断用户是否已经登录 false 没有登录 true 已经登录
 func IsLogin(r *http.Request) (bool, *model.Session) {
 	//根据Cookie的name获取Cookie
-	cookie, _ := r.Cookie("user")
+	cookie, _ := r.Cookie(conf.Session.CookieName)
 	if cookie != nil {
 		//获取Cookie的value
 		cookieValue := cookie.Value
-		//根据cookieValue去数据库中查询与之对应的Session
-		session, _ := GetSession(cookieValue)
-		if session.UserID > 0 {
+		//根据cookieValue去数据库中查询与之对应的Session，过期的Session视为没有登录
+		session := ValidateSession(r.Context(), cookieValue, time.Now())
+		if session != nil {
 			//已经登录
 			return true, session
 		}
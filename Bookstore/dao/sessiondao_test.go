@@ -0,0 +1,124 @@
+package dao
+
+import (
+	"bookstore/model"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSessionExpiry(t *testing.T) {
+	t.Run("测试空闲超时顺延", testSessionSlidingExpiry)
+	t.Run("测试最长有效期", testSessionMaxLifetime)
+	t.Run("测试在所有设备上注销", testDeleteSessionsByUserID)
+	t.Run("测试清理过期Session", testDeleteExpiredSessions)
+	t.Run("测试IsLogin拒绝过期Session", testIsLoginExpired)
+}
+
+func testSessionSlidingExpiry(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	user := &model.User{ID: 1, Username: "user1"}
+	sess := NewSession("sliding-session", user, now)
+	if err := AddSession(t.Context(), sess); err != nil {
+		t.Fatalf("添加Session失败: %v", err)
+	}
+	defer DeleteSession(t.Context(), sess.SessionID)
+	//快到空闲超时的时候访问一次，过期时间应顺延
+	later := now.Add(conf.Session.IdleTimeout.Duration - time.Minute)
+	got := ValidateSession(t.Context(), sess.SessionID, later)
+	if got == nil {
+		t.Fatalf("Session在空闲超时之前应有效")
+	}
+	if want := later.Add(conf.Session.IdleTimeout.Duration); !got.ExpiresAt.Equal(want) {
+		t.Errorf("过期时间应顺延到%v，实际为%v", want, got.ExpiresAt)
+	}
+	got, _ = GetSession(t.Context(), sess.SessionID)
+	if !got.LastSeenAt.Equal(later) {
+		t.Errorf("最后访问时间应保存到数据库，实际为%v", got.LastSeenAt)
+	}
+	//超过空闲时间没有访问，Session过期并被删除
+	if ValidateSession(t.Context(), sess.SessionID, later.Add(conf.Session.IdleTimeout.Duration)) != nil {
+		t.Errorf("Session空闲超时后应失效")
+	}
+	if got, _ := GetSession(t.Context(), sess.SessionID); got.UserID != 0 {
+		t.Errorf("过期的Session应被删除")
+	}
+}
+
+func testSessionMaxLifetime(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	sess := NewSession("lifetime-session", &model.User{ID: 1, Username: "user1"}, now)
+	if err := AddSession(t.Context(), sess); err != nil {
+		t.Fatalf("添加Session失败: %v", err)
+	}
+	defer DeleteSession(t.Context(), sess.SessionID)
+	//一直在访问，但过期时间不能超过最长有效期
+	at := now
+	for at.Before(now.Add(conf.Session.MaxLifetime.Duration - conf.Session.IdleTimeout.Duration)) {
+		at = at.Add(conf.Session.IdleTimeout.Duration / 2)
+		if ValidateSession(t.Context(), sess.SessionID, at) == nil {
+			t.Fatalf("Session在%v时应有效", at)
+		}
+	}
+	got, _ := GetSession(t.Context(), sess.SessionID)
+	if want := now.Add(conf.Session.MaxLifetime.Duration); got.ExpiresAt.After(want) {
+		t.Errorf("过期时间不应超过%v，实际为%v", want, got.ExpiresAt)
+	}
+	if ValidateSession(t.Context(), sess.SessionID, now.Add(conf.Session.MaxLifetime.Duration)) != nil {
+		t.Errorf("超过最长有效期后Session应失效")
+	}
+}
+
+func testDeleteSessionsByUserID(t *testing.T) {
+	now := time.Now()
+	for _, id := range []string{"device-1", "device-2"} {
+		AddSession(t.Context(), NewSession(id, &model.User{ID: 2, Username: "user2"}, now))
+	}
+	other := NewSession("other-user-device", &model.User{ID: 3, Username: "user3"}, now)
+	AddSession(t.Context(), other)
+	defer DeleteSession(t.Context(), other.SessionID)
+	if err := DeleteSessionsByUserID(t.Context(), 2); err != nil {
+		t.Fatalf("注销所有设备失败: %v", err)
+	}
+	for _, id := range []string{"device-1", "device-2"} {
+		if ValidateSession(t.Context(), id, now) != nil {
+			t.Errorf("Session %s应已被删除", id)
+		}
+	}
+	if ValidateSession(t.Context(), other.SessionID, now) == nil {
+		t.Errorf("其他用户的Session不应被删除")
+	}
+}
+
+func testDeleteExpiredSessions(t *testing.T) {
+	now := time.Now()
+	expired := NewSession("expired-session", &model.User{ID: 1, Username: "user1"}, now.Add(-conf.Session.MaxLifetime.Duration))
+	AddSession(t.Context(), expired)
+	valid := NewSession("valid-session", &model.User{ID: 1, Username: "user1"}, now)
+	AddSession(t.Context(), valid)
+	defer DeleteSession(t.Context(), valid.SessionID)
+	n, err := DeleteExpiredSessions(t.Context(), now)
+	if err != nil {
+		t.Fatalf("清理过期Session失败: %v", err)
+	}
+	if n < 1 {
+		t.Errorf("应至少清理1个过期的Session，实际为%d", n)
+	}
+	if got, _ := GetSession(t.Context(), expired.SessionID); got.UserID != 0 {
+		t.Errorf("过期的Session应被清理")
+	}
+	if got, _ := GetSession(t.Context(), valid.SessionID); got.UserID == 0 {
+		t.Errorf("有效的Session不应被清理")
+	}
+}
+
+func testIsLoginExpired(t *testing.T) {
+	now := time.Now()
+	expired := NewSession("islogin-expired", &model.User{ID: 1, Username: "user1"}, now.Add(-conf.Session.MaxLifetime.Duration))
+	AddSession(t.Context(), expired)
+	r := httptest.NewRequest("GET", "/main", nil)
+	r.Header.Set("Cookie", "user="+expired.SessionID)
+	if flag, _ := IsLogin(r); flag {
+		t.Errorf("过期的Session不应视为已登录")
+	}
+}
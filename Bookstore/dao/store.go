@@ -0,0 +1,433 @@
+package dao
+
+import (
+	"bookstore/config"
+	"bookstore/model"
+	"bookstore/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// current 当前使用的数据访问实现，由Setup或Use设置
+var current *Store
+
+// conf 当前使用的配置，由Setup设置
+var conf = config.Default()
+
+// NewMySQLStore 创建基于MySQL的数据访问实现
+func NewMySQLStore(db *sql.DB) *Store {
+	return newSQLStore(db, utils.DriverMySQL)
+}
+
+// NewSQLiteStore 创建基于嵌入式SQLite的数据访问实现，并自动将表结构升级到最新版本
+func NewSQLiteStore(db *sql.DB) (*Store, error) {
+	m, err := NewMigrator(db, utils.DriverSQLite)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.Up(); err != nil {
+		return nil, err
+	}
+	return newSQLStore(db, utils.DriverSQLite), nil
+}
+
+// newSQLStore MySQL和SQLite使用相同的SQL语句，因此共用一套实现，
+// 个别语法上的差异由driver区分
+func newSQLStore(sqlDB *sql.DB, driver string) *Store {
+	db := newLoggedDB(sqlDB)
+	return &Store{
+		Books:     &sqlBookRepository{db: db},
+		Carts:     &sqlCartRepository{db: db},
+		Orders:    &sqlOrderRepository{db: db, driver: driver},
+		Users:     &sqlUserRepository{db: db},
+		Addresses: &sqlAddressRepository{db: db},
+		Payments:  &sqlPaymentRepository{db: db, driver: driver},
+		Sessions:  &sqlSessionRepository{db: db},
+	}
+}
+
+// Setup 根据配置打开数据库并选择对应的数据访问实现，应在启动时调用一次，
+// 数据库的版本低于程序需要的版本时返回*SchemaVersionError，需要先执行 migrate up
+func Setup(cfg *config.Config) error {
+	dbConf := cfg.Database
+	db, err := utils.OpenDB(dbConf.Driver, dbConf.DSN)
+	if err != nil {
+		return err
+	}
+	//SQLite只使用一个连接，由OpenDB设置
+	if dbConf.Driver == utils.DriverMySQL {
+		db.SetMaxOpenConns(dbConf.MaxOpenConns)
+		db.SetMaxIdleConns(dbConf.MaxIdleConns)
+		db.SetConnMaxLifetime(dbConf.ConnMaxLifetime.Duration)
+	}
+	var store *Store
+	if dbConf.Driver == utils.DriverSQLite && isMemoryDSN(dbConf.DSN) {
+		//内存中的SQLite数据库每次启动都是空的，直接创建最新的表结构
+		store, err = NewSQLiteStore(db)
+	} else {
+		store, err = checkedStore(db, dbConf.Driver)
+	}
+	if err != nil {
+		db.Close()
+		return err
+	}
+	conf = cfg
+	Use(store)
+	return nil
+}
+
+// checkedStore 检查数据库的版本，与程序需要的版本一致时才创建数据访问实现
+func checkedStore(db *sql.DB, driver string) (*Store, error) {
+	m, err := NewMigrator(db, driver)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Check(); err != nil {
+		return nil, err
+	}
+	return newSQLStore(db, driver), nil
+}
+
+// isMemoryDSN 判断SQLite的连接信息是否是内存数据库
+func isMemoryDSN(dsn string) bool {
+	return dsn == "" || dsn == utils.DefaultSQLiteDSN || strings.Contains(dsn, "mode=memory")
+}
+
+// Ping 检查数据库连接是否可用，用于就绪检查
+func Ping(ctx context.Context) error {
+	if utils.Db == nil {
+		return errors.New("数据库还没有打开")
+	}
+	return utils.Db.PingContext(ctx)
+}
+
+// Close 关闭数据库连接，应在HTTP服务停止之后调用
+func Close() error {
+	if utils.Db == nil {
+		return nil
+	}
+	return utils.Db.Close()
+}
+
+// Use 设置当前使用的数据访问实现
+func Use(store *Store) {
+	current = store
+}
+
+// Current 获取当前使用的数据访问实现，测试中可以替换其中的一部分后再通过Use设置
+func Current() *Store {
+	return current
+}
+
+// GetBooks 获取数据库中所有的图书
+func GetBooks(ctx context.Context) ([]*model.Book, error) {
+	return current.Books.GetBooks(ctx)
+}
+
+// AddBook 向数据库中添加一本图书
+func AddBook(ctx context.Context, b *model.Book) error {
+	return current.Books.AddBook(ctx, b)
+}
+
+// DeleteBook 根据图书的id从数据库中删除一本图书
+func DeleteBook(ctx context.Context, bookID string) error {
+	return current.Books.DeleteBook(ctx, bookID)
+}
+
+// GetBookByID 根据图书的id从数据库中查询出一本图书
+func GetBookByID(ctx context.Context, bookID string) (*model.Book, error) {
+	return current.Books.GetBookByID(ctx, bookID)
+}
+
+// UpdateBook 根据图书的id更新图书信息
+func UpdateBook(ctx context.Context, b *model.Book) error {
+	return current.Books.UpdateBook(ctx, b)
+}
+
+// GetPageBooks 获取带分页的图书信息
+func GetPageBooks(ctx context.Context, pageNo string) (*model.Page, error) {
+	return current.Books.GetPageBooks(ctx, pageNo)
+}
+
+// GetPageBooksByPrice 获取带分页和价格范围的图书信息
+func GetPageBooksByPrice(ctx context.Context, pageNo string, minPrice string, maxPrice string) (*model.Page, error) {
+	return current.Books.GetPageBooksByPrice(ctx, pageNo, minPrice, maxPrice)
+}
+
+// SearchBooks 根据书名和作者的关键字、价格范围、库存等条件获取带分页和排序的图书信息
+func SearchBooks(ctx context.Context, q *BookQuery) (*model.Page, error) {
+	return current.Books.SearchBooks(ctx, q)
+}
+
+// AddCart 向购物车表中插入购物车
+func AddCart(ctx context.Context, cart *model.Cart) error {
+	return current.Carts.AddCart(ctx, cart)
+}
+
+// GetCartByUserID 根据用户的id从数据库中查询对应的购物车
+func GetCartByUserID(ctx context.Context, userID int) (*model.Cart, error) {
+	return current.Carts.GetCartByUserID(ctx, userID)
+}
+
+// GetCartByCartID 根据购物车的id从数据库中查询对应的购物车
+func GetCartByCartID(ctx context.Context, cartID string) (*model.Cart, error) {
+	return current.Carts.GetCartByCartID(ctx, cartID)
+}
+
+// GetCartByCartItemID 根据购物项的id查询该购物项所属的购物车
+func GetCartByCartItemID(ctx context.Context, cartItemID string) (*model.Cart, error) {
+	return current.Carts.GetCartByCartItemID(ctx, cartItemID)
+}
+
+// UpdateCart 更新购物车中的图书的总数量和总金额
+func UpdateCart(ctx context.Context, cart *model.Cart) error {
+	return current.Carts.UpdateCart(ctx, cart)
+}
+
+// DeleteCartByCartID 根据购物车的id删除购物车
+func DeleteCartByCartID(ctx context.Context, cartID string) error {
+	return current.Carts.DeleteCartByCartID(ctx, cartID)
+}
+
+// AddCartItem 向购物项表中插入购物项
+func AddCartItem(ctx context.Context, cartItem *model.CartItem) error {
+	return current.Carts.AddCartItem(ctx, cartItem)
+}
+
+// GetCartItemByBookIDAndCartID 根据图书的id和购物车的id获取对应的购物项
+func GetCartItemByBookIDAndCartID(ctx context.Context, bookID string, cartID string) (*model.CartItem, error) {
+	return current.Carts.GetCartItemByBookIDAndCartID(ctx, bookID, cartID)
+}
+
+// GetCartItemByID 根据购物项的id获取对应的购物项
+func GetCartItemByID(ctx context.Context, cartItemID string) (*model.CartItem, error) {
+	return current.Carts.GetCartItemByID(ctx, cartItemID)
+}
+
+// UpdateBookCount 根据购物项中的相关信息更新购物项中图书的数量和金额小计
+func UpdateBookCount(ctx context.Context, cartItem *model.CartItem) error {
+	return current.Carts.UpdateBookCount(ctx, cartItem)
+}
+
+// GetCartItemsByCartID 根据购物车的id获取购物车中所有的购物项
+func GetCartItemsByCartID(ctx context.Context, cartID string) ([]*model.CartItem, error) {
+	return current.Carts.GetCartItemsByCartID(ctx, cartID)
+}
+
+// DeleteCartItemsByCartID 根据购物车的id删除所有的购物项
+func DeleteCartItemsByCartID(ctx context.Context, cartID string) error {
+	return current.Carts.DeleteCartItemsByCartID(ctx, cartID)
+}
+
+// DeleteCartItemByID 根据购物项的id删除购物项
+func DeleteCartItemByID(ctx context.Context, cartItemID string) error {
+	return current.Carts.DeleteCartItemByID(ctx, cartItemID)
+}
+
+// MergeGuestCart 将游客购物车合并到用户的购物车中
+func MergeGuestCart(ctx context.Context, guestCartID string, userID int) ([]StockShortage, error) {
+	return current.Carts.MergeGuestCart(ctx, guestCartID, userID)
+}
+
+// DeleteExpiredGuestCarts 删除在before之后没有修改过的游客购物车
+func DeleteExpiredGuestCarts(ctx context.Context, before time.Time) (int64, error) {
+	return current.Carts.DeleteExpiredGuestCarts(ctx, before)
+}
+
+// AvailableStock 获取图书可以放入购物车的数量，即库存减去其他购物车保留的数量
+func AvailableStock(ctx context.Context, bookID int, cartID string, now time.Time) (int64, error) {
+	return current.Carts.AvailableStock(ctx, bookID, cartID, now)
+}
+
+// ReleaseExpiredReservations 释放已经过期的库存保留
+func ReleaseExpiredReservations(ctx context.Context, now time.Time) (int64, error) {
+	return current.Carts.ReleaseExpiredReservations(ctx, now)
+}
+
+// RecalculateCartTotals 按照图书当前的价格重新计算购物车中保存的金额，返回被修正的购物车的数量
+func RecalculateCartTotals(ctx context.Context) (int64, error) {
+	return current.Carts.RecalculateCartTotals(ctx)
+}
+
+// RecalculateBookCarts 按照图书当前的价格重新计算有该图书的购物车中保存的金额，返回被修正的购物车的数量
+func RecalculateBookCarts(ctx context.Context, bookID int) (int64, error) {
+	return current.Carts.RecalculateBookCarts(ctx, bookID)
+}
+
+// AddOrder 向数据库中插入订单
+func AddOrder(ctx context.Context, order *model.Order) error {
+	return current.Orders.AddOrder(ctx, order)
+}
+
+// GetOrders 获取数据库中所有的订单
+func GetOrders(ctx context.Context) ([]*model.Order, error) {
+	return current.Orders.GetOrders(ctx)
+}
+
+// GetOrderByID 根据订单号获取订单
+func GetOrderByID(ctx context.Context, orderID string) (*model.Order, error) {
+	return current.Orders.GetOrderByID(ctx, orderID)
+}
+
+// GetMyOrders 获取我的订单
+func GetMyOrders(ctx context.Context, userID int) ([]*model.Order, error) {
+	return current.Orders.GetMyOrders(ctx, userID)
+}
+
+// GetMyOrdersWithItems 获取我的订单以及每个订单的订单项
+func GetMyOrdersWithItems(ctx context.Context, userID int) ([]*model.Order, error) {
+	return current.Orders.GetMyOrdersWithItems(ctx, userID)
+}
+
+// TransitionOrder 按照订单的状态机变更订单的状态并记录操作人和时间，actorID为0时表示系统自动变更
+func TransitionOrder(ctx context.Context, orderID string, to int64, actorID int) error {
+	return current.Orders.TransitionOrder(ctx, orderID, to, actorID)
+}
+
+// GetOrderHistory 根据订单号获取订单状态的变更记录
+func GetOrderHistory(ctx context.Context, orderID string) ([]*model.OrderHistory, error) {
+	return current.Orders.GetOrderHistory(ctx, orderID)
+}
+
+// AddOrderItem 向数据库中插入订单项
+func AddOrderItem(ctx context.Context, orderItem *model.OrderItem) error {
+	return current.Orders.AddOrderItem(ctx, orderItem)
+}
+
+// GetOrderItemsByOrderID 根据订单号获取该订单的所有订单项
+func GetOrderItemsByOrderID(ctx context.Context, orderID string) ([]*model.OrderItem, error) {
+	return current.Orders.GetOrderItemsByOrderID(ctx, orderID)
+}
+
+// GetOrderItemsByUserID 获取用户所有订单的订单项
+func GetOrderItemsByUserID(ctx context.Context, userID int) ([]*model.OrderItem, error) {
+	return current.Orders.GetOrderItemsByUserID(ctx, userID)
+}
+
+// Checkout 在一个事务中将购物车转换为订单
+func Checkout(ctx context.Context, order *model.Order, cart *model.Cart) error {
+	return current.Orders.Checkout(ctx, order, cart)
+}
+
+// CancelOrder 取消还没有发货的订单并恢复图书的库存和销量
+func CancelOrder(ctx context.Context, orderID string, actorID int) error {
+	return current.Orders.CancelOrder(ctx, orderID, actorID)
+}
+
+// CheckUserNameAndPassword 根据用户名和密码从数据库中查询一条记录
+func CheckUserNameAndPassword(ctx context.Context, username string, password string) (*model.User, error) {
+	return current.Users.CheckUserNameAndPassword(ctx, username, password)
+}
+
+// CheckUserName 根据用户名从数据库中查询一条记录
+func CheckUserName(ctx context.Context, username string) (*model.User, error) {
+	return current.Users.CheckUserName(ctx, username)
+}
+
+// SaveUser 向数据库中插入用户信息
+func SaveUser(ctx context.Context, username string, password string, email string) error {
+	return current.Users.SaveUser(ctx, username, password, email)
+}
+
+// SaveAdmin 将用户设置为管理员并重新设置密码，用户不存在时创建
+func SaveAdmin(ctx context.Context, username string, password string, email string) (bool, error) {
+	return current.Users.SaveAdmin(ctx, username, password, email)
+}
+
+// GetUsers 获取所有的用户
+func GetUsers(ctx context.Context) ([]*model.User, error) {
+	return current.Users.GetUsers(ctx)
+}
+
+// UpdateUserRole 修改用户的角色
+func UpdateUserRole(ctx context.Context, userID int, role string) error {
+	return current.Users.UpdateUserRole(ctx, userID, role)
+}
+
+// AddAddress 向用户的地址簿中添加收货地址
+func AddAddress(ctx context.Context, address *model.Address) error {
+	return current.Addresses.AddAddress(ctx, address)
+}
+
+// GetAddressesByUserID 获取用户保存的所有收货地址
+func GetAddressesByUserID(ctx context.Context, userID int) ([]*model.Address, error) {
+	return current.Addresses.GetAddressesByUserID(ctx, userID)
+}
+
+// GetAddressByID 根据id获取收货地址
+func GetAddressByID(ctx context.Context, addressID string) (*model.Address, error) {
+	return current.Addresses.GetAddressByID(ctx, addressID)
+}
+
+// UpdateAddress 修改收货地址
+func UpdateAddress(ctx context.Context, address *model.Address) error {
+	return current.Addresses.UpdateAddress(ctx, address)
+}
+
+// DeleteAddressByID 删除收货地址
+func DeleteAddressByID(ctx context.Context, addressID string) error {
+	return current.Addresses.DeleteAddressByID(ctx, addressID)
+}
+
+// AddPayment 保存一条支付记录
+func AddPayment(ctx context.Context, p *model.Payment) error {
+	return current.Payments.AddPayment(ctx, p)
+}
+
+// GetPaymentByRef 根据网关的名称和网关中的支付号获取支付记录
+func GetPaymentByRef(ctx context.Context, provider string, ref string) (*model.Payment, error) {
+	return current.Payments.GetPaymentByRef(ctx, provider, ref)
+}
+
+// GetPaymentsByOrderID 获取订单所有的支付记录
+func GetPaymentsByOrderID(ctx context.Context, orderID string) ([]*model.Payment, error) {
+	return current.Payments.GetPaymentsByOrderID(ctx, orderID)
+}
+
+// SetPaymentRef 保存网关中的支付号
+func SetPaymentRef(ctx context.Context, paymentID int64, ref string) error {
+	return current.Payments.SetPaymentRef(ctx, paymentID, ref)
+}
+
+// UpdatePaymentStatus 变更支付的状态
+func UpdatePaymentStatus(ctx context.Context, paymentID int64, from string, to string) error {
+	return current.Payments.UpdatePaymentStatus(ctx, paymentID, from, to)
+}
+
+// CapturePayment 将已授权的支付变更为已扣款，并将订单变更为已付款
+func CapturePayment(ctx context.Context, paymentID int64, orderID string) error {
+	return current.Payments.CapturePayment(ctx, paymentID, orderID)
+}
+
+// AddSession 向数据库中添加Session
+func AddSession(ctx context.Context, sess *model.Session) error {
+	return current.Sessions.AddSession(ctx, sess)
+}
+
+// DeleteSession 删除数据库中的Session
+func DeleteSession(ctx context.Context, sessID string) error {
+	return current.Sessions.DeleteSession(ctx, sessID)
+}
+
+// GetSession 根据session的Id值从数据库中查询Session
+func GetSession(ctx context.Context, sessID string) (*model.Session, error) {
+	return current.Sessions.GetSession(ctx, sessID)
+}
+
+// TouchSession 更新Session的最后访问时间和过期时间
+func TouchSession(ctx context.Context, sess *model.Session) error {
+	return current.Sessions.TouchSession(ctx, sess)
+}
+
+// DeleteSessionsByUserID 删除用户所有的Session，即在所有设备上注销
+func DeleteSessionsByUserID(ctx context.Context, userID int) error {
+	return current.Sessions.DeleteSessionsByUserID(ctx, userID)
+}
+
+// DeleteExpiredSessions 删除在指定时间之前已经过期的Session
+func DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	return current.Sessions.DeleteExpiredSessions(ctx, now)
+}
@@ -0,0 +1,157 @@
+package dao
+
+import (
+	"bookstore/model"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	t.Run("测试图书的增删改查", testStoreBooks)
+	t.Run("测试购物车和购物项", testStoreCart)
+	t.Run("测试订单和订单项", testStoreOrder)
+	t.Run("测试用户和Session", testStoreUserSession)
+}
+
+func testStoreBooks(t *testing.T) {
+	book := &model.Book{Title: "嵌入式图书", Author: "测试作者", Price: model.Cents(1250), Sales: 0, Stock: 10, ImgPath: "/static/img/default.jpg"}
+	if err := AddBook(t.Context(), book); err != nil {
+		t.Fatalf("添加图书失败: %v", err)
+	}
+	books, err := GetBooks(t.Context())
+	if err != nil {
+		t.Fatalf("获取图书失败: %v", err)
+	}
+	var added *model.Book
+	for _, v := range books {
+		if v.Title == book.Title {
+			added = v
+		}
+	}
+	if added == nil {
+		t.Fatalf("没有找到刚添加的图书")
+	}
+	bookID := strconv.Itoa(added.ID)
+	added.Stock = 5
+	if err := UpdateBook(t.Context(), added); err != nil {
+		t.Fatalf("更新图书失败: %v", err)
+	}
+	got, _ := GetBookByID(t.Context(), bookID)
+	if got.Stock != 5 {
+		t.Errorf("库存应为5，实际为%d", got.Stock)
+	}
+	page, err := GetPageBooksByPrice(t.Context(), "1", "12", "13")
+	if err != nil {
+		t.Fatalf("按价格分页获取图书失败: %v", err)
+	}
+	if page.TotalRecord != 1 || len(page.Books) != 1 {
+		t.Errorf("价格范围内应只有1本图书，实际为%d", page.TotalRecord)
+	}
+	if err := DeleteBook(t.Context(), bookID); err != nil {
+		t.Fatalf("删除图书失败: %v", err)
+	}
+	got, _ = GetBookByID(t.Context(), bookID)
+	if got.ID != 0 {
+		t.Errorf("图书应已被删除")
+	}
+}
+
+func testStoreCart(t *testing.T) {
+	book, _ := GetBookByID(t.Context(), "1")
+	cart := &model.Cart{
+		CartID: "store-test-cart",
+		UserID: 1,
+		CartItems: []*model.CartItem{
+			{Book: book, Count: 2, CartID: "store-test-cart"},
+		},
+	}
+	if err := AddCart(t.Context(), cart); err != nil {
+		t.Fatalf("添加购物车失败: %v", err)
+	}
+	defer DeleteCartByCartID(t.Context(), cart.CartID)
+	got, err := GetCartByUserID(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("获取购物车失败: %v", err)
+	}
+	if len(got.CartItems) != 1 || got.CartItems[0].Book.Title != book.Title {
+		t.Fatalf("购物项不正确: %+v", got.CartItems)
+	}
+	if got.TotalAmount != book.Price.Mul(2) {
+		t.Errorf("总金额应为%v，实际为%v", book.Price.Mul(2), got.TotalAmount)
+	}
+	item := got.CartItems[0]
+	item.Count = 3
+	if err := UpdateBookCount(t.Context(), item); err != nil {
+		t.Fatalf("更新购物项失败: %v", err)
+	}
+	if err := UpdateCart(t.Context(), got); err != nil {
+		t.Fatalf("更新购物车失败: %v", err)
+	}
+	got, _ = GetCartByUserID(t.Context(), 1)
+	if got.TotalCount != 3 {
+		t.Errorf("总数量应为3，实际为%d", got.TotalCount)
+	}
+	if err := DeleteCartByCartID(t.Context(), cart.CartID); err != nil {
+		t.Fatalf("删除购物车失败: %v", err)
+	}
+	if _, err := GetCartByUserID(t.Context(), 1); err == nil {
+		t.Errorf("购物车应已被删除")
+	}
+}
+
+func testStoreOrder(t *testing.T) {
+	order := &model.Order{
+		OrderID:     "store-test-order",
+		CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+		TotalCount:  1,
+		TotalAmount: model.Cents(8900),
+		State:       model.OrderStatePaid,
+		UserID:      2,
+	}
+	if err := AddOrder(t.Context(), order); err != nil {
+		t.Fatalf("添加订单失败: %v", err)
+	}
+	orderItem := &model.OrderItem{Count: 1, Amount: model.Cents(8900), Title: "Go编程实战", Author: "John Smith", Price: model.Cents(8900), ImgPath: "/static/img/Go.jpg", OrderID: order.OrderID}
+	if err := AddOrderItem(t.Context(), orderItem); err != nil {
+		t.Fatalf("添加订单项失败: %v", err)
+	}
+	if err := TransitionOrder(t.Context(), order.OrderID, model.OrderStateShipped, 0); err != nil {
+		t.Fatalf("更新订单状态失败: %v", err)
+	}
+	orders, _ := GetMyOrders(t.Context(), 2)
+	if len(orders) != 1 || orders[0].State != model.OrderStateShipped || orders[0].CreateTime != order.CreateTime {
+		t.Fatalf("我的订单不正确: %+v", orders)
+	}
+	orderItems, _ := GetOrderItemsByOrderID(t.Context(), order.OrderID)
+	if len(orderItems) != 1 || orderItems[0].Title != orderItem.Title {
+		t.Errorf("订单项不正确: %+v", orderItems)
+	}
+}
+
+func testStoreUserSession(t *testing.T) {
+	if err := SaveUser(t.Context(), "storeuser", "secret", "storeuser@example.com"); err != nil {
+		t.Fatalf("保存用户失败: %v", err)
+	}
+	user, _ := CheckUserNameAndPassword(t.Context(), "storeuser", "secret")
+	if user.ID == 0 {
+		t.Fatalf("用户名和密码应验证通过")
+	}
+	user, _ = CheckUserNameAndPassword(t.Context(), "storeuser", "wrong")
+	if user.ID != 0 {
+		t.Errorf("错误的密码不应验证通过")
+	}
+	sess := &model.Session{SessionID: "store-test-session", UserName: "storeuser", UserID: 1}
+	if err := AddSession(t.Context(), sess); err != nil {
+		t.Fatalf("添加Session失败: %v", err)
+	}
+	got, _ := GetSession(t.Context(), sess.SessionID)
+	if got.UserName != sess.UserName {
+		t.Errorf("Session不正确: %+v", got)
+	}
+	DeleteSession(t.Context(), sess.SessionID)
+	got, _ = GetSession(t.Context(), sess.SessionID)
+	if got.UserID != 0 {
+		t.Errorf("Session应已被删除")
+	}
+}
@@ -0,0 +1,53 @@
+package dao
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// StartSessionSweeper 启动后台协程，每隔interval清理一次过期的Session，调用返回的函数停止清理
+func StartSessionSweeper(interval time.Duration) (stop func()) {
+	return startSweeper(interval, "过期Session", func(now time.Time) (int64, error) {
+		return DeleteExpiredSessions(context.Background(), now)
+	})
+}
+
+// StartGuestCartSweeper 启动后台协程，每隔interval清理一次超过ttl没有修改的游客购物车，调用返回的函数停止清理
+func StartGuestCartSweeper(interval time.Duration, ttl time.Duration) (stop func()) {
+	return startSweeper(interval, "过期的游客购物车", func(now time.Time) (int64, error) {
+		return DeleteExpiredGuestCarts(context.Background(), now.Add(-ttl))
+	})
+}
+
+// StartReservationSweeper 启动后台协程，每隔interval释放一次过期的库存保留，调用返回的函数停止清理
+func StartReservationSweeper(interval time.Duration) (stop func()) {
+	return startSweeper(interval, "过期的库存保留", func(now time.Time) (int64, error) {
+		return ReleaseExpiredReservations(context.Background(), now)
+	})
+}
+
+// startSweeper 启动后台协程，每隔interval执行一次sweep，并记录清理的数量
+func startSweeper(interval time.Duration, what string, sweep func(now time.Time) (int64, error)) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				n, err := sweep(now)
+				if err != nil {
+					slog.Error("清理"+what+"失败", slog.Any("error", err))
+				} else if n > 0 {
+					slog.Info("清理"+what, slog.Int64("count", n))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
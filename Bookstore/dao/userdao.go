@@ -1,40 +1,141 @@
 package dao
 
 import (
-	"bookstore0612/model"
-	"bookstore0612/utils"
+	"bookstore/model"
+	"bookstore/utils"
+	"context"
+	"database/sql"
+	"errors"
 )
 
-//CheckUserNameAndPassword 根据用户名和密码从数据库中查询一条记录
-func CheckUserNameAndPassword(username string, password string) (*model.User, error) {
+// sqlUserRepository 基于SQL数据库的用户数据访问实现
+type sqlUserRepository struct {
+	db loggedDB
+}
+
+// CheckUserNameAndPassword 根据用户名查询用户并验证密码，验证失败时返回的用户id为0。
+// 早期以明文保存的密码会在验证通过后自动替换为哈希值
+func (r *sqlUserRepository) CheckUserNameAndPassword(ctx context.Context, username string, password string) (*model.User, error) {
+	//密码在Go中验证，不再作为sql语句的查询条件
+	user, err := r.CheckUserName(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return user, nil
+	}
+	ok, needRehash := utils.CheckPassword(user.Password, password)
+	if !ok {
+		return &model.User{}, nil
+	}
+	if needRehash {
+		//升级失败不影响本次登录，下次登录时会再次尝试
+		if hash, err := utils.HashPassword(password); err == nil {
+			if r.updatePassword(ctx, user.ID, hash) == nil {
+				user.Password = hash
+			}
+		}
+	}
+	return user, nil
+}
+
+// updatePassword 更新用户保存的密码哈希值
+func (r *sqlUserRepository) updatePassword(ctx context.Context, userID int, hash string) error {
 	//写sql语句
-	sqlStr := "select id,username,password,email from users where username = ? and password = ?"
+	sqlStr := "update users set password = ? where id = ?"
 	//执行
-	row := utils.Db.QueryRow(sqlStr, username, password)
-	user := &model.User{}
-	row.Scan(&user.ID, &user.Username, &user.Password, &user.Email)
-	return user, nil
+	_, err := r.db.ExecContext(ctx, sqlStr, hash, userID)
+	return err
 }
 
-//CheckUserName 根据用户名和密码从数据库中查询一条记录
-func CheckUserName(username string) (*model.User, error) {
+// CheckUserName 根据用户名从数据库中查询一条记录，用户不存在时返回的用户id为0
+func (r *sqlUserRepository) CheckUserName(ctx context.Context, username string) (*model.User, error) {
 	//写sql语句
-	sqlStr := "select id,username,password,email from users where username = ?"
+	sqlStr := "select id,username,password,email,role from users where username = ?"
 	//执行
-	row := utils.Db.QueryRow(sqlStr, username)
+	row := r.db.QueryRowContext(ctx, sqlStr, username)
 	user := &model.User{}
-	row.Scan(&user.ID, &user.Username, &user.Password, &user.Email)
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &model.User{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
-//SaveUser 向数据库中插入用户信息
-func SaveUser(username string, password string, email string) error {
-	//写sql语句
-	sqlStr := "insert into users(username,password,email) values(?,?,?)"
+// SaveUser 向数据库中插入用户信息，密码以哈希值保存
+func (r *sqlUserRepository) SaveUser(ctx context.Context, username string, password string, email string) error {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	//写sql语句，新注册的用户都是顾客
+	sqlStr := "insert into users(username,password,email,role) values(?,?,?,?)"
 	//执行
-	_, err := utils.Db.Exec(sqlStr, username, password, email)
+	_, err = r.db.ExecContext(ctx, sqlStr, username, hash, email, model.RoleCustomer)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// SaveAdmin 将用户设置为管理员并重新设置密码，用户不存在时以email创建，created表示是否新创建了用户。
+// 用于部署后创建第一个管理员，或者找回管理员的密码
+func (r *sqlUserRepository) SaveAdmin(ctx context.Context, username string, password string, email string) (created bool, err error) {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return false, err
+	}
+	user, err := r.CheckUserName(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	if user.ID != 0 {
+		//写sql语句
+		sqlStr := "update users set password = ?, role = ? where id = ?"
+		//执行
+		_, err = r.db.ExecContext(ctx, sqlStr, hash, model.RoleAdmin, user.ID)
+		return false, err
+	}
+	if email == "" {
+		return false, errors.New("创建新用户时需要邮箱")
+	}
+	//写sql语句
+	sqlStr := "insert into users(username,password,email,role) values(?,?,?,?)"
+	//执行
+	_, err = r.db.ExecContext(ctx, sqlStr, username, hash, email, model.RoleAdmin)
+	return err == nil, err
+}
+
+// GetUsers 获取所有的用户
+func (r *sqlUserRepository) GetUsers(ctx context.Context) ([]*model.User, error) {
+	//写sql语句
+	sqlStr := "select id,username,email,role from users order by id"
+	//执行
+	rows, err := r.db.QueryContext(ctx, sqlStr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []*model.User
+	for rows.Next() {
+		user := &model.User{}
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// UpdateUserRole 修改用户的角色
+func (r *sqlUserRepository) UpdateUserRole(ctx context.Context, userID int, role string) error {
+	//写sql语句
+	sqlStr := "update users set role = ? where id = ?"
+	//执行
+	_, err := r.db.ExecContext(ctx, sqlStr, role, userID)
+	return err
+}
@@ -1,15 +1,25 @@
 package dao
 
 import (
+	"bookstore/config"
 	"bookstore/model"
+	"bookstore/utils"
 	"fmt"
+	"os"
 	"testing"
 	"time"
 )
 
 func TestMain(m *testing.M) {
 	// fmt.Println("测试bookdao中的方法")
-	m.Run()
+	//使用嵌入式数据库运行测试，无需MySQL服务
+	cfg := config.Default()
+	cfg.Database.Driver = utils.DriverSQLite
+	if err := Setup(cfg); err != nil {
+		fmt.Println("初始化测试数据库失败：", err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
 }
 
 func TestUser(t *testing.T) {
@@ -19,16 +29,123 @@ func TestUser(t *testing.T) {
 	// t.Run("保存用户：", testSave)
 }
 
+func TestPasswordHashing(t *testing.T) {
+	t.Run("测试注册时保存哈希值", testSaveHashedPassword)
+	t.Run("测试明文密码登录后自动升级", testLegacyPasswordUpgrade)
+	t.Run("测试修改用户角色", testUpdateUserRole)
+	t.Run("测试创建管理员", testSaveAdmin)
+}
+
+func testSaveAdmin(t *testing.T) {
+	if _, err := SaveAdmin(t.Context(), "firstadmin", "admin-pass", ""); err == nil {
+		t.Errorf("创建新的管理员时没有邮箱应返回错误")
+	}
+	if created, err := SaveAdmin(t.Context(), "firstadmin", "admin-pass", "firstadmin@example.com"); err != nil || !created {
+		t.Fatalf("创建管理员失败: %v %v", created, err)
+	}
+	if user, _ := CheckUserNameAndPassword(t.Context(), "firstadmin", "admin-pass"); user.ID == 0 || !user.IsAdmin() {
+		t.Fatalf("创建的用户应为管理员并可以登录，实际为%+v", user)
+	}
+	//已有的用户设置为管理员并重新设置密码
+	SaveUser(t.Context(), "promoteuser", "old-pass", "promoteuser@example.com")
+	if created, err := SaveAdmin(t.Context(), "promoteuser", "new-admin-pass", ""); err != nil || created {
+		t.Fatalf("设置已有的用户为管理员失败: %v %v", created, err)
+	}
+	if user, _ := CheckUserNameAndPassword(t.Context(), "promoteuser", "old-pass"); user.ID != 0 {
+		t.Errorf("原来的密码不应再验证通过")
+	}
+	if user, _ := CheckUserNameAndPassword(t.Context(), "promoteuser", "new-admin-pass"); !user.IsAdmin() {
+		t.Errorf("已有的用户应设置为管理员，实际为%q", user.Role)
+	}
+}
+
+func testSaveHashedPassword(t *testing.T) {
+	if err := SaveUser(t.Context(), "hashuser", "hash123", "hashuser@example.com"); err != nil {
+		t.Fatalf("保存用户失败: %v", err)
+	}
+	user, _ := CheckUserName(t.Context(), "hashuser")
+	if user.Password == "hash123" || !utils.IsPasswordHash(user.Password) {
+		t.Fatalf("密码应以哈希值保存，实际为%q", user.Password)
+	}
+	if user, _ := CheckUserNameAndPassword(t.Context(), "hashuser", "hash123"); user.ID == 0 {
+		t.Errorf("正确的密码应验证通过")
+	}
+	if user, _ := CheckUserNameAndPassword(t.Context(), "hashuser", user.Password); user.ID != 0 {
+		t.Errorf("使用哈希值作为密码不应验证通过")
+	}
+}
+
+func testLegacyPasswordUpgrade(t *testing.T) {
+	_, err := utils.Db.Exec("insert into users(username,password,email) values(?,?,?)", "legacyuser", "legacy123", "legacyuser@example.com")
+	if err != nil {
+		t.Fatalf("插入明文密码用户失败: %v", err)
+	}
+	if user, _ := CheckUserNameAndPassword(t.Context(), "legacyuser", "wrong"); user.ID != 0 {
+		t.Fatalf("错误的密码不应验证通过")
+	}
+	if user, _ := CheckUserName(t.Context(), "legacyuser"); user.Password != "legacy123" {
+		t.Fatalf("登录失败时不应修改密码")
+	}
+	if user, _ := CheckUserNameAndPassword(t.Context(), "legacyuser", "legacy123"); user.ID == 0 {
+		t.Fatalf("明文密码应验证通过")
+	}
+	user, _ := CheckUserName(t.Context(), "legacyuser")
+	if !utils.IsPasswordHash(user.Password) {
+		t.Fatalf("登录后明文密码应升级为哈希值，实际为%q", user.Password)
+	}
+	if user, _ := CheckUserNameAndPassword(t.Context(), "legacyuser", "legacy123"); user.ID == 0 {
+		t.Errorf("升级后的密码应验证通过")
+	}
+}
+
+func testUpdateUserRole(t *testing.T) {
+	if err := SaveUser(t.Context(), "roleuser", "role123", "roleuser@example.com"); err != nil {
+		t.Fatalf("保存用户失败: %v", err)
+	}
+	user, _ := CheckUserName(t.Context(), "roleuser")
+	if user.Role != model.RoleCustomer {
+		t.Fatalf("注册的用户应为顾客，实际为%q", user.Role)
+	}
+	sess := NewSession("role-session", user, time.Now())
+	if err := AddSession(t.Context(), sess); err != nil {
+		t.Fatalf("添加Session失败: %v", err)
+	}
+	defer DeleteSession(t.Context(), sess.SessionID)
+	if err := UpdateUserRole(t.Context(), user.ID, model.RoleStaff); err != nil {
+		t.Fatalf("修改用户角色失败: %v", err)
+	}
+	//Session中的角色从用户表中读取，修改后立即生效
+	if got, _ := GetSession(t.Context(), sess.SessionID); !got.IsStaff() {
+		t.Errorf("Session中的角色应为staff，实际为%q", got.Role)
+	}
+	users, err := GetUsers(t.Context())
+	if err != nil {
+		t.Fatalf("获取所有用户失败: %v", err)
+	}
+	found := false
+	for _, u := range users {
+		if u.Username == "admin" && !u.IsAdmin() {
+			t.Errorf("初始化的admin用户应为管理员，实际为%q", u.Role)
+		}
+		if u.ID == user.ID {
+			found = u.Role == model.RoleStaff
+		}
+	}
+	if !found {
+		t.Errorf("用户列表中应包含角色为staff的roleuser")
+	}
+}
+
 func testLogin(t *testing.T) {
-	user, _ := CheckUserNameAndPassword("admin", "123456")
+	user, _ := CheckUserNameAndPassword(t.Context(), "admin", "123456")
 	fmt.Println("获取用户信息是：", user)
 }
 func testRegist(t *testing.T) {
-	user, _ := CheckUserName("admin")
+	user, _ := CheckUserName(t.Context(), "admin")
 	fmt.Println("获取用户信息是：", user)
 }
 func testSave(t *testing.T) {
-	SaveUser("admin3", "123456", "admin@atguigu.com")
+	SaveUser(t.Context(), "admin3", "123456", "admin@atguigu.com")
 }
 
 func TestBook(t *testing.T) {
@@ -43,7 +160,7 @@ func TestBook(t *testing.T) {
 }
 
 func testGetBooks(t *testing.T) {
-	books, _ := GetBooks()
+	books, _ := GetBooks(t.Context())
 	//遍历得到每一本图书
 	for k, v := range books {
 		fmt.Printf("第%v本图书的信息是：%v\n", k+1, v)
@@ -53,21 +170,21 @@ func testAddBook(t *testing.T) {
 	book := &model.Book{
 		Title:   "三国演义",
 		Author:  "罗贯中",
-		Price:   88.88,
+		Price:   model.Cents(8888),
 		Sales:   100,
 		Stock:   100,
 		ImgPath: "/static/img/default.jpg",
 	}
 	//调用添加图书的函数
-	AddBook(book)
+	AddBook(t.Context(), book)
 }
 func testDeleteBook(t *testing.T) {
 	//调用删除图书的函数
-	DeleteBook("34")
+	DeleteBook(t.Context(), "34")
 }
 func testGetBook(t *testing.T) {
 	//调用获取图书的函数
-	book, _ := GetBookByID("32")
+	book, _ := GetBookByID(t.Context(), "32")
 	fmt.Println("获取的图书信息是：", book)
 }
 func testUpdateBook(t *testing.T) {
@@ -75,17 +192,17 @@ func testUpdateBook(t *testing.T) {
 		ID:      32,
 		Title:   "3个女人与105个男人的故事",
 		Author:  "罗贯中",
-		Price:   66.66,
+		Price:   model.Cents(6666),
 		Sales:   10000,
 		Stock:   1,
 		ImgPath: "/static/img/default.jpg",
 	}
 	//调用更新图书的函数
-	UpdateBook(book)
+	UpdateBook(t.Context(), book)
 }
 
 func testGetPageBooks(t *testing.T) {
-	page, _ := GetPageBooks("9")
+	page, _ := GetPageBooks(t.Context(), "9")
 	fmt.Println("当前页是：", page.PageNo)
 	fmt.Println("总页数是：", page.TotalPageNo)
 	fmt.Println("总记录数是：", page.TotalRecord)
@@ -95,7 +212,7 @@ func testGetPageBooks(t *testing.T) {
 	}
 }
 func testGetPageBooksByPrice(t *testing.T) {
-	page, _ := GetPageBooksByPrice("3", "10", "30")
+	page, _ := GetPageBooksByPrice(t.Context(), "3", "10", "30")
 	fmt.Println("当前页是：", page.PageNo)
 	fmt.Println("总页数是：", page.TotalPageNo)
 	fmt.Println("总记录数是：", page.TotalRecord)
@@ -118,14 +235,14 @@ func testAddSession(t *testing.T) {
 		UserName:  "马蓉",
 		UserID:    5,
 	}
-	AddSession(sess)
+	AddSession(t.Context(), sess)
 }
 
 func testDeleteSession(t *testing.T) {
-	DeleteSession("13838381438")
+	DeleteSession(t.Context(), "13838381438")
 }
 func testGetSession(t *testing.T) {
-	sess, _ := GetSession("c65d2a76-9447-44cc-5fe8-c183e1414076")
+	sess, _ := GetSession(t.Context(), "c65d2a76-9447-44cc-5fe8-c183e1414076")
 	fmt.Println("Session的信息是：", sess)
 }
 
@@ -144,12 +261,12 @@ func testAddCart(t *testing.T) {
 	//设置要买的第一本书
 	book := &model.Book{
 		ID:    1,
-		Price: 27.20,
+		Price: model.Cents(2720),
 	}
 	//设置要买的第二本书
 	book2 := &model.Book{
 		ID:    2,
-		Price: 23.00,
+		Price: model.Cents(2300),
 	}
 	//创建一个购物项切片
 	var cartItems []*model.CartItem
@@ -173,22 +290,22 @@ func testAddCart(t *testing.T) {
 		UserID:    1,
 	}
 	//将购物车插入到数据库中
-	AddCart(cart)
+	AddCart(t.Context(), cart)
 }
 
 func testGetCartItemByBookID(t *testing.T) {
-	cartItem, _ := GetCartItemByBookIDAndCartID("1", "66668888")
+	cartItem, _ := GetCartItemByBookIDAndCartID(t.Context(), "1", "66668888")
 	fmt.Println("图书id=1的购物项的信息是：", cartItem)
 }
 func testGetCartItemsByCartID(t *testing.T) {
-	cartItems, _ := GetCartItemsByCartID("66668888")
+	cartItems, _ := GetCartItemsByCartID(t.Context(), "66668888")
 	for k, v := range cartItems {
 		fmt.Printf("第%v个购物项是：%v\n", k+1, v)
 	}
 }
 
 func testGetCartByUserID(t *testing.T) {
-	cart, _ := GetCartByUserID(3)
+	cart, _ := GetCartByUserID(t.Context(), 3)
 	fmt.Println("id为2的用户的购物车信息是：", cart)
 }
 
@@ -196,11 +313,11 @@ func testUpdateBookCount(t *testing.T) {
 	// UpdateBookCount(100, 1, "66668888")
 }
 func testDeleteCartByCartID(t *testing.T) {
-	DeleteCartByCartID("80bb8008-8383-47d0-4694-5eae94f39ffd")
+	DeleteCartByCartID(t.Context(), "80bb8008-8383-47d0-4694-5eae94f39ffd")
 }
 
 func testDeleteCartItemByID(t *testing.T) {
-	DeleteCartItemByID("21")
+	DeleteCartItemByID(t.Context(), "21")
 }
 
 func TestOrder(t *testing.T) {
@@ -221,54 +338,54 @@ func testAddOrder(t *testing.T) {
 		OrderID:     orderID,
 		CreateTime:  time.Now().String(),
 		TotalCount:  2,
-		TotalAmount: 400,
+		TotalAmount: model.Cents(40000),
 		State:       0,
 		UserID:      1,
 	}
 	//创建订单项
 	orderItem := &model.OrderItem{
 		Count:   1,
-		Amount:  300,
+		Amount:  model.Cents(30000),
 		Title:   "三国演义",
 		Author:  "罗贯中",
-		Price:   300,
+		Price:   model.Cents(30000),
 		ImgPath: "/static/img/default.jpg",
 		OrderID: orderID,
 	}
 	orderItem2 := &model.OrderItem{
 		Count:   1,
-		Amount:  100,
+		Amount:  model.Cents(10000),
 		Title:   "西游记",
 		Author:  "吴承恩",
-		Price:   100,
+		Price:   model.Cents(10000),
 		ImgPath: "/static/img/default.jpg",
 		OrderID: orderID,
 	}
 	//保存订单
-	AddOrder(order)
+	AddOrder(t.Context(), order)
 	//保存订单项
-	AddOrderItem(orderItem)
-	AddOrderItem(orderItem2)
+	AddOrderItem(t.Context(), orderItem)
+	AddOrderItem(t.Context(), orderItem2)
 }
 func testGetOrders(t *testing.T) {
-	orders, _ := GetOrders()
+	orders, _ := GetOrders(t.Context())
 	for _, v := range orders {
 		fmt.Println("订单信息是：", v)
 	}
 }
 func testGetOrderItems(t *testing.T) {
-	orderItems, _ := GetOrderItemsByOrderID("9a738546-d240-4c1a-7b3d-a3837100977f")
+	orderItems, _ := GetOrderItemsByOrderID(t.Context(), "9a738546-d240-4c1a-7b3d-a3837100977f")
 	for _, v := range orderItems {
 		fmt.Println("订单项的信息是：", v)
 	}
 }
 
 func testGetMyOrders(t *testing.T) {
-	orders, _ := GetMyOrders(2)
+	orders, _ := GetMyOrders(t.Context(), 2)
 	for _, v := range orders {
 		fmt.Println("我的订单有：", v)
 	}
 }
 func testUpdateOrderState(t *testing.T) {
-	UpdateOrderState("5823f37e-f4e6-4a39-7567-2a7c0fd8e638", 1)
+	TransitionOrder(t.Context(), "5823f37e-f4e6-4a39-7567-2a7c0fd8e638", model.OrderStateShipped, 0)
 }
@@ -1,60 +1,246 @@
 package main
 
 import (
+	"bookstore/config"
 	"bookstore/controller"
+	"bookstore/dao"
+	"bookstore/model"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	//设置处理静态资源，如css和js文件
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("views/static"))))
+	//配置依次来自默认值、配置文件、环境变量和命令行参数，后者覆盖前者
+	configPath := flag.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "JSON格式的配置文件，为空时只使用默认值和环境变量")
+	driver := flag.String("db", "", "数据库驱动：mysql 或 sqlite，覆盖配置中的database.driver")
+	dsn := flag.String("dsn", "", "数据库连接信息，覆盖配置中的database.dsn")
+	addr := flag.String("addr", "", "监听的地址，覆盖配置中的server.addr")
+	flag.Parse()
+	cfg, err := loadConfig(*configPath, *driver, *dsn, *addr)
+	if err != nil {
+		log.Fatalln("读取配置失败：", err)
+	}
+	//日志以JSON格式输出到标准输出，log包的输出也使用同样的格式
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.Log.SlogLevel()})))
+	//执行数据库迁移命令，例如 bookstore -db sqlite -dsn bookstore.db migrate up
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(&cfg.Database, flag.Args()[1:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	//数据库的版本低于程序需要的版本时拒绝启动
+	if err := dao.Setup(cfg); err != nil {
+		log.Fatalln("初始化数据库失败：", err)
+	}
+	//创建管理员或重新设置管理员的密码，例如 BOOKSTORE_ADMIN_PASSWORD=... bookstore create-admin admin admin@example.com
+	if flag.Arg(0) == "create-admin" {
+		if err := runCreateAdmin(flag.Args()[1:], os.Stdin); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	//按照图书当前的价格修复购物车中保存的金额，例如 bookstore -db sqlite -dsn bookstore.db repair-carts
+	if flag.Arg(0) == "repair-carts" {
+		n, err := dao.RecalculateCartTotals(context.Background())
+		if err != nil {
+			log.Fatalln("修复购物车失败：", err)
+		}
+		fmt.Printf("已修复%d个购物车\n", n)
+		return
+	}
+	//启动时解析所有模板，模板有错误时拒绝启动
+	if err := controller.Configure(cfg); err != nil {
+		log.Fatalln("解析模板失败：", err)
+	}
+	if cfg.Payment.Enabled() && cfg.Payment.AllowMock {
+		slog.Warn("正在使用模拟支付网关，任何人都可以自己完成支付，不能用于生产环境")
+	}
+	//定期清理过期的Session
+	stopSweeper := dao.StartSessionSweeper(cfg.Session.SweepInterval.Duration)
+	//定期清理长期没有修改的游客购物车
+	stopCartSweeper := dao.StartGuestCartSweeper(cfg.Cart.SweepInterval.Duration, cfg.Cart.GuestTTL.Duration)
+	//购物项保留库存时定期释放过期的保留
+	stopReservationSweeper := func() {}
+	if ttl := cfg.Cart.ReservationTTL.Duration; ttl > 0 {
+		stopReservationSweeper = dao.StartReservationSweeper(ttl)
+	}
+	//设置处理静态资源，如css和js文件，带有内容哈希的地址可以长期缓存
+	http.Handle("/static/", http.StripPrefix("/static/", controller.StaticHandler()))
 	//直接去html页面
-	http.Handle("/pages/", http.StripPrefix("/pages/", http.FileServer(http.Dir("views/pages"))))
+	pages := controller.PagesHandler()
+	http.Handle("/pages/", http.StripPrefix("/pages/", pages))
+	//修改数据的请求只接受POST方法，其他方法返回405
+	//后台管理页面只有店员和管理员可以访问
+	staff := controller.RequireRole(model.RoleStaff, model.RoleAdmin)
+	admin := controller.RequireRole(model.RoleAdmin)
+	//订单和结账需要登录后才能访问，购物车没有登录时使用游客购物车
+	login := controller.RequireLogin
+	http.Handle("/pages/manager/", staff(http.StripPrefix("/pages/", pages)))
 	//去首页
-	http.HandleFunc("/main", controller.GetPageBooksByPrice)
+	http.Handle("/main", controller.HandlerFunc(controller.GetPageBooksByPrice))
 	//去登录
-	http.HandleFunc("/login", controller.Login)
+	http.Handle("POST /login", controller.HandlerFunc(controller.Login))
 	//去注销
-	http.HandleFunc("/logout", controller.Logout)
+	http.Handle("POST /logout", controller.HandlerFunc(controller.Logout))
+	//在所有设备上注销
+	http.Handle("POST /logoutAll", controller.HandlerFunc(controller.LogoutAll))
 	//去注册
-	http.HandleFunc("/regist", controller.Regist)
+	http.Handle("POST /regist", controller.HandlerFunc(controller.Regist))
 	//通过Ajax请求验证用户名是否可用
-	http.HandleFunc("/checkUserName", controller.CheckUserName)
+	http.Handle("POST /checkUserName", controller.HandlerFunc(controller.CheckUserName))
 	//获取所有图书
 	// http.HandleFunc("/getBooks", controller.GetBooks)
 	//获取带分页的图书信息
-	http.HandleFunc("/getPageBooks", controller.GetPageBooks)
-	http.HandleFunc("/getPageBooksByPrice", controller.GetPageBooksByPrice)
+	http.Handle("/getPageBooks", staff(controller.HandlerFunc(controller.GetPageBooks)))
+	http.Handle("/getPageBooksByPrice", controller.HandlerFunc(controller.GetPageBooksByPrice))
 	//添加图书
 	// http.HandleFunc("/addBook", controller.AddBook)
 	//删除图书
-	http.HandleFunc("/deleteBook", controller.DeleteBook)
+	http.Handle("POST /deleteBook", staff(controller.HandlerFunc(controller.DeleteBook)))
 	//去更新图书的页面
-	http.HandleFunc("/toUpdateBookPage", controller.ToUpdateBookPage)
+	http.Handle("/toUpdateBookPage", staff(controller.HandlerFunc(controller.ToUpdateBookPage)))
 	//更新或添加图书
-	http.HandleFunc("/updateOraddBook", controller.UpdateOrAddBook)
+	http.Handle("POST /updateOraddBook", staff(controller.HandlerFunc(controller.UpdateOrAddBook)))
 	//添加图书到购物车中
-	http.HandleFunc("/addBook2Cart", controller.AddBook2Cart)
+	http.Handle("POST /addBook2Cart", controller.HandlerFunc(controller.AddBook2Cart))
 	//获取购物车信息
-	http.HandleFunc("/getCartInfo", controller.GetCartInfo)
+	http.Handle("/getCartInfo", controller.HandlerFunc(controller.GetCartInfo))
 	//清空购物车
-	http.HandleFunc("/deleteCart", controller.DeleteCart)
+	http.Handle("POST /deleteCart", controller.HandlerFunc(controller.DeleteCart))
 	//删除购物项
-	http.HandleFunc("/deleteCartItem", controller.DeleteCartItem)
+	http.Handle("POST /deleteCartItem", controller.HandlerFunc(controller.DeleteCartItem))
 	//更新购物项
-	http.HandleFunc("/updateCartItem", controller.UpdateCartItem)
-	//去结账
-	http.HandleFunc("/checkout", controller.Checkout)
+	http.Handle("POST /updateCartItem", controller.HandlerFunc(controller.UpdateCartItem))
+	//去结账，在确认订单页面选择收货地址
+	http.Handle("/toCheckout", login(controller.HandlerFunc(controller.ToCheckout)))
+	//确认订单并结账
+	http.Handle("POST /checkout", login(controller.HandlerFunc(controller.Checkout)))
+	//配置了支付网关时才使用在线支付
+	if cfg.Payment.Enabled() {
+		//支付订单，跳转到支付网关的付款页面
+		http.Handle("POST /pay", login(controller.HandlerFunc(controller.Pay)))
+		//模拟网关的付款页面，使用模拟网关时才有
+		http.Handle("/mockpay", login(controller.HandlerFunc(controller.GetMockPay)))
+		//在模拟网关中付款或拒绝付款
+		http.Handle("POST /mockpay", login(controller.HandlerFunc(controller.MockPay)))
+		//接收支付网关的回调，通过签名验证
+		http.Handle("POST /payments/webhook/{provider}", controller.PaymentWebhookHandler())
+	}
 	//获取所有订单
-	http.HandleFunc("/getOrders", controller.GetOrders)
+	http.Handle("/getOrders", staff(controller.HandlerFunc(controller.GetOrders)))
 	//获取订单详情，即订单所对应的所有的订单项
-	http.HandleFunc("/getOrderInfo", controller.GetOrderInfo)
+	http.Handle("/getOrderInfo", login(controller.HandlerFunc(controller.GetOrderInfo)))
 	//获取我的订单
-	http.HandleFunc("/getMyOrder", controller.GetMyOrders)
+	http.Handle("/getMyOrder", login(controller.HandlerFunc(controller.GetMyOrders)))
 	//发货
-	http.HandleFunc("/sendOrder", controller.SendOrder)
+	http.Handle("POST /sendOrder", staff(controller.HandlerFunc(controller.SendOrder)))
+	//标记订单已送达
+	http.Handle("POST /deliverOrder", staff(controller.HandlerFunc(controller.DeliverOrder)))
+	//取消订单
+	http.Handle("POST /cancelOrder", login(controller.HandlerFunc(controller.CancelOrder)))
 	//确认收货
-	http.HandleFunc("/takeOrder", controller.TakeOrder)
+	http.Handle("POST /takeOrder", login(controller.HandlerFunc(controller.TakeOrder)))
+	//收货地址
+	http.Handle("/getAddresses", login(controller.HandlerFunc(controller.GetAddresses)))
+	//添加或修改收货地址
+	http.Handle("POST /updateOrAddAddress", login(controller.HandlerFunc(controller.UpdateOrAddAddress)))
+	//删除收货地址
+	http.Handle("POST /deleteAddress", login(controller.HandlerFunc(controller.DeleteAddress)))
+	//用户管理
+	http.Handle("/getUsers", admin(controller.HandlerFunc(controller.GetUsers)))
+	//修改用户的角色
+	http.Handle("POST /updateUserRole", admin(controller.HandlerFunc(controller.UpdateUserRole)))
+	//供移动端和合作方使用的JSON接口
+	if cfg.Features.API {
+		http.Handle("/api/v1/", controller.NewAPIHandler())
+	}
 
-	http.ListenAndServe(":8080", nil)
+	//存活和就绪检查不经过日志和CSRF中间件，避免探针产生大量的访问日志
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", controller.Healthz)
+	root.HandleFunc("GET /readyz", controller.Readyz)
+	//每个请求分配请求id并记录访问日志，Session每个请求最多查询一次，所有修改数据的请求都需要带上正确的CSRF令牌
+	root.Handle("/", controller.RequestID(controller.AccessLog(controller.LoadSession(controller.CSRF(http.DefaultServeMux)))))
+	err = serve(newServer(&cfg.Server, root), cfg.Server.ShutdownTimeout.Duration)
+	//所有请求都已处理完成，再停止后台任务并关闭数据库
+	stopSweeper()
+	stopCartSweeper()
+	stopReservationSweeper()
+	if err := dao.Close(); err != nil {
+		slog.Error("关闭数据库失败", slog.Any("error", err))
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	slog.Info("已退出")
+}
+
+// newServer 根据配置创建HTTP服务，设置超时时间防止慢速的客户端一直占用连接
+func newServer(c *config.ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              c.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: c.ReadHeaderTimeout.Duration,
+		ReadTimeout:       c.ReadTimeout.Duration,
+		WriteTimeout:      c.WriteTimeout.Duration,
+		IdleTimeout:       c.IdleTimeout.Duration,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// serve 启动HTTP服务，收到SIGINT或SIGTERM后优雅退出：就绪检查开始失败，停止接受新的连接，
+// 等待正在处理的请求（如正在结账的请求）完成，超过shutdownTimeout时强制关闭所有连接
+func serve(srv *http.Server, shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("开始监听", slog.String("addr", srv.Addr))
+		errCh <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		//监听失败，例如端口已被占用
+		return err
+	case <-ctx.Done():
+	}
+	slog.Info("收到退出信号，等待正在处理的请求完成")
+	controller.SetShuttingDown()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("等待请求处理完成超时: %w", err)
+	}
+	return nil
+}
+
+// loadConfig 读取配置，命令行参数不为空时覆盖配置中对应的值
+func loadConfig(path string, driver string, dsn string, addr string) (*config.Config, error) {
+	cfg, err := config.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if driver != "" && driver != cfg.Database.Driver {
+		//更换了驱动时，配置中的连接信息不再适用
+		cfg.Database.Driver = driver
+		cfg.Database.DSN = ""
+	}
+	if dsn != "" {
+		cfg.Database.DSN = dsn
+	}
+	if addr != "" {
+		cfg.Server.Addr = addr
+	}
+	cfg.SetDefaultDSN()
+	return cfg, cfg.Validate()
 }
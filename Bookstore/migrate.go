@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bookstore/config"
+	"bookstore/dao"
+	"bookstore/utils"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// migrateUsage migrate命令的用法
+const migrateUsage = `用法: bookstore [-config 配置文件] [-db mysql|sqlite] [-dsn 连接信息] migrate up|down [n]|status
+  up        升级到最新版本
+  down [n]  回退n个版本，默认为1
+  status    查看每个版本的执行情况`
+
+// runMigrate 执行migrate命令，升级、回退或查看数据库表结构的版本
+func runMigrate(dbConf *config.DatabaseConfig, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	db, err := utils.OpenDB(dbConf.Driver, dbConf.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m, err := dao.NewMigrator(db, dbConf.Driver)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		for _, migration := range applied {
+			fmt.Printf("已升级到版本 %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("数据库已经是最新版本")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("回退的版本数不正确: %q", args[1])
+			}
+		}
+		reverted, err := m.Down(steps)
+		for _, migration := range reverted {
+			fmt.Printf("已回退版本 %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			appliedAt := "未执行"
+			if s.Applied {
+				appliedAt = "执行于 " + s.AppliedAt
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, appliedAt)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package model
+
+// Address 用户地址簿中保存的收货地址
+type Address struct {
+	ID        int64  `json:"id"`        //地址的id
+	UserID    int    `json:"userId"`    //地址所属的用户
+	Recipient string `json:"recipient"` //收货人
+	Phone     string `json:"phone"`     //联系电话
+	Detail    string `json:"address"`   //详细地址
+}
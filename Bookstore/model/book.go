@@ -2,11 +2,11 @@ package model
 
 // Book 结构体
 type Book struct {
-	ID      int
-	Title   string
-	Author  string
-	Price   float64
-	Sales   int
-	Stock   int
-	ImgPath string
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+	Price   Money  `json:"price"`
+	Sales   int    `json:"sales"`
+	Stock   int    `json:"stock"`
+	ImgPath string `json:"imgPath"`
 }
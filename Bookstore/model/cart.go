@@ -2,11 +2,11 @@ package model
 
 // Cart 购物车结构体
 type Cart struct {
-	CartID      string      //购物车的id
-	CartItems   []*CartItem //购物车中所有的购物项
-	TotalCount  int64       //购物车中图书的总数量，通过计算得到
-	TotalAmount float64     //购物车中图书的总金额，通过计算得到
-	UserID      int         //当前购物车所属的用户
+	CartID      string      `json:"cartId"`      //购物车的id
+	CartItems   []*CartItem `json:"items"`       //购物车中所有的购物项
+	TotalCount  int64       `json:"totalCount"`  //购物车中图书的总数量，通过计算得到
+	TotalAmount Money       `json:"totalAmount"` //购物车中图书的总金额，通过计算得到
+	UserID      int         `json:"userId"`      //当前购物车所属的用户
 }
 
 //GetTotalCount 获取购物车中图书的总数量
@@ -20,12 +20,21 @@ func (cart *Cart) GetTotalCount() int64 {
 }
 
 //GetTotalAmount 获取购物车中图书的总金额
-func (cart *Cart) GetTotalAmount() float64 {
-	var totalAmount float64
+func (cart *Cart) GetTotalAmount() Money {
+	totalAmount := Cents(0)
 	//遍历购物车中的购物项切片
 	for _, v := range cart.CartItems {
-		totalAmount = totalAmount + v.GetAmount()
+		totalAmount = totalAmount.Add(v.GetAmount())
 	}
 	return totalAmount
 
 }
+
+//Recalculate 按照购物项中图书的价格和数量重新计算每个购物项的金额小计以及购物车的总数量和总金额
+func (cart *Cart) Recalculate() {
+	for _, v := range cart.CartItems {
+		v.Amount = v.GetAmount()
+	}
+	cart.TotalCount = cart.GetTotalCount()
+	cart.TotalAmount = cart.GetTotalAmount()
+}
@@ -2,16 +2,23 @@ package model
 
 // CartItem 购物项结构体
 type CartItem struct {
-	CartItemID int64   //购物项的id
-	Book       *Book   //购物项中的图书信息
-	Count      int64   //购物项中图书的数量
-	Amount     float64 //购物项中图书的金额小计，通过计算得到
-	CartID     string  //当前购物项属于哪一个购物车
+	CartItemID int64    `json:"id"`                //购物项的id
+	Book       *Book    `json:"book"`              //购物项中的图书信息
+	Count      int64    `json:"count"`             //购物项中图书的数量
+	Amount     Money    `json:"amount"`            //购物项中图书的金额小计，通过计算得到
+	Price      Money    `json:"price"`             //加入购物车时图书的单价，旧的购物项为0
+	CartID     string   `json:"cartId"`            //当前购物项属于哪一个购物车
+	Notices    []string `json:"notices,omitempty"` //需要展示给用户的提示信息，如数量超过库存时已被调整
 }
 
 //GetAmount 获取购物项中图书的金额小计，有图书的价格和图书的数量计算得到
-func (cartItem *CartItem) GetAmount() float64 {
+func (cartItem *CartItem) GetAmount() Money {
 	//获取当前购物项中图书的价格
 	price := cartItem.Book.Price
-	return float64(cartItem.Count) * price
+	return price.Mul(cartItem.Count)
+}
+
+//PriceChanged 判断图书的价格在加入购物车之后是否发生了变化，不知道加入时的单价时返回false
+func (cartItem *CartItem) PriceChanged() bool {
+	return !cartItem.Price.IsZero() && cartItem.Price.Cmp(cartItem.Book.Price) != 0
 }
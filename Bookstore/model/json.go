@@ -2,7 +2,9 @@ package model
 
 // Data 结构
 type Data struct {
-	Amount      float64
-	TotalAmount float64
+	Amount      Money
+	TotalAmount Money
 	TotalCount  int64
+	Count       int64  //购物项中实际的图书数量，超过可购买的库存时会被调整
+	Message     string //数量被调整时的提示信息
 }
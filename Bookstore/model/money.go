@@ -0,0 +1,235 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//DefaultCurrency 书城使用的货币，数据库中只保存金额的最小单位，读取时都视为该货币
+const DefaultCurrency = "CNY"
+
+//currencyDigits 每种货币最小单位的小数位数
+var currencyDigits = map[string]int{
+	"CNY": 2,
+	"USD": 2,
+	"JPY": 0,
+}
+
+//maxMoneyDigits 金额整数部分的最大位数，超过后乘以最小单位可能溢出
+const maxMoneyDigits = 15
+
+//Money 金额，以货币的最小单位（人民币为分）保存为整数，计算时不会产生浮点数的误差
+type Money struct {
+	Minor    int64  //以最小单位表示的金额
+	Currency string //ISO 4217货币代码，为空时视为DefaultCurrency
+}
+
+//Cents 创建以DefaultCurrency的最小单位表示的金额
+func Cents(minor int64) Money {
+	return Money{Minor: minor, Currency: DefaultCurrency}
+}
+
+//ParseMoney 将"89"、"89.5"、"-0.25"这样的十进制字符串解析为DefaultCurrency的金额，
+//超出最小单位的部分四舍五入，即远离零的方向舍入一半
+func ParseMoney(s string) (Money, error) {
+	return parseMoney(s, DefaultCurrency)
+}
+
+//parseMoney 将十进制字符串解析为指定货币的金额，整个过程不经过浮点数
+func parseMoney(s string, currency string) (Money, error) {
+	digits, ok := currencyDigits[currency]
+	if !ok {
+		return Money{}, fmt.Errorf("不支持的货币%q", currency)
+	}
+	str := strings.TrimSpace(s)
+	negative := strings.HasPrefix(str, "-")
+	str = strings.TrimPrefix(strings.TrimPrefix(str, "-"), "+")
+	intPart, fracPart, _ := strings.Cut(str, ".")
+	if (intPart == "" && fracPart == "") || !isDigits(intPart) || !isDigits(fracPart) {
+		return Money{}, fmt.Errorf("金额格式不正确: %q", s)
+	}
+	intPart = strings.TrimLeft(intPart, "0")
+	if len(intPart) > maxMoneyDigits {
+		return Money{}, fmt.Errorf("金额超出范围: %q", s)
+	}
+	//补齐或截断到最小单位的位数，第一位被截掉的数字决定是否进位
+	roundUp := len(fracPart) > digits && fracPart[digits] >= '5'
+	if len(fracPart) > digits {
+		fracPart = fracPart[:digits]
+	}
+	fracPart += strings.Repeat("0", digits-len(fracPart))
+	var minor int64
+	if intPart+fracPart != "" {
+		minor, _ = strconv.ParseInt(intPart+fracPart, 10, 64)
+	}
+	if roundUp {
+		minor++
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+//isDigits 判断字符串是否只包含数字，空字符串也视为合法
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//currency 获取金额的货币，没有设置时为DefaultCurrency
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+//mustSameCurrency 不同货币的金额不能直接计算，出现时是程序的错误
+func (m Money) mustSameCurrency(other Money) {
+	if m.currency() != other.currency() {
+		panic(fmt.Sprintf("不能计算不同货币的金额: %s和%s", m.currency(), other.currency()))
+	}
+}
+
+//Add 两个金额相加
+func (m Money) Add(other Money) Money {
+	m.mustSameCurrency(other)
+	return Money{Minor: m.Minor + other.Minor, Currency: m.currency()}
+}
+
+//Sub 两个金额相减
+func (m Money) Sub(other Money) Money {
+	m.mustSameCurrency(other)
+	return Money{Minor: m.Minor - other.Minor, Currency: m.currency()}
+}
+
+//Mul 金额乘以数量，例如单价乘以图书的数量
+func (m Money) Mul(n int64) Money {
+	return Money{Minor: m.Minor * n, Currency: m.currency()}
+}
+
+//Cmp 比较两个金额，m小于、等于、大于other时分别返回-1、0、1
+func (m Money) Cmp(other Money) int {
+	m.mustSameCurrency(other)
+	switch {
+	case m.Minor < other.Minor:
+		return -1
+	case m.Minor > other.Minor:
+		return 1
+	}
+	return 0
+}
+
+//IsZero 判断金额是否为0
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+//String 将金额格式化为带有固定小数位数的十进制字符串，如"89.00"，模板中直接输出该值
+func (m Money) String() string {
+	digits := currencyDigits[m.currency()]
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	if digits == 0 {
+		return sign + strconv.FormatInt(minor, 10)
+	}
+	unit := int64(math.Pow10(digits))
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/unit, digits, minor%unit)
+}
+
+//moneyJSON 金额的JSON格式，amount是格式化后的十进制字符串，minor是最小单位的整数，便于客户端计算
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Minor    int64  `json:"minor"`
+	Currency string `json:"currency"`
+}
+
+//MarshalJSON 将金额转换为JSON
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Minor: m.Minor, Currency: m.currency()})
+}
+
+//UnmarshalJSON 从JSON中读取金额，有minor时以minor为准，否则解析amount
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Amount   string `json:"amount"`
+		Minor    *int64 `json:"minor"`
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v.Currency == "" {
+		v.Currency = DefaultCurrency
+	}
+	if v.Minor != nil {
+		*m = Money{Minor: *v.Minor, Currency: v.Currency}
+		return nil
+	}
+	parsed, err := parseMoney(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+//Value 保存到数据库时只保存最小单位的金额
+func (m Money) Value() (driver.Value, error) {
+	return m.Minor, nil
+}
+
+//Scan 从数据库中读取最小单位的金额，货币为DefaultCurrency，
+//兼容迁移之前以小数保存的金额，按最小单位四舍五入
+func (m *Money) Scan(src interface{}) error {
+	var minor int64
+	switch v := src.(type) {
+	case nil:
+	case int64:
+		minor = v
+	case float64:
+		//按最短的十进制表示解析，避免0.285*100得到28.499999这样的误差
+		parsed, err := ParseMoney(strconv.FormatFloat(v, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	default:
+		return fmt.Errorf("不能将%T转换为金额", src)
+	}
+	*m = Cents(minor)
+	return nil
+}
+
+//scanString 读取以文本返回的金额，整数是最小单位的金额，小数是迁移之前的金额
+func (m *Money) scanString(s string) error {
+	if minor, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*m = Cents(minor)
+		return nil
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return errors.New("数据库中的金额格式不正确: " + s)
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		s     string
+		minor int64
+	}{
+		{"89", 8900},
+		{"89.5", 8950},
+		{"27.20", 2720},
+		{"0.07", 7},
+		{".5", 50},
+		{"12.", 1200},
+		{" 1.234 ", 123},
+		//超出分的部分四舍五入
+		{"1.235", 124},
+		{"0.005", 1},
+		{"-0.005", -1},
+		{"19.999", 2000},
+		{"+3", 300},
+	}
+	for _, tt := range tests {
+		m, err := ParseMoney(tt.s)
+		if err != nil {
+			t.Errorf("解析%q失败: %v", tt.s, err)
+			continue
+		}
+		if m.Minor != tt.minor || m.Currency != DefaultCurrency {
+			t.Errorf("%q应解析为%d分，实际为%+v", tt.s, tt.minor, m)
+		}
+	}
+	for _, s := range []string{"", ".", "abc", "1.2.3", "1e3", "--1", "1,000", "1234567890123456"} {
+		if _, err := ParseMoney(s); err == nil {
+			t.Errorf("%q应解析失败", s)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	price := Cents(1999)
+	if got := price.Mul(3); got != Cents(5997) {
+		t.Errorf("19.99*3应为59.97，实际为%v", got)
+	}
+	//以浮点数计算0.1+0.2会得到0.30000000000000004
+	if got := Cents(10).Add(Cents(20)); got != Cents(30) {
+		t.Errorf("0.10+0.20应为0.30，实际为%v", got)
+	}
+	if got := Cents(100).Sub(Cents(250)); got.String() != "-1.50" {
+		t.Errorf("1.00-2.50应为-1.50，实际为%v", got)
+	}
+	if Cents(100).Cmp(Cents(99)) != 1 || Cents(99).Cmp(Cents(100)) != -1 || Cents(5).Cmp(Money{Minor: 5}) != 0 {
+		t.Error("金额比较的结果不正确")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("不同货币的金额相加应panic")
+		}
+	}()
+	Cents(100).Add(Money{Minor: 100, Currency: "USD"})
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{Cents(8900), "89.00"},
+		{Cents(5), "0.05"},
+		{Cents(-105), "-1.05"},
+		{Money{}, "0.00"},
+		{Money{Minor: 500, Currency: "JPY"}, "500"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("%+v应格式化为%q，实际为%q", tt.m, tt.want, got)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	b, err := json.Marshal(Book{Price: Cents(2720)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Price map[string]interface{} `json:"price"`
+	}
+	json.Unmarshal(b, &got)
+	if got.Price["amount"] != "27.20" || got.Price["minor"] != float64(2720) || got.Price["currency"] != "CNY" {
+		t.Errorf("金额的JSON格式不正确: %s", b)
+	}
+	var m Money
+	if err := json.Unmarshal([]byte(`{"minor":2720,"currency":"CNY"}`), &m); err != nil || m != Cents(2720) {
+		t.Errorf("应按minor读取金额，实际为%+v %v", m, err)
+	}
+	if err := json.Unmarshal([]byte(`{"amount":"27.2"}`), &m); err != nil || m != Cents(2720) {
+		t.Errorf("没有minor时应解析amount，实际为%+v %v", m, err)
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		src   interface{}
+		minor int64
+	}{
+		{int64(2720), 2720},
+		{[]byte("2720"), 2720},
+		{"2720", 2720},
+		//迁移之前以小数保存的金额
+		{27.2, 2720},
+		{0.285, 29},
+		{float64(27), 2700},
+		{[]byte("27.20"), 2720},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := m.Scan(tt.src); err != nil {
+			t.Errorf("读取%v失败: %v", tt.src, err)
+			continue
+		}
+		if m != Cents(tt.minor) {
+			t.Errorf("%v应读取为%d分，实际为%+v", tt.src, tt.minor, m)
+		}
+	}
+	var m Money
+	if err := m.Scan(true); err == nil {
+		t.Error("不支持的类型应返回错误")
+	}
+	if v, _ := Cents(2720).Value(); v != int64(2720) {
+		t.Errorf("保存到数据库的应是分，实际为%v", v)
+	}
+}
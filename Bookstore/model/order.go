@@ -2,25 +2,146 @@ package model
 
 // Order 结构
 type Order struct {
-	OrderID     string  //订单号
-	CreateTime  string  //生成订单的时间
-	TotalCount  int64   //订单中图书的总数量
-	TotalAmount float64 //订单中图书的总金额
-	State       int64   //订单的状态 0 未发货 1 已发货 2 交易完成
-	UserID      int64   //订单所属的用户
+	OrderID       string       `json:"id"`              //订单号
+	CreateTime    string       `json:"createTime"`      //生成订单的时间
+	TotalCount    int64        `json:"totalCount"`      //订单中图书的总数量
+	TotalAmount   Money        `json:"totalAmount"`     //订单中图书的总金额
+	State         int64        `json:"state"`           //订单的状态，取值见OrderState开头的常量
+	UserID        int64        `json:"userId"`          //订单所属的用户
+	ShipRecipient string       `json:"shipRecipient"`   //收货人，下单时从收货地址复制，之后修改地址簿不影响订单
+	ShipPhone     string       `json:"shipPhone"`       //收货人的联系电话
+	ShipAddress   string       `json:"shipAddress"`     //收货地址，旧的订单没有收货地址
+	Items         []*OrderItem `json:"items,omitempty"` //订单中的订单项，只有一起查询时才有
+}
+
+//订单的状态，与迁移脚本0005_order_history中的说明保持一致
+const (
+	//OrderStatePendingPayment 待付款
+	OrderStatePendingPayment int64 = 0
+	//OrderStatePaid 已付款，等待发货
+	OrderStatePaid int64 = 1
+	//OrderStateShipped 已发货
+	OrderStateShipped int64 = 2
+	//OrderStateCompleted 交易完成
+	OrderStateCompleted int64 = 3
+	//OrderStateDelivered 已送达，等待用户确认收货
+	OrderStateDelivered int64 = 4
+	//OrderStateCancelled 已取消
+	OrderStateCancelled int64 = 5
+	//OrderStateRefunded 已退款
+	OrderStateRefunded int64 = 6
+)
+
+//OrderStateNone 订单创建之前的状态，只出现在订单历史的第一条记录中
+const OrderStateNone int64 = -1
+
+//orderStateNames 订单状态的名称
+var orderStateNames = map[int64]string{
+	OrderStatePendingPayment: "待付款",
+	OrderStatePaid:           "等待发货",
+	OrderStateShipped:        "已发货",
+	OrderStateCompleted:      "交易完成",
+	OrderStateDelivered:      "已送达",
+	OrderStateCancelled:      "已取消",
+	OrderStateRefunded:       "已退款",
+}
+
+//orderTransitions 每个状态允许变更到的状态，已取消和已退款的订单不能再变更
+var orderTransitions = map[int64][]int64{
+	OrderStatePendingPayment: {OrderStatePaid, OrderStateCancelled},
+	OrderStatePaid:           {OrderStateShipped, OrderStateCancelled, OrderStateRefunded},
+	OrderStateShipped:        {OrderStateDelivered, OrderStateCompleted},
+	OrderStateDelivered:      {OrderStateCompleted, OrderStateRefunded},
+	OrderStateCompleted:      {OrderStateRefunded},
+}
+
+//IsValidOrderState 判断是否是合法的订单状态
+func IsValidOrderState(state int64) bool {
+	_, ok := orderStateNames[state]
+	return ok
+}
+
+//CanTransition 判断订单能否从from状态变更为to状态
+func CanTransition(from int64, to int64) bool {
+	for _, v := range orderTransitions[from] {
+		if v == to {
+			return true
+		}
+	}
+	return false
+}
+
+//OrderStateName 获取订单状态的名称
+func OrderStateName(state int64) string {
+	if name, ok := orderStateNames[state]; ok {
+		return name
+	}
+	return "未知状态"
+}
+
+//SetShipping 将收货地址复制到订单中
+func (order *Order) SetShipping(address *Address) {
+	order.ShipRecipient = address.Recipient
+	order.ShipPhone = address.Phone
+	order.ShipAddress = address.Detail
+}
+
+//HasShipping 订单是否有收货地址
+func (order *Order) HasShipping() bool {
+	return order.ShipAddress != ""
+}
+
+//StateName 获取订单当前状态的名称
+func (order *Order) StateName() string {
+	return OrderStateName(order.State)
+}
+
+//CanPay 订单能否支付，只有待付款的订单需要支付
+func (order *Order) CanPay() bool {
+	return order.State == OrderStatePendingPayment
+}
+
+//CanShip 订单能否发货
+func (order *Order) CanShip() bool {
+	return CanTransition(order.State, OrderStateShipped)
+}
+
+//CanDeliver 订单能否标记为已送达
+func (order *Order) CanDeliver() bool {
+	return CanTransition(order.State, OrderStateDelivered)
+}
+
+//CanReceive 订单能否确认收货
+func (order *Order) CanReceive() bool {
+	return CanTransition(order.State, OrderStateCompleted)
+}
+
+//CanCancel 订单能否取消，只有还没有发货的订单可以取消
+func (order *Order) CanCancel() bool {
+	return CanTransition(order.State, OrderStateCancelled)
 }
 
 //NoSend 未发货
 func (order *Order) NoSend() bool {
-	return order.State == 0
+	return order.State == OrderStatePaid
 }
 
 //SendComplate 已发货
 func (order *Order) SendComplate() bool {
-	return order.State == 1
+	return order.State == OrderStateShipped || order.State == OrderStateDelivered
 }
 
 //Complate 交易完成
 func (order *Order) Complate() bool {
-	return order.State == 2
+	return order.State == OrderStateCompleted
+}
+
+//OrderDetail 订单详情页面的数据
+type OrderDetail struct {
+	Order      *Order
+	OrderItems []*OrderItem
+	History    []*OrderHistory //订单状态的变更记录，按时间先后排列
+	Payments   []*Payment      //订单的支付记录，按时间先后排列
+	IsStaff    bool            //当前用户是否是店员，店员可以返回后台
+	IsOwner    bool            //当前用户是否是订单的所有者，只有所有者可以付款
 }
@@ -0,0 +1,33 @@
+package model
+
+//OrderHistory 订单状态的变更记录
+type OrderHistory struct {
+	ID         int64  `json:"id"`
+	OrderID    string `json:"orderId"`    //所属的订单
+	FromState  int64  `json:"fromState"`  //变更前的状态，订单创建时为OrderStateNone
+	ToState    int64  `json:"toState"`    //变更后的状态
+	ActorID    int    `json:"actorId"`    //操作人的id，系统自动变更时为0
+	Actor      string `json:"actor"`      //操作人的用户名
+	CreateTime string `json:"createTime"` //变更的时间
+}
+
+//FromName 获取变更前状态的名称
+func (h *OrderHistory) FromName() string {
+	if h.FromState == OrderStateNone {
+		return "创建订单"
+	}
+	return OrderStateName(h.FromState)
+}
+
+//ToName 获取变更后状态的名称
+func (h *OrderHistory) ToName() string {
+	return OrderStateName(h.ToState)
+}
+
+//ActorName 获取操作人的名称，系统自动变更时为"系统"
+func (h *OrderHistory) ActorName() string {
+	if h.ActorID == 0 || h.Actor == "" {
+		return "系统"
+	}
+	return h.Actor
+}
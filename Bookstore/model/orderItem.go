@@ -2,12 +2,13 @@ package model
 
 //OrderItem 结构
 type OrderItem struct {
-	OrderItemID int64   //订单项的id
-	Count       int64   //订单项中图书的数量
-	Amount      float64 //订单项中图书的金额小计
-	Title       string  //订单项中图书的书名
-	Author      string  //订单项中图书的作者
-	Price       float64 //订单项中图书的价格
-	ImgPath     string  //订单项中图书的封面
-	OrderID     string  //订单行所属的订单
+	OrderItemID int64  `json:"id"`      //订单项的id
+	Count       int64  `json:"count"`   //订单项中图书的数量
+	Amount      Money  `json:"amount"`  //订单项中图书的金额小计
+	Title       string `json:"title"`   //订单项中图书的书名
+	Author      string `json:"author"`  //订单项中图书的作者
+	Price       Money  `json:"price"`   //订单项中图书的价格
+	ImgPath     string `json:"imgPath"` //订单项中图书的封面
+	BookID      int    `json:"bookId"`  //订单项中图书的id，取消订单时据此恢复库存，旧的订单项为0
+	OrderID     string `json:"orderId"` //订单行所属的订单
 }
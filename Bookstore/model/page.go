@@ -1,16 +1,23 @@
 package model
 
+import "html/template"
+
 // Page 结构
 type Page struct {
-	Books       []*Book //每页查询出来的图书存放的切片
-	PageNo      int64   //当前页
-	PageSize    int64   //每页显示的条数
-	TotalPageNo int64   //总页数，通过计算得到
-	TotalRecord int64   //总记录数，通过查询数据库得到
-	MinPrice    string
-	MaxPrice    string
-	IsLogin     bool
-	Username    string
+	Books       []*Book      `json:"books"`       //每页查询出来的图书存放的切片
+	PageNo      int64        `json:"pageNo"`      //当前页
+	PageSize    int64        `json:"pageSize"`    //每页显示的条数
+	TotalPageNo int64        `json:"totalPageNo"` //总页数，通过计算得到
+	TotalRecord int64        `json:"totalRecord"` //总记录数，通过查询数据库得到
+	MinPrice    string       `json:"-"`
+	MaxPrice    string       `json:"-"`
+	Keyword     string       `json:"-"` //书名或作者的关键字
+	InStock     bool         `json:"-"` //是否只显示有库存的图书
+	Sort        string       `json:"-"` //排序方式
+	Query       template.URL `json:"-"` //除页码之外的查询条件，用于拼接分页链接
+	IsLogin     bool         `json:"-"`
+	Username    string       `json:"-"`
+	IsStaff     bool         `json:"-"` //当前登录的用户是否可以进入后台管理
 }
 
 //IsHasPrev 判断是否有上一页
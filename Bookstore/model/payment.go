@@ -0,0 +1,47 @@
+package model
+
+// Payment 订单的一次支付，第一次支付失败后可以重新支付，因此一个订单可以有多条支付记录
+type Payment struct {
+	ID          int64  `json:"id"`
+	OrderID     string `json:"orderId"`     //支付的订单
+	Provider    string `json:"provider"`    //支付网关的名称
+	ProviderRef string `json:"providerRef"` //网关中的支付号，请求授权之后才有
+	Amount      Money  `json:"amount"`      //支付的金额，即订单的总金额
+	Status      string `json:"status"`      //支付的状态，取值见PaymentStatus开头的常量
+	CreatedAt   int64  `json:"createdAt"`   //创建的时间，Unix时间戳
+	UpdatedAt   int64  `json:"updatedAt"`   //最后一次变更状态的时间
+}
+
+// 支付的状态，与迁移脚本0012_payments中的说明保持一致
+const (
+	// PaymentStatusPending 等待用户在网关中付款
+	PaymentStatusPending = "pending"
+	// PaymentStatusAuthorized 用户已经授权付款，等待扣款
+	PaymentStatusAuthorized = "authorized"
+	// PaymentStatusCaptured 已经扣款，订单变为已付款
+	PaymentStatusCaptured = "captured"
+	// PaymentStatusFailed 用户拒绝付款或付款失败
+	PaymentStatusFailed = "failed"
+	// PaymentStatusRefunded 已经退款
+	PaymentStatusRefunded = "refunded"
+	// PaymentStatusReconcile 网关已经扣款但没有保存扣款的结果，需要人工与网关对账后补记或退款
+	PaymentStatusReconcile = "reconcile"
+)
+
+// paymentStatusNames 支付状态的名称
+var paymentStatusNames = map[string]string{
+	PaymentStatusPending:    "等待付款",
+	PaymentStatusAuthorized: "已授权",
+	PaymentStatusCaptured:   "已付款",
+	PaymentStatusFailed:     "付款失败",
+	PaymentStatusRefunded:   "已退款",
+	PaymentStatusReconcile:  "待对账",
+}
+
+// StatusName 获取支付当前状态的名称
+func (p *Payment) StatusName() string {
+	if name, ok := paymentStatusNames[p.Status]; ok {
+		return name
+	}
+	return "未知状态"
+}
@@ -1,11 +1,35 @@
 package model
 
+import "time"
+
 // Session 结构
 type Session struct {
-	SessionID string
-	UserName  string
-	UserID    int
-	Cart      *Cart
-	OrderID   string
-	Orders    []*Order
+	SessionID  string
+	UserName   string
+	UserID     int
+	Role       string    //用户的角色，每次都从用户表中读取，角色修改后立即生效
+	CreatedAt  time.Time //Session创建的时间
+	LastSeenAt time.Time //最后一次访问的时间
+	ExpiresAt  time.Time //过期时间，每次访问都会顺延，但不会超过最长有效期
+	Cart       *Cart
+	OrderID    string
+	Orders     []*Order
+	Addresses  []*Address //用户的地址簿，用于收货地址页面和确认订单页面
+	Address    *Address   //正在编辑的收货地址
+	Messages   []string   //需要展示给用户的提示信息，如结账时库存不足
+}
+
+//IsExpired 判断Session在指定时间是否已经过期
+func (sess *Session) IsExpired(now time.Time) bool {
+	return !now.Before(sess.ExpiresAt)
+}
+
+//IsStaff 判断当前登录的用户是否可以进入后台管理
+func (sess *Session) IsStaff() bool {
+	return sess.Role == RoleStaff || sess.Role == RoleAdmin
+}
+
+//IsAdmin 判断当前登录的用户是否是管理员
+func (sess *Session) IsAdmin() bool {
+	return sess.Role == RoleAdmin
 }
@@ -2,8 +2,41 @@ package model
 
 //User 结构体
 type User struct {
-	ID       int
-	Username string
-	Password string
-	Email    string
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Password string `json:"-"`
+	Email    string `json:"email"`
+	Role     string `json:"role"` //用户的角色：customer 顾客、staff 店员、admin 管理员
+}
+
+const (
+	//RoleCustomer 顾客，只能购物和查看自己的订单
+	RoleCustomer = "customer"
+	//RoleStaff 店员，可以管理图书和订单
+	RoleStaff = "staff"
+	//RoleAdmin 管理员，在店员的基础上还可以管理用户
+	RoleAdmin = "admin"
+)
+
+//Roles 所有的角色
+var Roles = []string{RoleCustomer, RoleStaff, RoleAdmin}
+
+//IsValidRole 判断是否是合法的角色
+func IsValidRole(role string) bool {
+	for _, v := range Roles {
+		if v == role {
+			return true
+		}
+	}
+	return false
+}
+
+//IsStaff 判断用户是否可以进入后台管理，管理员也是店员
+func (user *User) IsStaff() bool {
+	return user.Role == RoleStaff || user.Role == RoleAdmin
+}
+
+//IsAdmin 判断用户是否是管理员
+func (user *User) IsAdmin() bool {
+	return user.Role == RoleAdmin
 }
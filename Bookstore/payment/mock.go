@@ -0,0 +1,186 @@
+package payment
+
+import (
+	"bookstore/model"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// MockName 模拟网关的名称
+const MockName = "mock"
+
+// MockSignatureHeader 模拟网关在回调请求中携带签名的请求头，签名为请求体的HMAC-SHA256
+const MockSignatureHeader = "X-Mock-Signature"
+
+// maxWebhookBody 回调请求体的最大长度
+const maxWebhookBody = 64 << 10
+
+// MockPayment 模拟网关中的一笔支付
+type MockPayment struct {
+	Ref      string
+	OrderID  string
+	Amount   model.Money //授权的金额
+	Captured model.Money //已经扣除的金额
+	Refunded model.Money //已经退还的金额
+	Status   string      //取值与model.Payment的状态相同
+}
+
+// Mock 在本地运行的模拟网关，用于开发和测试，支付只保存在内存中。
+// 用户在模拟的付款页面中选择付款或拒绝后，由Complete生成签名的回调请求
+type Mock struct {
+	secret   []byte
+	mu       sync.Mutex
+	payments map[string]*MockPayment
+}
+
+// NewMock 创建模拟网关，secret为回调签名的密钥
+func NewMock(secret []byte) *Mock {
+	return &Mock{secret: secret, payments: map[string]*MockPayment{}}
+}
+
+// Name 实现Provider接口
+func (m *Mock) Name() string {
+	return MockName
+}
+
+// Authorize 创建等待用户付款的支付，付款页面为 /mockpay?ref=支付号
+func (m *Mock) Authorize(ctx context.Context, p *model.Payment) (*Authorization, error) {
+	b := make([]byte, 12)
+	rand.Read(b)
+	ref := "mock_" + hex.EncodeToString(b)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.payments[ref] = &MockPayment{Ref: ref, OrderID: p.OrderID, Amount: p.Amount, Status: model.PaymentStatusPending}
+	return &Authorization{Ref: ref, RedirectURL: "/mockpay?ref=" + url.QueryEscape(ref)}, nil
+}
+
+// Capture 扣除已经授权的款项，金额不能超过授权的金额
+func (m *Mock) Capture(ctx context.Context, ref string, amount model.Money) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, err := m.payment(ref)
+	if err != nil {
+		return err
+	}
+	if p.Status != model.PaymentStatusAuthorized {
+		return fmt.Errorf("支付%s的状态为%s，不能扣款", ref, p.Status)
+	}
+	if amount.Cmp(p.Amount) > 0 {
+		return fmt.Errorf("扣款金额%s超过了授权的金额%s", amount, p.Amount)
+	}
+	p.Captured = amount
+	p.Status = model.PaymentStatusCaptured
+	return nil
+}
+
+// Refund 退还已经扣除的款项，可以分多次退还，全部退还后支付变为已退款
+func (m *Mock) Refund(ctx context.Context, ref string, amount model.Money) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, err := m.payment(ref)
+	if err != nil {
+		return err
+	}
+	if p.Status != model.PaymentStatusCaptured {
+		return fmt.Errorf("支付%s的状态为%s，不能退款", ref, p.Status)
+	}
+	if p.Refunded.Add(amount).Cmp(p.Captured) > 0 {
+		return fmt.Errorf("退款金额%s超过了可以退还的金额%s", amount, p.Captured.Sub(p.Refunded))
+	}
+	p.Refunded = p.Refunded.Add(amount)
+	if p.Refunded.Cmp(p.Captured) == 0 {
+		p.Status = model.PaymentStatusRefunded
+	}
+	return nil
+}
+
+// Lookup 获取模拟网关中的支付，用于显示付款页面
+func (m *Mock) Lookup(ref string) (MockPayment, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.payments[ref]
+	if !ok {
+		return MockPayment{}, false
+	}
+	return *p, true
+}
+
+// Complete 模拟用户在付款页面中付款或拒绝付款，返回网关发给书城的签名的回调请求，
+// 只有等待付款的支付可以完成
+func (m *Mock) Complete(ref string, approve bool) (*http.Request, error) {
+	m.mu.Lock()
+	p, err := m.payment(ref)
+	if err == nil && p.Status != model.PaymentStatusPending {
+		err = fmt.Errorf("支付%s已经完成", ref)
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	event := &Event{Type: EventFailed, Ref: ref, Amount: p.Amount}
+	p.Status = model.PaymentStatusFailed
+	if approve {
+		event.Type = EventAuthorized
+		p.Status = model.PaymentStatusAuthorized
+	}
+	m.mu.Unlock()
+	return m.NewWebhookRequest(event)
+}
+
+// NewWebhookRequest 生成带有签名的回调请求
+func (m *Mock) NewWebhookRequest(event *Event) (*http.Request, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest(http.MethodPost, "/payments/webhook/"+MockName, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(MockSignatureHeader, m.sign(body))
+	return r, nil
+}
+
+// VerifyWebhook 验证回调请求体的签名并解析其中的事件
+func (m *Mock) VerifyWebhook(r *http.Request) (*Event, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(r.Header.Get(MockSignatureHeader)), []byte(m.sign(body))) {
+		return nil, ErrInvalidSignature
+	}
+	event := &Event{}
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// sign 计算请求体的签名
+func (m *Mock) sign(body []byte) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// payment 获取支付，调用时需要持有锁
+func (m *Mock) payment(ref string) (*MockPayment, error) {
+	p, ok := m.payments[ref]
+	if !ok {
+		return nil, errors.New("支付不存在: " + ref)
+	}
+	return p, nil
+}
@@ -0,0 +1,89 @@
+package payment
+
+import (
+	"bookstore/model"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMockPayment(t *testing.T) {
+	m := NewMock([]byte("secret"))
+	ctx := t.Context()
+	auth, err := m.Authorize(ctx, &model.Payment{OrderID: "order-1", Amount: model.Cents(2500)})
+	if err != nil || auth.Ref == "" || !strings.HasPrefix(auth.RedirectURL, "/mockpay?ref=") {
+		t.Fatalf("请求授权失败: %+v %v", auth, err)
+	}
+	if err := m.Capture(ctx, auth.Ref, model.Cents(2500)); err == nil {
+		t.Errorf("用户付款之前不能扣款")
+	}
+
+	r, err := m.Complete(auth.Ref, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := m.VerifyWebhook(r)
+	if err != nil || event.Type != EventAuthorized || event.Ref != auth.Ref || event.Amount != model.Cents(2500) {
+		t.Fatalf("回调的事件不正确: %+v %v", event, err)
+	}
+	if _, err := m.Complete(auth.Ref, false); err == nil {
+		t.Errorf("已经付款的支付不能再次完成")
+	}
+
+	if err := m.Capture(ctx, auth.Ref, model.Cents(2600)); err == nil {
+		t.Errorf("扣款金额不能超过授权的金额")
+	}
+	if err := m.Capture(ctx, auth.Ref, model.Cents(2500)); err != nil {
+		t.Fatalf("扣款失败: %v", err)
+	}
+	if err := m.Refund(ctx, auth.Ref, model.Cents(1000)); err != nil {
+		t.Fatalf("退款失败: %v", err)
+	}
+	if err := m.Refund(ctx, auth.Ref, model.Cents(2000)); err == nil {
+		t.Errorf("退款金额不能超过扣除的金额")
+	}
+	if err := m.Refund(ctx, auth.Ref, model.Cents(1500)); err != nil {
+		t.Fatalf("退款失败: %v", err)
+	}
+	if p, _ := m.Lookup(auth.Ref); p.Status != model.PaymentStatusRefunded {
+		t.Errorf("全部退还后应为已退款，实际为%s", p.Status)
+	}
+}
+
+func TestMockWebhookSignature(t *testing.T) {
+	m := NewMock([]byte("secret"))
+	r, _ := m.NewWebhookRequest(&Event{Type: EventAuthorized, Ref: "mock_1", Amount: model.Cents(100)})
+	body, _ := io.ReadAll(r.Body)
+
+	//金额被篡改
+	tampered := bytes.Replace(body, []byte(`"minor":100`), []byte(`"minor":1`), 1)
+	r.Body = io.NopCloser(bytes.NewReader(tampered))
+	if _, err := m.VerifyWebhook(r); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("篡改请求体后应返回ErrInvalidSignature，实际为%v", err)
+	}
+	//使用其他密钥签名
+	other, _ := NewMock([]byte("other")).NewWebhookRequest(&Event{Type: EventAuthorized, Ref: "mock_1", Amount: model.Cents(100)})
+	if _, err := m.VerifyWebhook(other); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("其他密钥签名的回调应返回ErrInvalidSignature，实际为%v", err)
+	}
+	//没有签名
+	r.Header.Del(MockSignatureHeader)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if _, err := m.VerifyWebhook(r); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("没有签名的回调应返回ErrInvalidSignature，实际为%v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if p, err := New(MockName, "secret"); err != nil || p.Name() != MockName {
+		t.Errorf("应创建模拟网关: %v %v", p, err)
+	}
+	if _, err := New(MockName, ""); err == nil {
+		t.Errorf("没有配置密钥时应返回错误")
+	}
+	if _, err := New("alipay", "secret"); err == nil {
+		t.Errorf("不支持的网关应返回错误")
+	}
+}
@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"bookstore/model"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Provider 支付网关：先请求用户在网关中授权付款，授权的结果由网关通过回调通知，
+// 授权之后扣款，扣款之后可以退款
+type Provider interface {
+	// Name 网关的名称，保存在支付记录中，回调地址为 /payments/webhook/{name}
+	Name() string
+	// Authorize 在网关中创建支付并请求用户授权，返回网关中的支付号和用户付款的页面地址
+	Authorize(ctx context.Context, p *model.Payment) (*Authorization, error)
+	// Capture 扣除已经授权的款项
+	Capture(ctx context.Context, ref string, amount model.Money) error
+	// Refund 退还已经扣除的款项
+	Refund(ctx context.Context, ref string, amount model.Money) error
+	// VerifyWebhook 验证回调请求的签名并解析其中的事件，签名不正确时返回ErrInvalidSignature
+	VerifyWebhook(r *http.Request) (*Event, error)
+}
+
+// Authorization 请求授权的结果
+type Authorization struct {
+	Ref         string //网关中的支付号，之后的扣款、退款和回调都通过支付号对应
+	RedirectURL string //用户付款的页面地址
+}
+
+// 回调事件的类型
+const (
+	// EventAuthorized 用户已经授权付款
+	EventAuthorized = "payment.authorized"
+	// EventFailed 用户拒绝付款或付款失败
+	EventFailed = "payment.failed"
+)
+
+// Event 网关通过回调通知的事件
+type Event struct {
+	Type   string      `json:"type"`   //事件的类型，取值见Event开头的常量
+	Ref    string      `json:"ref"`    //网关中的支付号
+	Amount model.Money `json:"amount"` //授权的金额
+}
+
+// ErrInvalidSignature 回调请求的签名不正确，请求可能是伪造的
+var ErrInvalidSignature = errors.New("回调的签名不正确")
+
+// New 根据配置的网关名称创建支付网关，secret为验证回调签名的密钥，不能为空
+func New(provider string, secret string) (Provider, error) {
+	if secret == "" {
+		return nil, errors.New("没有配置验证回调签名的密钥")
+	}
+	switch provider {
+	case MockName:
+		return NewMock([]byte(secret)), nil
+	}
+	return nil, fmt.Errorf("不支持的支付网关%q", provider)
+}
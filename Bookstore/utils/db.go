@@ -2,18 +2,59 @@ package utils
 
 import (
 	"database/sql"
+	"fmt"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 )
 
+const (
+	// DriverMySQL MySQL数据库驱动
+	DriverMySQL = "mysql"
+	// DriverSQLite 嵌入式SQLite数据库驱动，无需单独的数据库服务
+	DriverSQLite = "sqlite"
+)
+
+// DefaultSQLiteDSN 默认的SQLite连接信息，数据只保存在内存中
+const DefaultSQLiteDSN = ":memory:"
+
 var (
-	Db  *sql.DB
-	err error
+	// Db 当前使用的数据库连接，由OpenDB设置
+	Db *sql.DB
+	// DbDriver 当前数据库连接所使用的驱动
+	DbDriver string
 )
 
-func init() {
-	Db, err = sql.Open("mysql", "root:123456@tcp(localhost:3306)/bookstore")
+// OpenDB 根据驱动和连接信息打开数据库，并将其设置为当前使用的数据库连接
+func OpenDB(driver string, dsn string) (*sql.DB, error) {
+	switch driver {
+	case DriverMySQL:
+		//MySQL的连接信息包含密码，由配置提供，不使用默认值
+		if dsn == "" {
+			return nil, fmt.Errorf("缺少MySQL的连接信息")
+		}
+	case DriverSQLite:
+		if dsn == "" {
+			dsn = DefaultSQLiteDSN
+		}
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %q", driver)
+	}
+	db, err := sql.Open(driver, dsn)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
+	}
+	if driver == DriverSQLite {
+		//SQLite同一时间只允许一个写入者，只保留一个连接，内存数据库也因此不会丢失
+		db.SetMaxOpenConns(1)
+		db.SetConnMaxLifetime(0)
+		//SQLite默认不检查外键约束
+		if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
+	Db = db
+	DbDriver = driver
+	return db, nil
 }
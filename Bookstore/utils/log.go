@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// logState 保存在请求的context中的日志记录器，处理请求的过程中可以补充当前用户等信息
+type logState struct {
+	mu     sync.Mutex
+	base   *slog.Logger
+	logger *slog.Logger
+	userID int
+}
+
+// logStateKey 日志记录器在context中的键
+type logStateKey struct{}
+
+// WithLogger 返回带有日志记录器的context，之后通过Logger获取
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, logStateKey{}, &logState{base: logger, logger: logger})
+}
+
+// Logger 获取context中的日志记录器，没有时返回slog的默认记录器
+func Logger(ctx context.Context) *slog.Logger {
+	if st, ok := ctx.Value(logStateKey{}).(*logState); ok {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		return st.logger
+	}
+	return slog.Default()
+}
+
+// SetLogUser 记录发出请求的用户，之后通过Logger获取的记录器都会带上user_id
+func SetLogUser(ctx context.Context, userID int) {
+	st, ok := ctx.Value(logStateKey{}).(*logState)
+	if !ok {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.userID != userID {
+		st.userID = userID
+		st.logger = st.base.With(slog.Int("user_id", userID))
+	}
+}
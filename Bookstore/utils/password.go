@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordCost 生成密码哈希时使用的bcrypt计算成本
+var PasswordCost = bcrypt.DefaultCost
+
+// HashPassword 使用bcrypt生成密码的哈希值
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsPasswordHash 判断数据库中保存的密码是否已经是bcrypt哈希值，早期的用户保存的是明文
+func IsPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword 验证用户输入的密码与数据库中保存的密码是否一致，
+// needRehash为true表示保存的是明文或计算成本过低，应重新生成哈希值
+func CheckPassword(stored string, password string) (ok bool, needRehash bool) {
+	if !IsPasswordHash(stored) {
+		//兼容早期以明文保存的密码
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err == nil && cost < PasswordCost
+}
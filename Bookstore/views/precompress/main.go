@@ -0,0 +1,56 @@
+// Command precompress 为静态资源生成brotli压缩的.br文件，程序启动时直接读取，不需要在运行时压缩。
+// 修改了CSS或JS之后在views目录下运行go generate重新生成
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressible 需要压缩的文件的扩展名，图片本身已经压缩过
+var compressible = map[string]bool{".css": true, ".js": true, ".json": true, ".svg": true, ".txt": true}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "用法: precompress <静态资源目录>")
+		os.Exit(2)
+	}
+	if err := precompress(os.Args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, "生成.br文件失败：", err)
+		os.Exit(1)
+	}
+}
+
+// precompress 压缩dir下所有需要压缩的文件，压缩后没有变小时删除已有的.br文件
+func precompress(dir string) error {
+	return filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !compressible[filepath.Ext(name)] {
+			return err
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		zw := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		if buf.Len() >= len(data) {
+			if err := os.Remove(name + ".br"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+		return os.WriteFile(name+".br", buf.Bytes(), 0o644)
+	})
+}
@@ -0,0 +1,26 @@
+//提交修改数据的表单和Ajax请求时带上CSRF令牌，令牌由服务器保存在名为csrf_token的Cookie中
+(function($){
+	//从Cookie中读取当前的令牌，登录之后令牌会改变，所以每次提交时都重新读取
+	function csrfToken(){
+		var match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]*)/);
+		return match ? decodeURIComponent(match[1]) : "";
+	}
+	//POST表单提交时添加或更新隐藏的令牌字段
+	$(document).on("submit", "form", function(){
+		var $form = $(this);
+		if(($form.attr("method") || "GET").toUpperCase() != "POST"){
+			return;
+		}
+		var $field = $form.find("input[name=csrf_token]");
+		if($field.length == 0){
+			$field = $('<input type="hidden" name="csrf_token"/>').appendTo($form);
+		}
+		$field.val(csrfToken());
+	});
+	//除GET之外的Ajax请求在请求头中带上令牌
+	$(document).ajaxSend(function(event, xhr, settings){
+		if(settings.type && settings.type.toUpperCase() != "GET"){
+			xhr.setRequestHeader("X-CSRF-Token", csrfToken());
+		}
+	});
+})(jQuery);
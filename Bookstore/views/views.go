@@ -0,0 +1,12 @@
+// Package views 编译进程序中的模板和静态资源，程序不依赖启动时所在的目录
+package views
+
+import "embed"
+
+//go:generate go run ./precompress static
+
+// FS 模板根目录下的所有文件，包括index.html、layouts、partials、pages和static目录，
+// static目录中的.br文件是go generate预先压缩好的CSS和JS
+//
+//go:embed index.html layouts partials pages static
+var FS embed.FS